@@ -7,7 +7,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"go/types"
+	"maps"
 	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 
 	"golang.org/x/tools/go/packages"
@@ -41,6 +44,33 @@ type InitializeParams struct {
 type ValidateExprParams struct {
 	RootType   string `json:"rootType"`   // e.g., "github.com/myapp/models.User"
 	Expression string `json:"expression"` // e.g., "profile.Address.City"
+	// Iterable marks an each collection, which must be a slice, array or map.
+	Iterable bool `json:"iterable,omitempty"`
+	// Spread marks an attribute spread (...`attrs`), which must be a map
+	// with string keys, e.g. map[string]string.
+	Spread bool `json:"spread,omitempty"`
+	// Bindings maps the loop variables in scope to the collection
+	// expressions they range over, e.g. {"item": "orders"}, so item.total
+	// resolves against the element type of orders.
+	Bindings map[string]string `json:"bindings,omitempty"`
+}
+
+type ValidateTemplateParams struct {
+	RootType    string              `json:"rootType"`
+	Expressions []TemplateExprEntry `json:"expressions"`
+}
+
+type TemplateExprEntry struct {
+	Expression string            `json:"expression"`
+	Line       int               `json:"line"`
+	Column     int               `json:"column"`
+	Iterable   bool              `json:"iterable,omitempty"`
+	Spread     bool              `json:"spread,omitempty"`
+	Bindings   map[string]string `json:"bindings,omitempty"`
+}
+
+type ValidateParamTypesParams struct {
+	Types []string `json:"types"` // e.g., ["github.com/myapp/models.User", "[]string"]
 }
 
 type FindImplsParams struct {
@@ -53,6 +83,12 @@ type GetTypeInfoParams struct {
 	TypeName    string `json:"typeName"`
 }
 
+type ResolvePackagePathParams struct {
+	// Dir is a filesystem directory, absolute or relative to the workspace
+	// root.
+	Dir string `json:"dir"`
+}
+
 // Response results
 type InitializeResult struct {
 	Initialized bool `json:"initialized"`
@@ -62,12 +98,42 @@ type ValidateExprResult struct {
 	Valid      bool   `json:"valid"`
 	ResultType string `json:"resultType,omitempty"`
 	Error      string `json:"error,omitempty"`
+	// NilPointers lists the prefixes of the path that are pointers read
+	// without a nil-safe getter, so the access panics if one is nil. Proto
+	// messages have getters and never appear here.
+	NilPointers []string `json:"nilPointers,omitempty"`
+	Warning     string   `json:"warning,omitempty"`
+}
+
+type TemplateExprResult struct {
+	Expression string `json:"expression"`
+	Line       int    `json:"line"`
+	Column     int    `json:"column"`
+	ValidateExprResult
+}
+
+type ValidateTemplateResult struct {
+	Results []TemplateExprResult `json:"results"`
+}
+
+type ParamTypeResult struct {
+	Type  string `json:"type"`
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"`
+}
+
+type ValidateParamTypesResult struct {
+	Results []ParamTypeResult `json:"results"`
 }
 
 type FindImplsResult struct {
 	Implementations []string `json:"implementations"`
 }
 
+type ResolvePackagePathResult struct {
+	PackagePath string `json:"packagePath"`
+}
+
 type TypeInfoResult struct {
 	Kind    string      `json:"kind"` // "struct", "interface", "alias", "primitive"
 	Fields  []FieldInfo `json:"fields,omitempty"`
@@ -78,17 +144,23 @@ type FieldInfo struct {
 	Name     string `json:"name"`
 	Type     string `json:"type"`
 	Exported bool   `json:"exported"`
+	Tag      string `json:"tag,omitempty"`
 }
 
 type MethodInfo struct {
 	Name      string `json:"name"`
 	Signature string `json:"signature"`
+	// Receiver is "value" or "pointer". Pointer-receiver methods are only
+	// callable on an addressable value or through a pointer.
+	Receiver string `json:"receiver"`
 }
 
 // Analyzer holds the workspace state
 type Analyzer struct {
 	workspaceRoot string
 	pkgCache      map[string]*packages.Package
+	typeCache     map[string]types.Type // qualified type string -> resolved type
+	typeLookups   int                   // scope lookups done by ResolveType, for tests
 	cfg           *packages.Config
 }
 
@@ -101,6 +173,7 @@ func NewAnalyzer(root string) (*Analyzer, error) {
 	return &Analyzer{
 		workspaceRoot: root,
 		pkgCache:      make(map[string]*packages.Package),
+		typeCache:     make(map[string]types.Type),
 		cfg:           cfg,
 	}, nil
 }
@@ -129,8 +202,56 @@ func (a *Analyzer) LoadPackage(path string) (*packages.Package, error) {
 	return pkgs[0], nil
 }
 
+// ResolvePackagePath returns the Go import path of the package in dir, so
+// an editor can form the rootType for a .hudl file from the models package
+// next to it.
+func (a *Analyzer) ResolvePackagePath(dir string) (string, error) {
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(a.workspaceRoot, dir)
+	}
+	cfg := &packages.Config{Mode: packages.NeedName, Dir: dir}
+	pkgs, err := packages.Load(cfg, ".")
+	if err != nil {
+		if strings.Contains(err.Error(), "go.mod file not found") {
+			return "", fmt.Errorf("%s is not inside a Go module", dir)
+		}
+		return "", fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+	if len(pkgs) == 0 {
+		return "", fmt.Errorf("no package found in %s", dir)
+	}
+	pkg := pkgs[0]
+	// In GOPATH mode, go list names a directory outside any module _/abs/path
+	if strings.HasPrefix(pkg.PkgPath, "_/") {
+		return "", fmt.Errorf("%s is not inside a Go module", dir)
+	}
+	if len(pkg.Errors) > 0 {
+		var errs []string
+		for _, e := range pkg.Errors {
+			errs = append(errs, e.Error())
+		}
+		return "", fmt.Errorf("failed to resolve %s: %s", dir, strings.Join(errs, "; "))
+	}
+	return pkg.PkgPath, nil
+}
+
+// InvalidatePackage drops a package and the types resolved from it, so the
+// next request reloads it from disk.
+func (a *Analyzer) InvalidatePackage(path string) {
+	delete(a.pkgCache, path)
+	for qualified := range a.typeCache {
+		if qualified[:strings.LastIndex(qualified, ".")] == path {
+			delete(a.typeCache, qualified)
+		}
+	}
+}
+
 // ResolveType resolves a fully qualified type string like "github.com/pkg.Type"
 func (a *Analyzer) ResolveType(qualifiedType string) (types.Type, error) {
+	if cached, ok := a.typeCache[qualifiedType]; ok {
+		return cached, nil
+	}
+
 	// Split "github.com/pkg/path.TypeName" into package path and type name
 	lastDot := strings.LastIndex(qualifiedType, ".")
 	if lastDot == -1 {
@@ -145,26 +266,193 @@ func (a *Analyzer) ResolveType(qualifiedType string) (types.Type, error) {
 		return nil, err
 	}
 
+	a.typeLookups++
 	obj := pkg.Types.Scope().Lookup(typeName)
 	if obj == nil {
 		return nil, fmt.Errorf("type %s not found in package %s", typeName, pkgPath)
 	}
+	if _, ok := obj.(*types.TypeName); !ok {
+		return nil, fmt.Errorf("%s in package %s is not a type", typeName, pkgPath)
+	}
 
+	a.typeCache[qualifiedType] = obj.Type()
 	return obj.Type(), nil
 }
 
-// ValidateFieldPath validates a field path on a root type
+// ValidateExpression checks a field path expression against a root type.
+func (a *Analyzer) ValidateExpression(params ValidateExprParams) ValidateExprResult {
+	rootType, err := a.ResolveType(params.RootType)
+	if err != nil {
+		return ValidateExprResult{Valid: false, Error: err.Error()}
+	}
+	resultType, nilPointers, err := walkScopedPath(rootType, params.Expression, params.Bindings)
+	if err != nil {
+		return ValidateExprResult{Valid: false, Error: err.Error()}
+	}
+	if _, ok := elemType(resultType); params.Iterable && !ok {
+		return ValidateExprResult{
+			Valid:      false,
+			ResultType: resultType.String(),
+			Error:      fmt.Sprintf("%s is not iterable: %s (expected a slice, array or map)", params.Expression, resultType),
+		}
+	}
+	if params.Spread && !isAttrMap(resultType) {
+		return ValidateExprResult{
+			Valid:      false,
+			ResultType: resultType.String(),
+			Error:      fmt.Sprintf("%s cannot be spread as attributes: %s (expected map[string]string)", params.Expression, resultType),
+		}
+	}
+	res := ValidateExprResult{Valid: true, ResultType: resultType.String(), NilPointers: nilPointers}
+	if len(nilPointers) > 0 {
+		res.Warning = fmt.Sprintf("%s may be nil; check it first or add Get methods that handle a nil receiver",
+			strings.Join(nilPointers, ", "))
+	}
+	return res
+}
+
+// ValidateTemplate checks a template's expressions against its root type in
+// one call, echoing each entry's position back with its result.
+func (a *Analyzer) ValidateTemplate(params ValidateTemplateParams) ValidateTemplateResult {
+	results := make([]TemplateExprResult, 0, len(params.Expressions))
+	for _, e := range params.Expressions {
+		results = append(results, TemplateExprResult{
+			Expression: e.Expression,
+			Line:       e.Line,
+			Column:     e.Column,
+			ValidateExprResult: a.ValidateExpression(ValidateExprParams{
+				RootType:   params.RootType,
+				Expression: e.Expression,
+				Iterable:   e.Iterable,
+				Spread:     e.Spread,
+				Bindings:   e.Bindings,
+			}),
+		})
+	}
+	return ValidateTemplateResult{Results: results}
+}
+
+// ValidateParamTypes checks that each declared param type resolves. Pointer,
+// slice and map types are checked element by element; predeclared types
+// like string and int are always valid.
+func (a *Analyzer) ValidateParamTypes(params ValidateParamTypesParams) ValidateParamTypesResult {
+	results := make([]ParamTypeResult, 0, len(params.Types))
+	for _, typ := range params.Types {
+		res := ParamTypeResult{Type: typ, Valid: true}
+		if err := a.validateParamType(typ); err != nil {
+			res.Valid = false
+			res.Error = err.Error()
+		}
+		results = append(results, res)
+	}
+	return ValidateParamTypesResult{Results: results}
+}
+
+func (a *Analyzer) validateParamType(typ string) error {
+	typ = strings.TrimSpace(typ)
+	switch {
+	case typ == "":
+		return fmt.Errorf("empty type")
+	case strings.HasPrefix(typ, "*"):
+		return a.validateParamType(typ[1:])
+	case strings.HasPrefix(typ, "[]"):
+		return a.validateParamType(typ[2:])
+	case strings.HasPrefix(typ, "map["):
+		end := strings.Index(typ, "]")
+		if end == -1 {
+			return fmt.Errorf("invalid map type: %s", typ)
+		}
+		if err := a.validateParamType(typ[4:end]); err != nil {
+			return err
+		}
+		return a.validateParamType(typ[end+1:])
+	}
+
+	if !strings.Contains(typ, ".") {
+		if obj := types.Universe.Lookup(typ); obj != nil {
+			if _, ok := obj.(*types.TypeName); ok {
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown type %s (expected a builtin or pkg.Type)", typ)
+	}
+
+	_, err := a.ResolveType(typ)
+	return err
+}
+
+// ValidateFieldPath validates a field path on a root type. Each part may be
+// the Go field name or, for proto-generated structs, the proto or JSON name
+// from the field's struct tag (e.g. revenue_formatted or revenueFormatted).
+// A part may also call a method that takes no arguments and returns one
+// value, as in data.ActiveFeatures().
 func (a *Analyzer) ValidateFieldPath(rootType types.Type, path string) (types.Type, error) {
+	typ, _, err := walkFieldPath(rootType, path)
+	return typ, err
+}
+
+// walkScopedPath resolves path like walkFieldPath, except that a path
+// starting with a loop variable in bindings resolves against the element
+// type of the collection it ranges over. Collections may themselves start
+// with an outer loop variable.
+func walkScopedPath(rootType types.Type, path string, bindings map[string]string) (types.Type, []string, error) {
+	head, rest, _ := strings.Cut(path, ".")
+	collection, ok := bindings[head]
+	if !ok {
+		return walkFieldPath(rootType, path)
+	}
+
+	// The loop variable isn't in scope for its own collection
+	outer := maps.Clone(bindings)
+	delete(outer, head)
+	collType, _, err := walkScopedPath(rootType, collection, outer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("loop variable %s: %w", head, err)
+	}
+	elem, ok := elemType(collType)
+	if !ok {
+		return nil, nil, fmt.Errorf("loop variable %s ranges over %s, which is not a slice, array or map", head, collType)
+	}
+
+	typ, nilPointers, err := walkFieldPath(elem, rest)
+	for i, p := range nilPointers {
+		nilPointers[i] = head + "." + p
+	}
+	return typ, nilPointers, err
+}
+
+// walkFieldPath resolves path like ValidateFieldPath and also returns the
+// prefixes of path that dereference a pointer field with no nil-safe getter
+// for the next part. The root itself is not reported.
+func walkFieldPath(rootType types.Type, path string) (types.Type, []string, error) {
 	if path == "" {
-		return rootType, nil
+		return rootType, nil, nil
 	}
 
 	parts := strings.Split(path, ".")
 	current := rootType
+	var nilPointers []string
+
+	for i, part := range parts {
+		if name, isCall := strings.CutSuffix(part, "()"); isCall {
+			result, valueRecv, err := methodResult(current, name)
+			if err != nil {
+				return nil, nil, err
+			}
+			// A value receiver dereferences, so a nil pointer panics
+			if _, ok := current.(*types.Pointer); ok && i > 0 && valueRecv {
+				nilPointers = append(nilPointers, strings.Join(parts[:i], "."))
+			}
+			current = result
+			continue
+		}
 
-	for _, part := range parts {
 		// Dereference pointers automatically
+		owner := current
 		if ptr, ok := current.(*types.Pointer); ok {
+			if i > 0 && !hasGetter(ptr, part) {
+				nilPointers = append(nilPointers, strings.Join(parts[:i], "."))
+			}
 			current = ptr.Elem()
 		}
 
@@ -178,21 +466,139 @@ func (a *Analyzer) ValidateFieldPath(rootType types.Type, path string) (types.Ty
 			found := false
 			for i := 0; i < t.NumFields(); i++ {
 				field := t.Field(i)
-				if field.Name() == part {
+				if fieldMatches(field.Name(), t.Tag(i), part) {
 					current = field.Type()
 					found = true
 					break
 				}
 			}
 			if !found {
-				return nil, fmt.Errorf("field %q not found on type %s", part, rootType)
+				return nil, nil, fmt.Errorf("field %q not found on type %s%s", part, owner, methodHint(owner, part))
 			}
 		default:
-			return nil, fmt.Errorf("cannot access field %q on non-struct type %T", part, current)
+			return nil, nil, fmt.Errorf("cannot access field %q on non-struct type %T", part, current)
+		}
+	}
+
+	return current, nilPointers, nil
+}
+
+// methodResult returns the result type of the method name on typ, which
+// must take no arguments and return one value, and whether the method has a
+// value receiver.
+func methodResult(typ types.Type, name string) (types.Type, bool, error) {
+	obj, _, _ := types.LookupFieldOrMethod(typ, true, nil, name)
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return nil, false, fmt.Errorf("method %q not found on type %s", name, typ)
+	}
+	sig := fn.Type().(*types.Signature)
+	if sig.Params().Len() != 0 || sig.Results().Len() != 1 {
+		return nil, false, fmt.Errorf("method %s must take no arguments and return one value, has signature %s", name, sig)
+	}
+	_, ptrRecv := sig.Recv().Type().(*types.Pointer)
+	return sig.Results().At(0).Type(), !ptrRecv, nil
+}
+
+// methodHint suggests a method call for a field that doesn't exist, for
+// types read through methods: time.Time, whose fields are unexported, has
+// Year() rather than year, and a google.protobuf.Timestamp (timestamppb)
+// gets calendar fields through AsTime().
+func methodHint(typ types.Type, part string) string {
+	if name, ok := callableMethod(typ, part); ok {
+		return fmt.Sprintf("; call the method %s() instead", name)
+	}
+	if asTime, _, err := methodResult(typ, "AsTime"); err == nil {
+		if name, ok := callableMethod(asTime, part); ok {
+			return fmt.Sprintf("; convert it with AsTime().%s()", name)
 		}
 	}
+	return ""
+}
 
-	return current, nil
+// callableMethod finds a method on typ named part, ignoring case, that a
+// template can call.
+func callableMethod(typ types.Type, part string) (string, bool) {
+	// Methods with either receiver are callable, as through a pointer
+	if _, ok := typ.(*types.Pointer); !ok {
+		typ = types.NewPointer(typ)
+	}
+	mset := types.NewMethodSet(typ)
+	for i := 0; i < mset.Len(); i++ {
+		fn := mset.At(i).Obj()
+		if !fn.Exported() || !strings.EqualFold(fn.Name(), part) {
+			continue
+		}
+		if _, _, err := methodResult(typ, fn.Name()); err == nil {
+			return fn.Name(), true
+		}
+	}
+	return "", false
+}
+
+// elemType returns the type of the values each binds when ranging over a
+// value of typ, reporting false if typ can't be iterated. For a map that is
+// the value type.
+func elemType(typ types.Type) (types.Type, bool) {
+	switch t := typ.Underlying().(type) {
+	case *types.Slice:
+		return t.Elem(), true
+	case *types.Array:
+		return t.Elem(), true
+	case *types.Map:
+		return t.Elem(), true
+	}
+	return nil, false
+}
+
+// isAttrMap reports whether typ can be spread as attributes: a map with
+// string keys and string values, such as map[string]string.
+func isAttrMap(typ types.Type) bool {
+	m, ok := typ.Underlying().(*types.Map)
+	if !ok {
+		return false
+	}
+	isString := func(t types.Type) bool {
+		b, ok := t.Underlying().(*types.Basic)
+		return ok && b.Info()&types.IsString != 0
+	}
+	return isString(m.Key()) && isString(m.Elem())
+}
+
+// hasGetter reports whether ptr has a Get method for the struct field
+// matching part, as protoc-gen-go generates. Those return the zero value
+// on a nil receiver, so the path is safe through them.
+func hasGetter(ptr *types.Pointer, part string) bool {
+	st, ok := ptr.Elem().Underlying().(*types.Struct)
+	if !ok {
+		return false
+	}
+	for i := 0; i < st.NumFields(); i++ {
+		if field := st.Field(i); fieldMatches(field.Name(), st.Tag(i), part) {
+			sel := types.NewMethodSet(ptr).Lookup(field.Pkg(), "Get"+field.Name())
+			return sel != nil
+		}
+	}
+	return false
+}
+
+// fieldMatches reports whether name refers to a struct field, by its Go name
+// or by a name from its protobuf or json struct tag.
+func fieldMatches(goName, tag, name string) bool {
+	if goName == name {
+		return true
+	}
+	st := reflect.StructTag(tag)
+	for _, opt := range strings.Split(st.Get("protobuf"), ",") {
+		if v, ok := strings.CutPrefix(opt, "name="); ok && v == name {
+			return true
+		}
+		if v, ok := strings.CutPrefix(opt, "json="); ok && v == name {
+			return true
+		}
+	}
+	jsonName, _, _ := strings.Cut(st.Get("json"), ",")
+	return jsonName != "" && jsonName != "-" && jsonName == name
 }
 
 // FindInterfaceImplementations finds all types implementing an interface
@@ -245,17 +651,33 @@ func (a *Analyzer) GetTypeInfo(pkgPath, typeName string) (*TypeInfoResult, error
 	if obj == nil {
 		return nil, fmt.Errorf("type %s not found in package %s", typeName, pkgPath)
 	}
+	if _, ok := obj.(*types.TypeName); !ok {
+		return nil, fmt.Errorf("%s in package %s is not a type", typeName, pkgPath)
+	}
 
 	result := &TypeInfoResult{}
 	t := obj.Type()
 
-	// Get methods
-	if named, ok := t.(*types.Named); ok {
-		for i := 0; i < named.NumMethods(); i++ {
-			m := named.Method(i)
+	// Get methods: the method set of *T, which includes T's own, so
+	// pointer-receiver methods are reported too
+	if _, ok := t.(*types.Named); ok {
+		recv := t
+		if !types.IsInterface(t) {
+			recv = types.NewPointer(t)
+		}
+		mset := types.NewMethodSet(recv)
+		for i := 0; i < mset.Len(); i++ {
+			m := mset.At(i).Obj()
+			receiver := "value"
+			if sig := m.Type().(*types.Signature); sig.Recv() != nil {
+				if _, ok := sig.Recv().Type().(*types.Pointer); ok {
+					receiver = "pointer"
+				}
+			}
 			result.Methods = append(result.Methods, MethodInfo{
 				Name:      m.Name(),
 				Signature: m.Type().String(),
+				Receiver:  receiver,
 			})
 		}
 	}
@@ -270,6 +692,7 @@ func (a *Analyzer) GetTypeInfo(pkgPath, typeName string) (*TypeInfoResult, error
 				Name:     f.Name(),
 				Type:     f.Type().String(),
 				Exported: f.Exported(),
+				Tag:      u.Tag(i),
 			})
 		}
 	case *types.Interface:
@@ -330,17 +753,31 @@ func main() {
 				rpcErr = &RPCError{Code: -32602, Message: fmt.Sprintf("Invalid params: %v", err)}
 				break
 			}
-			rootType, err := analyzer.ResolveType(params.RootType)
-			if err != nil {
-				result = ValidateExprResult{Valid: false, Error: err.Error()}
+			result = analyzer.ValidateExpression(params)
+
+		case "validateTemplate":
+			if analyzer == nil {
+				rpcErr = &RPCError{Code: -32002, Message: "Analyzer not initialized"}
 				break
 			}
-			resultType, err := analyzer.ValidateFieldPath(rootType, params.Expression)
-			if err != nil {
-				result = ValidateExprResult{Valid: false, Error: err.Error()}
-			} else {
-				result = ValidateExprResult{Valid: true, ResultType: resultType.String()}
+			var params ValidateTemplateParams
+			if err := json.Unmarshal(req.Params, &params); err != nil {
+				rpcErr = &RPCError{Code: -32602, Message: fmt.Sprintf("Invalid params: %v", err)}
+				break
+			}
+			result = analyzer.ValidateTemplate(params)
+
+		case "validateParamTypes":
+			if analyzer == nil {
+				rpcErr = &RPCError{Code: -32002, Message: "Analyzer not initialized"}
+				break
 			}
+			var params ValidateParamTypesParams
+			if err := json.Unmarshal(req.Params, &params); err != nil {
+				rpcErr = &RPCError{Code: -32602, Message: fmt.Sprintf("Invalid params: %v", err)}
+				break
+			}
+			result = analyzer.ValidateParamTypes(params)
 
 		case "findImplementations":
 			if analyzer == nil {
@@ -395,6 +832,38 @@ func main() {
 				result = map[string]bool{"loaded": true}
 			}
 
+		case "resolvePackagePath":
+			if analyzer == nil {
+				rpcErr = &RPCError{Code: -32002, Message: "Analyzer not initialized"}
+				break
+			}
+			var params ResolvePackagePathParams
+			if err := json.Unmarshal(req.Params, &params); err != nil {
+				rpcErr = &RPCError{Code: -32602, Message: fmt.Sprintf("Invalid params: %v", err)}
+				break
+			}
+			path, err := analyzer.ResolvePackagePath(params.Dir)
+			if err != nil {
+				rpcErr = &RPCError{Code: -32000, Message: err.Error()}
+			} else {
+				result = ResolvePackagePathResult{PackagePath: path}
+			}
+
+		case "invalidatePackage":
+			if analyzer == nil {
+				rpcErr = &RPCError{Code: -32002, Message: "Analyzer not initialized"}
+				break
+			}
+			var params struct {
+				PackagePath string `json:"packagePath"`
+			}
+			if err := json.Unmarshal(req.Params, &params); err != nil {
+				rpcErr = &RPCError{Code: -32602, Message: fmt.Sprintf("Invalid params: %v", err)}
+				break
+			}
+			analyzer.InvalidatePackage(params.PackagePath)
+			result = map[string]bool{"invalidated": true}
+
 		case "shutdown":
 			os.Exit(0)
 
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAnalyzer(t *testing.T) *Analyzer {
+	t.Helper()
+	root, err := filepath.Abs("../..")
+	require.NoError(t, err)
+	if _, err := os.Stat(filepath.Join(root, "go.mod")); err != nil {
+		t.Skip("module root not found")
+	}
+	a, err := NewAnalyzer(root)
+	require.NoError(t, err)
+	return a
+}
+
+func TestValidateFieldPath_ProtoNames(t *testing.T) {
+	a := newTestAnalyzer(t)
+
+	typ, err := a.ResolveType("github.com/njreid/hudl/pkg/hudl/pb.DashboardData")
+	require.NoError(t, err)
+
+	for _, path := range []string{"RevenueFormatted", "revenue_formatted", "revenueFormatted"} {
+		ft, err := a.ValidateFieldPath(typ, path)
+		require.NoError(t, err, path)
+		assert.Equal(t, "string", ft.String(), path)
+	}
+
+	_, err = a.ValidateFieldPath(typ, "revenue")
+	assert.Error(t, err)
+}
+
+func TestFieldMatches(t *testing.T) {
+	tag := `protobuf:"bytes,2,opt,name=user_name,json=userName,proto3" json:"user_name,omitempty"`
+
+	assert.True(t, fieldMatches("UserName", tag, "UserName"))
+	assert.True(t, fieldMatches("UserName", tag, "user_name"))
+	assert.True(t, fieldMatches("UserName", tag, "userName"))
+	assert.False(t, fieldMatches("UserName", tag, "opt"))
+	assert.False(t, fieldMatches("UserName", tag, "username"))
+	assert.False(t, fieldMatches("Secret", `json:"-"`, "-"))
+}
+
+func TestValidateExpression_CachesRootType(t *testing.T) {
+	a := newTestAnalyzer(t)
+	const root = "github.com/njreid/hudl/pkg/hudl/pb.DashboardData"
+
+	for i := 0; i < 50; i++ {
+		res := a.ValidateExpression(ValidateExprParams{RootType: root, Expression: "revenue_formatted"})
+		require.True(t, res.Valid, res.Error)
+	}
+	assert.Equal(t, 1, a.typeLookups)
+
+	a.InvalidatePackage("github.com/njreid/hudl/pkg/hudl/pb")
+	res := a.ValidateExpression(ValidateExprParams{RootType: root, Expression: "revenue_formatted"})
+	require.True(t, res.Valid, res.Error)
+	assert.Equal(t, 2, a.typeLookups)
+}
+
+func TestValidateParamTypes(t *testing.T) {
+	a := newTestAnalyzer(t)
+
+	res := a.ValidateParamTypes(ValidateParamTypesParams{Types: []string{
+		"github.com/njreid/hudl/pkg/hudl/pb.DashboardData",
+		"github.com/njreid/hudl/pkg/hudl/pb.NoSuchType",
+	}})
+	require.Len(t, res.Results, 2)
+
+	assert.True(t, res.Results[0].Valid, res.Results[0].Error)
+	assert.Empty(t, res.Results[0].Error)
+
+	assert.False(t, res.Results[1].Valid)
+	assert.Equal(t, "type NoSuchType not found in package github.com/njreid/hudl/pkg/hudl/pb", res.Results[1].Error)
+}
+
+func TestValidateTemplate(t *testing.T) {
+	a := newTestAnalyzer(t)
+
+	res := a.ValidateTemplate(ValidateTemplateParams{
+		RootType: "github.com/njreid/hudl/pkg/hudl/pb.DashboardData",
+		Expressions: []TemplateExprEntry{
+			{Expression: "revenue_formatted", Line: 3, Column: 9},
+			{Expression: "revenue", Line: 7, Column: 14},
+			{Expression: "RevenueFormatted", Line: 12, Column: 5},
+		},
+	})
+	require.Len(t, res.Results, 3)
+
+	assert.True(t, res.Results[0].Valid, res.Results[0].Error)
+	assert.Equal(t, "string", res.Results[0].ResultType)
+	assert.Equal(t, 3, res.Results[0].Line)
+	assert.Equal(t, 9, res.Results[0].Column)
+
+	assert.False(t, res.Results[1].Valid)
+	assert.NotEmpty(t, res.Results[1].Error)
+	assert.Equal(t, "revenue", res.Results[1].Expression)
+	assert.Equal(t, 7, res.Results[1].Line)
+	assert.Equal(t, 14, res.Results[1].Column)
+
+	assert.True(t, res.Results[2].Valid)
+	assert.Equal(t, 12, res.Results[2].Line)
+
+	// The root type is resolved once for the whole batch
+	assert.Equal(t, 1, a.typeLookups)
+}
+
+func TestValidateExpression_NilPointers(t *testing.T) {
+	a := newTestAnalyzer(t)
+	const root = "github.com/njreid/hudl/cmd/hudl-analyzer/testdata/shop.Shop"
+
+	res := a.ValidateExpression(ValidateExprParams{RootType: root, Expression: "Owner.Name"})
+	require.True(t, res.Valid, res.Error)
+	assert.Equal(t, []string{"Owner"}, res.NilPointers)
+	assert.Contains(t, res.Warning, "Owner may be nil")
+
+	// A getter handles the nil receiver, and fields of the root are plain reads
+	for _, expr := range []string{"Manager.Name", "Name", "Owner"} {
+		res := a.ValidateExpression(ValidateExprParams{RootType: root, Expression: expr})
+		require.True(t, res.Valid, res.Error)
+		assert.Empty(t, res.NilPointers, expr)
+		assert.Empty(t, res.Warning, expr)
+	}
+
+	// Proto messages are always read through getters
+	res = a.ValidateExpression(ValidateExprParams{
+		RootType:   "github.com/njreid/hudl/pkg/hudl/pb.DashboardData",
+		Expression: "revenue_formatted",
+	})
+	require.True(t, res.Valid, res.Error)
+	assert.Empty(t, res.NilPointers)
+}
+
+func TestValidateExpression_IterableMethodCall(t *testing.T) {
+	a := newTestAnalyzer(t)
+	const root = "github.com/njreid/hudl/cmd/hudl-analyzer/testdata/shop.Shop"
+
+	res := a.ValidateExpression(ValidateExprParams{RootType: root, Expression: "ActiveProducts()", Iterable: true})
+	require.True(t, res.Valid, res.Error)
+	assert.Equal(t, "[]github.com/njreid/hudl/cmd/hudl-analyzer/testdata/shop.Product", res.ResultType)
+
+	res = a.ValidateExpression(ValidateExprParams{RootType: root, Expression: "Products", Iterable: true})
+	require.True(t, res.Valid, res.Error)
+
+	res = a.ValidateExpression(ValidateExprParams{RootType: root, Expression: "Name", Iterable: true})
+	assert.False(t, res.Valid)
+	assert.Equal(t, "Name is not iterable: string (expected a slice, array or map)", res.Error)
+
+	res = a.ValidateExpression(ValidateExprParams{RootType: root, Expression: "ProductNamed()"})
+	assert.False(t, res.Valid)
+	assert.Contains(t, res.Error, "must take no arguments")
+
+	res = a.ValidateExpression(ValidateExprParams{RootType: root, Expression: "Missing()"})
+	assert.False(t, res.Valid)
+	assert.Contains(t, res.Error, `method "Missing" not found`)
+}
+
+func TestValidateExpression_Spread(t *testing.T) {
+	a := newTestAnalyzer(t)
+	const root = "github.com/njreid/hudl/cmd/hudl-analyzer/testdata/shop.Shop"
+
+	res := a.ValidateExpression(ValidateExprParams{
+		RootType:   root,
+		Expression: "product.Attrs",
+		Spread:     true,
+		Bindings:   map[string]string{"product": "Products"},
+	})
+	require.True(t, res.Valid, res.Error)
+	assert.Equal(t, "map[string]string", res.ResultType)
+
+	for expr, typ := range map[string]string{"product.Stock": "map[string]int", "product.Tags": "[]string"} {
+		res = a.ValidateExpression(ValidateExprParams{
+			RootType:   root,
+			Expression: expr,
+			Spread:     true,
+			Bindings:   map[string]string{"product": "Products"},
+		})
+		assert.False(t, res.Valid)
+		assert.Equal(t, expr+" cannot be spread as attributes: "+typ+" (expected map[string]string)", res.Error)
+	}
+}
+
+func TestValidateExpression_LoopBindings(t *testing.T) {
+	a := newTestAnalyzer(t)
+	const root = "github.com/njreid/hudl/cmd/hudl-analyzer/testdata/shop.Shop"
+
+	// li data-id=`product.ID` inside each product `ActiveProducts()`
+	bindings := map[string]string{"product": "ActiveProducts()"}
+	res := a.ValidateExpression(ValidateExprParams{RootType: root, Expression: "product.ID", Bindings: bindings})
+	require.True(t, res.Valid, res.Error)
+	assert.Equal(t, "string", res.ResultType)
+
+	res = a.ValidateExpression(ValidateExprParams{RootType: root, Expression: "product.Price", Bindings: bindings})
+	assert.False(t, res.Valid)
+	assert.Contains(t, res.Error, `field "Price" not found`)
+
+	// Params stay in scope alongside the loop variable
+	res = a.ValidateExpression(ValidateExprParams{RootType: root, Expression: "Name", Bindings: bindings})
+	require.True(t, res.Valid, res.Error)
+
+	// A nested loop's collection starts from the outer loop variable
+	bindings["tag"] = "product.Tags"
+	res = a.ValidateExpression(ValidateExprParams{RootType: root, Expression: "tag", Bindings: bindings})
+	require.True(t, res.Valid, res.Error)
+	assert.Equal(t, "string", res.ResultType)
+
+	res = a.ValidateExpression(ValidateExprParams{
+		RootType:   root,
+		Expression: "name.Length",
+		Bindings:   map[string]string{"name": "Name"},
+	})
+	assert.False(t, res.Valid)
+	assert.Equal(t, "loop variable name ranges over string, which is not a slice, array or map", res.Error)
+}
+
+func TestValidateExpression_TimeTypes(t *testing.T) {
+	a := newTestAnalyzer(t)
+	const root = "github.com/njreid/hudl/cmd/hudl-analyzer/testdata/shop.Order"
+
+	// Stdlib and well-known proto types resolve on their own
+	for _, typ := range []string{"time.Time", "google.golang.org/protobuf/types/known/timestamppb.Timestamp"} {
+		_, err := a.ResolveType(typ)
+		require.NoError(t, err, typ)
+	}
+
+	// Timestamp fields by proto name, read through nil-safe getters
+	res := a.ValidateExpression(ValidateExprParams{RootType: root, Expression: "PlacedAt.seconds"})
+	require.True(t, res.Valid, res.Error)
+	assert.Equal(t, "int64", res.ResultType)
+	assert.Empty(t, res.NilPointers)
+
+	res = a.ValidateExpression(ValidateExprParams{RootType: root, Expression: "PlacedAt.AsTime().Year()"})
+	require.True(t, res.Valid, res.Error)
+	assert.Equal(t, "int", res.ResultType)
+
+	res = a.ValidateExpression(ValidateExprParams{RootType: root, Expression: "ShippedAt.Year()"})
+	require.True(t, res.Valid, res.Error)
+
+	// Calendar fields are methods, which the error points at
+	res = a.ValidateExpression(ValidateExprParams{RootType: root, Expression: "ShippedAt.year"})
+	assert.False(t, res.Valid)
+	assert.Equal(t, `field "year" not found on type time.Time; call the method Year() instead`, res.Error)
+
+	res = a.ValidateExpression(ValidateExprParams{RootType: root, Expression: "PlacedAt.month"})
+	assert.False(t, res.Valid)
+	assert.Equal(t, `field "month" not found on type *google.golang.org/protobuf/types/known/timestamppb.Timestamp; convert it with AsTime().Month()`, res.Error)
+}
+
+func TestGetTypeInfo_MethodReceivers(t *testing.T) {
+	a := newTestAnalyzer(t)
+
+	info, err := a.GetTypeInfo("github.com/njreid/hudl/cmd/hudl-analyzer/testdata/shop", "Person")
+	require.NoError(t, err)
+	assert.Equal(t, []MethodInfo{
+		{Name: "Greeting", Signature: "func() string", Receiver: "value"},
+		{Name: "Normalize", Signature: "func() string", Receiver: "pointer"},
+	}, info.Methods)
+}
+
+func TestResolvePackagePath(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "go.mod"), []byte("module example.com/shop\n\ngo 1.21\n"), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "internal", "models"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "internal", "models", "models.go"), []byte("package models\n\ntype User struct{ Name string }\n"), 0o644))
+
+	a, err := NewAnalyzer(root)
+	require.NoError(t, err)
+
+	path, err := a.ResolvePackagePath("internal/models")
+	require.NoError(t, err)
+	assert.Equal(t, "example.com/shop/internal/models", path)
+
+	path, err = a.ResolvePackagePath(filepath.Join(root, "internal", "models"))
+	require.NoError(t, err)
+	assert.Equal(t, "example.com/shop/internal/models", path)
+
+	outside := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "main.go"), []byte("package main\n"), 0o644))
+	_, err = a.ResolvePackagePath(outside)
+	assert.ErrorContains(t, err, "is not inside a Go module")
+}
@@ -0,0 +1,81 @@
+// Package shop holds plain Go view data for the analyzer tests.
+package shop
+
+import (
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type Shop struct {
+	Name     string
+	Owner    *Person
+	Manager  *Manager
+	Products []Product
+}
+
+type Product struct {
+	ID     string
+	Name   string
+	Active bool
+	Tags   []string
+	// Attrs are passed through to the product's markup
+	Attrs map[string]string
+	Stock map[string]int
+}
+
+// ActiveProducts filters Products, for collection expressions that call a
+// method.
+func (s *Shop) ActiveProducts() []Product {
+	var active []Product
+	for _, p := range s.Products {
+		if p.Active {
+			active = append(active, p)
+		}
+	}
+	return active
+}
+
+// ProductNamed takes an argument, so templates can't call it.
+func (s *Shop) ProductNamed(name string) *Product {
+	for i := range s.Products {
+		if s.Products[i].Name == name {
+			return &s.Products[i]
+		}
+	}
+	return nil
+}
+
+type Person struct {
+	Name string
+}
+
+// Greeting and Normalize have value and pointer receivers respectively.
+func (p Person) Greeting() string {
+	return "Hello, " + p.Name
+}
+
+func (p *Person) Normalize() string {
+	p.Name = strings.TrimSpace(p.Name)
+	return p.Name
+}
+
+// Manager has a nil-safe getter, like a proto message.
+type Manager struct {
+	Name string
+}
+
+func (m *Manager) GetName() string {
+	if m == nil {
+		return ""
+	}
+	return m.Name
+}
+
+// Order has a well-known proto timestamp and a stdlib time.
+type Order struct {
+	ID        string
+	PlacedAt  *timestamppb.Timestamp
+	ShippedAt time.Time
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const bundleFileName = "hudl_bundle.go"
+
+func runBundle(args []string) {
+	fs := flag.NewFlagSet("bundle", flag.ExitOnError)
+	out := fs.String("o", bundleFileName, "output Go file")
+	pkg := fs.String("package", "main", "package name of the generated file")
+	fs.Parse(args)
+
+	fmt.Println("Bundling views.wasm and public/ assets...")
+
+	dir := filepath.Dir(*out)
+	if err := writeBundle(dir, filepath.Base(*out), *pkg); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Success: %s generated.\n", *out)
+}
+
+// writeBundle generates a Go file in dir that embeds views.wasm (and public/,
+// if present) and wires them to hudl.NewRuntimeFromBundle.
+func writeBundle(dir, fileName, pkg string) error {
+	if _, err := os.Stat(filepath.Join(dir, "views.wasm")); err != nil {
+		return fmt.Errorf("views.wasm not found in %s (run 'hudl build' first)", dir)
+	}
+
+	hasPublic := false
+	if info, err := os.Stat(filepath.Join(dir, "public")); err == nil && info.IsDir() {
+		hasPublic = true
+	}
+
+	src, err := generateBundleSource(pkg, hasPublic)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, fileName), src, 0644)
+}
+
+func generateBundleSource(pkg string, hasPublic bool) ([]byte, error) {
+	var b strings.Builder
+
+	b.WriteString("// Code generated by hudl bundle. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkg)
+	b.WriteString("import (\n\t\"context\"\n\t\"embed\"\n")
+	if hasPublic {
+		b.WriteString("\t\"io/fs\"\n")
+	}
+	b.WriteString("\n\t\"github.com/njreid/hudl/pkg/hudl\"\n)\n\n")
+
+	b.WriteString("//go:embed views.wasm\n")
+	if hasPublic {
+		b.WriteString("//go:embed public\n")
+	}
+	b.WriteString("var hudlBundle embed.FS\n\n")
+
+	b.WriteString("// NewBundledRuntime creates a Hudl runtime from the embedded views.wasm.\n")
+	b.WriteString("func NewBundledRuntime(ctx context.Context) (*hudl.Runtime, error) {\n")
+	b.WriteString("\treturn hudl.NewRuntimeFromBundle(ctx, hudlBundle)\n}\n")
+
+	if hasPublic {
+		b.WriteString("\n// BundledAssets returns the embedded public/ directory, suitable for http.FS.\n")
+		b.WriteString("func BundledAssets() fs.FS {\n")
+		b.WriteString("\tsub, err := fs.Sub(hudlBundle, \"public\")\n")
+		b.WriteString("\tif err != nil {\n\t\tpanic(err)\n\t}\n")
+		b.WriteString("\treturn sub\n}\n")
+	}
+
+	src, err := format.Source([]byte(b.String()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to format bundle source: %w", err)
+	}
+	return src, nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -18,6 +19,7 @@ const LayoutTemplate = `// name: AppLayout
 // param: string title "Hudl Project"
 
 el {
+    doctype html
     html lang=en {
         head {
             meta charset=utf-8
@@ -67,6 +69,92 @@ el {
 }
 `
 
+// Datastar-specific parts of the templates, removed by --no-datastar.
+const (
+	datastarLayoutScript = `            _script "/datastar.js" type=module
+`
+	datastarIndexSection = `
+
+            section {
+                style {
+                    margin-top "2rem"
+                    padding "1rem"
+                    background "#eee"
+                    border-radius "8px"
+                }
+                h3 "Server-Sent Events Clock"
+                // Datastar connection to /events
+                div ~init="@get('/events')" {
+                    span "Current Time: "
+                    span#clock "Connecting..."
+                }
+            }`
+	datastarMainImports = `	"github.com/starfederation/datastar-go/datastar"
+`
+	datastarMainTimeImport = `	"time"
+`
+	datastarMainRoute = `
+	// --- Datastar SSE Events ---
+	r.Get("/events", func(w http.ResponseWriter, r *http.Request) {
+		sse := datastar.NewSSE(w, r)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-ticker.C:
+				currentTime := time.Now().Format("15:04:05")
+				// Push element update to #clock
+				sse.PatchElements(fmt.Sprintf("<span id=\"clock\">%s</span>", currentTime))
+			}
+		}
+	})
+`
+)
+
+// Chi-specific parts of MainGoTemplate, replaced by --router=stdlib.
+const (
+	chiMainImports = `	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+`
+	chiMainStringsImport = `	"strings"
+`
+	chiMainRouter = `	r := chi.NewRouter()
+	r.Use(middleware.Logger)
+	r.Use(middleware.Recoverer)
+`
+	chiMainFileServer = `
+// FileServer conveniently sets up a http.FileServer handler to serve
+// static files from a http.FileSystem.
+func FileServer(r chi.Router, path string, root http.FileSystem) {
+	if strings.ContainsAny(path, "{}*") {
+		panic("FileServer does not permit any URL parameters.")
+	}
+
+	if path != "/" && path[len(path)-1] != '/' {
+		r.Get(path, http.RedirectHandler(path+"/", 301).ServeHTTP)
+		path += "/"
+	}
+	path += "*"
+
+	r.Get(path, func(w http.ResponseWriter, r *http.Request) {
+		rctx := chi.RouteContext(r.Context())
+		pathPrefix := strings.TrimSuffix(rctx.RoutePattern(), "/*")
+		fs := http.StripPrefix(pathPrefix, http.FileServer(root))
+		fs.ServeHTTP(w, r)
+	})
+}
+`
+)
+
+// Routers hudl init can scaffold main.go for.
+const (
+	routerChi    = "chi"
+	routerStdlib = "stdlib"
+)
+
 const MainGoTemplate = `package main
 
 import (
@@ -137,6 +225,9 @@ func main() {
 	})
 
 	port := ":8080"
+	if p := os.Getenv("PORT"); p != "" {
+		port = ":" + p
+	}
 	fmt.Printf("Server starting on http://localhost%s\n", port)
 	log.Fatal(http.ListenAndServe(port, r))
 }
@@ -191,9 +282,13 @@ func main() {
 		fmt.Fprintf(os.Stderr, "Usage: hudl <command> [options]\n\n")
 		fmt.Fprintf(os.Stderr, "Commands:\n")
 		fmt.Fprintf(os.Stderr, "  install   Download and install hudlc and hudl-lsp binaries\n")
-		fmt.Fprintf(os.Stderr, "  init [name] Initialize a new Hudl-enabled Go project\n")
+		fmt.Fprintf(os.Stderr, "  init [name] Initialize a new Hudl-enabled Go project (--router=stdlib|chi, --no-datastar for a plain one)\n")
 		fmt.Fprintf(os.Stderr, "  dev       Run the project in development mode (hot-reload)\n")
-		fmt.Fprintf(os.Stderr, "  build     Build the project (compile templates to WASM)\n")
+		fmt.Fprintf(os.Stderr, "  serve     Build and run the project in production mode for a preview (-port, default 8080)\n")
+		fmt.Fprintf(os.Stderr, "  build     Build the project (compile templates to WASM; -xhtml for XHTML output)\n")
+		fmt.Fprintf(os.Stderr, "  check     Report params declared but unused and names used but not declared\n")
+		fmt.Fprintf(os.Stderr, "  bundle    Generate a Go file embedding views.wasm and public/ assets\n")
+		fmt.Fprintf(os.Stderr, "  generate  Generate Go wrappers for views (-data-types for typed constructors, -context for ctx params, -bytes for []byte results)\n")
 		fmt.Fprintf(os.Stderr, "  version   Show version information\n")
 		fmt.Fprintf(os.Stderr, "\nOptions:\n")
 		flag.PrintDefaults()
@@ -212,13 +307,19 @@ func main() {
 	case "install":
 		runInstall()
 	case "init":
-		runInit(flag.Arg(1))
+		runInit(flag.Args()[1:])
 	case "dev":
 		runDev()
+	case "serve":
+		runServe(flag.Args()[1:])
 	case "build":
-		runBuild()
+		runBuild(flag.Args()[1:])
+	case "check":
+		runCheck()
+	case "bundle":
+		runBundle(flag.Args()[1:])
 	case "generate":
-		runGenerate()
+		runGenerate(flag.Args()[1:])
 	case "version":
 		fmt.Println("hudl version 0.1.0")
 	default:
@@ -236,7 +337,11 @@ func runInstall() {
 	fmt.Println("  make build")
 }
 
-func runBuild() {
+func runBuild(flags []string) {
+	fs := flag.NewFlagSet("build", flag.ExitOnError)
+	xhtml := fs.Bool("xhtml", false, "serialize void elements and boolean attributes as XHTML")
+	fs.Parse(flags)
+
 	fmt.Println("Building Hudl templates...")
 
 	// Check if views directory exists
@@ -245,7 +350,11 @@ func runBuild() {
 		os.Exit(1)
 	}
 
-	cmd := exec.Command("hudlc", "views", "-o", "views.wasm")
+	args := []string{"views", "-o", "views.wasm", "--manifest", "views.manifest.json"}
+	if *xhtml {
+		args = append(args, "--xhtml")
+	}
+	cmd := exec.Command("hudlc", args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
@@ -253,10 +362,36 @@ func runBuild() {
 		fmt.Println("Make sure hudlc is installed and in your PATH.")
 		os.Exit(1)
 	}
-	fmt.Println("Success: views.wasm generated.")
+	fmt.Println("Success: views.wasm and views.manifest.json generated.")
+}
+
+func runCheck() {
+	if _, err := os.Stat("views"); os.IsNotExist(err) {
+		fmt.Println("Error: 'views' directory not found. Are you in the project root?")
+		os.Exit(1)
+	}
+
+	cmd := exec.Command("hudlc", "check", "views")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			fmt.Printf("Error: failed to run hudlc: %v\n", err)
+			fmt.Println("Make sure hudlc is installed and in your PATH.")
+		}
+		os.Exit(1)
+	}
 }
 
-func runGenerate() {
+func runGenerate(flags []string) {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	dataTypes := fs.Bool("data-types", false, "also generate a typed data struct and constructor per view")
+	withContext := fs.Bool("context", false, "take a context.Context as the first parameter of every view method")
+	asBytes := fs.Bool("bytes", false, "return []byte instead of string from every view method")
+	pbImportFlag := fs.String("pb-import", "", "Go import path of the protobuf package (default: pb_import in hudl.toml, else detected)")
+	fs.Parse(flags)
+
 	fmt.Println("Generating Go wrappers...")
 
 	// Check if views directory exists
@@ -265,52 +400,31 @@ func runGenerate() {
 		os.Exit(1)
 	}
 
-	// Assuming default options for now:
-	// - views dir: views
-	// - output: views/views.go
-	// - package: views
-	// - pb import: github.com/njreid/hudl/pkg/hudl/pb (need to make this configurable or detect from go.mod?)
-	// Actually, for now let's assume the user has a pb package relative to the current module.
-	
-	// Try to detect module name
-	modName := detectModuleName()
-	pbImport := ""
-	if modName != "" {
-		// HACK: For the default scaffold, we know the pb is in the library
-		// For user projects, they might define their own. 
-		// We'll need a better way to configure this later.
-		pbImport = "github.com/njreid/hudl/pkg/hudl/pb"
+	pbImport, pbPackage, err := resolvePBImport(".", "views", *pbImportFlag)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
 	}
 
 	args := []string{"generate-go", "views",
 		"-o", "views/views.go",
 		"--package", "views",
-		"--pb-package", "pb",
+		"--pb-package", pbPackage,
 	}
 	if pbImport != "" {
 		args = append(args, "--pb-import", pbImport)
 	}
+	if *dataTypes {
+		args = append(args, "--data-types")
+	}
+	if *withContext {
+		args = append(args, "--context")
+	}
+	if *asBytes {
+		args = append(args, "--bytes")
+	}
 
 	cmd := exec.Command("hudlc", args...)
-	
-	// If we have a pb import, use it. But for the generated scaffold, the pb is in `pkg/hudl/pb` inside the library?
-	// No, the generated scaffold uses `github.com/njreid/hudl/pkg/hudl/pb` for `SimpleData`.
-	// So we should pass that.
-	// But `SimpleData` is defined in `pkg/hudl/pb`.
-	// For user-defined protos, they might be elsewhere.
-	
-	// Let's pass what we know for the default scaffold.
-	// Actually, let's just run it. If message types are simple names like `SimpleData`, generated code will use `*pb.SimpleData`.
-	// We need `pb` to be imported.
-	
-	// HACK: For the default scaffold, we know the import.
-	// For general usage, we might need a config file (hudl.json/toml) later.
-	// For now, let's rely on manual flags if run directly, or sensible defaults.
-	// Since we can't easily guess, let's omit the import flag and let the user fix the imports if needed?
-	// Or try to guess.
-	
-	// Let's add a flag support to `hudl generate` later. For now, just run basic generation.
-	
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
@@ -319,20 +433,9 @@ func runGenerate() {
 	}
 }
 
-func detectModuleName() string {
-	if data, err := os.ReadFile("go.mod"); err == nil {
-		for _, line := range strings.Split(string(data), "\n") {
-			if strings.HasPrefix(line, "module ") {
-				return strings.TrimSpace(strings.TrimPrefix(line, "module "))
-			}
-		}
-	}
-	return ""
-}
-
 func runDev() {
 	// 0. Generate Go wrappers first
-	runGenerate()
+	runGenerate(nil)
 
 	fmt.Println("Starting Hudl development server...")
 
@@ -357,24 +460,62 @@ func runDev() {
 	// 2. Run Go app with HUDL_DEV=1
 	fmt.Println("Starting Go application...")
 
-	// Check if we should run main.go or .
-	var goArgs []string
-	if _, err := os.Stat("main.go"); err == nil {
-		goArgs = []string{"run", "main.go"}
-	} else {
-		goArgs = []string{"run", "."}
+	goCmd := goRunCommand()
+	goCmd.Env = append(os.Environ(), "HUDL_DEV=1")
+
+	if err := goCmd.Run(); err != nil {
+		fmt.Printf("\nGo application exited: %v\n", err)
 	}
+}
 
-	goCmd := exec.Command("go", goArgs...)
-	goCmd.Env = append(os.Environ(), "HUDL_DEV=1")
-	goCmd.Stdout = os.Stdout
-	goCmd.Stderr = os.Stderr
+// runServe builds the project as for production and runs it, for a quick
+// preview without the dev server or hot reload.
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	port := fs.Int("port", 8080, "port to serve on, passed to the app as PORT")
+	xhtml := fs.Bool("xhtml", false, "serialize void elements and boolean attributes as XHTML")
+	fs.Parse(args)
+
+	runGenerate(nil)
+	var buildFlags []string
+	if *xhtml {
+		buildFlags = append(buildFlags, "-xhtml")
+	}
+	runBuild(buildFlags)
 
+	fmt.Printf("Serving the production build at http://localhost:%d\n", *port)
+	goCmd := serveCommand(*port)
 	if err := goCmd.Run(); err != nil {
 		fmt.Printf("\nGo application exited: %v\n", err)
 	}
 }
 
+// serveCommand runs the app on port with the production runtime: HUDL_DEV
+// is removed from the environment so views.wasm is loaded.
+func serveCommand(port int) *exec.Cmd {
+	goCmd := goRunCommand()
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "HUDL_DEV=") && !strings.HasPrefix(kv, "PORT=") {
+			goCmd.Env = append(goCmd.Env, kv)
+		}
+	}
+	goCmd.Env = append(goCmd.Env, fmt.Sprintf("PORT=%d", port))
+	return goCmd
+}
+
+// goRunCommand runs the project's main.go, or its package if there is none,
+// with output passed through.
+func goRunCommand() *exec.Cmd {
+	goArgs := []string{"run", "."}
+	if _, err := os.Stat("main.go"); err == nil {
+		goArgs = []string{"run", "main.go"}
+	}
+	goCmd := exec.Command("go", goArgs...)
+	goCmd.Stdout = os.Stdout
+	goCmd.Stderr = os.Stderr
+	return goCmd
+}
+
 func isPortOpen(addr string) bool {
 	conn, err := net.DialTimeout("tcp", addr, 100*time.Millisecond)
 	if err != nil {
@@ -384,7 +525,22 @@ func isPortOpen(addr string) bool {
 	return true
 }
 
-func runInit(name string) {
+func runInit(args []string) {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	noDatastar := fs.Bool("no-datastar", false, "scaffold without datastar.js, the SSE route and the datastar-go dependency")
+	router := fs.String("router", routerChi, "router for main.go: stdlib (net/http only) or chi")
+	fs.Parse(args)
+	// Allow the flag after the name too: hudl init myapp --no-datastar
+	name := fs.Arg(0)
+	if fs.NArg() > 1 {
+		fs.Parse(fs.Args()[1:])
+	}
+	datastar := !*noDatastar
+	if *router != routerChi && *router != routerStdlib {
+		fmt.Printf("Error: unknown router %q (want %s or %s)\n", *router, routerStdlib, routerChi)
+		os.Exit(1)
+	}
+
 	if name == "" {
 		reader := bufio.NewReader(os.Stdin)
 		fmt.Print("Project name: ")
@@ -413,33 +569,10 @@ func runInit(name string) {
 		os.Exit(1)
 	}
 
-	// 3. Create structure
-	os.Mkdir(filepath.Join(name, "views"), 0755)
-	os.Mkdir(filepath.Join(name, "public"), 0755)
-
-	// 4. Write files
-	files := map[string]string{
-		"views/layout.hudl": LayoutTemplate,
-		"views/index.hudl":  IndexTemplate,
-		"public/style.css":  StylesTemplate,
-		"main.go":           MainGoTemplate,
-	}
-
-	for path, content := range files {
-		// Replace module name placeholder
-		content = strings.ReplaceAll(content, "MOD_NAME", name)
-		if err := os.WriteFile(filepath.Join(name, path), []byte(content), 0644); err != nil {
-			fmt.Printf("Error writing %s: %v\n", path, err)
-			os.Exit(1)
-		}
-	}
-
-	// 5. Download datastar.js
-	fmt.Println("Downloading datastar.js...")
-	datastarURL := "https://cdn.jsdelivr.net/gh/starfederation/datastar@1.0.0-RC.7/bundles/datastar.js"
-	if err := downloadFile(datastarURL, filepath.Join(name, "public/datastar.js")); err != nil {
-		fmt.Printf("Warning: failed to download datastar.js: %v\n", err)
-		fmt.Println("You may need to download it manually and place it in the public/ directory.")
+	// 3-5. Create structure, write files and download datastar.js
+	if err := writeScaffold(name, name, *router, datastar); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
 	}
 
 	// 6. Fetch dependencies
@@ -466,10 +599,12 @@ func runInit(name string) {
 		}
 	}
 
-	deps := []string{
-		"github.com/go-chi/chi/v5",
-		"github.com/njreid/hudl",
-		"github.com/starfederation/datastar-go",
+	deps := []string{"github.com/njreid/hudl"}
+	if *router == routerChi {
+		deps = append(deps, "github.com/go-chi/chi/v5")
+	}
+	if datastar {
+		deps = append(deps, "github.com/starfederation/datastar-go")
 	}
 	for _, dep := range deps {
 		fmt.Printf("  get %s...\n", dep)
@@ -495,6 +630,74 @@ func runInit(name string) {
 	fmt.Printf("  hudl dev\n")
 }
 
+// writeScaffold writes the project files for module name into dir, with
+// main.go routing through router. Without datastar, the layout script, SSE
+// clock section and /events route are left out and datastar.js is not
+// downloaded.
+func writeScaffold(dir, name, router string, datastar bool) error {
+	os.Mkdir(filepath.Join(dir, "views"), 0755)
+	os.Mkdir(filepath.Join(dir, "public"), 0755)
+
+	files := map[string]string{
+		"views/layout.hudl": LayoutTemplate,
+		"views/index.hudl":  IndexTemplate,
+		"public/style.css":  StylesTemplate,
+		"main.go":           MainGoTemplate,
+	}
+
+	for path, content := range files {
+		// Replace module name placeholder
+		content = strings.ReplaceAll(content, "MOD_NAME", name)
+		if !datastar {
+			content = stripDatastar(content)
+		}
+		if path == "main.go" && router == routerStdlib {
+			content = useStdlibRouter(content)
+		}
+		if err := os.WriteFile(filepath.Join(dir, path), []byte(content), 0644); err != nil {
+			return fmt.Errorf("writing %s: %w", path, err)
+		}
+	}
+
+	if !datastar {
+		return nil
+	}
+	fmt.Println("Downloading datastar.js...")
+	datastarURL := "https://cdn.jsdelivr.net/gh/starfederation/datastar@1.0.0-RC.7/bundles/datastar.js"
+	if err := downloadFile(datastarURL, filepath.Join(dir, "public/datastar.js")); err != nil {
+		fmt.Printf("Warning: failed to download datastar.js: %v\n", err)
+		fmt.Println("You may need to download it manually and place it in the public/ directory.")
+	}
+	return nil
+}
+
+func stripDatastar(content string) string {
+	for _, part := range []string{
+		datastarLayoutScript,
+		datastarIndexSection,
+		datastarMainImports,
+		datastarMainTimeImport,
+		datastarMainRoute,
+	} {
+		content = strings.Replace(content, part, "", 1)
+	}
+	return content
+}
+
+// useStdlibRouter rewrites the chi main.go to route with http.ServeMux
+// patterns instead.
+func useStdlibRouter(content string) string {
+	return strings.NewReplacer(
+		chiMainImports, "",
+		chiMainStringsImport, "",
+		chiMainRouter, "\tr := http.NewServeMux()\n",
+		chiMainFileServer, "",
+		`FileServer(r, "/", filesDir)`, `r.Handle("GET /", http.FileServer(filesDir))`,
+		`r.Get("/", `, `r.HandleFunc("GET /{$}", `,
+		`r.Get("/events", `, `r.HandleFunc("GET /events", `,
+	).Replace(content)
+}
+
 func downloadFile(url string, filepath string) error {
 	const timeout = 10 * time.Second
 	client := &http.Client{
@@ -2,11 +2,18 @@ package main
 
 import (
 	"bytes"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io"
+	"net"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -74,4 +81,232 @@ func TestCLI_Init(t *testing.T) {
 	require.NoError(t, err)
 	assert.Contains(t, string(content), "github.com/go-chi/chi/v5")
 	assert.Contains(t, string(content), "github.com/njreid/hudl/pkg/hudl")
-}
\ No newline at end of file
+}
+
+func TestCLI_Bundle(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hudl-bundle-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	// Smallest valid WASM module: magic number + version
+	wasm := []byte{0x00, 'a', 's', 'm', 0x01, 0x00, 0x00, 0x00}
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "views.wasm"), wasm, 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(tmpDir, "public"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "public/style.css"), []byte("body {}"), 0644))
+
+	err = writeBundle(tmpDir, bundleFileName, "main")
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, bundleFileName))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "//go:embed views.wasm")
+	assert.Contains(t, string(content), "//go:embed public")
+	assert.Contains(t, string(content), "hudl.NewRuntimeFromBundle(ctx, hudlBundle)")
+
+	if testing.Short() {
+		t.Skip("skipping bundle compile check in short mode")
+	}
+
+	// Verify the generated file compiles against this module
+	repoRoot, err := filepath.Abs("../..")
+	require.NoError(t, err)
+	goMod := "module bundletest\n\ngo 1.25\n\nrequire github.com/njreid/hudl v0.0.0\n\nreplace github.com/njreid/hudl => " + repoRoot + "\n"
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goMod), 0644))
+	goSum, err := os.ReadFile(filepath.Join(repoRoot, "go.sum"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "go.sum"), goSum, 0644))
+	mainGo := "package main\n\nimport \"context\"\n\nfunc main() {\n\t_, _ = NewBundledRuntime(context.Background())\n\t_ = BundledAssets()\n}\n"
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte(mainGo), 0644))
+
+	cmd := exec.Command("go", "build", "-mod=mod", "-o", filepath.Join(tmpDir, "app"), ".")
+	cmd.Dir = tmpDir
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+}
+
+func TestCLI_BundleRequiresWASM(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hudl-bundle-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	err = writeBundle(tmpDir, bundleFileName, "main")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "hudl build")
+}
+
+func TestScaffold_NoDatastar(t *testing.T) {
+	// Every part --no-datastar removes must actually be in a template
+	templates := LayoutTemplate + IndexTemplate + MainGoTemplate
+	for _, part := range []string{datastarLayoutScript, datastarIndexSection, datastarMainImports, datastarMainTimeImport, datastarMainRoute} {
+		require.Contains(t, templates, part)
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, writeScaffold(dir, "plainapp", routerChi, false))
+
+	assert.NoFileExists(t, filepath.Join(dir, "public/datastar.js"))
+	assert.FileExists(t, filepath.Join(dir, "public/style.css"))
+
+	mainGo, err := os.ReadFile(filepath.Join(dir, "main.go"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(mainGo), "/events")
+	assert.NotContains(t, string(mainGo), "datastar")
+	assert.Contains(t, string(mainGo), `"plainapp/views"`)
+	_, err = parser.ParseFile(token.NewFileSet(), "main.go", mainGo, 0)
+	require.NoError(t, err)
+
+	layout, err := os.ReadFile(filepath.Join(dir, "views/layout.hudl"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(layout), "datastar.js")
+
+	index, err := os.ReadFile(filepath.Join(dir, "views/index.hudl"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(index), "/events")
+}
+
+func TestScaffold_StdlibRouter(t *testing.T) {
+	// Every part --router=stdlib replaces must actually be in the template
+	for _, part := range []string{chiMainImports, chiMainStringsImport, chiMainRouter, chiMainFileServer} {
+		require.Contains(t, MainGoTemplate, part)
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, writeScaffold(dir, "stdapp", routerStdlib, false))
+
+	mainGo, err := os.ReadFile(filepath.Join(dir, "main.go"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(mainGo), "go-chi")
+	assert.NotContains(t, string(mainGo), "chi.")
+	assert.Contains(t, string(mainGo), "http.NewServeMux()")
+	assert.Contains(t, string(mainGo), `r.HandleFunc("GET /{$}", `)
+	assert.Contains(t, string(mainGo), `"stdapp/views"`)
+	_, err = parser.ParseFile(token.NewFileSet(), "main.go", mainGo, 0)
+	require.NoError(t, err)
+
+	// With datastar, the SSE route moves to the mux too
+	withDatastar := useStdlibRouter(MainGoTemplate)
+	assert.NotContains(t, withDatastar, "go-chi")
+	assert.Contains(t, withDatastar, `r.HandleFunc("GET /events", `)
+	_, err = parser.ParseFile(token.NewFileSet(), "main.go", withDatastar, 0)
+	require.NoError(t, err)
+}
+
+func TestResolvePBImport_DetectsModulePackage(t *testing.T) {
+	root := filepath.Join("testdata", "pbdetect")
+
+	types, err := templateMessageTypes(filepath.Join(root, "views"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Product", "Review"}, types)
+
+	importPath, pkgName, err := resolvePBImport(root, filepath.Join(root, "views"), "")
+	require.NoError(t, err)
+	assert.Equal(t, "example.com/shop/internal/shoppb", importPath)
+	assert.Equal(t, "shoppb", pkgName)
+}
+
+func TestResolvePBImport_ConfigAndFallback(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/app\n"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "views"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "views", "a.hudl"), []byte("// param: Order order\nel { p `order.id` }\n"), 0644))
+	views := filepath.Join(dir, "views")
+
+	// Nothing defines Order: the error points at hudl.toml
+	_, _, err := resolvePBImport(dir, views, "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Order")
+	assert.Contains(t, err.Error(), "pb_import in hudl.toml")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, configFileName), []byte("# hudl settings\npb_import = \"example.com/app/gen/orderspb\"\n"), 0644))
+	importPath, pkgName, err := resolvePBImport(dir, views, "")
+	require.NoError(t, err)
+	assert.Equal(t, "example.com/app/gen/orderspb", importPath)
+	assert.Equal(t, "orderspb", pkgName)
+
+	// The flag beats the config file
+	importPath, _, err = resolvePBImport(dir, views, "example.com/other/pb")
+	require.NoError(t, err)
+	assert.Equal(t, "example.com/other/pb", importPath)
+}
+
+func TestResolvePBImport_ScalarParamsNeedNoImport(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.hudl"), []byte(IndexTemplate), 0644))
+
+	importPath, pkgName, err := resolvePBImport(dir, dir, "")
+	require.NoError(t, err)
+	assert.Empty(t, importPath)
+	assert.Equal(t, "pb", pkgName)
+}
+
+func TestCLI_Serve(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping serve test in short mode")
+	}
+
+	// A stand-in hudlc that writes an empty module for build
+	binDir := t.TempDir()
+	fakeHudlc := "#!/bin/sh\nif [ \"$1\" != generate-go ]; then printf '\\000asm\\001\\000\\000\\000' > \"$3\"; fi\n"
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "hudlc"), []byte(fakeHudlc), 0755))
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("HUDL_DEV", "1")
+
+	// An app that reports what it was started with, then exits
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "views"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "views", "index.hudl"), []byte(IndexTemplate), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module serveapp\n\ngo 1.25\n"), 0644))
+	app := `package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+func main() {
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		wasm, err := os.ReadFile("views.wasm")
+		fmt.Fprintf(w, "wasm=%d err=%v dev=%q", len(wasm), err, os.Getenv("HUDL_DEV"))
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			os.Exit(0)
+		}()
+	})
+	http.ListenAndServe(":"+os.Getenv("PORT"), nil)
+}
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte(app), 0644))
+
+	l, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	binPath := filepath.Join(t.TempDir(), "hudl")
+	build := exec.Command("go", "build", "-o", binPath, ".")
+	require.NoError(t, build.Run())
+
+	cmd := exec.Command(binPath, "serve", "-port", fmt.Sprint(port))
+	cmd.Dir = dir
+	require.NoError(t, cmd.Start())
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	var body string
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/", port))
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		body = string(b)
+		return true
+	}, 60*time.Second, 100*time.Millisecond)
+	assert.Equal(t, `wasm=8 err=<nil> dev=""`, body)
+	assert.FileExists(t, filepath.Join(dir, "views.wasm"))
+}
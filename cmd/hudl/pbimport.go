@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	configFileName   = "hudl.toml"
+	protobufModule   = "google.golang.org/protobuf"
+	defaultPBPkgName = "pb"
+)
+
+// paramTypeRe matches `// param: [repeated|optional] <type> <name>` lines,
+// the same shape hudlc accepts.
+var paramTypeRe = regexp.MustCompile(`//\s*param:\s*(?:(?:repeated|optional)\s+)?([\w.]+)\s+\w+`)
+
+// scalarTypes are the param types that map to Go builtins and need no pb import.
+var scalarTypes = map[string]bool{
+	"string": true, "bool": true, "bytes": true, "float": true, "double": true,
+	"int32": true, "int64": true, "uint32": true, "uint64": true,
+	"sint32": true, "sint64": true, "fixed32": true, "fixed64": true,
+	"sfixed32": true, "sfixed64": true,
+}
+
+// resolvePBImport decides which Go package the generated wrappers import for
+// message and enum params. An explicit flag wins, then pb_import in
+// hudl.toml, then a package in the module that imports protobuf and defines
+// every type the templates reference. Templates with only scalar params need
+// no import and get an empty path.
+func resolvePBImport(root, viewsDir, explicit string) (importPath, pkgName string, err error) {
+	if explicit != "" {
+		return explicit, path.Base(explicit), nil
+	}
+	cfg, err := readConfig(filepath.Join(root, configFileName))
+	if err != nil {
+		return "", "", err
+	}
+	if imp := cfg["pb_import"]; imp != "" {
+		if name := cfg["pb_package"]; name != "" {
+			return imp, name, nil
+		}
+		return imp, path.Base(imp), nil
+	}
+
+	types, err := templateMessageTypes(viewsDir)
+	if err != nil {
+		return "", "", err
+	}
+	if len(types) == 0 {
+		return "", defaultPBPkgName, nil
+	}
+	return detectPBPackage(root, types)
+}
+
+// readConfig reads the flat `key = "value"` pairs of hudl.toml. A missing
+// file is not an error.
+func readConfig(file string) (map[string]string, error) {
+	cfg := map[string]string{}
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[") {
+			continue
+		}
+		key, val, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected key = value", file, n)
+		}
+		val = strings.TrimSpace(val)
+		if unquoted, err := strconv.Unquote(val); err == nil {
+			val = unquoted
+		}
+		cfg[strings.TrimSpace(key)] = val
+	}
+	return cfg, scanner.Err()
+}
+
+// templateMessageTypes returns the sorted, unqualified message and enum type
+// names used by `// param:` lines in the .hudl files under dir.
+func templateMessageTypes(dir string) ([]string, error) {
+	seen := map[string]bool{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(p) != ".hudl" {
+			return err
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		for _, m := range paramTypeRe.FindAllStringSubmatch(string(content), -1) {
+			name := m[1]
+			// Qualified names (pkg.Type) already carry their own package.
+			if !scalarTypes[name] && !strings.Contains(name, ".") {
+				seen[name] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	types := make([]string, 0, len(seen))
+	for name := range seen {
+		types = append(types, name)
+	}
+	sort.Strings(types)
+	return types, nil
+}
+
+// detectPBPackage finds the single package in the module rooted at root that
+// imports protobuf and declares all of types.
+func detectPBPackage(root string, types []string) (importPath, pkgName string, err error) {
+	modPath := readModulePath(root)
+	if modPath == "" {
+		return "", "", fmt.Errorf("no go.mod in %s; set pb_import in %s", root, configFileName)
+	}
+
+	type candidate struct{ importPath, name string }
+	var found []candidate
+
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		if p != root {
+			name := d.Name()
+			if strings.HasPrefix(name, ".") || name == "vendor" || name == "testdata" || name == "node_modules" {
+				return filepath.SkipDir
+			}
+			// Nested modules are not importable under this module's path.
+			if _, err := os.Stat(filepath.Join(p, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+		}
+
+		name, ok, err := definesProtoTypes(p, types)
+		if err != nil || !ok {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		found = append(found, candidate{path.Join(modPath, filepath.ToSlash(rel)), name})
+		return nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	switch len(found) {
+	case 1:
+		return found[0].importPath, found[0].name, nil
+	case 0:
+		return "", "", fmt.Errorf("no package in %s imports %s and defines %s; set pb_import in %s",
+			modPath, protobufModule, strings.Join(types, ", "), configFileName)
+	default:
+		paths := make([]string, len(found))
+		for i, c := range found {
+			paths[i] = c.importPath
+		}
+		return "", "", fmt.Errorf("several packages define %s (%s); set pb_import in %s",
+			strings.Join(types, ", "), strings.Join(paths, ", "), configFileName)
+	}
+}
+
+// definesProtoTypes reports whether the Go package in dir imports protobuf
+// and declares every one of types, returning the package name.
+func definesProtoTypes(dir string, types []string) (string, bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", false, err
+	}
+
+	fset := token.NewFileSet()
+	pkgName := ""
+	importsProto := false
+	declared := map[string]bool{}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.SkipObjectResolution)
+		if err != nil {
+			// Broken files are the user's problem, not a reason to stop looking.
+			continue
+		}
+		pkgName = file.Name.Name
+		for _, imp := range file.Imports {
+			if p, _ := strconv.Unquote(imp.Path.Value); strings.HasPrefix(p, protobufModule) {
+				importsProto = true
+			}
+		}
+		for _, decl := range file.Decls {
+			if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.TYPE {
+				for _, spec := range gen.Specs {
+					declared[spec.(*ast.TypeSpec).Name.Name] = true
+				}
+			}
+		}
+	}
+
+	if !importsProto {
+		return "", false, nil
+	}
+	for _, t := range types {
+		if !declared[t] {
+			return "", false, nil
+		}
+	}
+	return pkgName, true, nil
+}
+
+func readModulePath(dir string) string {
+	if data, err := os.ReadFile(filepath.Join(dir, "go.mod")); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if strings.HasPrefix(line, "module ") {
+				return strings.TrimSpace(strings.TrimPrefix(line, "module "))
+			}
+		}
+	}
+	return ""
+}
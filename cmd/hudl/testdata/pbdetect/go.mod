@@ -0,0 +1,5 @@
+module example.com/shop
+
+go 1.22
+
+require google.golang.org/protobuf v1.36.0
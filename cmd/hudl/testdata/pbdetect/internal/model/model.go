@@ -0,0 +1,10 @@
+// Package model has a Product too, but it is not a protobuf message.
+package model
+
+type Product struct {
+	Name string
+}
+
+type Review struct {
+	Body string
+}
@@ -73,17 +73,10 @@ func (app *App) handleHome(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Render the features section as content
+	// Render the features section into the layout's content slot
 	features := mockdata.GetFeatures()
-	featuresHTML, err := app.views.Render("FeatureList", features)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to render features: %v", err), 500)
-		return
-	}
-
-	// Render the layout with the features as content
-	layoutData := mockdata.GetLayoutData("Welcome to Hudl", featuresHTML, true)
-	html, err := app.views.Render("AppLayout", layoutData)
+	layoutData := mockdata.GetLayoutData("Welcome to Hudl", "", true)
+	html, err := app.views.RenderLayout("AppLayout", layoutData, "FeatureList", features)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to render layout: %v", err), 500)
 		return
@@ -97,14 +90,8 @@ func (app *App) handleHome(w http.ResponseWriter, r *http.Request) {
 func (app *App) handleDashboard(w http.ResponseWriter, r *http.Request) {
 	dashData := mockdata.GetDashboardData()
 
-	dashboardHTML, err := app.views.Render("Dashboard", dashData)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to render dashboard: %v", err), 500)
-		return
-	}
-
-	layoutData := mockdata.GetLayoutData("Dashboard - Hudl App", dashboardHTML, true)
-	html, err := app.views.Render("AppLayout", layoutData)
+	layoutData := mockdata.GetLayoutData("Dashboard - Hudl App", "", true)
+	html, err := app.views.RenderLayout("AppLayout", layoutData, "Dashboard", dashData)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to render layout: %v", err), 500)
 		return
@@ -123,14 +110,8 @@ func (app *App) handleRegister(w http.ResponseWriter, r *http.Request) {
 		formData = mockdata.GetFormWithErrors(csrfToken)
 	}
 
-	formHTML, err := app.views.Render("RegistrationForm", formData)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to render form: %v", err), 500)
-		return
-	}
-
-	layoutData := mockdata.GetLayoutData("Register - Hudl App", formHTML, false)
-	html, err := app.views.Render("AppLayout", layoutData)
+	layoutData := mockdata.GetLayoutData("Register - Hudl App", "", false)
+	html, err := app.views.RenderLayout("AppLayout", layoutData, "RegistrationForm", formData)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to render layout: %v", err), 500)
 		return
@@ -144,14 +125,8 @@ func (app *App) handleRegister(w http.ResponseWriter, r *http.Request) {
 func (app *App) handleFeatures(w http.ResponseWriter, r *http.Request) {
 	features := mockdata.GetFeatures()
 
-	featuresHTML, err := app.views.Render("FeatureList", features)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to render features: %v", err), 500)
-		return
-	}
-
-	layoutData := mockdata.GetLayoutData("Features - Hudl App", featuresHTML, false)
-	html, err := app.views.Render("AppLayout", layoutData)
+	layoutData := mockdata.GetLayoutData("Features - Hudl App", "", false)
+	html, err := app.views.RenderLayout("AppLayout", layoutData, "FeatureList", features)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to render layout: %v", err), 500)
 		return
@@ -3,6 +3,7 @@
 //! Uses JSON-RPC over stdin/stdout for type analysis queries.
 
 use serde::{Deserialize, Serialize};
+use std::collections::HashMap;
 use std::io::{BufRead, BufReader, Write};
 use std::process::{Child, ChildStdin, ChildStdout, Command, Stdio};
 use std::sync::atomic::{AtomicU64, Ordering};
@@ -55,6 +56,38 @@ struct ValidateExprParams {
     expression: String,
 }
 
+#[derive(Debug, Serialize)]
+#[allow(dead_code)]
+struct ValidateTemplateParams<'a> {
+    #[serde(rename = "rootType")]
+    root_type: &'a str,
+    expressions: &'a [TemplateExpr],
+}
+
+/// An expression and where it appears in the template
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[allow(dead_code)]
+pub struct TemplateExpr {
+    pub expression: String,
+    pub line: u32,
+    pub column: u32,
+    /// Set for an `each` collection, which must be a slice, array or map
+    #[serde(default, skip_serializing_if = "std::ops::Not::not")]
+    pub iterable: bool,
+    /// Set for an attribute spread, which must be a map[string]string
+    #[serde(default, skip_serializing_if = "std::ops::Not::not")]
+    pub spread: bool,
+    /// Loop variables in scope, mapped to the collections they range over
+    #[serde(default, skip_serializing_if = "HashMap::is_empty")]
+    pub bindings: HashMap<String, String>,
+}
+
+#[derive(Debug, Serialize)]
+#[allow(dead_code)]
+struct ValidateParamTypesParams {
+    types: Vec<String>,
+}
+
 #[derive(Debug, Serialize)]
 #[allow(dead_code)]
 struct FindImplsParams {
@@ -70,6 +103,17 @@ struct LoadPackageParams {
     package_path: String,
 }
 
+#[derive(Debug, Serialize)]
+struct ResolvePackagePathParams<'a> {
+    dir: &'a str,
+}
+
+#[derive(Debug, Deserialize)]
+struct ResolvePackagePathResult {
+    #[serde(rename = "packagePath")]
+    package_path: String,
+}
+
 // Response result types
 #[derive(Debug, Deserialize)]
 struct InitializeResult {
@@ -86,6 +130,38 @@ pub struct ValidateExprResult {
     pub error: Option<String>,
 }
 
+/// Validation result for one template expression, with its position
+#[derive(Debug, Clone, Deserialize)]
+#[allow(dead_code)]
+pub struct TemplateExprResult {
+    #[serde(flatten)]
+    pub expr: TemplateExpr,
+    #[serde(flatten)]
+    pub result: ValidateExprResult,
+}
+
+#[derive(Debug, Deserialize)]
+#[allow(dead_code)]
+struct ValidateTemplateResult {
+    results: Vec<TemplateExprResult>,
+}
+
+/// Resolution result for one declared param type
+#[derive(Debug, Clone, Deserialize)]
+#[allow(dead_code)]
+pub struct ParamTypeResult {
+    #[serde(rename = "type")]
+    pub type_name: String,
+    pub valid: bool,
+    pub error: Option<String>,
+}
+
+#[derive(Debug, Deserialize)]
+#[allow(dead_code)]
+struct ValidateParamTypesResult {
+    results: Vec<ParamTypeResult>,
+}
+
 /// Result of finding interface implementations
 #[derive(Debug, Clone, Deserialize)]
 #[allow(dead_code)]
@@ -188,6 +264,34 @@ impl AnalyzerClient {
         )
     }
 
+    /// Validate all of a template's expressions against its root type in one
+    /// round trip. Results come back in order with their positions.
+    #[allow(dead_code)]
+    pub fn validate_template(
+        &mut self,
+        root_type: &str,
+        expressions: &[TemplateExpr],
+    ) -> Result<Vec<TemplateExprResult>, String> {
+        let result: ValidateTemplateResult = self.call(
+            "validateTemplate",
+            ValidateTemplateParams { root_type, expressions },
+        )?;
+        Ok(result.results)
+    }
+
+    /// Check that each declared param type resolves, in order.
+    ///
+    /// # Arguments
+    /// * `types` - Param types like "github.com/pkg/models.User" or "[]string"
+    #[allow(dead_code)]
+    pub fn validate_param_types(&mut self, types: &[String]) -> Result<Vec<ParamTypeResult>, String> {
+        let result: ValidateParamTypesResult = self.call(
+            "validateParamTypes",
+            ValidateParamTypesParams { types: types.to_vec() },
+        )?;
+        Ok(result.results)
+    }
+
     /// Find all types implementing an interface.
     ///
     /// # Arguments
@@ -208,6 +312,17 @@ impl AnalyzerClient {
         )
     }
 
+    /// Resolve a directory to the Go import path of its package, e.g. the
+    /// models package next to a template, to form its root type.
+    ///
+    /// # Arguments
+    /// * `dir` - Directory, absolute or relative to the workspace root
+    #[allow(dead_code)]
+    pub fn resolve_package_path(&mut self, dir: &str) -> Result<String, String> {
+        let result: ResolvePackagePathResult = self.call("resolvePackagePath", ResolvePackagePathParams { dir })?;
+        Ok(result.package_path)
+    }
+
     /// Pre-load a package into the analyzer's cache.
     pub fn load_package(&mut self, package_path: &str) -> Result<(), String> {
         let _: serde_json::Value = self.call(
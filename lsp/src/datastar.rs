@@ -201,7 +201,7 @@ fn collect_datastar_attrs_from_nodes<'a>(
                     }
                 }
             },
-            Node::Text(_) | Node::ContentSlot => {}
+            Node::Text(_) | Node::ContentSlot | Node::Flush => {}
         }
     }
 }
@@ -271,7 +271,7 @@ fn collect_signals_from_nodes(nodes: &[Node], out: &mut Vec<String>) {
                     }
                 }
             },
-            Node::Text(_) | Node::ContentSlot => {}
+            Node::Text(_) | Node::ContentSlot | Node::Flush => {}
         }
     }
 }
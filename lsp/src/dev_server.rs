@@ -17,6 +17,7 @@ use serde::Serialize;
 use std::collections::HashMap;
 use std::convert::Infallible;
 use std::path::{Path, PathBuf};
+use std::sync::atomic::{AtomicU64, Ordering};
 use std::sync::{Arc, Mutex};
 use tokio::sync::broadcast;
 use tokio_stream::wrappers::BroadcastStream;
@@ -26,6 +27,8 @@ use tokio_stream::StreamExt as _;
 struct CachedTemplate {
     root: hudlc::ast::Root,
     schema: hudlc::proto::ProtoSchema,
+    /// File name, for source line comments
+    file: String,
 }
 
 /// Shared state for the dev server.
@@ -40,6 +43,12 @@ pub struct DevServerState {
     port: u16,
     /// Whether to log detailed render requests
     verbose: bool,
+    /// Whether to fill in accessible defaults, as `hudlc --a11y-defaults` does
+    semantic_defaults: bool,
+    /// Number of successful reloads since startup
+    reloads: AtomicU64,
+    /// Number of reloads that failed to compile
+    reload_errors: AtomicU64,
 }
 
 impl DevServerState {
@@ -51,9 +60,28 @@ impl DevServerState {
             reload_tx,
             port,
             verbose,
+            semantic_defaults: false,
+            reloads: AtomicU64::new(0),
+            reload_errors: AtomicU64::new(0),
         }
     }
 
+    /// Fill in accessible defaults when rendering, as `hudlc --a11y-defaults` does.
+    pub fn with_semantic_defaults(mut self, on: bool) -> Self {
+        self.semantic_defaults = on;
+        self
+    }
+
+    /// Return the number of successful reloads.
+    pub fn reload_count(&self) -> u64 {
+        self.reloads.load(Ordering::Relaxed)
+    }
+
+    /// Return the number of reloads that failed to compile.
+    pub fn reload_error_count(&self) -> u64 {
+        self.reload_errors.load(Ordering::Relaxed)
+    }
+
     /// Return the number of cached templates.
     pub fn templates_count(&self) -> usize {
         self.templates.lock().unwrap().len()
@@ -120,12 +148,28 @@ impl DevServerState {
         if let Some(name) = &root.name {
             let name = name.clone();
             let mut templates = self.templates.lock().unwrap();
+            // Check invocations against params as codegen does, with this
+            // file in place of its cached version
+            let views: Vec<(String, hudlc::ast::Root)> = templates.iter()
+                .filter(|(cached_name, _)| **cached_name != name)
+                .map(|(cached_name, cached)| (cached_name.clone(), cached.root.clone()))
+                .chain(std::iter::once((name.clone(), root.clone())))
+                .collect();
+            if let Err(e) = hudlc::transformer::check_component_args(&views) {
+                let err = format!("Component args error in {}: {}", path.display(), e);
+                eprintln!("[dev-server] {}", err);
+                return Err(err);
+            }
             eprintln!("[dev-server] Loaded component: {}", name);
             templates.insert(
                 name.clone(),
                 CachedTemplate {
                     root,
                     schema,
+                    file: path
+                        .file_name()
+                        .map(|f| f.to_string_lossy().into_owned())
+                        .unwrap_or_default(),
                 },
             );
             Ok(name)
@@ -135,10 +179,13 @@ impl DevServerState {
     }
 
     /// Reload a file (on change notification).
-    pub fn reload_file(&self, path: &Path) {
+    pub fn reload_file(&self, path: &Path) -> Result<String, String> {
         eprintln!("[dev-server] Reloading: {}", path.display());
-        match self.load_file(path) {
+        let result = self.load_file(path);
+        eprintln!("[dev-server] {}", format_reload_event(path, &result));
+        match &result {
             Ok(name) => {
+                self.reloads.fetch_add(1, Ordering::Relaxed);
                 // Notify via broadcast channel
                 let msg = serde_json::json!({
                     "type": "reload",
@@ -149,6 +196,7 @@ impl DevServerState {
                 let _ = self.reload_tx.send(msg);
             }
             Err(err) => {
+                self.reload_errors.fetch_add(1, Ordering::Relaxed);
                 // Notify error
                 let msg = serde_json::json!({
                     "type": "error",
@@ -159,6 +207,15 @@ impl DevServerState {
                 let _ = self.reload_tx.send(msg);
             }
         }
+        result
+    }
+}
+
+/// Format a reload outcome as a single `key=value` log line.
+fn format_reload_event(path: &Path, result: &Result<String, String>) -> String {
+    match result {
+        Ok(name) => format!("reload ok file={} component={}", path.display(), name),
+        Err(err) => format!("reload failed file={} error={:?}", path.display(), err),
     }
 }
 
@@ -166,6 +223,8 @@ impl DevServerState {
 struct HealthResponse {
     status: String,
     templates_loaded: usize,
+    reloads: u64,
+    reload_errors: u64,
 }
 
 #[derive(Serialize)]
@@ -180,9 +239,45 @@ async fn health_handler(State(state): State<Arc<DevServerState>>) -> impl IntoRe
     Json(HealthResponse {
         status: "ok".to_string(),
         templates_loaded: templates.len(),
+        reloads: state.reload_count(),
+        reload_errors: state.reload_error_count(),
     })
 }
 
+/// GET /views — names of all renderable views, including fragment functions.
+async fn views_handler(State(state): State<Arc<DevServerState>>) -> impl IntoResponse {
+    let templates = state.templates.lock().unwrap();
+    let mut views: Vec<String> = Vec::new();
+    for (name, cached) in templates.iter() {
+        views.push(name.clone());
+        for (fragment, _) in hudlc::ast::collect_fragments(&cached.root.nodes) {
+            views.push(hudlc::ast::fragment_function_name(name, &fragment));
+        }
+    }
+    views.sort();
+    Json(views)
+}
+
+/// GET /views/schema — Views with their data message, sorted by name
+async fn views_schema_handler(State(state): State<Arc<DevServerState>>) -> impl IntoResponse {
+    let templates = state.templates.lock().unwrap();
+    let mut views = Vec::new();
+    for (name, cached) in templates.iter() {
+        match hudlc::proto::view_schemas(name, &cached.root, &cached.schema) {
+            Ok(schemas) => views.extend(schemas),
+            Err(error) => {
+                return (
+                    StatusCode::INTERNAL_SERVER_ERROR,
+                    Json(RenderErrorResponse { error, file: Some(cached.file.clone()) }),
+                )
+                    .into_response();
+            }
+        }
+    }
+    views.sort_by(|a, b| a.name.cmp(&b.name));
+    Json(views).into_response()
+}
+
 /// GET /__hudl/live_reload — SSE for live reload notifications.
 async fn live_reload_handler(
     State(state): State<Arc<DevServerState>>,
@@ -206,6 +301,20 @@ async fn live_reload_handler(
     Sse::new(combined_stream)
 }
 
+/// Resolve a generated fragment function name (e.g. `DashboardClock`) to its
+/// view and fragment.
+fn find_fragment<'a>(
+    templates: &'a HashMap<String, CachedTemplate>,
+    component_name: &str,
+) -> Option<(&'a str, &'a CachedTemplate, Option<String>)> {
+    templates.iter().find_map(|(name, cached)| {
+        hudlc::ast::collect_fragments(&cached.root.nodes)
+            .into_iter()
+            .find(|(fragment, _)| hudlc::ast::fragment_function_name(name, fragment) == component_name)
+            .map(|(fragment, _)| (name.as_str(), cached, Some(fragment)))
+    })
+}
+
 /// POST /render — Wire-format render (used by Go runtime)
 async fn render_handler(
     State(state): State<Arc<DevServerState>>,
@@ -245,8 +354,12 @@ async fn render_handler(
 
     // Look up the cached template
     let templates = state.templates.lock().unwrap();
-    let cached = match templates.get(&component_name) {
-        Some(c) => c,
+    let target = templates
+        .get_key_value(&component_name)
+        .map(|(name, c)| (name.as_str(), c, None))
+        .or_else(|| find_fragment(&templates, &component_name));
+    let (owner, cached, fragment) = match target {
+        Some(t) => t,
         None => {
             return (
                 StatusCode::NOT_FOUND,
@@ -262,13 +375,97 @@ async fn render_handler(
     // Render the template
     let start = std::time::Instant::now();
     
+    // Source comments and accessible defaults are added per request so the
+    // cached ASTs stay clean; defaults depend on how views use each other
+    let source_comments = headers.contains_key("X-Hudl-Source-Comments");
+    let mut prepared: Vec<(String, hudlc::ast::Root)> = Vec::new();
+    if source_comments || state.semantic_defaults {
+        prepared = templates
+            .iter()
+            .map(|(name, c)| {
+                let root = if source_comments {
+                    hudlc::interpreter::with_source_comments(&c.root, &c.file)
+                } else {
+                    c.root.clone()
+                };
+                (name.clone(), root)
+            })
+            .collect();
+        if state.semantic_defaults {
+            hudlc::transformer::apply_semantic_defaults(&mut prepared);
+        }
+    }
+    let prepared: HashMap<String, hudlc::ast::Root> = prepared.into_iter().collect();
+    let root = prepared.get(owner).unwrap_or(&cached.root);
+
     // Build component map for the interpreter
     let mut components = HashMap::new();
     for (name, cached) in templates.iter() {
-        components.insert(name.clone(), &cached.root);
+        components.insert(name.clone(), prepared.get(name).unwrap_or(&cached.root));
     }
 
-    match hudlc::interpreter::render(&cached.root, &cached.schema, &body, &components) {
+    // Layout composition (RenderLayout) prefixes the body with the content
+    // slot's pre-rendered HTML, whose length is in X-Hudl-Slot-Length
+    let slot_len = headers
+        .get("X-Hudl-Slot-Length")
+        .and_then(|v| v.to_str().ok())
+        .and_then(|v| v.parse::<usize>().ok());
+    let (content_html, body) = match slot_len {
+        Some(n) if n <= body.len() => (
+            Some(String::from_utf8_lossy(&body[..n]).into_owned()),
+            body.slice(n..),
+        ),
+        Some(_) => {
+            return (
+                StatusCode::BAD_REQUEST,
+                Json(RenderErrorResponse {
+                    error: "X-Hudl-Slot-Length exceeds body length".to_string(),
+                    file: None,
+                }),
+            )
+                .into_response();
+        }
+        None => (None, body),
+    };
+
+    // JSON bodies (RenderDevJSON) skip proto decoding entirely
+    let is_json = headers
+        .get("Content-Type")
+        .and_then(|v| v.to_str().ok())
+        .map_or(false, |ct| ct.starts_with("application/json"));
+
+    let locale = headers
+        .get("X-Hudl-Locale")
+        .and_then(|v| v.to_str().ok())
+        .unwrap_or("");
+
+    let result = if is_json {
+        match serde_json::from_slice::<serde_json::Value>(&body) {
+            Ok(json) => hudlc::interpreter::render_with_values(
+                root,
+                &cached.schema,
+                hudlc::cel::json_to_cel(&json),
+                &components,
+                content_html.as_deref(),
+                locale,
+            ),
+            Err(e) => Err(hudlc::interpreter::RenderError {
+                message: format!("Invalid JSON body: {}", e),
+            }),
+        }
+    } else if let Some(fragment) = &fragment {
+        hudlc::interpreter::render_fragment(root, fragment, &cached.schema, &body, &components, locale)
+    } else {
+        hudlc::interpreter::render_with_content(root, &cached.schema, &body, &components, content_html.as_deref(), locale)
+    };
+
+    let csp_nonce = headers
+        .get("X-Hudl-CSP-Nonce")
+        .and_then(|v| v.to_str().ok())
+        .filter(|n| !n.is_empty())
+        .map(|n| n.to_string());
+
+    match result {
         Ok(mut html) => {
             let elapsed = start.elapsed();
             if state.verbose {
@@ -279,6 +476,19 @@ async fn render_handler(
                 );
             }
 
+            // Callers comparing output (e.g. VerifyConsistency) opt out of the reload script
+            if headers.contains_key("X-Hudl-No-Reload") {
+                if let Some(nonce) = &csp_nonce {
+                    html = add_csp_nonce(&html, nonce);
+                }
+                let mut response_headers = HeaderMap::new();
+                response_headers.insert(
+                    "Content-Type",
+                    "text/html; charset=utf-8".parse().unwrap(),
+                );
+                return (StatusCode::OK, response_headers, html).into_response();
+            }
+
             // Inject reload script using the LSP port
             let reload_script = format!(r#"
 <script>
@@ -310,6 +520,9 @@ async fn render_handler(
             } else {
                 html.push_str(&reload_script);
             }
+            if let Some(nonce) = &csp_nonce {
+                html = add_csp_nonce(&html, nonce);
+            }
 
             let mut response_headers = HeaderMap::new();
             response_headers.insert(
@@ -340,6 +553,43 @@ async fn render_handler(
     }
 }
 
+/// Add a CSP nonce attribute to every inline `<style>` and `<script>` tag,
+/// mirroring what compiled views do when the host sets a nonce.
+fn add_csp_nonce(html: &str, nonce: &str) -> String {
+    let attr = format!(
+        " nonce=\"{}\"",
+        nonce.replace('&', "&amp;").replace('"', "&quot;").replace('<', "&lt;")
+    );
+    let mut out = String::with_capacity(html.len());
+    let mut rest = html;
+    while let Some(pos) = rest.find('<') {
+        out.push_str(&rest[..pos]);
+        rest = &rest[pos..];
+        let tag = ["<style", "<script"].into_iter().find(|t| {
+            rest.len() > t.len()
+                && rest.as_bytes()[..t.len()].eq_ignore_ascii_case(t.as_bytes())
+                && matches!(rest.as_bytes()[t.len()], b'>' | b' ' | b'\t' | b'\n' | b'/')
+        });
+        match tag {
+            Some(t) => {
+                let end = rest.find('>').unwrap_or(rest.len());
+                let is_external = t == "<script" && rest[..end].to_ascii_lowercase().contains(" src=");
+                out.push_str(&rest[..t.len()]);
+                if !is_external {
+                    out.push_str(&attr);
+                }
+                rest = &rest[t.len()..];
+            }
+            None => {
+                out.push('<');
+                rest = &rest[1..];
+            }
+        }
+    }
+    out.push_str(rest);
+    out
+}
+
 /// Create the dev server router for the given state.
 pub fn create_router(state: Arc<DevServerState>) -> Router {
     let cors = CorsLayer::new()
@@ -350,6 +600,8 @@ pub fn create_router(state: Arc<DevServerState>) -> Router {
     Router::new()
         .route("/health", get(health_handler))
         .route("/render", post(render_handler))
+        .route("/views", get(views_handler))
+        .route("/views/schema", get(views_schema_handler))
         .route("/__hudl/live_reload", get(live_reload_handler))
         .layer(cors)
         .with_state(state)
@@ -361,12 +613,16 @@ pub fn create_router(state: Arc<DevServerState>) -> Router {
 /// * `port` - Port to listen on
 /// * `watch_dir` - Directory containing .hudl files to serve
 /// * `verbose` - Whether to enable detailed logging
+/// * `semantic_defaults` - Whether to fill in accessible defaults, as `hudlc --a11y-defaults` does
 pub async fn start(
     port: u16,
     watch_dir: PathBuf,
     verbose: bool,
+    semantic_defaults: bool,
 ) -> Result<(), Box<dyn std::error::Error>> {
-    let state = Arc::new(DevServerState::new(watch_dir.clone(), port, verbose));
+    let state = Arc::new(
+        DevServerState::new(watch_dir.clone(), port, verbose).with_semantic_defaults(semantic_defaults),
+    );
 
     // Load all templates initially
     state.load_all();
@@ -379,7 +635,7 @@ pub async fn start(
             if event.kind.is_modify() || event.kind.is_create() {
                 for path in &event.paths {
                     if path.extension().and_then(|e| e.to_str()) == Some("hudl") {
-                        watcher_state.reload_file(path);
+                        let _ = watcher_state.reload_file(path);
                     }
                 }
             }
@@ -465,6 +721,28 @@ el {{
         assert_eq!(state.templates_count(), 0);
     }
 
+    #[test]
+    fn test_load_file_checks_component_args() {
+        let dir = tempfile::tempdir().unwrap();
+        let card = write_hudl_file(
+            dir.path(),
+            "card.hudl",
+            "// name: Card\n// param: string label\nel {\n    div `label`\n}\n",
+        );
+        let page = write_hudl_file(
+            dir.path(),
+            "page.hudl",
+            "// name: Page\nel {\n    Card lable=\"x\"\n}\n",
+        );
+
+        let state = DevServerState::new(dir.path().to_path_buf(), 9999, false);
+        let _ = state.load_file(&card);
+        let err = state.load_file(&page).unwrap_err();
+
+        assert!(err.contains("Page:3:5: Card: missing required arg 'label'"), "{}", err);
+        assert!(!state.has_template("Page"));
+    }
+
     #[test]
     fn test_load_all_directory() {
         let dir = tempfile::tempdir().unwrap();
@@ -504,9 +782,11 @@ el {{
 
         // Overwrite with a new component name
         fs::write(&path, valid_template("CardV2")).unwrap();
-        state.reload_file(&path);
+        assert_eq!(state.reload_file(&path), Ok("CardV2".to_string()));
 
         assert!(state.has_template("CardV2"));
+        assert_eq!(state.reload_count(), 1);
+        assert_eq!(state.reload_error_count(), 0);
     }
 
     #[test]
@@ -520,10 +800,32 @@ el {{
 
         // Overwrite with broken content — original "Card" should remain
         fs::write(&path, "// name: Card\nbroken {{ syntax").unwrap();
-        state.reload_file(&path);
+        assert!(state.reload_file(&path).is_err());
 
         // The stale "Card" is preserved because parse failed
         assert!(state.has_template("Card"));
+        assert_eq!(state.reload_count(), 0);
+        assert_eq!(state.reload_error_count(), 1);
+    }
+
+    #[test]
+    fn test_format_reload_event() {
+        let path = Path::new("views/card.hudl");
+
+        let ok = format_reload_event(path, &Ok("Card".to_string()));
+        assert_eq!(ok, "reload ok file=views/card.hudl component=Card");
+
+        let failed = format_reload_event(path, &Err("Parse error".to_string()));
+        assert_eq!(failed, r#"reload failed file=views/card.hudl error="Parse error""#);
+    }
+
+    #[test]
+    fn test_add_csp_nonce() {
+        let html = r#"<style>.a{}</style><script>go()</script><script src="/app.js"></script><strong>x</strong>"#;
+        assert_eq!(
+            add_csp_nonce(html, "r4nd0m"),
+            r#"<style nonce="r4nd0m">.a{}</style><script nonce="r4nd0m">go()</script><script src="/app.js"></script><strong>x</strong>"#
+        );
     }
 
     #[test]
@@ -294,14 +294,18 @@ fn extract_cases(lines: &[&str], start_line: usize) -> (Vec<String>, bool) {
             let before = &line[..pos];
             if !before.contains("//") {
                 let after = &line[pos + 5..];
-                // Extract the pattern (until { or end of line)
-                // Handle both bare identifiers and backtick expressions
-                let pattern = if let Some(brace) = after.find('{') {
-                    after[..brace].trim().trim_matches('`').to_string()
-                } else {
-                    after.trim().trim_matches('`').to_string()
+                // Extract the patterns (until { or end of line)
+                // Handle both bare identifiers and backtick expressions;
+                // a case may list several identifiers
+                let patterns = match after.find('{') {
+                    Some(brace) => after[..brace].trim(),
+                    None => after.trim(),
                 };
-                cases.push(pattern);
+                if patterns.starts_with('`') {
+                    cases.push(patterns.trim_matches('`').to_string());
+                } else {
+                    cases.extend(patterns.split_whitespace().map(str::to_string));
+                }
             }
         }
 
@@ -364,6 +368,19 @@ switch `status` {
         assert!(!switches[0].has_default);
     }
 
+    #[test]
+    fn test_extract_switches_multiple_values() {
+        let content = r#"
+switch `status` {
+    case STATUS_ACTIVE STATUS_PENDING {
+        span "Open"
+    }
+}
+"#;
+        let switches = extract_switches(content);
+        assert_eq!(switches[0].cases, vec!["STATUS_ACTIVE", "STATUS_PENDING"]);
+    }
+
     #[test]
     fn test_extract_switches_with_default() {
         let content = r#"
@@ -154,10 +154,14 @@ impl Backend {
                     if let Some(info) = registry.get(name) {
                         // This is a known component!
                         
-                        // Check for missing required params
+                        // Check for missing required params; an `attrs` param
+                        // collects forwarded attributes and may be left out
                         let mut missing = Vec::new();
                         for param in &info.params {
-                            if !node.entries().iter().any(|p| p.name().map_or(false, |n| n.value() == param.name)) && param.default_value.is_none() {
+                            if param.name == "attrs" && param.type_name.starts_with("map<") {
+                                continue;
+                            }
+                            if !node.entries().iter().any(|p| p.name().map_or(false, |n| n.value() == param.name)) && param.default_value.is_none() && !param.optional {
                                 missing.push(param.name.clone());
                             }
                         }
@@ -244,12 +248,17 @@ impl Backend {
     ) -> Vec<Diagnostic> {
         let mut diagnostics = Vec::new();
         let backtick_re = Regex::new(r"`([^`]+)`").unwrap();
+        let client_expr_re = Regex::new(r#"\(js\)#?"(?:[^"\\]|\\.)*""#).unwrap();
 
         for (line_num, line) in content.lines().enumerate() {
             // Get the scope for this line
             let line_scope = scope::get_scope_for_line(line_scopes, line_num as u32);
 
-            for cap in backtick_re.captures_iter(line) {
+            // Backticks in (js) client expressions are JS template literals,
+            // not CEL; blank them out, keeping columns intact
+            let line = client_expr_re.replace_all(line, |c: &regex::Captures| " ".repeat(c[0].len()));
+
+            for cap in backtick_re.captures_iter(&line) {
                 let expr_str = &cap[1];
                 let match_start = cap.get(1).unwrap().start();
 
@@ -738,8 +747,9 @@ async fn main() {
             .unwrap_or_else(|| std::path::PathBuf::from("."));
 
         let verbose = args.iter().any(|a| a == "--verbose" || a == "-v");
+        let semantic_defaults = args.iter().any(|a| a == "--a11y-defaults");
 
-        if let Err(e) = dev_server::start(port, watch_dir, verbose).await {
+        if let Err(e) = dev_server::start(port, watch_dir, verbose, semantic_defaults).await {
             eprintln!("Dev server error: {}", e);
             std::process::exit(1);
         }
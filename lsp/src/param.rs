@@ -46,6 +46,7 @@ pub struct ParamDef {
     pub name: String,
     pub type_name: String,
     pub repeated: bool,
+    pub optional: bool,
     pub default_value: Option<String>,
 }
 
@@ -77,8 +78,8 @@ pub fn extract_metadata(content: &str) -> ViewMetadata {
 
     // Extract name from comments
     let name_re = Regex::new(r"//\s*name:\s*(\w+)").unwrap();
-    // param: [repeated] <type> <name> [default]
-    let param_re = Regex::new(r#"//\s*param:\s*(repeated\s+)?([\w.]+)\s+(\w+)(?:\s+(.*))?"#).unwrap();
+    // param: [repeated|optional] <type> <name> [default]
+    let param_re = Regex::new(r#"//\s*param:\s*(?:(repeated|optional)\s+)?(map\s*<\s*\w+\s*,\s*[\w.]+\s*>|[\w.]+)\s+(\w+)(?:\s+(.*))?"#).unwrap();
     let import_re = Regex::new(r"//\s*import:\s*(\w+)\s+(\S+)").unwrap();
 
     for line in content.lines() {
@@ -86,8 +87,8 @@ pub fn extract_metadata(content: &str) -> ViewMetadata {
             metadata.name = Some(caps[1].to_string());
         }
         if let Some(caps) = param_re.captures(line) {
-            let repeated = caps.get(1).is_some();
-            let type_name = caps[2].to_string();
+            let modifier = caps.get(1).map(|m| m.as_str());
+            let type_name: String = caps[2].split_whitespace().collect();
             let name = caps[3].to_string();
             let default_value = caps.get(4).map(|m| {
                 let s = m.as_str().trim();
@@ -101,7 +102,8 @@ pub fn extract_metadata(content: &str) -> ViewMetadata {
             metadata.params.push(ParamDef {
                 name,
                 type_name,
-                repeated,
+                repeated: modifier == Some("repeated"),
+                optional: modifier == Some("optional"),
                 default_value,
             });
         }
@@ -315,4 +317,15 @@ el {
         let metadata = extract_metadata(content);
         assert_eq!(metadata.params[0].type_name, "myapp.models.User");
     }
+
+    #[test]
+    fn test_map_param_type() {
+        let content = r#"
+// name: Button
+// param: map<string, string> attrs
+"#;
+        let metadata = extract_metadata(content);
+        assert_eq!(metadata.params[0].name, "attrs");
+        assert_eq!(metadata.params[0].type_name, "map<string,string>");
+    }
 }
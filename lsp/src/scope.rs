@@ -31,6 +31,8 @@ pub enum VarSource {
     EachLoop { line: u32 },
     /// From a CEL expression (list comprehension, etc.)
     CelLocal,
+    /// An enum constant like `STATUS_ACTIVE`, compared by number
+    EnumConstant,
 }
 
 /// A scope containing variables and nested child scopes
@@ -101,6 +103,20 @@ impl Default for Scope {
 pub fn build_root_scope(schema: &ProtoSchema, params: &[ParamDef]) -> Scope {
     let mut scope = Scope::new();
 
+    // Enum constants are usable in expressions; params of the same name win
+    for (enum_name, proto_enum) in &schema.enums {
+        for ev in &proto_enum.values {
+            scope.add_var(
+                ev.name.clone(),
+                VarInfo {
+                    proto_type: ProtoType::Enum(enum_name.clone()),
+                    repeated: false,
+                    source: VarSource::EnumConstant,
+                },
+            );
+        }
+    }
+
     for param in params {
         let mut field_type = ProtoSchema::parse_type(&param.type_name);
         
@@ -158,43 +174,62 @@ pub fn build_scopes_from_content(
 
         // Check if this is an `each` node
         if node_name == "__hudl_each" {
-            let entries: Vec<_> = node.entries().into_iter().collect();
-            if entries.len() >= 2 {
-                // First entry is the loop variable name
-                // Second entry is the collection expression
-                let var_name: Option<String> = entries[0].value().as_string()
-                    .map(|s| s.trim_matches('`').to_string());
-                let collection_expr: Option<String> = entries[1].value().as_string()
-                    .map(|s| s.trim_matches('`').to_string());
-
-                if let (Some(var_name), Some(collection_expr)) = (var_name, collection_expr) {
-                    // Create a child scope with the loop variable
-                    let mut child_scope = current_scope.child();
-
-                    // Try to determine the type of the loop variable
-                    // by looking up the collection and getting its element type
-                    let var_type = infer_loop_var_type(current_scope, &collection_expr, schema);
-
-                    child_scope.add_var(
-                        var_name,
-                        VarInfo {
-                            proto_type: var_type,
-                            repeated: false,
-                            source: VarSource::EachLoop { line: line_num },
-                        },
-                    );
-
-                    // Process children with the new scope
-                    if let Some(children) = node.children() {
-                        for child in children.nodes() {
-                            process_node(child, &child_scope, line_scopes, schema, content);
-                        }
+            let args: Vec<String> = node.entries().into_iter()
+                .filter(|e| e.name().is_none())
+                .filter_map(|e| e.value().as_string().map(|s| s.trim_matches('`').to_string()))
+                .collect();
+            // The loop variable name then the collection expression, or a
+            // map's key and value names then the map expression
+            let vars = match args.as_slice() {
+                [var_name, collection_expr] => Some((None, var_name, collection_expr)),
+                [key_name, var_name, collection_expr] => Some((Some(key_name), var_name, collection_expr)),
+                _ => None,
+            };
+            if let Some((key_name, var_name, collection_expr)) = vars {
+                // Create a child scope with the loop variables
+                let mut child_scope = current_scope.child();
+
+                // Try to determine the type of the loop variable
+                // by looking up the collection and getting its element type
+                let collection_type = infer_loop_var_type(current_scope, collection_expr, schema);
+                let var_type = match key_name {
+                    Some(key_name) => {
+                        let (key_type, value_type) = match collection_type {
+                            ProtoType::Map(key_type, value_type) => (*key_type, *value_type),
+                            _ => (ProtoType::String, ProtoType::String),
+                        };
+                        child_scope.add_var(
+                            key_name.clone(),
+                            VarInfo {
+                                proto_type: key_type,
+                                repeated: false,
+                                source: VarSource::EachLoop { line: line_num },
+                            },
+                        );
+                        value_type
+                    }
+                    None => collection_type,
+                };
+
+                child_scope.add_var(
+                    var_name.clone(),
+                    VarInfo {
+                        proto_type: var_type,
+                        repeated: false,
+                        source: VarSource::EachLoop { line: line_num },
+                    },
+                );
+
+                // Process children with the new scope
+                if let Some(children) = node.children() {
+                    for child in children.nodes() {
+                        process_node(child, &child_scope, line_scopes, schema, content);
                     }
-
-                    // Store the scope for lines within this node
-                    line_scopes.insert(line_num, child_scope);
-                    return;
                 }
+
+                // Store the scope for lines within this node
+                line_scopes.insert(line_num, child_scope);
+                return;
             }
         }
 
@@ -294,6 +329,30 @@ el {
         assert!(scope.contains("data"));
     }
 
+    #[test]
+    fn test_enum_constants_in_scope() {
+        let content = r#"/**
+enum Status {
+    STATUS_UNKNOWN = 0;
+    STATUS_ACTIVE = 1;
+}
+*/
+// param: Status status
+el {
+    if `status == STATUS_ACTIVE` { span "Active" }
+}
+"#;
+        let schema = ProtoSchema::from_template(content, None).unwrap();
+        let metadata = extract_metadata(content);
+        let scope = build_root_scope(&schema, &metadata.params);
+
+        let constant = scope.lookup("STATUS_ACTIVE").expect("enum constant should be in scope");
+        assert_eq!(constant.proto_type, ProtoType::Enum("Status".to_string()));
+        assert!(matches!(constant.source, VarSource::EnumConstant));
+        assert_eq!(scope.lookup("status").unwrap().proto_type, ProtoType::Enum("Status".to_string()));
+        assert!(!scope.contains("STATUS_INACTIVE"));
+    }
+
     #[test]
     fn test_each_scope() {
         let content = r#"/**
@@ -458,6 +517,33 @@ el {
         assert!(each_scope.contains("data")); // Still accessible
     }
 
+    #[test]
+    fn test_each_map_scope() {
+        let content = r#"/**
+message Item {
+    string name = 1;
+}
+
+message StockData {
+    map<string, Item> items = 1;
+}
+*/
+// param: StockData data
+el {
+    each sku item `data.items` {
+        div "`sku`: `item.name`"
+    }
+}
+"#;
+        let schema = ProtoSchema::from_template(content, None).unwrap();
+        let line_scopes = build_scopes_from_content(content, &schema);
+
+        // Inside each, the key and value get the map's key and value types
+        let each_scope = get_scope_for_line(&line_scopes, 13);
+        assert_eq!(each_scope.lookup("sku").unwrap().proto_type, ProtoType::String);
+        assert_eq!(each_scope.lookup("item").unwrap().proto_type, ProtoType::Message("Item".to_string()));
+    }
+
     #[test]
     fn test_nested_each_loops() {
         let content = r#"/**
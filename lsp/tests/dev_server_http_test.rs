@@ -73,6 +73,21 @@ async fn test_health_reflects_loaded() {
     assert!(body.contains(r#""templates_loaded":2"#));
 }
 
+#[tokio::test]
+async fn test_views_lists_loaded_templates() {
+    let (_dir, _state, router) =
+        setup_with_content(&[("b.hudl", &valid_template("Beta")), ("a.hudl", &valid_template("Alpha"))]);
+
+    let response = router
+        .oneshot(Request::get("/views").body(Body::empty()).unwrap())
+        .await
+        .unwrap();
+
+    assert_eq!(response.status(), StatusCode::OK);
+    let body = body_string(response.into_body()).await;
+    assert_eq!(body, r#"["Alpha","Beta"]"#);
+}
+
 #[tokio::test]
 async fn test_render_simple_component() {
     let (_dir, _state, router) = setup_with_content(&[("card.hudl", &valid_template("Card"))]);
@@ -227,7 +242,7 @@ el {
 }
 "#;
     fs::write(&hudl_path, v2_content).unwrap();
-    state.reload_file(&hudl_path);
+    let _ = state.reload_file(&hudl_path);
 
     let router = create_router(Arc::clone(&state));
     let response = router
@@ -245,7 +260,7 @@ el {
 
     // 3. Break the file — stale v2 should be preserved
     fs::write(&hudl_path, "// name: Card\nbroken {{ syntax").unwrap();
-    state.reload_file(&hudl_path);
+    let _ = state.reload_file(&hudl_path);
 
     let router = create_router(Arc::clone(&state));
     let response = router
@@ -268,7 +283,7 @@ el {
 }
 "#;
     fs::write(&hudl_path, v3_content).unwrap();
-    state.reload_file(&hudl_path);
+    let _ = state.reload_file(&hudl_path);
 
     let router = create_router(Arc::clone(&state));
     let response = router
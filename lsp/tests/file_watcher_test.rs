@@ -34,7 +34,7 @@ el {
     
     // Start dev server in background
     tokio::spawn(async move {
-        hudl_lsp::dev_server::start(port, watch_dir, true).await.unwrap();
+        hudl_lsp::dev_server::start(port, watch_dir, true, false).await.unwrap();
     });
 
     // Wait for server to start and load initial file
@@ -0,0 +1,42 @@
+package hudl
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// assetTag matches the start tags whose URLs AssetPrefix rewrites, and
+// assetAttr the one URL attribute each of them loads an asset from.
+var (
+	assetTag  = regexp.MustCompile(`(?i)<(?:link|script|img)\b[^>]*>`)
+	hrefAttr  = regexp.MustCompile(`(?i)(\shref=")(/[^/"][^"]*|/)"`)
+	srcAttr   = regexp.MustCompile(`(?i)(\ssrc=")(/[^/"][^"]*|/)"`)
+	assetAttr = map[string]*regexp.Regexp{"link": hrefAttr, "script": srcAttr, "img": srcAttr}
+)
+
+// prefixAssets prepends prefix to the root-relative URLs that <link href>,
+// <script src> and <img src> load. Other elements and attributes, absolute
+// URLs and protocol-relative ones (//host/...) are left alone.
+func prefixAssets(html, prefix string) string {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return assetTag.ReplaceAllStringFunc(html, func(tag string) string {
+		end := 1 + strings.IndexFunc(tag[1:], func(r rune) bool { return !unicode.IsLetter(r) })
+		attr := assetAttr[strings.ToLower(tag[1:end])]
+		return attr.ReplaceAllString(tag, `${1}`+strings.ReplaceAll(prefix, "$", "$$")+`${2}"`)
+	})
+}
+
+// assetPrefix rewrites asset URLs in each render's output.
+func assetPrefix(prefix string) RenderMiddleware {
+	return func(next RenderFunc) RenderFunc {
+		return func(ctx context.Context, viewName string, data []byte) (string, error) {
+			out, err := next(ctx, viewName, data)
+			if err != nil {
+				return "", err
+			}
+			return prefixAssets(out, prefix), nil
+		}
+	}
+}
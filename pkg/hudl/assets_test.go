@@ -0,0 +1,64 @@
+package hudl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefixAssets(t *testing.T) {
+	const prefix = "https://cdn.example.com/app/"
+
+	cases := map[string]string{
+		`<link rel="stylesheet" href="/style.css">`:          `<link rel="stylesheet" href="https://cdn.example.com/app/style.css">`,
+		`<script src="/datastar.js" type="module"></script>`: `<script src="https://cdn.example.com/app/datastar.js" type="module"></script>`,
+		`<IMG alt="" SRC="/img/logo.png" />`:                 `<IMG alt="" SRC="https://cdn.example.com/app/img/logo.png" />`,
+		// Navigation links and other attributes stay put
+		`<a href="/about">About</a>`:            `<a href="/about">About</a>`,
+		`<img src="/a.png" data-full="/b.png">`: `<img src="https://cdn.example.com/app/a.png" data-full="/b.png">`,
+		`<link rel="canonical" data-href="/x">`: `<link rel="canonical" data-href="/x">`,
+		`<p>See <code>src="/x.js"</code></p>`:   `<p>See <code>src="/x.js"</code></p>`,
+		// So do absolute, protocol-relative and relative URLs
+		`<script src="https://other.example/x.js"></script>`: `<script src="https://other.example/x.js"></script>`,
+		`<script src="//other.example/x.js"></script>`:       `<script src="//other.example/x.js"></script>`,
+		`<img src="logo.png">`:                               `<img src="logo.png">`,
+		`<linked href="/x">`:                                 `<linked href="/x">`,
+	}
+	for in, want := range cases {
+		assert.Equal(t, want, prefixAssets(in, prefix), in)
+	}
+}
+
+func TestAssetPrefix(t *testing.T) {
+	rt, err := NewRuntime(context.Background(), Options{
+		WASMBytes:   stubWASM(map[string]string{"Layout": `<link rel="stylesheet" href="/style.css"><a href="/">Home</a>`}),
+		AssetPrefix: "/preview",
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	html, err := rt.Render("Layout", nil)
+	require.NoError(t, err)
+	assert.Equal(t, `<link rel="stylesheet" href="/preview/style.css"><a href="/">Home</a>`, html)
+
+	// RenderSize measures the prefixed output Render returns
+	size, err := rt.RenderSize("Layout", nil)
+	require.NoError(t, err)
+	assert.Equal(t, len(html), size)
+}
+
+func TestAssetPrefix_RenderRaw(t *testing.T) {
+	// The -bytes wrappers render with RenderRaw, so it must be prefixed too
+	rt, err := NewRuntime(context.Background(), Options{
+		WASMBytes:   stubWASM(map[string]string{"Layout": `<link rel="stylesheet" href="/style.css">`}),
+		AssetPrefix: "/preview",
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	raw, err := rt.RenderRaw("Layout", nil)
+	require.NoError(t, err)
+	assert.Equal(t, `<link rel="stylesheet" href="/preview/style.css">`, string(raw))
+}
@@ -0,0 +1,63 @@
+package hudl
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// RenderN renders a view n times with the same data and returns the total
+// time the renders took, as a building block for benchmarking templates:
+//
+//	func BenchmarkDashboard(b *testing.B) {
+//		elapsed, err := rt.RenderN("Dashboard", data, b.N)
+//		if err != nil {
+//			b.Fatal(err)
+//		}
+//		b.ReportMetric(float64(elapsed.Nanoseconds())/float64(b.N), "ns/render")
+//	}
+//
+// Data is marshaled once, and in prod mode every render runs on the same
+// pooled instance, so the time is the view's own rather than the pool's.
+// Renders skip Options.Middleware, and their output is freed without being
+// copied out of WASM memory. RenderN stops at the first failed render.
+func (r *Runtime) RenderN(viewName string, data proto.Message, n int) (time.Duration, error) {
+	params, err := marshalData(data)
+	if err != nil {
+		return 0, err
+	}
+	return r.renderN(r.ctx, viewName, params, n)
+}
+
+func (r *Runtime) renderN(ctx context.Context, viewName string, protoBytes []byte, n int) (time.Duration, error) {
+	if r.devMode {
+		start := time.Now()
+		for i := range n {
+			if _, err := r.renderDev(ctx, viewName, protoBytes); err != nil {
+				return time.Since(start), fmt.Errorf("render %d of %d: %w", i+1, n, err)
+			}
+		}
+		return time.Since(start), nil
+	}
+	if sub, name, ok := r.mounted(viewName); ok {
+		return sub.renderN(ctx, name, protoBytes, n)
+	}
+
+	inst, err := r.acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer r.release(inst)
+
+	start := time.Now()
+	for i := range n {
+		ptr, size, err := r.callView(ctx, inst, viewName, protoBytes)
+		if err != nil {
+			return time.Since(start), fmt.Errorf("render %d of %d: %w", i+1, n, err)
+		}
+		inst.free.Call(r.ctx, uint64(ptr), uint64(size))
+	}
+	return time.Since(start), nil
+}
@@ -0,0 +1,33 @@
+package hudl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderN(t *testing.T) {
+	rt, err := NewRuntime(context.Background(), Options{
+		WASMBytes: stubModule{
+			views:  map[string]string{"Home": "<p>home</p>"},
+			panics: map[string]string{"Broken": "boom"},
+		}.build(),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	elapsed, err := rt.RenderN("Home", nil, 50)
+	require.NoError(t, err)
+	assert.Positive(t, elapsed)
+	// All the renders shared one instance
+	assert.Equal(t, 1, rt.Stats().Instances)
+
+	_, err = rt.RenderN("Broken", nil, 3)
+	assert.ErrorContains(t, err, "render 1 of 3")
+	assert.ErrorContains(t, err, "boom")
+
+	_, err = rt.RenderN("Missing", nil, 3)
+	assert.ErrorContains(t, err, "view function Missing not found")
+}
@@ -0,0 +1,81 @@
+package hudl
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ConsistencyError reports a view whose dev server output differs from its
+// compiled WASM output.
+type ConsistencyError struct {
+	View string
+	Dev  string
+	WASM string
+	// Offset is the byte offset of the first difference.
+	Offset int
+}
+
+func (e *ConsistencyError) Error() string {
+	return fmt.Sprintf("view %s: dev and WASM output diverge at byte %d\n  dev:  %q\n  wasm: %q",
+		e.View, e.Offset, excerpt(e.Dev, e.Offset), excerpt(e.WASM, e.Offset))
+}
+
+// VerifyConsistency renders a view through both the dev server (interpreter)
+// and the compiled WASM module and returns a *ConsistencyError if the output
+// differs. It is intended for CI, to catch compiler/interpreter drift.
+//
+// The runtime must be in dev mode and created with WASMBytes.
+func (r *Runtime) VerifyConsistency(viewName string, data proto.Message) error {
+	if !r.devMode || r.pool == nil {
+		return fmt.Errorf("VerifyConsistency requires dev mode with WASMBytes set")
+	}
+
+	var params []byte
+	if data != nil {
+		var err error
+		params, err = proto.Marshal(data)
+		if err != nil {
+			return fmt.Errorf("failed to marshal data to proto: %w", err)
+		}
+	}
+
+	devOut, err := r.postDev(r.ctx, viewName, "application/x-protobuf", params, false, false)
+	if err != nil {
+		return err
+	}
+	wasmOut, err := r.renderWASM(r.ctx, viewName, params)
+	if err != nil {
+		return err
+	}
+
+	if devOut == wasmOut {
+		return nil
+	}
+	return &ConsistencyError{
+		View:   viewName,
+		Dev:    devOut,
+		WASM:   wasmOut,
+		Offset: firstDifference(devOut, wasmOut),
+	}
+}
+
+func firstDifference(a, b string) int {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
+
+// excerpt returns up to 40 bytes of context around offset.
+func excerpt(s string, offset int) string {
+	start := max(offset-20, 0)
+	end := min(offset+20, len(s))
+	if start > len(s) {
+		return ""
+	}
+	return s[start:end]
+}
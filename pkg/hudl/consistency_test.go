@@ -0,0 +1,61 @@
+package hudl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newConsistencyRuntime(t *testing.T, devOutput string, views map[string]string) *Runtime {
+	t.Helper()
+
+	srv := newDevServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "1", r.Header.Get("X-Hudl-No-Reload"))
+		fmt.Fprint(w, devOutput)
+	})
+
+	rt, err := NewRuntime(context.Background(), Options{
+		DevMode:       true,
+		DevServerAddr: strings.TrimPrefix(srv.URL, "http://"),
+		WASMBytes:     stubWASM(views),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { rt.Close() })
+	return rt
+}
+
+func TestVerifyConsistency_Match(t *testing.T) {
+	rt := newConsistencyRuntime(t, "<p>Hello</p>", map[string]string{"Card": "<p>Hello</p>"})
+
+	assert.NoError(t, rt.VerifyConsistency("Card", nil))
+}
+
+func TestVerifyConsistency_Divergence(t *testing.T) {
+	rt := newConsistencyRuntime(t, "<p class=\"a\">Hello</p>", map[string]string{"Card": "<p class=\"b\">Hello</p>"})
+
+	err := rt.VerifyConsistency("Card", nil)
+	require.Error(t, err)
+
+	var ce *ConsistencyError
+	require.True(t, errors.As(err, &ce))
+	assert.Equal(t, "Card", ce.View)
+	assert.Equal(t, 10, ce.Offset)
+	assert.Equal(t, "<p class=\"a\">Hello</p>", ce.Dev)
+	assert.Equal(t, "<p class=\"b\">Hello</p>", ce.WASM)
+	assert.Contains(t, err.Error(), "diverge at byte 10")
+}
+
+func TestVerifyConsistency_RequiresWASM(t *testing.T) {
+	rt, err := NewRuntime(context.Background(), Options{DevMode: true})
+	require.NoError(t, err)
+
+	err = rt.VerifyConsistency("Card", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "WASMBytes")
+}
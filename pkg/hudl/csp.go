@@ -0,0 +1,50 @@
+package hudl
+
+import (
+	"context"
+	"fmt"
+)
+
+type cspNonceKey struct{}
+
+// WithCSPNonce returns a context whose renders add nonce to the inline
+// <style> and <script> tags they emit, for apps whose Content-Security-Policy
+// allows inline content by nonce. Pass the result to RenderContext.
+func WithCSPNonce(ctx context.Context, nonce string) context.Context {
+	return context.WithValue(ctx, cspNonceKey{}, nonce)
+}
+
+// cspNonce returns the nonce set by WithCSPNonce, or "".
+func cspNonce(ctx context.Context) string {
+	nonce, _ := ctx.Value(cspNonceKey{}).(string)
+	return nonce
+}
+
+// setNonce passes the render's CSP nonce to the instance via hudl_set_nonce.
+// Instances keep the last nonce, so it is only sent when it changes. Modules
+// built before nonce support don't export hudl_set_nonce and are left as is.
+func (r *Runtime) setNonce(ctx context.Context, inst *instance, nonce string) error {
+	if inst.setNonce == nil || inst.nonce == nonce {
+		return nil
+	}
+
+	ptr := uint64(0)
+	if nonce != "" {
+		var err error
+		ptr, err = r.malloc(ctx, inst, len(nonce))
+		if err != nil {
+			return err
+		}
+		defer inst.free.Call(r.ctx, ptr, uint64(len(nonce)))
+		if err := writeMemory(inst, uint32(ptr), []byte(nonce)); err != nil {
+			return err
+		}
+	}
+
+	if _, err := inst.setNonce.Call(ctx, ptr, uint64(len(nonce))); err != nil {
+		inst.broken = true
+		return fmt.Errorf("hudl_set_nonce failed: %w", err)
+	}
+	inst.nonce = nonce
+	return nil
+}
@@ -0,0 +1,45 @@
+package hudl
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderContext_CSPNonce(t *testing.T) {
+	rt, err := NewRuntime(context.Background(), Options{
+		WASMBytes: stubModule{nonceView: "Nonce"}.build(),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	out, err := rt.RenderContext(WithCSPNonce(context.Background(), "r4nd0m"), "Nonce", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "r4nd0m", out)
+
+	// The instance is reused; a render without a nonce clears it
+	out, err = rt.RenderContext(context.Background(), "Nonce", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "", out)
+}
+
+func TestRenderContext_CSPNonceDevHeader(t *testing.T) {
+	srv := newDevServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<style nonce="` + r.Header.Get("X-Hudl-CSP-Nonce") + `">.a{}</style>`))
+	})
+
+	rt, err := NewRuntime(context.Background(), Options{
+		DevMode:       true,
+		DevServerAddr: strings.TrimPrefix(srv.URL, "http://"),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	out, err := rt.RenderContext(WithCSPNonce(context.Background(), "r4nd0m"), "Card", nil)
+	require.NoError(t, err)
+	assert.Equal(t, `<style nonce="r4nd0m">.a{}</style>`, out)
+}
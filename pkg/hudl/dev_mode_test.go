@@ -2,11 +2,16 @@ package hudl
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -73,7 +78,7 @@ el {
 	defer os.Unsetenv("HUDL_DEV")
 	defer os.Unsetenv("HUDL_DEV_ADDR")
 
-	rt, err := NewRuntime(context.Background(), Options{})
+	rt, err := NewRuntime(context.Background(), Options{WatchReloads: true})
 	require.NoError(t, err)
 	require.NotNil(t, rt)
 
@@ -82,6 +87,14 @@ el {
 	require.NoError(t, err)
 	assert.Contains(t, html, "v1 from dev mode")
 
+	// The edit below must happen while the reload stream is connected, or
+	// its reload event is never seen
+	select {
+	case <-rt.watching:
+	case <-time.After(5 * time.Second):
+		t.Fatal("live reload stream failed to connect in time")
+	}
+
 	// 7. Update file and trigger hot-reload (simulated)
 	// The dev server should detect the change via notify
 	contentV2 := `// name: Card
@@ -92,10 +105,152 @@ el {
 	err = os.WriteFile(hudlFile, []byte(contentV2), 0644)
 	require.NoError(t, err)
 
-	// Wait for reload
-	time.Sleep(500 * time.Millisecond)
+	// Wait for the dev server to report the reload
+	require.Eventually(t, func() bool { return rt.Stats().Reloads > 0 }, 5*time.Second, 50*time.Millisecond)
 
 	html, err = rt.Render("Card", nil)
 	require.NoError(t, err)
 	assert.Contains(t, html, "v2 updated")
 }
+
+// newDevServer starts a fake dev server that serves /render with render.
+// Other endpoints, such as the live reload stream, return 404.
+func newDevServer(t *testing.T, render http.HandlerFunc) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /render", render)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRenderDevJSON(t *testing.T) {
+	srv := newDevServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "ContactForm", r.Header.Get("X-Hudl-Component"))
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"name": "Ann", "errors": ["email required"]}`, string(body))
+		fmt.Fprint(w, "<form>Ann</form>")
+	})
+
+	rt, err := NewRuntime(context.Background(), Options{
+		DevMode:       true,
+		DevServerAddr: strings.TrimPrefix(srv.URL, "http://"),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	html, err := rt.RenderDevJSON("ContactForm", []byte(`{"name": "Ann", "errors": ["email required"]}`))
+	require.NoError(t, err)
+	assert.Equal(t, "<form>Ann</form>", html)
+}
+
+func TestRenderDevJSON_RenderError(t *testing.T) {
+	srv := newDevServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error": "Invalid JSON body: expected value", "file": ""}`)
+	})
+
+	rt, err := NewRuntime(context.Background(), Options{
+		DevMode:       true,
+		DevServerAddr: strings.TrimPrefix(srv.URL, "http://"),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	_, err = rt.RenderDevJSON("ContactForm", []byte(`{not json`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid JSON body")
+}
+
+func TestRenderDevJSON_ProdMode(t *testing.T) {
+	rt := &Runtime{}
+	_, err := rt.RenderDevJSON("ContactForm", []byte(`{}`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "only available in dev mode")
+}
+
+func TestRenderDev_LocatedError(t *testing.T) {
+	srv := newDevServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, `{"error": "no such field: user.nmae", "file": "views/card.hudl", "line": 12, "column": 9}`)
+	})
+
+	rt, err := NewRuntime(context.Background(), Options{
+		DevMode:       true,
+		DevServerAddr: strings.TrimPrefix(srv.URL, "http://"),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	_, err = rt.Render("Card", nil)
+	require.Error(t, err)
+	assert.Equal(t, "dev mode: render error at views/card.hudl:12:9: no such field: user.nmae", err.Error())
+}
+
+func TestRenderDev_StatusError(t *testing.T) {
+	srv := newDevServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Hudl-Component") == "Missing" {
+			http.Error(w, "component not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, `{"error": "no such field: user.nmae"}`)
+	})
+
+	rt, err := NewRuntime(context.Background(), Options{
+		DevMode:       true,
+		DevServerAddr: strings.TrimPrefix(srv.URL, "http://"),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	_, err = rt.Render("Missing", nil)
+	var statusErr *ErrDevStatus
+	require.True(t, errors.As(err, &statusErr), "got %v", err)
+	assert.Equal(t, http.StatusNotFound, statusErr.Code)
+	assert.Equal(t, "component not found\n", statusErr.Body)
+	assert.Empty(t, statusErr.Message)
+
+	_, err = rt.Render("Card", nil)
+	require.True(t, errors.As(err, &statusErr), "got %v", err)
+	assert.Equal(t, http.StatusInternalServerError, statusErr.Code)
+	assert.Equal(t, "no such field: user.nmae", statusErr.Message)
+	assert.Equal(t, "dev mode: render error: no such field: user.nmae", err.Error())
+}
+
+func TestDevErrorLocation(t *testing.T) {
+	assert.Equal(t, "", devErrorLocation("", 3, 4))
+	assert.Equal(t, "views/card.hudl", devErrorLocation("views/card.hudl", 0, 0))
+	assert.Equal(t, "views/card.hudl:3", devErrorLocation("views/card.hudl", 3, 0))
+	assert.Equal(t, "views/card.hudl:3:4", devErrorLocation("views/card.hudl", 3, 4))
+}
+
+func TestRenderDev_SourceComments(t *testing.T) {
+	srv := newDevServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Hudl-Source-Comments") == "1" {
+			w.Write([]byte(`<!-- card.hudl:4 --><div class="card"></div>`))
+			return
+		}
+		w.Write([]byte(`<div class="card"></div>`))
+	})
+	addr := strings.TrimPrefix(srv.URL, "http://")
+
+	rt, err := NewRuntime(context.Background(), Options{DevMode: true, DevServerAddr: addr, SourceComments: true})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	out, err := rt.Render("Card", nil)
+	require.NoError(t, err)
+	assert.Equal(t, `<!-- card.hudl:4 --><div class="card"></div>`, out)
+
+	// Off by default
+	plain, err := NewRuntime(context.Background(), Options{DevMode: true, DevServerAddr: addr})
+	require.NoError(t, err)
+	defer plain.Close()
+
+	out, err = plain.Render("Card", nil)
+	require.NoError(t, err)
+	assert.Equal(t, `<div class="card"></div>`, out)
+}
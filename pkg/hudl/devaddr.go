@@ -0,0 +1,25 @@
+package hudl
+
+import "os"
+
+// SetDevAddr points subsequent dev mode requests at addr, for when the dev
+// server restarts on another port. Renders already in flight finish against
+// the old address. It has no effect in prod mode.
+func (r *Runtime) SetDevAddr(addr string) {
+	r.devAddrMu.Lock()
+	r.devAddr = addr
+	r.devAddrMu.Unlock()
+}
+
+// devServerAddr is the dev server address for the next request, updated
+// from HUDL_DEV_ADDR first when Options.DevAddrFromEnv is set.
+func (r *Runtime) devServerAddr() string {
+	if r.devAddrFromEnv {
+		if addr := os.Getenv("HUDL_DEV_ADDR"); addr != "" {
+			r.SetDevAddr(addr)
+		}
+	}
+	r.devAddrMu.RLock()
+	defer r.devAddrMu.RUnlock()
+	return r.devAddr
+}
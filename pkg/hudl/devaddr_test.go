@@ -0,0 +1,69 @@
+package hudl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func namedDevServer(t *testing.T, name string) string {
+	srv := newDevServer(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "<p>%s</p>", name)
+	})
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
+func TestSetDevAddr(t *testing.T) {
+	first, second := namedDevServer(t, "first"), namedDevServer(t, "second")
+
+	rt, err := NewRuntime(context.Background(), Options{DevMode: true, DevServerAddr: first})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	html, err := rt.Render("Home", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<p>first</p>", html)
+
+	rt.SetDevAddr(second)
+	html, err = rt.Render("Home", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<p>second</p>", html)
+}
+
+func TestDevAddrFromEnv(t *testing.T) {
+	first, second := namedDevServer(t, "first"), namedDevServer(t, "second")
+	t.Setenv("HUDL_DEV_ADDR", first)
+
+	rt, err := NewRuntime(context.Background(), Options{DevMode: true, DevAddrFromEnv: true})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	html, err := rt.Render("Home", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<p>first</p>", html)
+
+	t.Setenv("HUDL_DEV_ADDR", second)
+	html, err = rt.Render("Home", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<p>second</p>", html)
+}
+
+func TestDevOptionsOverrideEnv(t *testing.T) {
+	first, second := namedDevServer(t, "first"), namedDevServer(t, "second")
+	t.Setenv("HUDL_DEV", "1")
+	t.Setenv("HUDL_DEV_ADDR", first)
+
+	// HUDL_DEV turns dev mode on, but DevServerAddr wins over HUDL_DEV_ADDR
+	rt, err := NewRuntime(context.Background(), Options{DevServerAddr: second})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	html, err := rt.Render("Home", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<p>second</p>", html)
+}
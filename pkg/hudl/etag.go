@@ -0,0 +1,25 @@
+package hudl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// RenderWithETag renders a view like Render and also returns a strong ETag
+// for the output, so a handler can answer If-None-Match with 304 Not
+// Modified. The ETag is the quoted hex of the first 16 bytes of the
+// output's SHA-256: the same HTML always gets the same ETag.
+func (r *Runtime) RenderWithETag(viewName string, data proto.Message) (html, etag string, err error) {
+	html, err = r.Render(viewName, data)
+	if err != nil {
+		return "", "", err
+	}
+	return html, etagOf(html), nil
+}
+
+func etagOf(html string) string {
+	sum := sha256.Sum256([]byte(html))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
@@ -0,0 +1,51 @@
+package hudl
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestRenderWithETag(t *testing.T) {
+	// A dev server that renders the data it's sent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Hudl-Component") != "Greeting" {
+			http.Error(w, `{"error":"no such view"}`, http.StatusNotFound)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Write(append([]byte("<p>"), append(body, "</p>"...)...))
+	}))
+	defer srv.Close()
+
+	rt, err := NewRuntime(context.Background(), Options{
+		DevMode:       true,
+		DevServerAddr: strings.TrimPrefix(srv.URL, "http://"),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	html, etag, err := rt.RenderWithETag("Greeting", wrapperspb.String("Ann"))
+	require.NoError(t, err)
+	assert.Contains(t, html, "Ann")
+	assert.Regexp(t, `^"[0-9a-f]{32}"$`, etag)
+
+	_, same, err := rt.RenderWithETag("Greeting", wrapperspb.String("Ann"))
+	require.NoError(t, err)
+	assert.Equal(t, etag, same)
+
+	_, other, err := rt.RenderWithETag("Greeting", wrapperspb.String("Bob"))
+	require.NoError(t, err)
+	assert.NotEqual(t, etag, other)
+
+	_, etag, err = rt.RenderWithETag("Missing", nil)
+	assert.Error(t, err)
+	assert.Empty(t, etag)
+}
@@ -0,0 +1,79 @@
+package hudl
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/tetratelabs/wazero/api"
+	"google.golang.org/protobuf/proto"
+)
+
+// flushTarget is where a streamed render writes its output.
+type flushTarget struct {
+	w io.Writer
+	// start, if set, runs before the first write, e.g. to set headers.
+	start func()
+	wrote bool
+	err   error
+}
+
+type flushTargetKey struct{}
+
+// write writes b to w, flushing w afterwards if flush is set and w is an
+// http.Flusher. After a failed write, further output is dropped.
+func (t *flushTarget) write(b []byte, flush bool) {
+	if t.err != nil {
+		return
+	}
+	if !t.wrote && t.start != nil {
+		t.start()
+	}
+	t.wrote = true
+	if _, err := t.w.Write(b); err != nil {
+		t.err = err
+		return
+	}
+	if f, ok := t.w.(http.Flusher); ok && flush {
+		f.Flush()
+	}
+}
+
+// hostFlush implements the hudl.flush import, which views call at a `flush`
+// node with the output rendered so far. If the render is streamed it writes
+// the output to the render's flushTarget and returns 1, so the view drops
+// it; otherwise it returns 0 and the view keeps it.
+func hostFlush(ctx context.Context, m api.Module, ptr, size uint32) uint32 {
+	t, _ := ctx.Value(flushTargetKey{}).(*flushTarget)
+	if t == nil {
+		return 0
+	}
+	out, ok := m.Memory().Read(ptr, size)
+	if !ok {
+		return 0
+	}
+	t.write(out, true)
+	return 1
+}
+
+// renderStreaming renders a view to w. In prod mode without middleware, the
+// output up to each of the view's flush points is written (and w flushed) as
+// the view reaches it, while the rest is still rendering. Otherwise the
+// output is written once the view has rendered, since middleware needs all
+// of it. start runs before the first write. It reports whether anything was
+// written, as a render that fails after a flush point has already sent
+// part of the page.
+func (r *Runtime) renderStreaming(w io.Writer, viewName string, data proto.Message, start func()) (bool, error) {
+	t := &flushTarget{w: w, start: start}
+	ctx := r.ctx
+	if r.render == nil && !r.devMode {
+		ctx = context.WithValue(ctx, flushTargetKey{}, t)
+	}
+
+	out, err := r.RenderContext(ctx, viewName, data)
+	if err != nil {
+		return t.wrote, err
+	}
+	t.write([]byte(out), false)
+	return t.wrote, t.err
+}
@@ -0,0 +1,73 @@
+package hudl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ErrNoErrorField is returned by FormRenderer when a field error has no
+// matching string field on the form message.
+var ErrNoErrorField = errors.New("hudl: form message has no error field")
+
+// FormRenderer re-renders a form after a failed submission. The form's view
+// data carries an error message per input by convention: for an input
+// field username, a string field username_error (UsernameError in Go).
+type FormRenderer struct {
+	rt *Runtime
+}
+
+// NewFormRenderer returns a FormRenderer that renders with rt.
+func NewFormRenderer(rt *Runtime) *FormRenderer {
+	return &FormRenderer{rt: rt}
+}
+
+// Render renders viewName with form and the validation errors in fieldErrors,
+// which map an input's field name to its message, e.g.
+// {"email": "Email is already registered"}. Each error is set on the
+// matching <name>_error field of a copy of form; form itself is unchanged.
+// Like Runtime.Render, it renders with the runtime's context.
+func (f *FormRenderer) Render(viewName string, form proto.Message, fieldErrors map[string]string) (string, error) {
+	return f.RenderContext(f.rt.ctx, viewName, form, fieldErrors)
+}
+
+// RenderContext is Render with a context.
+func (f *FormRenderer) RenderContext(ctx context.Context, viewName string, form proto.Message, fieldErrors map[string]string) (string, error) {
+	data, err := withFieldErrors(form, fieldErrors)
+	if err != nil {
+		return "", err
+	}
+	return f.rt.RenderContext(ctx, viewName, data)
+}
+
+// withFieldErrors returns a copy of form with fieldErrors set on its error
+// fields, or form itself if there are none.
+func withFieldErrors(form proto.Message, fieldErrors map[string]string) (proto.Message, error) {
+	if len(fieldErrors) == 0 {
+		return form, nil
+	}
+	merged := proto.Clone(form)
+	msg := merged.ProtoReflect()
+	fields := msg.Descriptor().Fields()
+
+	// Sorted, so a message with several bad fields always reports the same one
+	names := make([]string, 0, len(fieldErrors))
+	for name := range fieldErrors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fd := fields.ByName(protoreflect.Name(name + "_error"))
+		if fd == nil || fd.Kind() != protoreflect.StringKind || fd.IsList() {
+			return nil, fmt.Errorf("%w: %s has no string field %s_error for %q",
+				ErrNoErrorField, msg.Descriptor().FullName(), name, name)
+		}
+		msg.Set(fd, protoreflect.ValueOfString(fieldErrors[name]))
+	}
+	return merged, nil
+}
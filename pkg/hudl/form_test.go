@@ -0,0 +1,50 @@
+package hudl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/njreid/hudl/pkg/hudl/pb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestFormRenderer_MergesFieldErrors(t *testing.T) {
+	rt, err := NewRuntime(context.Background(), Options{WASMBytes: stubWASM(nil)})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	form := &pb.RegistrationFormData{Username: "ann", Email: "ann@example.com", CsrfToken: "tok"}
+	forms := NewFormRenderer(rt)
+
+	// Echo returns the serialized data, so this shows what the view got
+	out, err := forms.Render("Echo", form, map[string]string{
+		"email":    "Email is already registered",
+		"password": "Password is too short",
+	})
+	require.NoError(t, err)
+	var got pb.RegistrationFormData
+	require.NoError(t, proto.Unmarshal([]byte(out), &got))
+	want := &pb.RegistrationFormData{
+		Username:      "ann",
+		Email:         "ann@example.com",
+		CsrfToken:     "tok",
+		EmailError:    "Email is already registered",
+		PasswordError: "Password is too short",
+	}
+	assert.True(t, proto.Equal(want, &got), "got %v", &got)
+
+	// The caller's message is left as it was
+	assert.Empty(t, form.EmailError)
+}
+
+func TestFormRenderer_UnknownField(t *testing.T) {
+	rt, err := NewRuntime(context.Background(), Options{WASMBytes: stubWASM(nil)})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	_, err = NewFormRenderer(rt).Render("Echo", &pb.RegistrationFormData{}, map[string]string{"nickname": "Too long"})
+	assert.ErrorIs(t, err, ErrNoErrorField)
+	assert.Contains(t, err.Error(), "nickname_error")
+}
@@ -0,0 +1,95 @@
+package hudl
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// RenderJSONBytes renders a view from its data as JSON, for callers with no
+// Go type for it, such as a handler passing on a request body from another
+// service. In prod mode the JSON is decoded with protojson into the view's
+// message, which is found by name (from ViewsWithSchema) among the
+// registered proto types, so the generated Go package for it must be linked
+// in. In dev mode the JSON is posted to the dev server as is.
+func (r *Runtime) RenderJSONBytes(viewName string, jsonBytes []byte) (string, error) {
+	if r.devMode {
+		return r.postDev(r.ctx, viewName, "application/json", jsonBytes, true, r.sourceComments)
+	}
+	protoBytes, err := r.jsonToProto(viewName, jsonBytes)
+	if err != nil {
+		return "", err
+	}
+	return r.RenderBytes(viewName, protoBytes)
+}
+
+// RenderProtoJSON renders a view from data in protojson, the canonical JSON
+// mapping of proto3 (lowerCamelCase field names, enums by name, 64-bit
+// integers as strings, Timestamps in RFC 3339), as produced by backends that
+// already speak it. Unlike RenderJSONBytes it converts the data in Go in dev
+// mode too, so the view's message must always be registered.
+func (r *Runtime) RenderProtoJSON(viewName string, protojsonBody []byte) (string, error) {
+	protoBytes, err := r.jsonToProto(viewName, protojsonBody)
+	if err != nil {
+		return "", err
+	}
+	return r.RenderBytes(viewName, protoBytes)
+}
+
+// jsonToProto converts JSON view data to the wire format of the view's
+// message. A view without params takes no data.
+func (r *Runtime) jsonToProto(viewName string, jsonBytes []byte) ([]byte, error) {
+	schemas := r
+	if sub, name, ok := r.mounted(viewName); ok {
+		schemas, viewName = sub, name
+	}
+	views, err := schemas.ViewsWithSchema()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range views {
+		if v.Name != viewName {
+			continue
+		}
+		if v.MessageType == "" {
+			return nil, nil
+		}
+		mt, err := findMessageType(v.MessageType)
+		if err != nil {
+			return nil, err
+		}
+		msg := mt.New().Interface()
+		if err := protojson.Unmarshal(jsonBytes, msg); err != nil {
+			return nil, fmt.Errorf("invalid JSON for view %s (%s): %w", viewName, v.MessageType, err)
+		}
+		return marshalData(msg)
+	}
+	return nil, fmt.Errorf("view %s not found", viewName)
+}
+
+// findMessageType looks up a message in the global registry by full name,
+// or by its short name if exactly one registered message has it, since
+// templates declare messages without their proto package.
+func findMessageType(name string) (protoreflect.MessageType, error) {
+	if mt, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(name)); err == nil {
+		return mt, nil
+	}
+	var matches []protoreflect.MessageType
+	protoregistry.GlobalTypes.RangeMessages(func(mt protoreflect.MessageType) bool {
+		if string(mt.Descriptor().Name()) == name {
+			matches = append(matches, mt)
+		}
+		return true
+	})
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("message %s is not registered; import its generated Go package", name)
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, fmt.Errorf("message %s is ambiguous: %s and %s are both registered",
+			name, matches[0].Descriptor().FullName(), matches[1].Descriptor().FullName())
+	}
+}
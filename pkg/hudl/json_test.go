@@ -0,0 +1,119 @@
+package hudl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/njreid/hudl/pkg/hudl/pb"
+)
+
+func TestRenderJSONBytes(t *testing.T) {
+	wasmBytes, err := os.ReadFile("../../views.wasm")
+	if err != nil {
+		t.Skip("views.wasm not found, skipping runtime test")
+	}
+
+	rt, err := NewRuntimeFromWASM(context.Background(), wasmBytes)
+	require.NoError(t, err)
+	defer rt.Close()
+
+	html, err := rt.RenderJSONBytes("Simple", []byte(`{"title": "Hello from JSON", "features": ["Portability"]}`))
+	require.NoError(t, err)
+	assert.Contains(t, html, "Hello from JSON")
+	assert.Contains(t, html, "Portability")
+}
+
+func TestRenderJSONBytes_Conversion(t *testing.T) {
+	rt, err := NewRuntime(context.Background(), Options{
+		WASMBytes: stubModule{
+			views: map[string]string{"Simple": "<p>simple</p>", "Footer": "<footer></footer>", "Orphan": "<p></p>"},
+			custom: map[string]string{viewsSection: `[
+				{"name":"Simple","message":"SimpleData"},
+				{"name":"Footer","message":""},
+				{"name":"Orphan","message":"NoSuchData"}
+			]`},
+		}.build(),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	html, err := rt.RenderJSONBytes("Simple", []byte(`{"title": "Hi"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "<p>simple</p>", html)
+
+	html, err = rt.RenderJSONBytes("Footer", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<footer></footer>", html)
+
+	_, err = rt.RenderJSONBytes("Simple", []byte(`{"titel": "Hi"}`))
+	assert.ErrorContains(t, err, "invalid JSON for view Simple (SimpleData)")
+
+	_, err = rt.RenderJSONBytes("Orphan", []byte(`{}`))
+	assert.ErrorContains(t, err, "message NoSuchData is not registered")
+
+	_, err = rt.RenderJSONBytes("Missing", []byte(`{}`))
+	assert.ErrorContains(t, err, "view Missing not found")
+}
+
+func TestRenderJSONBytes_DevMode(t *testing.T) {
+	srv := newDevServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		fmt.Fprintf(w, "<p>%s</p>", body)
+	})
+
+	rt, err := NewRuntime(context.Background(), Options{
+		DevMode:       true,
+		DevServerAddr: strings.TrimPrefix(srv.URL, "http://"),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	html, err := rt.RenderJSONBytes("Simple", []byte(`{"title":"Hi"}`))
+	require.NoError(t, err)
+	assert.Equal(t, `<p>{"title":"Hi"}</p>`, html)
+}
+
+func TestRenderProtoJSON(t *testing.T) {
+	// A dev server that decodes the view's message, so the test sees what
+	// the protojson became
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /views/schema", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"name":"AppLayout","message":"LayoutData"}]`)
+	})
+	mux.HandleFunc("POST /render", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		var data pb.LayoutData
+		require.NoError(t, proto.Unmarshal(body, &data))
+		fmt.Fprintf(w, "<header>%s %s %v</header>", data.Title, data.UserName, data.IsLoggedIn)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	rt, err := NewRuntime(context.Background(), Options{
+		DevMode:       true,
+		DevServerAddr: strings.TrimPrefix(srv.URL, "http://"),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	html, err := rt.RenderProtoJSON("AppLayout", []byte(`{"title": "Shop", "userName": "ann", "isLoggedIn": true}`))
+	require.NoError(t, err)
+	assert.Equal(t, "<header>Shop ann true</header>", html)
+
+	_, err = rt.RenderProtoJSON("AppLayout", []byte(`{"userName": 7}`))
+	assert.ErrorContains(t, err, "invalid JSON for view AppLayout (LayoutData)")
+}
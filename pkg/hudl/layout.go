@@ -0,0 +1,81 @@
+package hudl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrNoContentSlot is returned by RenderLayout when views.wasm was built
+// before hudl_set_content existed. Rebuild it with the current hudlc.
+var ErrNoContentSlot = errors.New("hudl: views.wasm does not support content slots (rebuild with hudlc)")
+
+type slotContentKey struct{}
+
+// RenderLayout renders contentView with contentData, then layoutView with
+// layoutData, placing the content's output at the layout's #content slot.
+// The content is trusted HTML produced by the content view (which escapes
+// its own data) and is inserted without further escaping.
+func (r *Runtime) RenderLayout(layoutView string, layoutData proto.Message, contentView string, contentData proto.Message) (string, error) {
+	return r.RenderLayoutContext(r.ctx, layoutView, layoutData, contentView, contentData)
+}
+
+// RenderLayoutContext is RenderLayout with a context, as in RenderContext.
+func (r *Runtime) RenderLayoutContext(ctx context.Context, layoutView string, layoutData proto.Message, contentView string, contentData proto.Message) (string, error) {
+	content, err := r.renderContent(ctx, contentView, contentData)
+	if err != nil {
+		return "", fmt.Errorf("render content %s: %w", contentView, err)
+	}
+	return r.RenderContext(context.WithValue(ctx, slotContentKey{}, content), layoutView, layoutData)
+}
+
+// renderContent renders a view for a layout's slot with the core render,
+// skipping Options.Middleware, which runs once over the whole page when the
+// layout renders. In dev mode the live reload script is left for the layout
+// to add.
+func (r *Runtime) renderContent(ctx context.Context, viewName string, data proto.Message) (string, error) {
+	params, err := marshalData(data)
+	if err != nil {
+		return "", err
+	}
+	if r.devMode {
+		return r.postDev(ctx, viewName, "application/x-protobuf", params, false, r.sourceComments)
+	}
+	return r.renderWASM(ctx, viewName, params)
+}
+
+// slotContent returns the #content HTML for a render started by
+// RenderLayout, or "".
+func slotContent(ctx context.Context) string {
+	content, _ := ctx.Value(slotContentKey{}).(string)
+	return content
+}
+
+// setContent passes a layout's slot content to the instance via
+// hudl_set_content. The module clears it after the next render, so it is
+// only sent for renders that have content.
+func (r *Runtime) setContent(ctx context.Context, inst *instance, content string) error {
+	if content == "" {
+		return nil
+	}
+	if inst.setContent == nil {
+		return ErrNoContentSlot
+	}
+
+	ptr, err := r.malloc(ctx, inst, len(content))
+	if err != nil {
+		return err
+	}
+	defer inst.free.Call(r.ctx, ptr, uint64(len(content)))
+	if err := writeMemory(inst, uint32(ptr), []byte(content)); err != nil {
+		return err
+	}
+
+	if _, err := inst.setContent.Call(ctx, ptr, uint64(len(content))); err != nil {
+		inst.broken = true
+		return fmt.Errorf("hudl_set_content failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package hudl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderLayout_ComposesContent(t *testing.T) {
+	rt, err := NewRuntime(context.Background(), Options{
+		WASMBytes: stubModule{
+			views: map[string]string{"FeatureList": `<ul><li>Fast &amp; small</li></ul>`},
+			slot: slotStub{
+				view:   "AppLayout",
+				before: `<html><body><header>HUDL App</header><main>`,
+				after:  `</main></body></html>`,
+			},
+		}.build(),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	html, err := rt.RenderLayout("AppLayout", nil, "FeatureList", nil)
+	require.NoError(t, err)
+	assert.Equal(t, `<html><body><header>HUDL App</header><main><ul><li>Fast &amp; small</li></ul></main></body></html>`, html)
+
+	_, err = rt.RenderLayout("AppLayout", nil, "Missing", nil)
+	assert.ErrorContains(t, err, "render content Missing")
+}
+
+func TestRenderLayout_MiddlewareRunsOnce(t *testing.T) {
+	var processed []string
+	rt, err := NewRuntime(context.Background(), Options{
+		WASMBytes: stubModule{
+			views: map[string]string{"Logo": `<img src="/logo.png">`},
+			slot: slotStub{
+				view:   "AppLayout",
+				before: `<html><head><link href="/app.css"></head><body>`,
+				after:  `</body></html>`,
+			},
+		}.build(),
+		AssetPrefix: "/app",
+		PostProcess: func(view string, html []byte) ([]byte, error) {
+			processed = append(processed, view)
+			return html, nil
+		},
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	html, err := rt.RenderLayout("AppLayout", nil, "Logo", nil)
+	require.NoError(t, err)
+	assert.Equal(t, `<html><head><link href="/app/app.css"></head><body><img src="/app/logo.png"></body></html>`, html)
+	assert.Equal(t, []string{"AppLayout"}, processed)
+}
+
+func TestRenderLayout_NeedsContentSlot(t *testing.T) {
+	rt, err := NewRuntime(context.Background(), Options{
+		WASMBytes: stubWASM(map[string]string{"AppLayout": "<main></main>", "FeatureList": "<ul></ul>"}),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	_, err = rt.RenderLayout("AppLayout", nil, "FeatureList", nil)
+	assert.ErrorIs(t, err, ErrNoContentSlot)
+}
+
+func TestRenderLayout_DevMode(t *testing.T) {
+	srv := newDevServer(t, func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		switch r.Header.Get("X-Hudl-Component") {
+		case "FeatureList":
+			assert.NotEmpty(t, r.Header.Get("X-Hudl-No-Reload"), "the layout adds the reload script")
+			fmt.Fprint(w, "<ul></ul>")
+		case "AppLayout":
+			n, err := strconv.Atoi(r.Header.Get("X-Hudl-Slot-Length"))
+			require.NoError(t, err)
+			fmt.Fprintf(w, "<main>%s</main>", body[:n])
+		}
+	})
+
+	rt, err := NewRuntime(context.Background(), Options{
+		DevMode:       true,
+		DevServerAddr: strings.TrimPrefix(srv.URL, "http://"),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	html, err := rt.RenderLayout("AppLayout", nil, "FeatureList", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<main><ul></ul></main>", html)
+}
@@ -0,0 +1,135 @@
+package hudl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// NegotiateLocale selects the best locale from supported for the request's
+// Accept-Language header. Tags are tried in order of quality; a tag matches a
+// supported locale exactly (case-insensitive) or by base language, so "fr-CA"
+// matches "fr" and "fr" matches "fr-FR". If nothing matches, the first
+// supported locale is returned as the default.
+func NegotiateLocale(r *http.Request, supported []string) string {
+	if len(supported) == 0 {
+		return ""
+	}
+
+	for _, tag := range parseAcceptLanguage(r.Header.Get("Accept-Language")) {
+		if tag == "*" {
+			return supported[0]
+		}
+		if match := matchLocale(tag, supported); match != "" {
+			return match
+		}
+	}
+	return supported[0]
+}
+
+type localeKey struct{}
+
+// WithLocale returns a context whose renders bind locale to the `locale`
+// variable in templates, typically the result of NegotiateLocale. Pass the
+// result to RenderContext.
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey{}, locale)
+}
+
+// LocaleFromContext returns the locale set by WithLocale, or "".
+func LocaleFromContext(ctx context.Context) string {
+	locale, _ := ctx.Value(localeKey{}).(string)
+	return locale
+}
+
+// setLocale passes the render's locale to the instance via hudl_set_locale.
+// Instances keep the last locale, so it is only sent when it changes. Modules
+// built before locale support don't export hudl_set_locale and are left as is.
+func (r *Runtime) setLocale(ctx context.Context, inst *instance, locale string) error {
+	if inst.setLocale == nil || inst.locale == locale {
+		return nil
+	}
+
+	ptr := uint64(0)
+	if locale != "" {
+		var err error
+		ptr, err = r.malloc(ctx, inst, len(locale))
+		if err != nil {
+			return err
+		}
+		defer inst.free.Call(r.ctx, ptr, uint64(len(locale)))
+		if err := writeMemory(inst, uint32(ptr), []byte(locale)); err != nil {
+			return err
+		}
+	}
+
+	if _, err := inst.setLocale.Call(ctx, ptr, uint64(len(locale))); err != nil {
+		inst.broken = true
+		return fmt.Errorf("hudl_set_locale failed: %w", err)
+	}
+	inst.locale = locale
+	return nil
+}
+
+// parseAcceptLanguage returns the language tags from an Accept-Language header,
+// ordered by descending quality. Tags with q=0 are dropped.
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q <= 0 {
+			continue
+		}
+		tags = append(tags, weighted{tag: tag, q: q})
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	result := make([]string, len(tags))
+	for i, t := range tags {
+		result[i] = t.tag
+	}
+	return result
+}
+
+func matchLocale(tag string, supported []string) string {
+	for _, s := range supported {
+		if strings.EqualFold(tag, s) {
+			return s
+		}
+	}
+
+	base := baseLanguage(tag)
+	for _, s := range supported {
+		if strings.EqualFold(base, baseLanguage(s)) {
+			return s
+		}
+	}
+	return ""
+}
+
+func baseLanguage(tag string) string {
+	base, _, _ := strings.Cut(strings.ReplaceAll(tag, "_", "-"), "-")
+	return base
+}
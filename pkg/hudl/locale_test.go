@@ -0,0 +1,80 @@
+package hudl
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateLocale(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		supported []string
+		want      string
+	}{
+		{"base language fallback", "fr-CA,fr;q=0.9,en;q=0.8", []string{"en", "fr", "de"}, "fr"},
+		{"exact match wins", "fr-CA,fr;q=0.9,en;q=0.8", []string{"en", "fr", "fr-CA"}, "fr-CA"},
+		{"regional supported locale", "fr-CA,fr;q=0.9,en;q=0.8", []string{"en-US", "fr-FR"}, "fr-FR"},
+		{"quality ordering", "de;q=0.5,en;q=0.8", []string{"de", "en"}, "en"},
+		{"q=0 excluded", "fr;q=0,en;q=0.1", []string{"fr", "en"}, "en"},
+		{"case insensitive", "EN-gb", []string{"de", "en-GB"}, "en-GB"},
+		{"wildcard", "ja, *;q=0.5", []string{"de", "en"}, "de"},
+		{"no match uses default", "ja", []string{"en", "fr"}, "en"},
+		{"missing header uses default", "", []string{"en", "fr"}, "en"},
+		{"no supported locales", "en", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.header != "" {
+				r.Header.Set("Accept-Language", tt.header)
+			}
+			assert.Equal(t, tt.want, NegotiateLocale(r, tt.supported))
+		})
+	}
+}
+
+func TestRenderContext_Locale(t *testing.T) {
+	rt, err := NewRuntime(context.Background(), Options{
+		WASMBytes: stubModule{localeView: "Locale"}.build(),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	out, err := rt.RenderContext(WithLocale(context.Background(), "fr-CA"), "Locale", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "fr-CA", out)
+
+	// The instance is reused; a render without a locale clears it
+	out, err = rt.RenderContext(context.Background(), "Locale", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "", out)
+}
+
+func TestRenderContext_LocaleDevHeader(t *testing.T) {
+	srv := newDevServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<p lang="` + r.Header.Get("X-Hudl-Locale") + `">Bonjour</p>`))
+	})
+
+	rt, err := NewRuntime(context.Background(), Options{
+		DevMode:       true,
+		DevServerAddr: strings.TrimPrefix(srv.URL, "http://"),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Language", "fr-CA,fr;q=0.9,en;q=0.8")
+	ctx := WithLocale(context.Background(), NegotiateLocale(req, []string{"en", "fr"}))
+
+	out, err := rt.RenderContext(ctx, "Greeting", nil)
+	require.NoError(t, err)
+	assert.Equal(t, `<p lang="fr">Bonjour</p>`, out)
+}
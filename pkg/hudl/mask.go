@@ -0,0 +1,68 @@
+package hudl
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// ErrInvalidFieldMask is returned by RenderMasked when a mask path does not
+// name a field of the data's message type.
+var ErrInvalidFieldMask = errors.New("hudl: field mask does not match message")
+
+// RenderMasked renders a view with only the fields of data named by mask, so
+// a view that reads a few fields of a large message doesn't pay to serialize
+// the rest. Paths may reach into singular message fields ("user.name").
+// Fields outside the mask read as unset in the template. A nil mask renders
+// all of data.
+func (r *Runtime) RenderMasked(viewName string, data proto.Message, mask *fieldmaskpb.FieldMask) (string, error) {
+	if data == nil || mask == nil {
+		return r.Render(viewName, data)
+	}
+	if !mask.IsValid(data) {
+		return "", fmt.Errorf("%w: %s has no field for one of %v",
+			ErrInvalidFieldMask, data.ProtoReflect().Descriptor().FullName(), mask.GetPaths())
+	}
+	return r.Render(viewName, maskedCopy(data.ProtoReflect(), mask.GetPaths()).Interface())
+}
+
+// maskedCopy returns a new message holding only the fields of src named by
+// paths. Copied field values are shared with src, not cloned.
+func maskedCopy(src protoreflect.Message, paths []string) protoreflect.Message {
+	whole := map[string]bool{}
+	nested := map[string][]string{}
+	for _, p := range paths {
+		if head, rest, ok := strings.Cut(p, "."); ok {
+			nested[head] = append(nested[head], rest)
+		} else {
+			whole[p] = true
+		}
+	}
+
+	dst := src.New()
+	fields := src.Descriptor().Fields()
+	copyField := func(name string) {
+		fd := fields.ByName(protoreflect.Name(name))
+		if fd == nil || !src.Has(fd) {
+			return
+		}
+		if whole[name] {
+			dst.Set(fd, src.Get(fd))
+			return
+		}
+		dst.Set(fd, protoreflect.ValueOfMessage(maskedCopy(src.Get(fd).Message(), nested[name])))
+	}
+	for name := range whole {
+		copyField(name)
+	}
+	for name := range nested {
+		if !whole[name] {
+			copyField(name)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,66 @@
+package hudl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/njreid/hudl/pkg/hudl/pb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/apipb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/sourcecontextpb"
+)
+
+func TestRenderMasked_MatchesFullRenderOfMaskedFields(t *testing.T) {
+	rt, err := NewRuntime(context.Background(), Options{WASMBytes: stubWASM(nil)})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	full := &pb.DashboardData{
+		RevenueFormatted: "$1,200",
+		ActiveUsers:      42,
+		SystemLoad:       7,
+		Transactions:     []*pb.Transaction{{Id: "t1", CustomerName: "Ann"}, {Id: "t2"}},
+	}
+	mask := &fieldmaskpb.FieldMask{Paths: []string{"revenue_formatted", "active_users"}}
+
+	// Echo returns the bytes it was given, so this compares what was serialized
+	masked, err := rt.RenderMasked("Echo", full, mask)
+	require.NoError(t, err)
+	want, err := rt.Render("Echo", &pb.DashboardData{RevenueFormatted: "$1,200", ActiveUsers: 42})
+	require.NoError(t, err)
+	assert.Equal(t, want, masked)
+
+	// A nil mask renders everything
+	all, err := rt.RenderMasked("Echo", full, nil)
+	require.NoError(t, err)
+	wantAll, err := rt.Render("Echo", full)
+	require.NoError(t, err)
+	assert.Equal(t, wantAll, all)
+}
+
+func TestRenderMasked_InvalidPath(t *testing.T) {
+	rt, err := NewRuntime(context.Background(), Options{WASMBytes: stubWASM(nil)})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	_, err = rt.RenderMasked("Echo", &pb.ButtonData{Label: "Go"}, &fieldmaskpb.FieldMask{Paths: []string{"colour"}})
+	assert.ErrorIs(t, err, ErrInvalidFieldMask)
+}
+
+func TestMaskedCopy_NestedPaths(t *testing.T) {
+	src := &apipb.Api{
+		Name:          "shop.v1.Orders",
+		Version:       "v1",
+		SourceContext: &sourcecontextpb.SourceContext{FileName: "orders.proto"},
+	}
+
+	got := maskedCopy(src.ProtoReflect(), []string{"name", "source_context.file_name"}).Interface()
+	want := &apipb.Api{
+		Name:          "shop.v1.Orders",
+		SourceContext: &sourcecontextpb.SourceContext{FileName: "orders.proto"},
+	}
+	assert.True(t, proto.Equal(want, got), "got %v", got)
+}
@@ -0,0 +1,74 @@
+package hudl
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// RenderFunc renders a view with its data in proto wire format. Data is
+// already marshaled, so a middleware can key a cache on it as is.
+type RenderFunc func(ctx context.Context, viewName string, data []byte) (string, error)
+
+// RenderMiddleware wraps a render, e.g. to time it, trace it, rewrite its
+// output or swap the view for an A/B test. It calls next to continue the
+// chain, possibly with another view name, or returns without calling it to
+// short-circuit (as a cache would).
+type RenderMiddleware func(next RenderFunc) RenderFunc
+
+// chainMiddleware wraps core so that mw[0] is outermost and runs first.
+func chainMiddleware(core RenderFunc, mw []RenderMiddleware) RenderFunc {
+	for i := len(mw) - 1; i >= 0; i-- {
+		core = mw[i](core)
+	}
+	return core
+}
+
+// renderMiddleware is Options.Middleware plus the built-in middleware the
+// options enable, which run innermost so they time the render itself.
+func renderMiddleware(opts Options, logger *slog.Logger) []RenderMiddleware {
+	mw := opts.Middleware
+	if opts.PostProcess != nil {
+		mw = append(mw[:len(mw):len(mw)], postProcess(opts.PostProcess))
+	}
+	if opts.AssetPrefix != "" {
+		mw = append(mw[:len(mw):len(mw)], assetPrefix(opts.AssetPrefix))
+	}
+	if opts.SlowRenderThreshold > 0 {
+		mw = append(mw[:len(mw):len(mw)], slowRenderWarning(logger, opts.SlowRenderThreshold))
+	}
+	return mw
+}
+
+// slowRenderWarning logs a warning for each render that takes longer than
+// threshold.
+func slowRenderWarning(logger *slog.Logger, threshold time.Duration) RenderMiddleware {
+	return func(next RenderFunc) RenderFunc {
+		return func(ctx context.Context, viewName string, data []byte) (string, error) {
+			start := time.Now()
+			out, err := next(ctx, viewName, data)
+			if elapsed := time.Since(start); elapsed > threshold {
+				logger.Warn("hudl: slow render", "view", viewName, "duration", elapsed, "threshold", threshold)
+			}
+			return out, err
+		}
+	}
+}
+
+// postProcess passes the output of each successful render through fn.
+func postProcess(fn func(view string, html []byte) ([]byte, error)) RenderMiddleware {
+	return func(next RenderFunc) RenderFunc {
+		return func(ctx context.Context, viewName string, data []byte) (string, error) {
+			out, err := next(ctx, viewName, data)
+			if err != nil {
+				return "", err
+			}
+			processed, err := fn(viewName, []byte(out))
+			if err != nil {
+				return "", fmt.Errorf("post-processing %s: %w", viewName, err)
+			}
+			return string(processed), nil
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package hudl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/njreid/hudl/pkg/hudl/pb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestRenderMiddleware(t *testing.T) {
+	var calls []string
+	record := func(next RenderFunc) RenderFunc {
+		return func(ctx context.Context, viewName string, data []byte) (string, error) {
+			calls = append(calls, viewName)
+			return next(ctx, viewName, data)
+		}
+	}
+	prefix := func(next RenderFunc) RenderFunc {
+		return func(ctx context.Context, viewName string, data []byte) (string, error) {
+			out, err := next(ctx, viewName, data)
+			return "<!-- " + viewName + " -->" + out, err
+		}
+	}
+
+	rt, err := NewRuntime(context.Background(), Options{
+		WASMBytes:  stubWASM(map[string]string{"Card": "<p>Hello</p>"}),
+		Middleware: []RenderMiddleware{record, prefix},
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	html, err := rt.Render("Card", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<!-- Card --><p>Hello</p>", html)
+
+	// Helpers built on Render go through the chain too
+	_, err = rt.RenderHTML("Card", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Card", "Card"}, calls)
+
+	// So do raw proto bytes, whether rendered to a string or to bytes as
+	// the -bytes wrappers do
+	html, err = rt.RenderBytes("Card", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<!-- Card --><p>Hello</p>", html)
+	raw, err := rt.RenderRaw("Card", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<!-- Card --><p>Hello</p>", string(raw))
+	assert.Len(t, calls, 4)
+}
+
+func TestRenderMiddleware_RewritesView(t *testing.T) {
+	// An A/B test sending some renders of Card to CardB
+	variant := func(next RenderFunc) RenderFunc {
+		return func(ctx context.Context, viewName string, data []byte) (string, error) {
+			var button pb.ButtonData
+			if viewName == "Card" && proto.Unmarshal(data, &button) == nil && button.Label == "B" {
+				viewName = "CardB"
+			}
+			return next(ctx, viewName, data)
+		}
+	}
+	wrap := func(next RenderFunc) RenderFunc {
+		return func(ctx context.Context, viewName string, data []byte) (string, error) {
+			out, err := next(ctx, viewName, data)
+			return "<section>" + out + "</section>", err
+		}
+	}
+
+	rt, err := NewRuntime(context.Background(), Options{
+		WASMBytes:  stubWASM(map[string]string{"Card": "<p>A</p>", "CardB": "<p>B</p>"}),
+		Middleware: []RenderMiddleware{wrap, variant},
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	html, err := rt.Render("Card", &pb.ButtonData{Label: "B"})
+	require.NoError(t, err)
+	assert.Equal(t, "<section><p>B</p></section>", html)
+
+	html, err = rt.Render("Card", &pb.ButtonData{Label: "A"})
+	require.NoError(t, err)
+	assert.Equal(t, "<section><p>A</p></section>", html)
+}
+
+func TestRenderMiddleware_ShortCircuit(t *testing.T) {
+	cached := func(next RenderFunc) RenderFunc {
+		return func(ctx context.Context, viewName string, data []byte) (string, error) {
+			if viewName == "Card" {
+				return "<p>cached</p>", nil
+			}
+			return next(ctx, viewName, data)
+		}
+	}
+
+	rt, err := NewRuntime(context.Background(), Options{
+		WASMBytes:  stubWASM(map[string]string{"Card": "<p>Hello</p>", "Badge": "<b></b>"}),
+		Middleware: []RenderMiddleware{cached},
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	html, err := rt.Render("Card", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<p>cached</p>", html)
+
+	html, err = rt.Render("Badge", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<b></b>", html)
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestSlowRenderThreshold(t *testing.T) {
+	// A dev server that takes 50ms over Slow and answers Fast at once
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path != "/render" {
+			return &http.Response{StatusCode: http.StatusNotFound, Body: http.NoBody, Request: req}, nil
+		}
+		view := req.Header.Get("X-Hudl-Component")
+		if view == "Slow" {
+			time.Sleep(50 * time.Millisecond)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"text/html"}},
+			Body:       io.NopCloser(strings.NewReader("<p>" + view + "</p>")),
+			Request:    req,
+		}, nil
+	})
+
+	var logs bytes.Buffer
+	rt, err := NewRuntime(context.Background(), Options{
+		DevMode:             true,
+		DevServerAddr:       "hudl.test",
+		HttpClient:          &http.Client{Transport: transport},
+		Logger:              slog.New(slog.NewTextHandler(&logs, nil)),
+		SlowRenderThreshold: 20 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	_, err = rt.Render("Fast", nil)
+	require.NoError(t, err)
+	assert.NotContains(t, logs.String(), "slow render")
+
+	html, err := rt.Render("Slow", nil)
+	require.NoError(t, err)
+	assert.Contains(t, html, "<p>Slow</p>")
+	assert.Contains(t, logs.String(), "hudl: slow render")
+	assert.Contains(t, logs.String(), "view=Slow")
+	assert.Contains(t, logs.String(), "threshold=20ms")
+}
+
+func TestPostProcess(t *testing.T) {
+	cdn := func(view string, html []byte) ([]byte, error) {
+		if view == "Broken" {
+			return nil, errors.New("unbalanced markup")
+		}
+		return bytes.ReplaceAll(html, []byte(`src="/static/`), []byte(`src="https://cdn.example.com/static/`)), nil
+	}
+	var seen string
+	outer := func(next RenderFunc) RenderFunc {
+		return func(ctx context.Context, viewName string, data []byte) (string, error) {
+			out, err := next(ctx, viewName, data)
+			seen = out
+			return out, err
+		}
+	}
+
+	rt, err := NewRuntime(context.Background(), Options{
+		WASMBytes: stubWASM(map[string]string{
+			"Logo":   `<img src="/static/logo.png">`,
+			"Broken": "<p>",
+		}),
+		Middleware:  []RenderMiddleware{outer},
+		PostProcess: cdn,
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	html, err := rt.Render("Logo", nil)
+	require.NoError(t, err)
+	assert.Equal(t, `<img src="https://cdn.example.com/static/logo.png">`, html)
+	// Middleware sees the rewritten output
+	assert.Equal(t, html, seen)
+
+	_, err = rt.Render("Broken", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "post-processing Broken: unbalanced markup")
+}
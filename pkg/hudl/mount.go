@@ -0,0 +1,87 @@
+package hudl
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrMountExists is returned by Mount when a bundle is already mounted at
+// the prefix.
+var ErrMountExists = errors.New("hudl: a bundle is already mounted at this prefix")
+
+// Mount loads another compiled views bundle and serves its views under
+// prefix, as "prefix/View", for apps that split templates into bundles per
+// feature area. Every render method accepts the prefixed name. A bundle's
+// views are only reachable through its prefix, so bundles may reuse view
+// names without colliding with each other or with this runtime's own views.
+// Mounted bundles get the same pool, memory and WASI options as the runtime
+// and are closed with it. In dev mode the dev server renders every template,
+// and the prefix is dropped before asking it.
+func (r *Runtime) Mount(prefix string, wasmBytes []byte) error {
+	if prefix == "" || strings.Contains(prefix, "/") {
+		return fmt.Errorf("hudl: invalid mount prefix %q", prefix)
+	}
+
+	r.mountsMu.Lock()
+	defer r.mountsMu.Unlock()
+	if _, ok := r.mounts[prefix]; ok {
+		return fmt.Errorf("%w: %q", ErrMountExists, prefix)
+	}
+
+	sub := &Runtime{ctx: r.ctx, logger: r.logger, defaultContentType: r.defaultContentType}
+	opts := r.moduleOpts
+	opts.WASMBytes = wasmBytes
+	if err := sub.initWASM(opts); err != nil {
+		return fmt.Errorf("mount %s: %w", prefix, err)
+	}
+	if r.mounts == nil {
+		r.mounts = make(map[string]*Runtime)
+	}
+	r.mounts[prefix] = sub
+	return nil
+}
+
+// mounted resolves a "prefix/View" name to the bundle mounted at prefix.
+func (r *Runtime) mounted(viewName string) (*Runtime, string, bool) {
+	prefix, name, ok := strings.Cut(viewName, "/")
+	if !ok {
+		return nil, "", false
+	}
+	r.mountsMu.RLock()
+	defer r.mountsMu.RUnlock()
+	sub, ok := r.mounts[prefix]
+	return sub, name, ok
+}
+
+// mountedViews lists the views of every mounted bundle with their prefix.
+func (r *Runtime) mountedViews() ([]string, error) {
+	r.mountsMu.RLock()
+	defer r.mountsMu.RUnlock()
+
+	var views []string
+	for prefix, sub := range r.mounts {
+		names, err := sub.ListViews()
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			views = append(views, prefix+"/"+name)
+		}
+	}
+	sort.Strings(views)
+	return views, nil
+}
+
+func (r *Runtime) closeMounts() error {
+	r.mountsMu.Lock()
+	defer r.mountsMu.Unlock()
+
+	var errs []error
+	for _, sub := range r.mounts {
+		errs = append(errs, sub.Close())
+	}
+	r.mounts = nil
+	return errors.Join(errs...)
+}
@@ -0,0 +1,56 @@
+package hudl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuntime_Mount(t *testing.T) {
+	rt, err := NewRuntime(context.Background(), Options{
+		WASMBytes: stubWASM(map[string]string{"Home": "<h1>Home</h1>"}),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	// Both bundles define Dashboard
+	require.NoError(t, rt.Mount("billing", stubWASM(map[string]string{"Dashboard": "<p>Invoices</p>"})))
+	require.NoError(t, rt.Mount("admin", stubWASM(map[string]string{"Dashboard": "<p>Users</p>"})))
+
+	html, err := rt.Render("billing/Dashboard", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<p>Invoices</p>", html)
+
+	html, err = rt.Render("admin/Dashboard", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<p>Users</p>", html)
+
+	html, err = rt.Render("Home", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<h1>Home</h1>", html)
+
+	// Mounted views are only reachable through their prefix
+	_, err = rt.Render("Dashboard", nil)
+	assert.Error(t, err)
+	_, err = rt.Render("reports/Dashboard", nil)
+	assert.Error(t, err)
+
+	views, err := rt.ListViews()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Echo", "Home", "admin/Dashboard", "admin/Echo", "billing/Dashboard", "billing/Echo"}, views)
+}
+
+func TestRuntime_MountErrors(t *testing.T) {
+	rt, err := NewRuntime(context.Background(), Options{WASMBytes: stubWASM(nil)})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	require.NoError(t, rt.Mount("billing", stubWASM(nil)))
+	assert.ErrorIs(t, rt.Mount("billing", stubWASM(nil)), ErrMountExists)
+
+	assert.Error(t, rt.Mount("", stubWASM(nil)))
+	assert.Error(t, rt.Mount("a/b", stubWASM(nil)))
+	assert.ErrorContains(t, rt.Mount("broken", []byte("not wasm")), "mount broken")
+}
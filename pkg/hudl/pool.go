@@ -0,0 +1,314 @@
+package hudl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// ErrPoolExhausted is returned when no WASM instance becomes free within
+// Options.AcquireTimeout. Servers can map it to 503 Service Unavailable.
+var ErrPoolExhausted = errors.New("hudl: no WASM instance available (pool exhausted)")
+
+// ErrClosed is returned by renders that start once Close has been called.
+var ErrClosed = errors.New("hudl: runtime is closed")
+
+// instance is one instantiation of the compiled views module. An instance is
+// used by a single render at a time.
+type instance struct {
+	mod    api.Module
+	malloc api.Function
+	free   api.Function
+	// setNonce is the optional hudl_set_nonce export; nonce is the last
+	// CSP nonce passed to it.
+	setNonce api.Function
+	nonce    string
+	// setLocale is the optional hudl_set_locale export; locale is the last
+	// locale passed to it.
+	setLocale api.Function
+	locale    string
+	// setContent is the optional hudl_set_content export, used by RenderLayout.
+	setContent api.Function
+	// liveAllocs is the optional hudl_live_allocs export, used by
+	// MemoryDiagnostics.
+	liveAllocs api.Function
+	// views caches view exports by name, as each ExportedFunction lookup
+	// allocates a new function handle.
+	views  map[string]api.Function
+	stdout bytes.Buffer
+	stderr bytes.Buffer
+	// broken is set when a call traps; the instance is discarded on release.
+	broken bool
+}
+
+// instancePool bounds the number of live instances. A render holds a slot
+// for its duration; idle instances are reused before new ones are created.
+type instancePool struct {
+	slots   chan struct{}
+	idle    chan *instance
+	timeout time.Duration
+	// live counts the instances created and not yet discarded
+	live atomic.Int64
+	// closed is closed by drain, failing new acquires with ErrClosed
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	// mu guards the memory snapshots MemoryDiagnostics takes of idle
+	// instances
+	mu     sync.Mutex
+	memory map[*instance]InstanceMemory
+	peak   InstanceMemory
+}
+
+func newInstancePool(size int, timeout time.Duration) *instancePool {
+	if size <= 0 {
+		size = runtime.GOMAXPROCS(0)
+	}
+	return &instancePool{
+		slots:   make(chan struct{}, size),
+		idle:    make(chan *instance, size),
+		timeout: timeout,
+		closed:  make(chan struct{}),
+		memory:  make(map[*instance]InstanceMemory),
+		peak:    InstanceMemory{Allocations: -1},
+	}
+}
+
+func (r *Runtime) newInstance() (*instance, error) {
+	inst := &instance{views: make(map[string]api.Function)}
+	// Anonymous modules can be instantiated more than once; capture
+	// stdout/stderr so module diagnostics reach the logger.
+	config := wazero.NewModuleConfig().
+		WithName("").
+		WithStdout(&inst.stdout).
+		WithStderr(&inst.stderr)
+
+	mod, err := r.rt.InstantiateModule(r.ctx, r.compiled, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to instantiate module: %w", err)
+	}
+
+	inst.mod = mod
+	inst.malloc = mod.ExportedFunction("hudl_malloc")
+	inst.free = mod.ExportedFunction("hudl_free")
+	inst.setNonce = mod.ExportedFunction("hudl_set_nonce")
+	inst.setLocale = mod.ExportedFunction("hudl_set_locale")
+	inst.setContent = mod.ExportedFunction("hudl_set_content")
+	inst.liveAllocs = mod.ExportedFunction("hudl_live_allocs")
+	if inst.malloc == nil || inst.free == nil {
+		mod.Close(r.ctx)
+		return nil, fmt.Errorf("missing required exports: hudl_malloc or hudl_free")
+	}
+	r.pool.live.Add(1)
+	return inst, nil
+}
+
+// view returns the export for viewName, or nil if the module has none.
+func (inst *instance) view(viewName string) api.Function {
+	if fn, ok := inst.views[viewName]; ok {
+		return fn
+	}
+	fn := inst.mod.ExportedFunction(viewName)
+	if fn != nil {
+		inst.views[viewName] = fn
+	}
+	return fn
+}
+
+// acquire takes a pool slot, waiting up to the pool's timeout (not at all if
+// it is negative) or until ctx is done, and returns an idle instance or a
+// freshly created one.
+func (r *Runtime) acquire(ctx context.Context) (*instance, error) {
+	p := r.pool
+	if p.timeout < 0 {
+		select {
+		case p.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-p.closed:
+			return nil, ErrClosed
+		default:
+			return nil, ErrPoolExhausted
+		}
+	} else if p.timeout > 0 {
+		timer := time.NewTimer(p.timeout)
+		defer timer.Stop()
+		select {
+		case p.slots <- struct{}{}:
+		case <-timer.C:
+			return nil, ErrPoolExhausted
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-p.closed:
+			return nil, ErrClosed
+		}
+	} else {
+		select {
+		case p.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-p.closed:
+			return nil, ErrClosed
+		}
+	}
+
+	// A slot freed while closing belongs to drain
+	select {
+	case <-p.closed:
+		<-p.slots
+		return nil, ErrClosed
+	default:
+	}
+
+	select {
+	case inst := <-p.idle:
+		return inst, nil
+	default:
+	}
+
+	inst, err := r.newInstance()
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	return inst, nil
+}
+
+// release returns an instance to the pool, or discards it if it trapped.
+func (r *Runtime) release(inst *instance) {
+	if inst.broken {
+		r.pool.forget(inst)
+		r.pool.live.Add(-1)
+		inst.mod.Close(r.ctx)
+	} else {
+		r.pool.idle <- inst
+	}
+	<-r.pool.slots
+}
+
+// drain fails renders that haven't started with ErrClosed, waits for those
+// in flight to release their instances, and closes the instances. Only the
+// first call does anything; later ones wait for it to finish.
+func (r *Runtime) drain() {
+	p := r.pool
+	p.closeOnce.Do(func() {
+		close(p.closed)
+		// Holding every slot means no render is running
+		for range cap(p.slots) {
+			p.slots <- struct{}{}
+		}
+		for {
+			select {
+			case inst := <-p.idle:
+				p.forget(inst)
+				p.live.Add(-1)
+				inst.mod.Close(r.ctx)
+			default:
+				return
+			}
+		}
+	})
+}
+
+// InstanceMemory describes the memory of one pooled WASM instance.
+type InstanceMemory struct {
+	// Bytes is the size of the instance's linear memory. WASM memory never
+	// shrinks, so this is also the most the instance has needed.
+	Bytes uint32
+	// Allocations is the number of live heap allocations in the module, or
+	// -1 if the module doesn't report it (built by an older hudlc).
+	Allocations int64
+}
+
+// MemoryStats is a snapshot of the instance pool's memory use.
+type MemoryStats struct {
+	// Instances has one entry per live instance that has been sampled.
+	// Idle instances are sampled by each MemoryDiagnostics call; instances
+	// rendering right now are reported as the last call found them.
+	Instances []InstanceMemory
+	// Peak holds the largest memory size and allocation count sampled on
+	// any instance, including discarded ones.
+	Peak InstanceMemory
+}
+
+// MemoryDiagnostics reports the memory use of the runtime's WASM instances,
+// to tell a view that leaks (allocations climbing from render to render)
+// from a pool that is merely growing (more instances, each stable). Mounted
+// bundles have their own pools and aren't included. In dev mode it returns
+// the zero MemoryStats.
+func (r *Runtime) MemoryDiagnostics() MemoryStats {
+	if r.pool == nil {
+		return MemoryStats{}
+	}
+	r.sampleIdle()
+	p := r.pool
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := MemoryStats{Peak: p.peak}
+	for _, mem := range p.memory {
+		stats.Instances = append(stats.Instances, mem)
+	}
+	return stats
+}
+
+// sampleIdle records the memory use of every idle instance. Each is taken
+// with a pool slot, as a render would take it, so sampling never calls into
+// an instance that is rendering; all are returned once sampled.
+func (r *Runtime) sampleIdle() {
+	p := r.pool
+	var sampled []*instance
+	defer func() {
+		for _, inst := range sampled {
+			p.idle <- inst
+			<-p.slots
+		}
+	}()
+	for {
+		select {
+		case p.slots <- struct{}{}:
+		default:
+			return
+		}
+		select {
+		case inst := <-p.idle:
+			r.recordMemory(inst)
+			sampled = append(sampled, inst)
+		default:
+			<-p.slots
+			return
+		}
+	}
+}
+
+// recordMemory snapshots an instance's memory use. The caller must own the
+// instance, since reading the allocation count calls into the module.
+func (r *Runtime) recordMemory(inst *instance) {
+	mem := InstanceMemory{Bytes: inst.mod.Memory().Size(), Allocations: -1}
+	if inst.liveAllocs != nil {
+		if results, err := inst.liveAllocs.Call(r.ctx); err == nil {
+			mem.Allocations = int64(uint32(results[0]))
+		}
+	}
+
+	p := r.pool
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.memory[inst] = mem
+	p.peak.Bytes = max(p.peak.Bytes, mem.Bytes)
+	p.peak.Allocations = max(p.peak.Allocations, mem.Allocations)
+}
+
+// forget drops a discarded instance's memory snapshot.
+func (p *instancePool) forget(inst *instance) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.memory, inst)
+}
@@ -0,0 +1,280 @@
+package hudl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPooledRuntime(t *testing.T, opts Options) *Runtime {
+	t.Helper()
+	if opts.WASMBytes == nil {
+		opts.WASMBytes = stubWASM(map[string]string{"Static": "<p>ok</p>"})
+	}
+	rt, err := NewRuntime(context.Background(), opts)
+	require.NoError(t, err)
+	t.Cleanup(func() { rt.Close() })
+	return rt
+}
+
+func TestPool_AcquireTimeout(t *testing.T) {
+	rt := newPooledRuntime(t, Options{MaxInstances: 1, AcquireTimeout: 20 * time.Millisecond})
+
+	// Saturate the pool
+	inst, err := rt.acquire(rt.ctx)
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = rt.Render("Static", nil)
+	assert.True(t, errors.Is(err, ErrPoolExhausted), "got %v", err)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+	rt.release(inst)
+
+	html, err := rt.Render("Static", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<p>ok</p>", html)
+}
+
+func TestPool_BlocksWithoutTimeout(t *testing.T) {
+	rt := newPooledRuntime(t, Options{MaxInstances: 1})
+
+	inst, err := rt.acquire(rt.ctx)
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := rt.Render("Static", nil)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("render should block while the pool is saturated, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	rt.release(inst)
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("render did not complete after the instance was released")
+	}
+}
+
+func TestPool_ConcurrentRenders(t *testing.T) {
+	rt := newPooledRuntime(t, Options{MaxInstances: 4})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			input := fmt.Sprintf("payload-%d", i)
+			out, err := rt.RenderBytes("Echo", []byte(input))
+			assert.NoError(t, err)
+			assert.Equal(t, input, out)
+		}(i)
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, len(rt.pool.idle), 4)
+}
+
+func TestPool_FailsFastWithNegativeTimeout(t *testing.T) {
+	rt := newPooledRuntime(t, Options{MaxInstances: 1, AcquireTimeout: -1})
+
+	inst, err := rt.acquire(rt.ctx)
+	require.NoError(t, err)
+
+	_, err = rt.Render("Static", nil)
+	assert.True(t, errors.Is(err, ErrPoolExhausted), "got %v", err)
+
+	rt.release(inst)
+	_, err = rt.Render("Static", nil)
+	assert.NoError(t, err)
+}
+
+func TestPool_DefaultsToGOMAXPROCS(t *testing.T) {
+	rt := newPooledRuntime(t, Options{})
+	assert.Equal(t, runtime.GOMAXPROCS(0), cap(rt.pool.slots))
+}
+
+func TestPool_CloseDrains(t *testing.T) {
+	rt := newPooledRuntime(t, Options{MaxInstances: 1})
+
+	// A render in flight holds the only instance
+	inst, err := rt.acquire(rt.ctx)
+	require.NoError(t, err)
+
+	closed := make(chan error, 1)
+	go func() { closed <- rt.Close() }()
+
+	// Renders waiting for an instance fail rather than start
+	_, err = rt.Render("Static", nil)
+	assert.True(t, errors.Is(err, ErrClosed), "got %v", err)
+
+	select {
+	case err := <-closed:
+		t.Fatalf("Close should wait for the render in flight, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	rt.release(inst)
+	select {
+	case err := <-closed:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Close did not return after the instance was released")
+	}
+	assert.Zero(t, rt.Stats().Instances)
+
+	_, err = rt.Render("Static", nil)
+	assert.True(t, errors.Is(err, ErrClosed), "got %v", err)
+}
+
+func TestPool_StatsCountsInstances(t *testing.T) {
+	rt := newPooledRuntime(t, Options{
+		MaxInstances: 1,
+		WASMBytes:    stubModule{panics: map[string]string{"Broken": "boom"}}.build(),
+	})
+	// The instance is created up front
+	assert.Equal(t, Stats{Instances: 1, IdleInstances: 1}, rt.Stats())
+
+	inst, err := rt.acquire(rt.ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Instances: 1, IdleInstances: 0}, rt.Stats())
+	rt.release(inst)
+
+	// A trapped instance is discarded and replaced on the next render
+	_, err = rt.Render("Broken", nil)
+	require.Error(t, err)
+	assert.Equal(t, Stats{}, rt.Stats())
+
+	_, err = rt.RenderBytes("Echo", []byte("x"))
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Instances: 1, IdleInstances: 1}, rt.Stats())
+}
+
+func TestPool_MaxInstancesBoundsStats(t *testing.T) {
+	rt := newPooledRuntime(t, Options{MaxInstances: 2})
+	// One instance is created up front
+	assert.Equal(t, Stats{Instances: 1, IdleInstances: 1}, rt.Stats())
+
+	// Sample the instance count while the renders run
+	stop := make(chan struct{})
+	peak := make(chan int)
+	go func() {
+		ticker := time.NewTicker(100 * time.Microsecond)
+		defer ticker.Stop()
+		most := 0
+		for {
+			select {
+			case <-stop:
+				peak <- most
+				return
+			case <-ticker.C:
+				most = max(most, rt.Stats().Instances)
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			input := fmt.Sprintf("payload-%d", i)
+			out, err := rt.RenderBytes("Echo", []byte(input))
+			assert.NoError(t, err)
+			assert.Equal(t, input, out)
+		}(i)
+	}
+	wg.Wait()
+	close(stop)
+
+	assert.LessOrEqual(t, <-peak, 2)
+	stats := rt.Stats()
+	assert.LessOrEqual(t, stats.Instances, 2)
+	assert.Equal(t, stats.Instances, stats.IdleInstances)
+}
+
+func TestPool_DiscardsTrappedInstance(t *testing.T) {
+	rt := newPooledRuntime(t, Options{
+		MaxInstances: 1,
+		WASMBytes:    stubModule{panics: map[string]string{"Broken": "boom"}}.build(),
+	})
+
+	_, err := rt.Render("Broken", nil)
+	require.Error(t, err)
+
+	// A fresh instance replaces the trapped one
+	out, err := rt.RenderBytes("Echo", []byte("still works"))
+	require.NoError(t, err)
+	assert.Equal(t, "still works", out)
+}
+
+func TestPool_MemoryDiagnostics(t *testing.T) {
+	rt := newPooledRuntime(t, Options{
+		MaxInstances: 2,
+		WASMBytes:    stubModule{views: map[string]string{"Static": "<p>ok</p>"}, liveAllocs: true}.build(),
+	})
+
+	stats := rt.MemoryDiagnostics()
+	require.Len(t, stats.Instances, 1)
+	assert.Equal(t, uint32(wasmPageSize), stats.Instances[0].Bytes)
+	assert.Equal(t, InstanceMemory{Bytes: wasmPageSize, Allocations: 0}, stats.Peak)
+
+	// A view that allocates nothing settles: repeated renders leave the
+	// high-water mark where the first ones put it
+	render := func(n int) {
+		var wg sync.WaitGroup
+		for range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := rt.Render("Static", nil)
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+	}
+	render(10)
+	settled := rt.MemoryDiagnostics().Peak
+	render(100)
+	stats = rt.MemoryDiagnostics()
+	assert.Equal(t, settled, stats.Peak)
+	assert.LessOrEqual(t, len(stats.Instances), 2)
+
+	// The stub never frees its input buffers, so Echo leaks one allocation
+	// per render
+	for range 5 {
+		_, err := rt.RenderBytes("Echo", []byte("x"))
+		require.NoError(t, err)
+	}
+	var leaked int64
+	for _, inst := range rt.MemoryDiagnostics().Instances {
+		leaked += inst.Allocations
+	}
+	assert.Equal(t, int64(5), leaked)
+}
+
+func TestPool_MemoryDiagnosticsWithoutAllocCount(t *testing.T) {
+	rt := newPooledRuntime(t, Options{})
+
+	_, err := rt.Render("Static", nil)
+	require.NoError(t, err)
+	stats := rt.MemoryDiagnostics()
+	require.Len(t, stats.Instances, 1)
+	assert.Equal(t, int64(-1), stats.Instances[0].Allocations)
+	assert.Equal(t, int64(-1), stats.Peak.Allocations)
+}
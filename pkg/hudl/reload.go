@@ -0,0 +1,119 @@
+package hudl
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// reloadRetryInterval is how long the runtime waits before reconnecting to
+// the dev server's live reload stream.
+var reloadRetryInterval = time.Second
+
+// Stats reports runtime counters.
+type Stats struct {
+	// Reloads is the number of templates the dev server has recompiled
+	// successfully since the runtime started, counted with
+	// Options.WatchReloads.
+	Reloads uint64
+	// ReloadErrors is the number of template edits that failed to compile.
+	ReloadErrors uint64
+	// Instances is the number of live WASM instances, at most
+	// Options.MaxInstances; IdleInstances of them aren't rendering. Both
+	// are zero in dev mode.
+	Instances     int
+	IdleInstances int
+}
+
+// Stats returns a snapshot of the runtime's counters.
+func (r *Runtime) Stats() Stats {
+	s := Stats{
+		Reloads:      r.reloads.Load(),
+		ReloadErrors: r.reloadErrors.Load(),
+	}
+	if r.pool != nil {
+		s.Instances = int(r.pool.live.Load())
+		s.IdleInstances = len(r.pool.idle)
+	}
+	return s
+}
+
+// watchReloads follows the dev server's live reload stream until ctx is
+// cancelled, logging each template reload.
+func (r *Runtime) watchReloads(ctx context.Context) {
+	for {
+		err := r.streamReloads(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		r.logger.Debug("hudl: live reload stream disconnected", "error", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reloadRetryInterval):
+		}
+	}
+}
+
+func (r *Runtime) streamReloads(ctx context.Context) error {
+	url := fmt.Sprintf("http://%s/__hudl/live_reload", r.devServerAddr())
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	// The render client's timeout would cut off the long-lived stream.
+	client := &http.Client{Transport: r.client.Transport}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("live reload stream returned status %d", resp.StatusCode)
+	}
+	r.watchingOnce.Do(func() { close(r.watching) })
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		r.handleReloadEvent([]byte(strings.TrimSpace(data)))
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+// handleReloadEvent logs a reload notification from the dev server.
+func (r *Runtime) handleReloadEvent(data []byte) {
+	var ev struct {
+		Type      string `json:"type"`
+		Component string `json:"component"`
+		File      string `json:"file"`
+		Error     string `json:"error"`
+	}
+	if err := json.Unmarshal(data, &ev); err != nil {
+		return
+	}
+
+	switch ev.Type {
+	case "reload":
+		r.logger.Info("hudl: template reloaded", "file", ev.File, "view", ev.Component)
+		r.reloads.Add(1)
+	case "error":
+		r.logger.Error("hudl: template reload failed", "file", ev.File, "error", ev.Error)
+		r.reloadErrors.Add(1)
+	}
+}
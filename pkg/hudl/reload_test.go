@@ -0,0 +1,96 @@
+package hudl
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// reloadServer is a fake dev server whose live reload stream sends whatever
+// is pushed onto events.
+func reloadServer(t *testing.T) (*httptest.Server, chan<- string) {
+	events := make(chan string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/__hudl/live_reload" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case ev := <-events:
+				fmt.Fprintf(w, "data: %s\n\n", ev)
+				w.(http.Flusher).Flush()
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, events
+}
+
+func TestRuntime_LogsReloads(t *testing.T) {
+	srv, events := reloadServer(t)
+
+	var logs bytes.Buffer
+	rt, err := NewRuntime(context.Background(), Options{
+		DevMode:       true,
+		WatchReloads:  true,
+		DevServerAddr: strings.TrimPrefix(srv.URL, "http://"),
+		Logger:        slog.New(slog.NewTextHandler(&logs, nil)),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	events <- `{"type":"reload","component":"Card","file":"views/card.hudl"}`
+	require.Eventually(t, func() bool { return rt.Stats().Reloads == 1 }, 2*time.Second, 10*time.Millisecond)
+
+	events <- `{"type":"error","error":"Parse error in views/card.hudl","file":"views/card.hudl"}`
+	require.Eventually(t, func() bool { return rt.Stats().ReloadErrors == 1 }, 2*time.Second, 10*time.Millisecond)
+
+	out := logs.String()
+	assert.Contains(t, out, `level=INFO msg="hudl: template reloaded" file=views/card.hudl view=Card`)
+	assert.Contains(t, out, `level=ERROR msg="hudl: template reload failed" file=views/card.hudl error="Parse error in views/card.hudl"`)
+	assert.Equal(t, Stats{Reloads: 1, ReloadErrors: 1}, rt.Stats())
+}
+
+func TestRuntime_ReloadStreamReconnects(t *testing.T) {
+	old := reloadRetryInterval
+	reloadRetryInterval = 10 * time.Millisecond
+	defer func() { reloadRetryInterval = old }()
+
+	var fail = true
+	srv, events := reloadServer(t)
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			fail = false
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		srv.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer flaky.Close()
+
+	rt, err := NewRuntime(context.Background(), Options{
+		DevMode:       true,
+		WatchReloads:  true,
+		DevServerAddr: strings.TrimPrefix(flaky.URL, "http://"),
+		Logger:        slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	events <- `{"type":"reload","component":"Card","file":"views/card.hudl"}`
+	require.Eventually(t, func() bool { return rt.Stats().Reloads == 1 }, 2*time.Second, 10*time.Millisecond)
+}
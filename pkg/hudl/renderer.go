@@ -0,0 +1,95 @@
+package hudl
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"sort"
+	"unicode"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Renderer renders Hudl views. *Runtime implements it; application code can
+// depend on Renderer to swap in another implementation, e.g. in tests.
+type Renderer interface {
+	// Render renders a view with the given proto message data.
+	Render(viewName string, data proto.Message) (string, error)
+	// RenderTo renders a view and writes the output to w.
+	RenderTo(w io.Writer, viewName string, data proto.Message) error
+	// ListViews returns the names of all renderable views, sorted.
+	ListViews() ([]string, error)
+}
+
+// RenderTo renders a view and writes the output to w. At each of the view's
+// flush points, the output so far is written and w is flushed if it is an
+// http.Flusher, before the rest renders. Nothing is written if rendering
+// fails before the first flush point.
+func (r *Runtime) RenderTo(w io.Writer, viewName string, data proto.Message) error {
+	_, err := r.renderStreaming(w, viewName, data, nil)
+	return err
+}
+
+// RenderHTML renders a view as template.HTML, for embedding Hudl components
+// in html/template pages without double-escaping. The output is trusted as
+// is: Hudl escapes interpolated values itself, except through raw().
+func (r *Runtime) RenderHTML(viewName string, data proto.Message) (template.HTML, error) {
+	out, err := r.Render(viewName, data)
+	if err != nil {
+		return "", err
+	}
+	return template.HTML(out), nil
+}
+
+// ListViews returns the names of all renderable views, sorted. In prod mode
+// these are the view exports of the WASM module, followed by the views of
+// mounted bundles as "prefix/View"; in dev mode they are the templates
+// loaded by the dev server.
+func (r *Runtime) ListViews() ([]string, error) {
+	if r.devMode && r.compiled == nil {
+		return r.listDevViews()
+	}
+
+	var views []string
+	for name := range r.compiled.ExportedFunctions() {
+		// Views are PascalCase; ABI helpers like hudl_malloc are not
+		if name != "" && unicode.IsUpper(rune(name[0])) {
+			views = append(views, name)
+		}
+	}
+	sort.Strings(views)
+
+	mounted, err := r.mountedViews()
+	if err != nil {
+		return nil, err
+	}
+	return append(views, mounted...), nil
+}
+
+func (r *Runtime) listDevViews() ([]string, error) {
+	url := fmt.Sprintf("http://%s/views", r.devServerAddr())
+
+	req, err := http.NewRequestWithContext(r.ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("dev mode: failed to create request: %w", err)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("dev mode: request to LSP failed (is hudl-lsp --dev-server running?): %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("dev mode: listing views failed with status %d", resp.StatusCode)
+	}
+
+	var views []string
+	if err := json.NewDecoder(resp.Body).Decode(&views); err != nil {
+		return nil, fmt.Errorf("dev mode: failed to decode view list: %w", err)
+	}
+	sort.Strings(views)
+	return views, nil
+}
@@ -0,0 +1,138 @@
+package hudl
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ Renderer = (*Runtime)(nil)
+
+func TestRuntime_RenderTo(t *testing.T) {
+	rt, err := NewRuntime(context.Background(), Options{
+		WASMBytes: stubWASM(map[string]string{"Card": "<p>Hello</p>"}),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	var buf bytes.Buffer
+	require.NoError(t, rt.RenderTo(&buf, "Card", nil))
+	assert.Equal(t, "<p>Hello</p>", buf.String())
+
+	buf.Reset()
+	require.Error(t, rt.RenderTo(&buf, "Missing", nil))
+	assert.Empty(t, buf.String())
+}
+
+// flushRecorder records the body written by each Flush.
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushed []string
+}
+
+func (f *flushRecorder) Flush() {
+	f.flushed = append(f.flushed, f.Body.String())
+	f.ResponseRecorder.Flush()
+}
+
+func TestRuntime_RenderToFlushPoint(t *testing.T) {
+	const head = "<html><head><title>Shop</title></head>"
+	const body = "<body><p>Products</p></body></html>"
+	rt, err := NewRuntime(context.Background(), Options{
+		WASMBytes: stubModule{flush: slotStub{view: "Page", before: head, after: body}}.build(),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	// The head is written and flushed from within the view, before it returns
+	rec := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	require.NoError(t, rt.RenderTo(rec, "Page", nil))
+	assert.Equal(t, []string{head}, rec.flushed)
+	assert.Equal(t, head+body, rec.Body.String())
+
+	rec = &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	require.NoError(t, rt.RenderToResponse(rec, httptest.NewRequest("GET", "/", nil), "Page", nil))
+	assert.Equal(t, []string{head}, rec.flushed)
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+
+	// Writers that can't flush still get the output in order, and renders
+	// that return a string keep it all
+	var buf bytes.Buffer
+	require.NoError(t, rt.RenderTo(&buf, "Page", nil))
+	assert.Equal(t, head+body, buf.String())
+	html, err := rt.Render("Page", nil)
+	require.NoError(t, err)
+	assert.Equal(t, head+body, html)
+
+	// Middleware sees the whole page, so it is written in one piece
+	rt, err = NewRuntime(context.Background(), Options{
+		WASMBytes:   stubModule{flush: slotStub{view: "Page", before: head, after: body}}.build(),
+		PostProcess: func(_ string, html []byte) ([]byte, error) { return bytes.ToUpper(html), nil },
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+	rec = &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	require.NoError(t, rt.RenderTo(rec, "Page", nil))
+	assert.Empty(t, rec.flushed)
+	assert.Equal(t, strings.ToUpper(head+body), rec.Body.String())
+}
+
+func TestRuntime_RenderHTML(t *testing.T) {
+	rt, err := NewRuntime(context.Background(), Options{
+		WASMBytes: stubWASM(map[string]string{"Card": "<p>Hello &amp; welcome</p>"}),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	card, err := rt.RenderHTML("Card", nil)
+	require.NoError(t, err)
+	assert.IsType(t, template.HTML(""), card)
+
+	page := template.Must(template.New("page").Parse(`<main>{{.Card}}</main>`))
+	var buf bytes.Buffer
+	require.NoError(t, page.Execute(&buf, map[string]any{"Card": card}))
+	assert.Equal(t, "<main><p>Hello &amp; welcome</p></main>", buf.String())
+
+	_, err = rt.RenderHTML("Missing", nil)
+	assert.Error(t, err)
+}
+
+func TestRuntime_ListViews(t *testing.T) {
+	rt, err := NewRuntime(context.Background(), Options{
+		WASMBytes: stubWASM(map[string]string{"Card": "<p>Hello</p>", "Badge": "<b></b>"}),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	views, err := rt.ListViews()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Badge", "Card", "Echo"}, views)
+}
+
+func TestRuntime_ListViews_DevMode(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /views", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `["Dashboard","DashboardClock"]`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	rt, err := NewRuntime(context.Background(), Options{
+		DevMode:       true,
+		DevServerAddr: strings.TrimPrefix(srv.URL, "http://"),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	views, err := rt.ListViews()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Dashboard", "DashboardClock"}, views)
+}
@@ -0,0 +1,132 @@
+package hudl
+
+import (
+	"fmt"
+	"html"
+	"mime"
+	"net/http"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const htmlContentType = "text/html; charset=utf-8"
+
+// RenderToResponse renders a view as an HTTP response. The Content-Type is
+// the one the view's template declares (`// content-type: image/svg+xml`),
+// else Options.DefaultContentType. The response is flushed at the view's
+// flush points as in RenderTo. If rendering fails, nothing is written for the
+// view and Options.ErrorHandler presents the error instead; the error is also
+// returned for logging. A render that fails after a flush point has already
+// sent part of the page, so the error is only returned.
+func (r *Runtime) RenderToResponse(w http.ResponseWriter, req *http.Request, viewName string, data proto.Message) error {
+	wrote, err := r.renderStreaming(w, viewName, data, func() {
+		w.Header().Set("Content-Type", r.contentType(viewName))
+	})
+	if err != nil && !wrote {
+		r.errorHandler()(w, req, err)
+	}
+	return err
+}
+
+// RenderResponse renders a view with its declared content type, so an SVG
+// view is sent as image/svg+xml. It is a deliberate alias of
+// RenderToResponse, named for use alongside the `// content-type:` directive.
+func (r *Runtime) RenderResponse(w http.ResponseWriter, req *http.Request, viewName string, data proto.Message) error {
+	return r.RenderToResponse(w, req, viewName, data)
+}
+
+// contentType returns a view's declared content type, or the runtime's
+// default for modules without a hudl.views section and views that declare
+// none.
+func (r *Runtime) contentType(viewName string) string {
+	if sub, name, ok := r.mounted(viewName); ok {
+		if !r.devMode {
+			return sub.contentType(name)
+		}
+		viewName = name
+	}
+	if r.devMode && r.compiled == nil {
+		// Templates can change under the dev server, so ask every time
+		views, _ := r.devViewsWithSchema()
+		return r.orDefaultContentType(contentTypeOf(views, viewName))
+	}
+
+	r.contentTypesOnce.Do(func() {
+		views, _ := r.ViewsWithSchema()
+		r.contentTypes = make(map[string]string, len(views))
+		for _, v := range views {
+			r.contentTypes[v.Name] = v.ContentType
+		}
+	})
+	return r.orDefaultContentType(r.contentTypes[viewName])
+}
+
+func (r *Runtime) orDefaultContentType(ct string) string {
+	if ct != "" {
+		return withCharset(ct)
+	}
+	if r.defaultContentType == "" {
+		return htmlContentType
+	}
+	return r.defaultContentType
+}
+
+func defaultContentType(opts Options) string {
+	if opts.DefaultContentType == "" {
+		return htmlContentType
+	}
+	return withCharset(opts.DefaultContentType)
+}
+
+func contentTypeOf(views []ViewSchema, viewName string) string {
+	for _, v := range views {
+		if v.Name == viewName {
+			return v.ContentType
+		}
+	}
+	return ""
+}
+
+// withCharset adds charset=utf-8 to text types that don't name a charset,
+// since views always render UTF-8.
+func withCharset(ct string) string {
+	mediaType, params, err := mime.ParseMediaType(ct)
+	if err != nil || !strings.HasPrefix(mediaType, "text/") || params["charset"] != "" {
+		return ct
+	}
+	return ct + "; charset=utf-8"
+}
+
+func (r *Runtime) errorHandler() func(w http.ResponseWriter, r *http.Request, err error) {
+	if r.onError != nil {
+		return r.onError
+	}
+	if r.devMode {
+		return devErrorOverlay
+	}
+	return defaultErrorHandler
+}
+
+func defaultErrorHandler(w http.ResponseWriter, _ *http.Request, _ error) {
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+// devErrorOverlay shows the render error in the browser during development.
+func devErrorOverlay(w http.ResponseWriter, _ *http.Request, err error) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	fmt.Fprintf(w, devOverlayTemplate, html.EscapeString(err.Error()))
+}
+
+const devOverlayTemplate = `<!DOCTYPE html>
+<html>
+<head><title>Hudl render error</title></head>
+<body style="margin:0;font-family:sans-serif;background:#1e1e1e;color:#eee">
+<div style="padding:2rem">
+<h1 style="color:#ff6b6b;font-size:1.25rem">Hudl render error</h1>
+<pre style="white-space:pre-wrap;background:#2d2d2d;padding:1rem;border-radius:4px">%s</pre>
+</div>
+</body>
+</html>
+`
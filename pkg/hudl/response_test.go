@@ -0,0 +1,171 @@
+package hudl
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderToResponse(t *testing.T) {
+	rt, err := NewRuntime(context.Background(), Options{
+		WASMBytes: stubWASM(map[string]string{"Card": "<p>Hello</p>"}),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	w := httptest.NewRecorder()
+	err = rt.RenderToResponse(w, httptest.NewRequest("GET", "/", nil), "Card", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "<p>Hello</p>", w.Body.String())
+}
+
+func TestRenderToResponse_DefaultErrorHandler(t *testing.T) {
+	rt, err := NewRuntime(context.Background(), Options{
+		WASMBytes: stubWASM(map[string]string{"Card": "<p>Hello</p>"}),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	w := httptest.NewRecorder()
+	err = rt.RenderToResponse(w, httptest.NewRequest("GET", "/", nil), "Missing", nil)
+	require.Error(t, err)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NotContains(t, w.Body.String(), "Missing", "prod errors should not leak details")
+}
+
+func TestRenderToResponse_CustomErrorHandler(t *testing.T) {
+	var handled error
+	rt, err := NewRuntime(context.Background(), Options{
+		WASMBytes: stubWASM(map[string]string{"Card": "<p>Hello</p>"}),
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			handled = err
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte(`{"error":"render failed"}`))
+		},
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	w := httptest.NewRecorder()
+	err = rt.RenderToResponse(w, httptest.NewRequest("GET", "/", nil), "Missing", nil)
+	require.Error(t, err)
+
+	assert.Equal(t, err, handled)
+	assert.Contains(t, handled.Error(), "view function Missing not found")
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.JSONEq(t, `{"error":"render failed"}`, w.Body.String())
+}
+
+func TestRenderToResponse_DevOverlay(t *testing.T) {
+	srv := newDevServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Component not found: <Card>"}`))
+	})
+
+	rt, err := NewRuntime(context.Background(), Options{
+		DevMode:       true,
+		DevServerAddr: strings.TrimPrefix(srv.URL, "http://"),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	w := httptest.NewRecorder()
+	err = rt.RenderToResponse(w, httptest.NewRequest("GET", "/", nil), "Card", nil)
+	require.Error(t, err)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "Hudl render error")
+	assert.Contains(t, w.Body.String(), "Component not found: &lt;Card&gt;")
+}
+
+func TestRenderToResponse_ContentTypes(t *testing.T) {
+	rt, err := NewRuntime(context.Background(), Options{
+		WASMBytes: stubModule{
+			views: map[string]string{
+				"Card":   "<p>Hello</p>",
+				"Icon":   "<svg></svg>",
+				"Robots": "User-agent: *",
+			},
+			custom: map[string]string{viewsSection: `[
+				{"name":"Card","message":""},
+				{"name":"Icon","message":"","contentType":"image/svg+xml"},
+				{"name":"Robots","message":"","contentType":"text/plain"}
+			]`},
+		}.build(),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	for view, want := range map[string]string{
+		"Card":   "text/html; charset=utf-8",
+		"Icon":   "image/svg+xml",
+		"Robots": "text/plain; charset=utf-8",
+	} {
+		w := httptest.NewRecorder()
+		require.NoError(t, rt.RenderToResponse(w, httptest.NewRequest("GET", "/", nil), view, nil))
+		assert.Equal(t, want, w.Header().Get("Content-Type"), view)
+	}
+}
+
+func TestRenderToResponse_DefaultContentType(t *testing.T) {
+	rt, err := NewRuntime(context.Background(), Options{
+		WASMBytes:          stubWASM(map[string]string{"Feed": "<rss></rss>"}),
+		DefaultContentType: "application/rss+xml",
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	w := httptest.NewRecorder()
+	require.NoError(t, rt.RenderToResponse(w, httptest.NewRequest("GET", "/", nil), "Feed", nil))
+	assert.Equal(t, "application/rss+xml", w.Header().Get("Content-Type"))
+}
+
+func TestRenderResponse_DeclaredContentType(t *testing.T) {
+	rt, err := NewRuntime(context.Background(), Options{
+		WASMBytes: stubModule{
+			views: map[string]string{
+				"Card": "<p>Hello</p>",
+				"Logo": `<svg xmlns="http://www.w3.org/2000/svg"></svg>`,
+			},
+			custom: map[string]string{viewsSection: `[
+				{"name":"Card","message":""},
+				{"name":"Logo","message":"","contentType":"image/svg+xml"}
+			]`},
+		}.build(),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	w := httptest.NewRecorder()
+	require.NoError(t, rt.RenderResponse(w, httptest.NewRequest("GET", "/", nil), "Card", nil))
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "<p>Hello</p>", w.Body.String())
+
+	w = httptest.NewRecorder()
+	require.NoError(t, rt.RenderResponse(w, httptest.NewRequest("GET", "/logo.svg", nil), "Logo", nil))
+	assert.Equal(t, "image/svg+xml", w.Header().Get("Content-Type"))
+	assert.True(t, strings.HasPrefix(w.Body.String(), "<svg"))
+}
+
+func TestRenderResponse_NoViewsSectionDefaultsToHTML(t *testing.T) {
+	rt, err := NewRuntime(context.Background(), Options{
+		WASMBytes: stubWASM(map[string]string{"Logo": "<svg></svg>"}),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	w := httptest.NewRecorder()
+	require.NoError(t, rt.RenderResponse(w, httptest.NewRequest("GET", "/", nil), "Logo", nil))
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+}
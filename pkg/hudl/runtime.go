@@ -4,14 +4,20 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/tetratelabs/wazero"
-	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
 	"google.golang.org/protobuf/proto"
 )
@@ -25,24 +31,118 @@ type Options struct {
 	// If empty, it will check the HUDL_DEV_ADDR environment variable.
 	DevServerAddr string
 	// WASMBytes is the compiled WASM module data (required in prod mode).
+	// In dev mode it is optional and enables VerifyConsistency.
 	WASMBytes []byte
+	// WASMPath is the file the module is loaded from. If WASMBytes is nil it
+	// is read from here; it is also what SourcesDir is checked against.
+	WASMPath string
+	// SourcesDir, when set in prod mode, is scanned for .hudl files at
+	// startup; if any is newer than WASMPath a warning is logged, since the
+	// module renders stale HTML until it is rebuilt.
+	SourcesDir string
+	// FailOnStale makes a stale module a startup error (ErrStaleWASM)
+	// instead of a warning.
+	FailOnStale bool
 	// HttpClient is used for dev mode requests (optional).
 	HttpClient *http.Client
+	// Logger receives anything the WASM module writes to stdout/stderr,
+	// such as Rust panic messages (default: slog.Default()).
+	Logger *slog.Logger
+	// MaxInstances is the number of WASM module instances, and therefore
+	// concurrent renders, in the pool (default: GOMAXPROCS). Instances are created on
+	// demand and reused.
+	MaxInstances int
+	// AcquireTimeout bounds how long a render waits for a free instance when
+	// all MaxInstances are busy; it then fails with ErrPoolExhausted.
+	// Zero (the default) waits indefinitely, and a negative value fails
+	// at once instead of waiting.
+	AcquireTimeout time.Duration
+	// MaxMemoryPages caps each WASM instance's memory, in 64KiB pages
+	// (default: wazero's limit of 65536 pages, i.e. 4GiB). Renders whose input
+	// the module can't allocate within the cap fail with ErrOutOfMemory.
+	MaxMemoryPages uint32
+	// DefaultContentType is the Content-Type RenderToResponse sends for views
+	// whose template declares none (default: text/html; charset=utf-8).
+	// Text types without a charset get charset=utf-8.
+	DefaultContentType string
+	// ErrorHandler presents render failures in RenderToResponse. The default
+	// replies with a plain 500, or with an error overlay page in dev mode.
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+	// DisableWASI skips instantiating wasi_snapshot_preview1 for modules
+	// that don't import it, saving startup work. A module that does import
+	// it then fails to load with ErrWASIDisabled.
+	DisableWASI bool
+	// Middleware wraps every render (Render, RenderBytes, RenderRaw, their
+	// Context variants and everything built on them), in order: the first
+	// entry is outermost.
+	Middleware []RenderMiddleware
+	// SlowRenderThreshold, if set, logs a warning with the view name and
+	// duration for each render that takes longer, to spot pathological
+	// templates without wiring up full metrics.
+	SlowRenderThreshold time.Duration
+	// PostProcess, if set, rewrites the HTML of every render that goes
+	// through Middleware, e.g. to point asset URLs at a CDN or inline
+	// critical CSS. It gets the view name for context and runs inside
+	// Middleware; an error fails the render.
+	PostProcess func(view string, html []byte) ([]byte, error)
+	// AssetPrefix, if set, is prepended to the root-relative asset URLs in
+	// rendered HTML, for apps served under a path prefix or with assets on a
+	// CDN: with "https://cdn.example.com/app", <link href="/style.css">
+	// loads https://cdn.example.com/app/style.css. Only <link href>,
+	// <script src> and <img src> are rewritten, before PostProcess runs.
+	AssetPrefix string
+	// SourceComments makes dev mode renders mark each element with the
+	// .hudl line it came from, as <!-- card.hudl:12 -->. Prod mode output
+	// never has them.
+	SourceComments bool
+	// DevAddrFromEnv makes dev mode re-read HUDL_DEV_ADDR before each
+	// request to the dev server, so one restarted on another port is
+	// picked up without restarting the app. While the variable is empty the
+	// current address is kept.
+	DevAddrFromEnv bool
+	// WatchReloads makes dev mode follow the dev server's live reload
+	// stream, logging each template the server recompiles and counting them
+	// in Stats. It reconnects until Close if the stream drops.
+	WatchReloads bool
 }
 
 // Runtime renders Hudl templates.
 type Runtime struct {
 	// WASM runtime (prod mode)
-	rt     wazero.Runtime
-	mod    api.Module
-	ctx    context.Context
-	malloc api.Function
-	free   api.Function
+	rt       wazero.Runtime
+	compiled wazero.CompiledModule
+	pool     *instancePool
+	ctx      context.Context
+	logger   *slog.Logger
+	onError  func(w http.ResponseWriter, r *http.Request, err error)
+	// render is renderProto wrapped in Options.Middleware, or nil when no
+	// middleware is configured
+	render RenderFunc
+
+	// Bundles added with Mount, by prefix; moduleOpts holds the module
+	// options they are created with
+	mountsMu   sync.RWMutex
+	mounts     map[string]*Runtime
+	moduleOpts Options
+
+	// Declared content types by view, read once from the module
+	contentTypesOnce   sync.Once
+	contentTypes       map[string]string
+	defaultContentType string
 
 	// Dev mode
-	devMode bool
-	devAddr string
-	client  *http.Client
+	devMode        bool
+	devAddrMu      sync.RWMutex
+	devAddr        string
+	devAddrFromEnv bool
+	sourceComments bool
+	client         *http.Client
+	stopWatch      context.CancelFunc
+	reloads        atomic.Uint64
+	reloadErrors   atomic.Uint64
+	// watching is closed once the live reload stream first connects
+	watching     chan struct{}
+	watchingOnce sync.Once
 }
 
 // NewRuntime creates a new Hudl runtime with the given options.
@@ -62,6 +162,11 @@ func NewRuntime(ctx context.Context, opts Options) (*Runtime, error) {
 		}
 	}
 
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
 	if devMode {
 		client := opts.HttpClient
 		if client == nil {
@@ -69,48 +174,134 @@ func NewRuntime(ctx context.Context, opts Options) (*Runtime, error) {
 				Timeout: 5 * time.Second,
 			}
 		}
-		return &Runtime{
-			ctx:     ctx,
-			devMode: true,
-			devAddr: devAddr,
-			client:  client,
-		}, nil
+		rt := &Runtime{
+			ctx:            ctx,
+			devMode:        true,
+			devAddr:        devAddr,
+			devAddrFromEnv: opts.DevAddrFromEnv,
+			sourceComments: opts.SourceComments,
+			client:         client,
+			logger:         logger,
+			onError:        opts.ErrorHandler,
+
+			defaultContentType: defaultContentType(opts),
+		}
+		if mw := renderMiddleware(opts, logger); len(mw) > 0 {
+			rt.render = chainMiddleware(rt.renderProto, mw)
+		}
+		rt.moduleOpts = moduleOptions(opts)
+		// WASM is optional in dev mode; when provided it enables VerifyConsistency.
+		if opts.WASMBytes != nil {
+			if err := rt.initWASM(opts); err != nil {
+				return nil, err
+			}
+		}
+
+		if opts.WatchReloads {
+			watchCtx, stop := context.WithCancel(ctx)
+			rt.stopWatch = stop
+			rt.watching = make(chan struct{})
+			go rt.watchReloads(watchCtx)
+		}
+		return rt, nil
 	}
 
 	// Prod mode: initialize WASM
+	if opts.WASMBytes == nil && opts.WASMPath != "" {
+		wasmBytes, err := os.ReadFile(opts.WASMPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", opts.WASMPath, err)
+		}
+		opts.WASMBytes = wasmBytes
+	}
 	if opts.WASMBytes == nil {
 		return nil, fmt.Errorf("wasmBytes required in prod mode (set HUDL_DEV=1 for dev mode)")
 	}
 
-	r := wazero.NewRuntime(ctx)
-	wasi_snapshot_preview1.MustInstantiate(ctx, r)
+	rt := &Runtime{ctx: ctx, logger: logger, onError: opts.ErrorHandler, defaultContentType: defaultContentType(opts)}
+	if mw := renderMiddleware(opts, logger); len(mw) > 0 {
+		rt.render = chainMiddleware(rt.renderProto, mw)
+	}
+	rt.moduleOpts = moduleOptions(opts)
+	if opts.SourcesDir != "" {
+		if err := rt.checkStale(opts); err != nil {
+			return nil, err
+		}
+	}
+	if err := rt.initWASM(opts); err != nil {
+		return nil, err
+	}
+	return rt, nil
+}
 
-	mod, err := r.Instantiate(ctx, opts.WASMBytes)
-	if err != nil {
-		r.Close(ctx)
-		return nil, fmt.Errorf("failed to instantiate module: %w", err)
+// moduleOptions keeps the options initWASM uses, other than the module itself.
+func moduleOptions(opts Options) Options {
+	return Options{
+		MaxInstances:   opts.MaxInstances,
+		AcquireTimeout: opts.AcquireTimeout,
+		MaxMemoryPages: opts.MaxMemoryPages,
+		DisableWASI:    opts.DisableWASI,
 	}
+}
 
-	malloc := mod.ExportedFunction("hudl_malloc")
-	free := mod.ExportedFunction("hudl_free")
+func (r *Runtime) initWASM(opts Options) error {
+	// Close modules when a render's context is done, so a slow view can't
+	// outlive its deadline.
+	// Custom sections are kept for ViewsWithSchema
+	config := wazero.NewRuntimeConfig().WithCloseOnContextDone(true).WithCustomSections(true)
+	if opts.MaxMemoryPages > 0 {
+		config = config.WithMemoryLimitPages(opts.MaxMemoryPages)
+	}
+	rt := wazero.NewRuntimeWithConfig(r.ctx, config)
+	if !opts.DisableWASI {
+		wasi_snapshot_preview1.MustInstantiate(r.ctx, rt)
+	}
+	// Views with a flush node import hudl.flush
+	_, err := rt.NewHostModuleBuilder("hudl").
+		NewFunctionBuilder().WithFunc(hostFlush).Export("flush").
+		Instantiate(r.ctx)
+	if err != nil {
+		rt.Close(r.ctx)
+		return fmt.Errorf("failed to instantiate host module: %w", err)
+	}
 
-	if malloc == nil || free == nil {
-		r.Close(ctx)
-		return nil, fmt.Errorf("missing required exports: hudl_malloc or hudl_free")
+	compiled, err := rt.CompileModule(r.ctx, opts.WASMBytes)
+	if err != nil {
+		rt.Close(r.ctx)
+		return fmt.Errorf("failed to compile module: %w", err)
 	}
+	if opts.DisableWASI {
+		for _, fn := range compiled.ImportedFunctions() {
+			if module, name, _ := fn.Import(); module == wasi_snapshot_preview1.ModuleName {
+				rt.Close(r.ctx)
+				return fmt.Errorf("%w: module imports %s.%s", ErrWASIDisabled, module, name)
+			}
+		}
+	}
+
+	r.rt = rt
+	r.compiled = compiled
+	r.pool = newInstancePool(opts.MaxInstances, opts.AcquireTimeout)
 
-	return &Runtime{
-		rt:     r,
-		mod:    mod,
-		ctx:    ctx,
-		malloc: malloc,
-		free:   free,
-	}, nil
+	// Instantiate one instance up front so a bad module fails here rather
+	// than on the first render.
+	inst, err := r.newInstance()
+	if err != nil {
+		rt.Close(r.ctx)
+		return err
+	}
+	r.pool.idle <- inst
+	return nil
 }
 
+// ErrWASIDisabled is returned when Options.DisableWASI is set but the module
+// imports WASI, as modules built by hudlc do.
+var ErrWASIDisabled = errors.New("hudl: module imports WASI but Options.DisableWASI is set")
+
 // MustNewRuntime creates a new Hudl runtime with default logic:
 // 1. If HUDL_DEV is set, connects to the LSP sidecar.
-// 2. Otherwise, loads views.wasm from the current directory and initializes WASM.
+// 2. Otherwise, loads views.wasm from the current directory and initializes WASM,
+// warning if a template under views/ is newer than it.
 // It panics on failure.
 func MustNewRuntime(ctx context.Context) *Runtime {
 	opts := Options{}
@@ -121,6 +312,10 @@ func MustNewRuntime(ctx context.Context) *Runtime {
 			panic(fmt.Sprintf("failed to read views.wasm: %v (set HUDL_DEV=1 for dev mode)", err))
 		}
 		opts.WASMBytes = wasmBytes
+		opts.WASMPath = "views.wasm"
+		if info, err := os.Stat("views"); err == nil && info.IsDir() {
+			opts.SourcesDir = "views"
+		}
 	}
 
 	rt, err := NewRuntime(ctx, opts)
@@ -135,47 +330,203 @@ func NewRuntimeFromWASM(ctx context.Context, wasmBytes []byte) (*Runtime, error)
 	return NewRuntime(ctx, Options{WASMBytes: wasmBytes})
 }
 
+// NewRuntimeFromBundle creates a runtime from a file system containing views.wasm
+// at its root, such as the embed.FS generated by `hudl bundle`.
+// Dev mode (HUDL_DEV) still takes precedence over the bundled WASM.
+func NewRuntimeFromBundle(ctx context.Context, bundle fs.FS) (*Runtime, error) {
+	opts := Options{}
+
+	if os.Getenv("HUDL_DEV") == "" {
+		wasmBytes, err := fs.ReadFile(bundle, "views.wasm")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read views.wasm from bundle: %w", err)
+		}
+		opts.WASMBytes = wasmBytes
+	}
+
+	return NewRuntime(ctx, opts)
+}
+
+// Close shuts the runtime down. Renders already running finish first; any
+// that start or are waiting for an instance fail with ErrClosed. Mounted
+// bundles are closed with it.
 func (r *Runtime) Close() error {
+	if r.stopWatch != nil {
+		r.stopWatch()
+	}
+	err := r.closeMounts()
+	if r.pool != nil {
+		r.drain()
+	}
 	if r.rt != nil {
-		return r.rt.Close(r.ctx)
+		return errors.Join(err, r.rt.Close(r.ctx))
 	}
-	return nil
+	return err
 }
 
 // Render renders a view with the given proto message data.
 func (r *Runtime) Render(viewName string, data proto.Message) (string, error) {
-	var params []byte
-	if data != nil {
-		var err error
-		params, err = proto.Marshal(data)
-		if err != nil {
-			return "", fmt.Errorf("failed to marshal data to proto: %w", err)
-		}
+	return r.RenderContext(r.ctx, viewName, data)
+}
+
+// RenderContext renders a view like Render, but gives up when ctx is done.
+// In prod mode a view still running at that point is aborted and its error
+// wraps ctx.Err().
+func (r *Runtime) RenderContext(ctx context.Context, viewName string, data proto.Message) (string, error) {
+	params, err := marshalData(data)
+	if err != nil {
+		return "", err
+	}
+	return r.RenderBytesContext(ctx, viewName, params)
+}
+
+// marshalData returns data in proto wire format; nil data is empty.
+func marshalData(data proto.Message) ([]byte, error) {
+	if data == nil {
+		return nil, nil
+	}
+	params, err := proto.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal data to proto: %w", err)
 	}
+	return params, nil
+}
 
+// renderProto is the render at the core of the middleware chain.
+func (r *Runtime) renderProto(ctx context.Context, viewName string, protoBytes []byte) (string, error) {
 	if r.devMode {
-		return r.renderDev(viewName, params)
+		return r.renderDev(ctx, viewName, protoBytes)
 	}
-	return r.renderWASM(viewName, params)
+	return r.renderWASM(ctx, viewName, protoBytes)
+}
+
+// RenderWithTimeout renders a view, giving up after timeout.
+func (r *Runtime) RenderWithTimeout(viewName string, data proto.Message, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(r.ctx, timeout)
+	defer cancel()
+	return r.RenderContext(ctx, viewName, data)
+}
+
+// RenderOr renders a view, returning fallback instead if the render fails,
+// so a non-critical widget can degrade without failing the page around it.
+// The error is logged.
+func (r *Runtime) RenderOr(viewName string, data proto.Message, fallback string) string {
+	html, err := r.Render(viewName, data)
+	if err != nil {
+		r.logger.Warn("hudl: render failed, using fallback", "view", viewName, "error", err)
+		return fallback
+	}
+	return html
 }
 
 // RenderBytes renders a view with raw proto wire format bytes.
 func (r *Runtime) RenderBytes(viewName string, protoBytes []byte) (string, error) {
+	return r.RenderBytesContext(r.ctx, viewName, protoBytes)
+}
+
+// RenderBytesContext renders raw proto bytes like RenderBytes, giving up
+// when ctx is done as in RenderContext. Wrappers generated with -context
+// call it.
+func (r *Runtime) RenderBytesContext(ctx context.Context, viewName string, protoBytes []byte) (string, error) {
+	if r.render != nil {
+		return r.render(ctx, viewName, protoBytes)
+	}
+	return r.renderProto(ctx, viewName, protoBytes)
+}
+
+// RenderRaw renders raw proto bytes like RenderBytes but returns the output
+// as bytes, for callers that pass it on as bytes (e.g. across cgo/FFI, where
+// the length travels with the slice). Output goes through Options.Middleware
+// like any other render. Without middleware, in prod mode the output is
+// copied out of WASM memory once, straight into the returned slice, which
+// the caller owns; no string is built.
+func (r *Runtime) RenderRaw(viewName string, protoBytes []byte) ([]byte, error) {
+	return r.RenderRawContext(r.ctx, viewName, protoBytes)
+}
+
+// RenderRawContext is RenderRaw giving up when ctx is done. Wrappers
+// generated with -bytes and -context call it.
+func (r *Runtime) RenderRawContext(ctx context.Context, viewName string, protoBytes []byte) ([]byte, error) {
+	if r.render != nil {
+		out, err := r.render(ctx, viewName, protoBytes)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(out), nil
+	}
 	if r.devMode {
-		return r.renderDev(viewName, protoBytes)
+		out, err := r.renderDev(ctx, viewName, protoBytes)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(out), nil
+	}
+	return r.renderRawWASM(ctx, viewName, protoBytes)
+}
+
+// RenderSize renders a view and returns only the byte length of the output,
+// e.g. for Content-Length or pre-sizing buffers. The length is that of the
+// output Render returns, Options.Middleware included. It still performs a
+// full render; without middleware, in prod mode it just avoids copying the
+// output out of WASM memory.
+func (r *Runtime) RenderSize(viewName string, data proto.Message) (int, error) {
+	params, err := marshalData(data)
+	if err != nil {
+		return 0, err
+	}
+	if r.render != nil || r.devMode {
+		html, err := r.RenderBytesContext(r.ctx, viewName, params)
+		return len(html), err
 	}
-	return r.renderWASM(viewName, protoBytes)
+	return r.runView(r.ctx, viewName, params, nil)
 }
 
-func (r *Runtime) renderDev(viewName string, protoBytes []byte) (string, error) {
-	url := fmt.Sprintf("http://%s/render", r.devAddr)
+// RenderDevJSON renders a view in dev mode from a raw JSON body, for quick
+// iteration on templates without constructing proto messages.
+// It is only available in dev mode.
+func (r *Runtime) RenderDevJSON(viewName string, jsonBody []byte) (string, error) {
+	if !r.devMode {
+		return "", fmt.Errorf("RenderDevJSON is only available in dev mode (set HUDL_DEV=1)")
+	}
+	return r.postDev(r.ctx, viewName, "application/json", jsonBody, true, r.sourceComments)
+}
 
-	req, err := http.NewRequestWithContext(r.ctx, "POST", url, bytes.NewReader(protoBytes))
+func (r *Runtime) renderDev(ctx context.Context, viewName string, protoBytes []byte) (string, error) {
+	return r.postDev(ctx, viewName, "application/x-protobuf", protoBytes, true, r.sourceComments)
+}
+
+func (r *Runtime) postDev(ctx context.Context, viewName, contentType string, body []byte, liveReload, sourceComments bool) (string, error) {
+	if _, name, ok := r.mounted(viewName); ok {
+		viewName = name
+	}
+	url := fmt.Sprintf("http://%s/render", r.devServerAddr())
+
+	content := slotContent(ctx)
+	if content != "" {
+		body = append([]byte(content), body...)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
 	if err != nil {
 		return "", fmt.Errorf("dev mode: failed to create request: %w", err)
 	}
+	if content != "" {
+		req.Header.Set("X-Hudl-Slot-Length", strconv.Itoa(len(content)))
+	}
 	req.Header.Set("X-Hudl-Component", viewName)
-	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Type", contentType)
+	if !liveReload {
+		req.Header.Set("X-Hudl-No-Reload", "1")
+	}
+	if nonce := cspNonce(ctx); nonce != "" {
+		req.Header.Set("X-Hudl-CSP-Nonce", nonce)
+	}
+	if locale := LocaleFromContext(ctx); locale != "" {
+		req.Header.Set("X-Hudl-Locale", locale)
+	}
+	if sourceComments {
+		req.Header.Set("X-Hudl-Source-Comments", "1")
+	}
 
 	resp, err := r.client.Do(req)
 	if err != nil {
@@ -183,58 +534,215 @@ func (r *Runtime) renderDev(viewName string, protoBytes []byte) (string, error)
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return "", fmt.Errorf("dev mode: failed to read response: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		var errResp struct {
-			Error string `json:"error"`
-		}
-		if json.Unmarshal(body, &errResp) == nil && errResp.Error != "" {
-			return "", fmt.Errorf("dev mode: render error: %s", errResp.Error)
-		}
-		return "", fmt.Errorf("dev mode: render failed with status %d: %s", resp.StatusCode, string(body))
+		return "", devStatusError(resp.StatusCode, respBody)
+	}
+
+	return string(respBody), nil
+}
+
+// ErrDevStatus is returned when the dev server answers a render with a
+// status other than 200 OK, so callers can branch on Code with errors.As:
+// 404 for a view it doesn't know, 500 for a template error.
+type ErrDevStatus struct {
+	// Code is the response's HTTP status code.
+	Code int
+	// Body is the response body as sent.
+	Body string
+	// Message is the error from a JSON body ({"error": ...}), if any, and
+	// Location the template position it reported as file:line:column.
+	Message  string
+	Location string
+}
+
+func (e *ErrDevStatus) Error() string {
+	switch {
+	case e.Message == "":
+		return fmt.Sprintf("dev mode: render failed with status %d: %s", e.Code, e.Body)
+	case e.Location != "":
+		return fmt.Sprintf("dev mode: render error at %s: %s", e.Location, e.Message)
+	default:
+		return "dev mode: render error: " + e.Message
 	}
+}
 
-	return string(body), nil
+// devStatusError builds the error for a non-200 render response, taking the
+// message and location from a JSON body when there is one.
+func devStatusError(code int, body []byte) *ErrDevStatus {
+	err := &ErrDevStatus{Code: code, Body: string(body)}
+	var errResp struct {
+		Error  string `json:"error"`
+		File   string `json:"file"`
+		Line   int    `json:"line"`
+		Column int    `json:"column"`
+	}
+	if json.Unmarshal(body, &errResp) == nil && errResp.Error != "" {
+		err.Message = errResp.Error
+		err.Location = devErrorLocation(errResp.File, errResp.Line, errResp.Column)
+	}
+	return err
 }
 
-func (r *Runtime) renderWASM(viewName string, protoBytes []byte) (string, error) {
-	renderFunc := r.mod.ExportedFunction(viewName)
+// devErrorLocation formats the optional template location of a dev server
+// error as file:line:column, omitting whatever the server didn't report.
+func devErrorLocation(file string, line, column int) string {
+	switch {
+	case file == "":
+		return ""
+	case line <= 0:
+		return file
+	case column <= 0:
+		return fmt.Sprintf("%s:%d", file, line)
+	default:
+		return fmt.Sprintf("%s:%d:%d", file, line, column)
+	}
+}
+
+func (r *Runtime) renderWASM(ctx context.Context, viewName string, protoBytes []byte) (string, error) {
+	var out string
+	_, err := r.runView(ctx, viewName, protoBytes, func(b []byte) { out = string(b) })
+	return out, err
+}
+
+func (r *Runtime) renderRawWASM(ctx context.Context, viewName string, protoBytes []byte) ([]byte, error) {
+	var out []byte
+	_, err := r.runView(ctx, viewName, protoBytes, func(b []byte) { out = bytes.Clone(b) })
+	return out, err
+}
+
+// runView renders a view on a pooled instance and returns the output size.
+// If read is set it is passed the output while it is still in WASM memory,
+// and must copy whatever it keeps.
+func (r *Runtime) runView(ctx context.Context, viewName string, protoBytes []byte, read func([]byte)) (int, error) {
+	if sub, name, ok := r.mounted(viewName); ok {
+		return sub.runView(ctx, name, protoBytes, read)
+	}
+	inst, err := r.acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer r.release(inst)
+
+	ptr, size, err := r.callView(ctx, inst, viewName, protoBytes)
+	if err != nil {
+		return 0, err
+	}
+	defer inst.free.Call(r.ctx, uint64(ptr), uint64(size))
+
+	if read == nil {
+		return int(size), nil
+	}
+
+	outBytes, ok := inst.mod.Memory().Read(ptr, size)
+	if !ok {
+		return 0, fmt.Errorf("failed to read result from memory at %d (size %d)", ptr, size)
+	}
+	read(outBytes)
+
+	return int(size), nil
+}
+
+// callView invokes a view export and returns the location of its output in
+// module memory. The caller must free it. Cleanup calls use the runtime's
+// context, since ctx may already be done.
+func (r *Runtime) callView(ctx context.Context, inst *instance, viewName string, protoBytes []byte) (ptr, size uint32, err error) {
+	renderFunc := inst.view(viewName)
 	if renderFunc == nil {
-		return "", fmt.Errorf("view function %s not found", viewName)
+		return 0, 0, fmt.Errorf("view function %s not found", viewName)
+	}
+
+	if err := r.setNonce(ctx, inst, cspNonce(ctx)); err != nil {
+		return 0, 0, err
+	}
+	if err := r.setLocale(ctx, inst, LocaleFromContext(ctx)); err != nil {
+		return 0, 0, err
+	}
+	if err := r.setContent(ctx, inst, slotContent(ctx)); err != nil {
+		return 0, 0, err
 	}
 
 	paramPtr := uint64(0)
 	if len(protoBytes) > 0 {
-		results, err := r.malloc.Call(r.ctx, uint64(len(protoBytes)))
+		paramPtr, err = r.malloc(ctx, inst, len(protoBytes))
 		if err != nil {
-			return "", fmt.Errorf("malloc failed: %w", err)
+			return 0, 0, err
 		}
-		paramPtr = results[0]
-		if !r.mod.Memory().Write(uint32(paramPtr), protoBytes) {
-			return "", fmt.Errorf("failed to write params to memory")
+		defer inst.free.Call(r.ctx, paramPtr, uint64(len(protoBytes)))
+		if err := writeMemory(inst, uint32(paramPtr), protoBytes); err != nil {
+			return 0, 0, err
 		}
-		defer r.free.Call(r.ctx, paramPtr, uint64(len(protoBytes)))
 	}
 
-	results, err := renderFunc.Call(r.ctx, paramPtr, uint64(len(protoBytes)))
+	results, err := renderFunc.Call(ctx, paramPtr, uint64(len(protoBytes)))
+	stderr := r.flushOutput(inst, viewName)
 	if err != nil {
-		return "", fmt.Errorf("render failed: %w", err)
+		// A trapped module may be left in an inconsistent state
+		inst.broken = true
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return 0, 0, fmt.Errorf("render failed: %w", ctxErr)
+		}
+		if stderr != "" {
+			return 0, 0, fmt.Errorf("render failed: %w (stderr: %s)", err, stderr)
+		}
+		return 0, 0, fmt.Errorf("render failed: %w", err)
 	}
 
 	packed := results[0]
-	ptr := uint32(packed >> 32)
-	size := uint32(packed)
+	return uint32(packed >> 32), uint32(packed), nil
+}
 
-	outBytes, ok := r.mod.Memory().Read(ptr, size)
-	if !ok {
-		return "", fmt.Errorf("failed to read result from memory at %d (size %d)", ptr, size)
+// ErrOutOfMemory is returned when the module can't allocate room for a
+// render's input, even after growing memory up to Options.MaxMemoryPages.
+var ErrOutOfMemory = errors.New("hudl: input does not fit in WASM memory")
+
+// malloc allocates size bytes with the module's hudl_malloc. Only the
+// module's allocator grows memory, so an allocation it can't satisfy, whether
+// it traps or returns null, fails with ErrOutOfMemory.
+func (r *Runtime) malloc(ctx context.Context, inst *instance, size int) (uint64, error) {
+	results, err := inst.malloc.Call(ctx, uint64(size))
+	if err != nil {
+		inst.broken = true
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return 0, fmt.Errorf("render failed: %w", ctxErr)
+		}
+		return 0, fmt.Errorf("%w: %d-byte input, memory is %d bytes: %v", ErrOutOfMemory, size, inst.mod.Memory().Size(), err)
+	}
+	if results[0] == 0 {
+		return 0, fmt.Errorf("%w: %d-byte input, memory is %d bytes", ErrOutOfMemory, size, inst.mod.Memory().Size())
 	}
+	return results[0], nil
+}
 
-	defer r.free.Call(r.ctx, uint64(ptr), uint64(size))
+// writeMemory copies data into the instance's memory at ptr, which the
+// module's allocator returned.
+func writeMemory(inst *instance, ptr uint32, data []byte) error {
+	if !inst.mod.Memory().Write(ptr, data) {
+		return fmt.Errorf("failed to write %d bytes to memory at offset %d", len(data), ptr)
+	}
+	return nil
+}
 
-	return string(outBytes), nil
-}
\ No newline at end of file
+// flushOutput logs and clears anything the module wrote to stdout/stderr
+// during a render, returning the stderr text so it can be surfaced in errors.
+func (r *Runtime) flushOutput(inst *instance, viewName string) string {
+	if inst.stdout.Len() == 0 && inst.stderr.Len() == 0 {
+		return ""
+	}
+	stdout := strings.TrimSpace(inst.stdout.String())
+	stderr := strings.TrimSpace(inst.stderr.String())
+	inst.stdout.Reset()
+	inst.stderr.Reset()
+
+	if stdout != "" {
+		r.logger.Info("hudl: wasm stdout", "view", viewName, "output", stdout)
+	}
+	if stderr != "" {
+		r.logger.Warn("hudl: wasm stderr", "view", viewName, "output", stderr)
+	}
+	return stderr
+}
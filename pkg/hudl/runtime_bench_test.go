@@ -0,0 +1,97 @@
+package hudl
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/njreid/hudl/pkg/hudl/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+// The Simple benchmarks compare Render, which marshals its message on every
+// call, with RenderBytes given the same message marshaled once up front.
+// The gap is the cost of proto.Marshal, which RenderBytes callers that cache
+// or forward wire-format data skip. It grows with the size of the message.
+
+func benchRuntime(b *testing.B) *Runtime {
+	b.Helper()
+	wasmBytes, err := os.ReadFile("../../views.wasm")
+	if err != nil {
+		b.Skip("views.wasm not found, skipping runtime benchmark")
+	}
+	rt, err := NewRuntimeFromWASM(context.Background(), wasmBytes)
+	if err != nil {
+		b.Fatalf("Failed to create runtime: %v", err)
+	}
+	b.Cleanup(func() { rt.Close() })
+	return rt
+}
+
+var benchSimpleData = &pb.SimpleData{Title: "Hello", Description: "World"}
+
+func BenchmarkRender_Simple(b *testing.B) {
+	rt := benchRuntime(b)
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := rt.Render("Simple", benchSimpleData); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRenderBytes_Simple(b *testing.B) {
+	rt := benchRuntime(b)
+	protoBytes, err := proto.Marshal(benchSimpleData)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := rt.RenderBytes("Simple", protoBytes); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkRenderBytes_Stub measures the per-call overhead of a render
+// (pool, input copy, view call, output read) against the stub module,
+// whose Echo view does no work of its own.
+func BenchmarkRenderBytes_Stub(b *testing.B) {
+	rt, err := NewRuntime(context.Background(), Options{WASMBytes: stubWASM(nil)})
+	if err != nil {
+		b.Fatalf("Failed to create runtime: %v", err)
+	}
+	defer rt.Close()
+
+	input := []byte("<p>Hello, World</p>")
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := rt.RenderBytes("Echo", input); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkRenderN_Dashboard times the Dashboard view with RenderN, as a
+// user harness over their own templates would, reporting the time per
+// render without the pool or output copy.
+func BenchmarkRenderN_Dashboard(b *testing.B) {
+	rt := benchRuntime(b)
+	data := &pb.DashboardData{
+		RevenueFormatted: "$12,345.67",
+		ActiveUsers:      2847,
+		SystemLoad:       73,
+		Transactions: []*pb.Transaction{
+			{Id: "TXN-001", CustomerName: "Alice Johnson", CustomerEmail: "alice@example.com", AmountFormatted: "$150.00", Status: pb.TransactionStatus_STATUS_ACTIVE},
+			{Id: "TXN-002", CustomerName: "Bob Smith", CustomerEmail: "bob@example.com", AmountFormatted: "$75.00", Status: pb.TransactionStatus_STATUS_PENDING},
+		},
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	elapsed, err := rt.RenderN("Dashboard", data, b.N)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportMetric(float64(elapsed.Nanoseconds())/float64(b.N), "ns/render")
+}
@@ -1,10 +1,14 @@
 package hudl
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"log/slog"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/njreid/hudl/pkg/hudl/pb"
 )
@@ -661,3 +665,270 @@ func TestRuntime_EmptyCollections(t *testing.T) {
 		t.Errorf("Expected some HTML output even with empty features")
 	}
 }
+
+func TestRuntime_RenderSize(t *testing.T) {
+	ctx := context.Background()
+	rt, err := NewRuntimeFromWASM(ctx, stubWASM(map[string]string{"Static": "<p>Hello</p>"}))
+	if err != nil {
+		t.Fatalf("Failed to create runtime: %v", err)
+	}
+	defer rt.Close()
+
+	data := &pb.SimpleData{Title: "Hello", Features: []string{"a", "b"}}
+	for _, view := range []string{"Static", "Echo"} {
+		output, err := rt.Render(view, data)
+		if err != nil {
+			t.Fatalf("Render(%s) failed: %v", view, err)
+		}
+		size, err := rt.RenderSize(view, data)
+		if err != nil {
+			t.Fatalf("RenderSize(%s) failed: %v", view, err)
+		}
+		if size != len(output) {
+			t.Errorf("RenderSize(%s) = %d, want %d", view, size, len(output))
+		}
+	}
+
+	if _, err := rt.RenderSize("NonExistentView", nil); err == nil {
+		t.Errorf("Expected error for non-existent view")
+	}
+}
+
+func TestRuntime_CapturesWASMStderr(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	wasm := stubModule{
+		stderr: map[string]string{"Noisy": "warning: missing field"},
+		panics: map[string]string{"Broken": "panicked at 'index out of bounds'"},
+	}.build()
+
+	ctx := context.Background()
+	rt, err := NewRuntime(ctx, Options{WASMBytes: wasm, Logger: logger})
+	if err != nil {
+		t.Fatalf("Failed to create runtime: %v", err)
+	}
+	defer rt.Close()
+
+	if _, err := rt.Render("Noisy", nil); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if !strings.Contains(logs.String(), "warning: missing field") || !strings.Contains(logs.String(), "view=Noisy") {
+		t.Errorf("Expected stderr to be logged, got: %s", logs.String())
+	}
+
+	_, err = rt.Render("Broken", nil)
+	if err == nil {
+		t.Fatalf("Expected error from trapping view")
+	}
+	if !strings.Contains(err.Error(), "index out of bounds") {
+		t.Errorf("Expected stderr in error, got: %v", err)
+	}
+
+	// Output is per render, not accumulated
+	logs.Reset()
+	if _, err := rt.Render("Echo", nil); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if logs.Len() != 0 {
+		t.Errorf("Expected no output for quiet view, got: %s", logs.String())
+	}
+}
+
+func TestRuntime_RenderWithTimeout(t *testing.T) {
+	wasm := stubModule{
+		views: map[string]string{"Fast": "<p>fast</p>"},
+		spins: []string{"Slow"},
+	}.build()
+
+	ctx := context.Background()
+	rt, err := NewRuntime(ctx, Options{WASMBytes: wasm})
+	if err != nil {
+		t.Fatalf("Failed to create runtime: %v", err)
+	}
+	defer rt.Close()
+
+	_, err = rt.RenderWithTimeout("Slow", nil, 50*time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got: %v", err)
+	}
+
+	// The aborted instance is replaced, so later renders still work
+	html, err := rt.RenderWithTimeout("Fast", nil, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if html != "<p>fast</p>" {
+		t.Errorf("Expected <p>fast</p>, got: %s", html)
+	}
+}
+
+func TestRuntime_RenderContextCanceled(t *testing.T) {
+	wasm := stubModule{spins: []string{"Slow"}}.build()
+
+	rt, err := NewRuntime(context.Background(), Options{WASMBytes: wasm})
+	if err != nil {
+		t.Fatalf("Failed to create runtime: %v", err)
+	}
+	defer rt.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	if _, err := rt.RenderContext(ctx, "Slow", nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got: %v", err)
+	}
+}
+
+func TestRuntime_RenderRaw(t *testing.T) {
+	rt, err := NewRuntime(context.Background(), Options{WASMBytes: stubWASM(nil)})
+	if err != nil {
+		t.Fatalf("Failed to create runtime: %v", err)
+	}
+	defer rt.Close()
+
+	raw, err := rt.RenderRaw("Echo", []byte("<p>first</p>"))
+	if err != nil {
+		t.Fatalf("RenderRaw failed: %v", err)
+	}
+	str, err := rt.RenderBytes("Echo", []byte("<p>first</p>"))
+	if err != nil {
+		t.Fatalf("RenderBytes failed: %v", err)
+	}
+	if !bytes.Equal(raw, []byte(str)) {
+		t.Errorf("Expected RenderRaw to match RenderBytes %q, got %q", str, raw)
+	}
+
+	// The slice is the caller's: later renders reuse WASM memory but don't change it
+	if _, err := rt.RenderRaw("Echo", []byte("<p>other</p>")); err != nil {
+		t.Fatalf("RenderRaw failed: %v", err)
+	}
+	if string(raw) != "<p>first</p>" {
+		t.Errorf("Expected earlier output to be unchanged, got %q", raw)
+	}
+}
+
+func TestRuntime_RenderBytesContextCanceled(t *testing.T) {
+	wasm := stubModule{spins: []string{"Slow"}}.build()
+
+	rt, err := NewRuntime(context.Background(), Options{WASMBytes: wasm})
+	if err != nil {
+		t.Fatalf("Failed to create runtime: %v", err)
+	}
+	defer rt.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	if _, err := rt.RenderBytesContext(ctx, "Slow", nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got: %v", err)
+	}
+}
+
+func TestRuntime_RenderRawContextCanceled(t *testing.T) {
+	wasm := stubModule{spins: []string{"Slow"}}.build()
+
+	rt, err := NewRuntime(context.Background(), Options{WASMBytes: wasm})
+	if err != nil {
+		t.Fatalf("Failed to create runtime: %v", err)
+	}
+	defer rt.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	if _, err := rt.RenderRawContext(ctx, "Slow", nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got: %v", err)
+	}
+}
+
+func TestRuntime_LargeInputGrowsMemory(t *testing.T) {
+	// The stub starts with a single 64KiB page and writes input at 4096
+	rt, err := NewRuntime(context.Background(), Options{WASMBytes: stubWASM(nil)})
+	if err != nil {
+		t.Fatalf("Failed to create runtime: %v", err)
+	}
+	defer rt.Close()
+
+	input := bytes.Repeat([]byte("x"), 200*1024)
+	out, err := rt.RenderBytes("Echo", input)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if len(out) != len(input) {
+		t.Errorf("Expected %d bytes of output, got %d", len(input), len(out))
+	}
+}
+
+func TestRuntime_LargeInputOutOfMemory(t *testing.T) {
+	rt, err := NewRuntime(context.Background(), Options{WASMBytes: stubWASM(nil), MaxMemoryPages: 2})
+	if err != nil {
+		t.Fatalf("Failed to create runtime: %v", err)
+	}
+	defer rt.Close()
+
+	_, err = rt.RenderBytes("Echo", bytes.Repeat([]byte("x"), 200*1024))
+	if !errors.Is(err, ErrOutOfMemory) {
+		t.Fatalf("Expected ErrOutOfMemory, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "204800-byte input") || !strings.Contains(err.Error(), "memory is 65536 bytes") {
+		t.Errorf("Expected input and memory sizes in error, got: %v", err)
+	}
+}
+
+func TestRuntime_DisableWASI(t *testing.T) {
+	rt, err := NewRuntime(context.Background(), Options{
+		WASMBytes:   stubModule{views: map[string]string{"Card": "<p>Hi</p>"}, noWASI: true}.build(),
+		DisableWASI: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create runtime: %v", err)
+	}
+	defer rt.Close()
+
+	out, err := rt.Render("Card", nil)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if out != "<p>Hi</p>" {
+		t.Errorf("Expected <p>Hi</p>, got %q", out)
+	}
+
+	// A module that imports WASI can't load without it
+	_, err = NewRuntime(context.Background(), Options{WASMBytes: stubWASM(nil), DisableWASI: true})
+	if !errors.Is(err, ErrWASIDisabled) {
+		t.Fatalf("Expected ErrWASIDisabled, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "wasi_snapshot_preview1.fd_write") {
+		t.Errorf("Expected the import in the error, got: %v", err)
+	}
+}
+
+func TestRuntime_RenderOr(t *testing.T) {
+	var logs bytes.Buffer
+	rt, err := NewRuntime(context.Background(), Options{
+		WASMBytes: stubModule{
+			views:  map[string]string{"Weather": "<p>Sunny</p>"},
+			panics: map[string]string{"Broken": "boom"},
+		}.build(),
+		Logger: slog.New(slog.NewTextHandler(&logs, nil)),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create runtime: %v", err)
+	}
+	defer rt.Close()
+
+	if out := rt.RenderOr("Weather", nil, "<p>unavailable</p>"); out != "<p>Sunny</p>" {
+		t.Errorf("Expected the rendered view, got %q", out)
+	}
+	if logs.Len() != 0 {
+		t.Errorf("Expected nothing logged on success, got: %s", logs.String())
+	}
+
+	if out := rt.RenderOr("Broken", nil, "<p>unavailable</p>"); out != "<p>unavailable</p>" {
+		t.Errorf("Expected the fallback, got %q", out)
+	}
+	if !strings.Contains(logs.String(), "view=Broken") || !strings.Contains(logs.String(), "boom") {
+		t.Errorf("Expected the error to be logged, got: %s", logs.String())
+	}
+}
@@ -0,0 +1,104 @@
+package hudl
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// viewsSection is the custom section in which hudlc lists a module's views.
+const viewsSection = "hudl.views"
+
+// ViewSchema describes a renderable view and the proto message it takes.
+type ViewSchema struct {
+	Name string `json:"name"`
+	// MessageType is the view's data message as declared in its template,
+	// e.g. "DashboardData", or "" for views without params.
+	MessageType string `json:"message"`
+	// ContentType is the template's `// content-type:`, or "" for HTML.
+	ContentType string `json:"contentType,omitempty"`
+	// Fields are the data field paths the view's expressions read, sorted,
+	// e.g. "user.name". Fields read through an each binding are listed
+	// under the iterated field ("user.orders.total").
+	Fields []string `json:"fields,omitempty"`
+	// Params are the view's `// param:` declarations, in order.
+	Params []ViewParam `json:"params,omitempty"`
+}
+
+// ViewParam is a param a view declares.
+type ViewParam struct {
+	Name string `json:"name"`
+	// Type is the declared type, e.g. "User" or "string".
+	Type     string `json:"type"`
+	Repeated bool   `json:"repeated,omitempty"`
+	Optional bool   `json:"optional,omitempty"`
+}
+
+// ViewsWithSchema returns every renderable view with its data message type,
+// sorted by name, so tests can render each view with sample data. In prod
+// mode this reads the module's hudl.views section; modules compiled before
+// it existed return an error.
+func (r *Runtime) ViewsWithSchema() ([]ViewSchema, error) {
+	if r.devMode && r.compiled == nil {
+		return r.devViewsWithSchema()
+	}
+
+	for _, section := range r.compiled.CustomSections() {
+		if section.Name() != viewsSection {
+			continue
+		}
+		var views []ViewSchema
+		if err := json.Unmarshal(section.Data(), &views); err != nil {
+			return nil, fmt.Errorf("invalid %s section: %w", viewsSection, err)
+		}
+		sort.Slice(views, func(i, j int) bool { return views[i].Name < views[j].Name })
+		return views, nil
+	}
+	return nil, fmt.Errorf("module has no %s section; rebuild it with a newer hudlc", viewsSection)
+}
+
+// AnalyzeView returns the data field paths a view reads, without rendering
+// it, for building field masks or finding unused fields. The paths come
+// from the same listing as ViewsWithSchema.
+func (r *Runtime) AnalyzeView(viewName string) (fields []string, err error) {
+	if sub, name, ok := r.mounted(viewName); ok {
+		return sub.AnalyzeView(name)
+	}
+	views, err := r.ViewsWithSchema()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range views {
+		if v.Name == viewName {
+			return v.Fields, nil
+		}
+	}
+	return nil, fmt.Errorf("view %s not found", viewName)
+}
+
+func (r *Runtime) devViewsWithSchema() ([]ViewSchema, error) {
+	url := fmt.Sprintf("http://%s/views/schema", r.devServerAddr())
+
+	req, err := http.NewRequestWithContext(r.ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("dev mode: failed to create request: %w", err)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("dev mode: request to LSP failed (is hudl-lsp --dev-server running?): %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("dev mode: listing views failed with status %d", resp.StatusCode)
+	}
+
+	var views []ViewSchema
+	if err := json.NewDecoder(resp.Body).Decode(&views); err != nil {
+		return nil, fmt.Errorf("dev mode: failed to decode view list: %w", err)
+	}
+	sort.Slice(views, func(i, j int) bool { return views[i].Name < views[j].Name })
+	return views, nil
+}
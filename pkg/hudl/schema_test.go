@@ -0,0 +1,119 @@
+package hudl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	_ "github.com/njreid/hudl/pkg/hudl/pb"
+)
+
+func TestRuntime_ViewsWithSchema_RenderAll(t *testing.T) {
+	wasm := stubModule{
+		views: map[string]string{
+			"Simple":           "<p>simple</p>",
+			"AppLayout":        "<html></html>",
+			"RegistrationForm": "<form></form>",
+			"Dashboard":        "<main></main>",
+			"DashboardClock":   "<span></span>",
+			"Footer":           "<footer></footer>",
+		},
+		custom: map[string]string{viewsSection: `[
+			{"name":"Simple","message":"SimpleData"},
+			{"name":"AppLayout","message":"LayoutData","params":[{"name":"title","type":"string"},{"name":"user_name","type":"string","optional":true}]},
+			{"name":"RegistrationForm","message":"RegistrationFormData"},
+			{"name":"Dashboard","message":"DashboardData"},
+			{"name":"DashboardClock","message":"DashboardData"},
+			{"name":"Footer","message":""}
+		]`},
+	}.build()
+
+	rt, err := NewRuntime(context.Background(), Options{WASMBytes: wasm})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	views, err := rt.ViewsWithSchema()
+	require.NoError(t, err)
+	require.Len(t, views, 6)
+	assert.Equal(t, ViewSchema{
+		Name:        "AppLayout",
+		MessageType: "LayoutData",
+		Params:      []ViewParam{{Name: "title", Type: "string"}, {Name: "user_name", Type: "string", Optional: true}},
+	}, views[0])
+
+	// What a snapshot test would do: render every view with a zero message
+	for _, v := range views {
+		var data proto.Message
+		if v.MessageType != "" {
+			mt, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName("hudl." + v.MessageType))
+			require.NoError(t, err, v.Name)
+			data = mt.New().Interface()
+		}
+		_, err := rt.Render(v.Name, data)
+		assert.NoError(t, err, v.Name)
+	}
+}
+
+func TestRuntime_ViewsWithSchema_MissingSection(t *testing.T) {
+	rt, err := NewRuntime(context.Background(), Options{WASMBytes: stubWASM(nil)})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	_, err = rt.ViewsWithSchema()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no hudl.views section")
+}
+
+func TestRuntime_ViewsWithSchema_DevMode(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /views/schema", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"name":"Dashboard","message":"DashboardData"}]`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	rt, err := NewRuntime(context.Background(), Options{
+		DevMode:       true,
+		DevServerAddr: strings.TrimPrefix(srv.URL, "http://"),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	views, err := rt.ViewsWithSchema()
+	require.NoError(t, err)
+	assert.Equal(t, []ViewSchema{{Name: "Dashboard", MessageType: "DashboardData"}}, views)
+}
+
+func TestRuntime_AnalyzeView(t *testing.T) {
+	wasm := stubModule{
+		views: map[string]string{"OrderList": "<ul></ul>"},
+		custom: map[string]string{viewsSection: `[
+			{"name":"OrderList","message":"OrderListData","fields":["customer.name","customer.orders","customer.orders.total"]},
+			{"name":"OrderListFooter","message":"OrderListData"}
+		]`},
+	}.build()
+
+	rt, err := NewRuntime(context.Background(), Options{WASMBytes: wasm})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	fields, err := rt.AnalyzeView("OrderList")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"customer.name", "customer.orders", "customer.orders.total"}, fields)
+
+	fields, err = rt.AnalyzeView("OrderListFooter")
+	require.NoError(t, err)
+	assert.Empty(t, fields)
+
+	_, err = rt.AnalyzeView("Missing")
+	assert.Error(t, err)
+}
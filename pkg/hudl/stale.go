@@ -0,0 +1,68 @@
+package hudl
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrStaleWASM is returned by NewRuntime with Options.FailOnStale when the
+// WASM module is older than one of its templates.
+var ErrStaleWASM = errors.New("hudl: WASM module is older than its templates")
+
+// checkStale warns, or fails with ErrStaleWASM, when a .hudl file under
+// opts.SourcesDir was modified after opts.WASMPath, i.e. someone edited a
+// template and forgot to run `hudl build`.
+func (r *Runtime) checkStale(opts Options) error {
+	if opts.WASMPath == "" {
+		return fmt.Errorf("hudl: SourcesDir requires WASMPath to check for a stale module")
+	}
+	info, err := os.Stat(opts.WASMPath)
+	if err != nil {
+		return fmt.Errorf("hudl: stale check: %w", err)
+	}
+
+	newest, modTime, err := newestTemplate(opts.SourcesDir)
+	if err != nil {
+		return fmt.Errorf("hudl: stale check: %w", err)
+	}
+	if newest == "" || !modTime.After(info.ModTime()) {
+		return nil
+	}
+
+	if opts.FailOnStale {
+		return fmt.Errorf("%w: %s changed after %s was built; run hudl build", ErrStaleWASM, newest, opts.WASMPath)
+	}
+	r.logger.Warn("hudl: WASM module is older than its templates and renders stale HTML; run hudl build",
+		"wasm", opts.WASMPath,
+		"template", newest,
+		"behind", modTime.Sub(info.ModTime()).Round(time.Second).String())
+	return nil
+}
+
+// newestTemplate returns the most recently modified .hudl file under dir.
+func newestTemplate(dir string) (string, time.Time, error) {
+	var newest string
+	var newestTime time.Time
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".hudl") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(newestTime) {
+			newest, newestTime = path, info.ModTime()
+		}
+		return nil
+	})
+	return newest, newestTime, err
+}
@@ -0,0 +1,82 @@
+package hudl
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeProject writes views.wasm and views/card.hudl, with the template
+// modified templateAge after the module.
+func writeProject(t *testing.T, templateAge time.Duration) (wasmPath, sourcesDir string) {
+	t.Helper()
+	dir := t.TempDir()
+	wasmPath = filepath.Join(dir, "views.wasm")
+	sourcesDir = filepath.Join(dir, "views")
+	template := filepath.Join(sourcesDir, "card.hudl")
+
+	require.NoError(t, os.WriteFile(wasmPath, stubWASM(map[string]string{"Card": "<p>old</p>"}), 0o644))
+	require.NoError(t, os.MkdirAll(sourcesDir, 0o755))
+	require.NoError(t, os.WriteFile(template, []byte("el { p \"new\" }\n"), 0o644))
+
+	built := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(wasmPath, built, built))
+	require.NoError(t, os.Chtimes(template, built.Add(templateAge), built.Add(templateAge)))
+	return wasmPath, sourcesDir
+}
+
+func TestNewRuntime_StaleWASMWarns(t *testing.T) {
+	wasmPath, sourcesDir := writeProject(t, 10*time.Minute)
+
+	var logs bytes.Buffer
+	rt, err := NewRuntime(context.Background(), Options{
+		WASMPath:   wasmPath,
+		SourcesDir: sourcesDir,
+		Logger:     slog.New(slog.NewTextHandler(&logs, nil)),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	assert.Contains(t, logs.String(), "level=WARN")
+	assert.Contains(t, logs.String(), "older than its templates")
+	assert.Contains(t, logs.String(), "card.hudl")
+
+	// Still serves the old module
+	html, err := rt.Render("Card", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<p>old</p>", html)
+}
+
+func TestNewRuntime_FailOnStale(t *testing.T) {
+	wasmPath, sourcesDir := writeProject(t, 10*time.Minute)
+
+	_, err := NewRuntime(context.Background(), Options{
+		WASMPath:    wasmPath,
+		SourcesDir:  sourcesDir,
+		FailOnStale: true,
+	})
+	require.ErrorIs(t, err, ErrStaleWASM)
+	assert.Contains(t, err.Error(), "card.hudl")
+}
+
+func TestNewRuntime_FreshWASMIsQuiet(t *testing.T) {
+	wasmPath, sourcesDir := writeProject(t, -10*time.Minute)
+
+	var logs bytes.Buffer
+	rt, err := NewRuntime(context.Background(), Options{
+		WASMPath:    wasmPath,
+		SourcesDir:  sourcesDir,
+		FailOnStale: true,
+		Logger:      slog.New(slog.NewTextHandler(&logs, nil)),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+	assert.Empty(t, logs.String())
+}
@@ -0,0 +1,70 @@
+package hudl
+
+import (
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// RenderWithStyles renders a view like Render but returns the CSS of its
+// <style> tags separately, so a page assembler can place it in <head>. The
+// returned HTML keeps its scoped classes but has the <style> tags removed;
+// the CSS is deduplicated, since every instance of a styled component emits
+// the same rules.
+func (r *Runtime) RenderWithStyles(viewName string, data proto.Message) (html, css string, err error) {
+	out, err := r.Render(viewName, data)
+	if err != nil {
+		return "", "", err
+	}
+	html, css = splitStyles(out)
+	return html, css, nil
+}
+
+// splitStyles removes the <style> elements from html and returns their
+// contents, first occurrence of each only, one per line.
+func splitStyles(html string) (body, css string) {
+	var b strings.Builder
+	var rules []string
+	seen := map[string]bool{}
+
+	rest := html
+	for {
+		start := indexStyleTag(rest)
+		if start < 0 {
+			break
+		}
+		open := strings.IndexByte(rest[start:], '>')
+		if open < 0 {
+			break
+		}
+		contentStart := start + open + 1
+		end := strings.Index(rest[contentStart:], "</style>")
+		if end < 0 {
+			break
+		}
+
+		b.WriteString(rest[:start])
+		if rule := strings.TrimSpace(rest[contentStart : contentStart+end]); rule != "" && !seen[rule] {
+			seen[rule] = true
+			rules = append(rules, rule)
+		}
+		rest = rest[contentStart+end+len("</style>"):]
+	}
+	b.WriteString(rest)
+	return b.String(), strings.Join(rules, "\n")
+}
+
+// indexStyleTag returns the offset of the next <style> or <style ...> tag.
+func indexStyleTag(s string) int {
+	for offset := 0; ; {
+		i := strings.Index(s[offset:], "<style")
+		if i < 0 {
+			return -1
+		}
+		i += offset
+		if next := i + len("<style"); next < len(s) && (s[next] == '>' || s[next] == ' ') {
+			return i
+		}
+		offset = i + len("<style")
+	}
+}
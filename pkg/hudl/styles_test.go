@@ -0,0 +1,37 @@
+package hudl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderWithStyles(t *testing.T) {
+	rt, err := NewRuntime(context.Background(), Options{
+		WASMBytes: stubWASM(map[string]string{
+			"ButtonRow": `<style>.h-1a2b.btn{color:red}</style><button class="btn h-1a2b">A</button>` +
+				`<style nonce="r4nd0m">.h-1a2b.btn{color:red}</style><button class="btn h-1a2b">B</button>` +
+				`<style>.h-3c4d{margin:0}</style><p class="h-3c4d">done</p>`,
+		}),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	html, css, err := rt.RenderWithStyles("ButtonRow", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, `<button class="btn h-1a2b">A</button><button class="btn h-1a2b">B</button><p class="h-3c4d">done</p>`, html)
+	assert.NotContains(t, html, "<style")
+	assert.Equal(t, ".h-1a2b.btn{color:red}\n.h-3c4d{margin:0}", css)
+
+	_, _, err = rt.RenderWithStyles("Missing", nil)
+	assert.Error(t, err)
+}
+
+func TestSplitStyles_LeavesOtherTags(t *testing.T) {
+	body, css := splitStyles(`<styled-box>x</styled-box><p>no styles</p>`)
+	assert.Equal(t, `<styled-box>x</styled-box><p>no styles</p>`, body)
+	assert.Empty(t, css)
+}
@@ -0,0 +1,68 @@
+package hudl
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ValidationError lists the views Validate found missing or failing.
+type ValidationError struct {
+	// Missing are required views the module doesn't export.
+	Missing []string
+	// Failed maps each view whose dry render failed to its error.
+	Failed map[string]error
+}
+
+func (e *ValidationError) Error() string {
+	var problems []string
+	if len(e.Missing) > 0 {
+		problems = append(problems, "missing views: "+strings.Join(e.Missing, ", "))
+	}
+	for _, view := range sortedViews(e.Failed) {
+		problems = append(problems, fmt.Sprintf("view %s failed: %v", view, e.Failed[view]))
+	}
+	return "hudl: " + strings.Join(problems, "; ")
+}
+
+func sortedViews(m map[string]error) []string {
+	views := make([]string, 0, len(m))
+	for view := range m {
+		views = append(views, view)
+	}
+	slices.Sort(views)
+	return views
+}
+
+// Validate checks at startup that each required view exists and renders,
+// so a corrupt bundle or one built from the wrong templates fails fast
+// rather than on the first request for a missing view. Each view gets a dry
+// render with empty data, which skips Options.Middleware. With no arguments
+// every view from ListViews is checked.
+//
+// It returns a *ValidationError listing every missing and failing view.
+func (r *Runtime) Validate(required ...string) error {
+	views, err := r.ListViews()
+	if err != nil {
+		return err
+	}
+	if len(required) == 0 {
+		required = views
+	}
+
+	verr := &ValidationError{Failed: make(map[string]error)}
+	for _, view := range required {
+		if !slices.Contains(views, view) {
+			verr.Missing = append(verr.Missing, view)
+			continue
+		}
+		if _, err := r.renderProto(r.ctx, view, nil); err != nil {
+			verr.Failed[view] = err
+		}
+	}
+
+	if len(verr.Missing) == 0 && len(verr.Failed) == 0 {
+		return nil
+	}
+	return verr
+}
@@ -0,0 +1,38 @@
+package hudl
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	rt, err := NewRuntime(context.Background(), Options{
+		WASMBytes: stubModule{
+			views:  map[string]string{"Home": "<p>home</p>", "About": "<p>about</p>"},
+			panics: map[string]string{"Broken": "boom"},
+		}.build(),
+	})
+	require.NoError(t, err)
+	defer rt.Close()
+
+	assert.NoError(t, rt.Validate("Home", "About"))
+
+	err = rt.Validate("Home", "Checkout", "Broken", "Cart")
+	var verr *ValidationError
+	require.True(t, errors.As(err, &verr), "got %v", err)
+	assert.Equal(t, []string{"Checkout", "Cart"}, verr.Missing)
+	require.Len(t, verr.Failed, 1)
+	assert.ErrorContains(t, verr.Failed["Broken"], "boom")
+	assert.Contains(t, err.Error(), "missing views: Checkout, Cart")
+	assert.Contains(t, err.Error(), "view Broken failed")
+
+	// With no arguments, every view is checked
+	err = rt.Validate()
+	require.True(t, errors.As(err, &verr), "got %v", err)
+	assert.Empty(t, verr.Missing)
+	assert.Equal(t, []string{"Broken"}, sortedViews(verr.Failed))
+}
@@ -0,0 +1,285 @@
+package hudl
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+// stubModule hand-assembles a minimal module implementing the hudl ABI, so
+// runtime behaviour can be tested without compiling real templates.
+//
+// The module imports WASI fd_write and exports memory, hudl_malloc (always
+// returns offset 4096, growing memory to fit, or 0 if it can't), a no-op
+// hudl_free, an "Echo" view that returns its
+// input unchanged, plus the views described by the fields below.
+type stubModule struct {
+	// views maps a view name to the fixed string it returns.
+	views map[string]string
+	// stderr maps a view name to a message it writes to stderr before
+	// returning empty output.
+	stderr map[string]string
+	// panics maps a view name to a message it writes to stderr before trapping.
+	panics map[string]string
+	// spins lists views that loop forever.
+	spins []string
+	// nonceView, if set, adds a hudl_set_nonce export and a view of that
+	// name that returns the last nonce passed to it.
+	nonceView string
+	// localeView, if set, adds a hudl_set_locale export and a view of that
+	// name that returns the last locale passed to it.
+	localeView string
+	// custom maps a custom section name to its contents.
+	custom map[string]string
+	// noWASI replaces the fd_write import with a local no-op, for a module
+	// that doesn't import WASI.
+	noWASI bool
+	// slot, if its view is set, adds a hudl_set_content export and a view
+	// that returns before + the last content passed to it + after.
+	slot slotStub
+	// liveAllocs adds a hudl_live_allocs export returning the number of
+	// hudl_malloc calls so far; as hudl_free is a no-op, each one leaks.
+	liveAllocs bool
+	// flush, if its view is set, imports hudl.flush in place of fd_write, so
+	// it can't be combined with stderr or panics. The view passes before to
+	// hudl.flush, then returns after, or before + after if the host didn't
+	// take it.
+	flush slotStub
+}
+
+type slotStub struct {
+	view, before, after string
+}
+
+// stubWASM builds a stub module with only fixed-output views.
+func stubWASM(views map[string]string) []byte {
+	return stubModule{views: views}.build()
+}
+
+// wasmPageSize is the size of a WASM memory page.
+const wasmPageSize = 65536
+
+func (m stubModule) build() []byte {
+	const (
+		i32 = 0x7f
+		i64 = 0x7e
+	)
+
+	// Types: 0 = malloc (i32) -> i32, 1 = free (i32, i32), 2 = view (i32, i32) -> i64,
+	// 3 = fd_write (i32, i32, i32, i32) -> i32, 4 = live allocs () -> i32,
+	// 5 = flush (i32, i32) -> i32
+	types := vec(
+		[]byte{0x60, 1, i32, 1, i32},
+		[]byte{0x60, 2, i32, i32, 0},
+		[]byte{0x60, 2, i32, i32, 1, i64},
+		[]byte{0x60, 4, i32, i32, i32, i32, 1, i32},
+		[]byte{0x60, 0, 1, i32},
+		[]byte{0x60, 2, i32, i32, 1, i32},
+	)
+	imports := vec(cat(name("wasi_snapshot_preview1"), name("fd_write"), []byte{0x00, 3}))
+	if m.flush.view != "" {
+		imports = vec(cat(name("hudl"), name("flush"), []byte{0x00, 5}))
+	}
+
+	// Function index 0 is the fd_write (or flush) import.
+	funcTypes := [][]byte{{0}, {1}, {2}}
+	exports := [][]byte{
+		export("memory", 0x02, 0),
+		export("hudl_malloc", 0x00, 1),
+		export("hudl_free", 0x00, 2),
+		export("Echo", 0x00, 3),
+	}
+	// malloc counts its calls at address 16, then grows memory by the pages
+	// 4096+size runs past its end, returning 0 if that fails
+	const allocsAt = 16
+	needed := cat([]byte{0x20, 0, 0x41}, sleb(4096), []byte{0x6a})
+	memoryEnd := []byte{0x3f, 0, 0x41, 16, 0x74}
+	bodies := [][]byte{
+		body(0x41, allocsAt, 0x41, allocsAt, 0x28, 2, 0, 0x41, 1, 0x6a, 0x36, 2, 0,
+			needed, memoryEnd, 0x4b, 0x04, 0x40,
+			needed, memoryEnd, 0x6b, 0x41, sleb(wasmPageSize-1), 0x6a, 0x41, 16, 0x76, 0x40, 0, 0x41, 0x7f, 0x46,
+			0x04, 0x40, 0x41, 0, 0x0f, 0x0b,
+			0x0b,
+			0x41, sleb(4096)),
+		body(),
+		// (i64(ptr) << 32) | i64(len)
+		body(0x20, 0, 0xad, 0x42, 32, 0x86, 0x20, 1, 0xad, 0x84),
+	}
+
+	var data [][]byte
+	offset := 1024
+	addData := func(b []byte) int {
+		at := offset
+		data = append(data, cat([]byte{0, 0x41}, sleb(int64(at)), []byte{0x0b}, uleb(len(b)), b))
+		offset += len(b)
+		return at
+	}
+	addView := func(viewName string, code []byte) {
+		funcTypes = append(funcTypes, []byte{2})
+		exports = append(exports, export(viewName, 0x00, len(funcTypes)))
+		bodies = append(bodies, code)
+	}
+	// writeStderr emits fd_write(2, iovec, 1, 0) for msg, dropping the result.
+	writeStderr := func(msg string) []byte {
+		msgAt := addData([]byte(msg))
+		iovec := binary.LittleEndian.AppendUint32(nil, uint32(msgAt))
+		iovec = binary.LittleEndian.AppendUint32(iovec, uint32(len(msg)))
+		iovecAt := addData(iovec)
+		return cat([]byte{0x41, 2, 0x41}, sleb(int64(iovecAt)), []byte{0x41, 1, 0x41, 0, 0x10, 0, 0x1a})
+	}
+
+	for _, viewName := range sortedKeys(m.views) {
+		out := m.views[viewName]
+		at := addData([]byte(out))
+		addView(viewName, body(0x42, sleb(int64(at)<<32|int64(len(out)))))
+	}
+	for _, viewName := range sortedKeys(m.stderr) {
+		addView(viewName, body(writeStderr(m.stderr[viewName]), 0x42, 0))
+	}
+	for _, viewName := range sortedKeys(m.panics) {
+		addView(viewName, body(writeStderr(m.panics[viewName]), 0x00))
+	}
+	for _, viewName := range m.spins {
+		// loop br 0 end unreachable
+		addView(viewName, body(0x03, 0x40, 0x0c, 0, 0x0b, 0x00))
+	}
+	if m.nonceView != "" {
+		// hudl_set_nonce stores (i64(ptr) << 32) | i64(len) at address 0,
+		// which the view returns as its output.
+		funcTypes = append(funcTypes, []byte{1})
+		exports = append(exports, export("hudl_set_nonce", 0x00, len(funcTypes)))
+		bodies = append(bodies, body(0x41, 0, 0x20, 0, 0xad, 0x42, 32, 0x86, 0x20, 1, 0xad, 0x84, 0x37, 3, 0))
+		addView(m.nonceView, body(0x41, 0, 0x29, 3, 0))
+	}
+	if m.localeView != "" {
+		// As hudl_set_nonce, storing at address 24.
+		funcTypes = append(funcTypes, []byte{1})
+		exports = append(exports, export("hudl_set_locale", 0x00, len(funcTypes)))
+		bodies = append(bodies, body(0x41, 24, 0x20, 0, 0xad, 0x42, 32, 0x86, 0x20, 1, 0xad, 0x84, 0x37, 3, 0))
+		addView(m.localeView, body(0x41, 24, 0x29, 3, 0))
+	}
+
+	if m.slot.view != "" {
+		// The output is assembled at slotAt: before (a data segment), then
+		// hudl_set_content copies the content and after in behind it and
+		// stores (i64(slotAt) << 32) | i64(total len) at address 8.
+		const slotAt = 8192
+		before, after := len(m.slot.before), len(m.slot.after)
+		data = append(data, cat([]byte{0, 0x41}, sleb(slotAt), []byte{0x0b}, uleb(before), []byte(m.slot.before)))
+		afterAt := addData([]byte(m.slot.after))
+		memoryCopy := []byte{0xfc, 10, 0, 0}
+
+		funcTypes = append(funcTypes, []byte{1})
+		exports = append(exports, export("hudl_set_content", 0x00, len(funcTypes)))
+		bodies = append(bodies, body(
+			0x41, sleb(int64(slotAt+before)), 0x20, 0, 0x20, 1, memoryCopy,
+			0x41, sleb(int64(slotAt+before)), 0x20, 1, 0x6a, 0x41, sleb(int64(afterAt)), 0x41, sleb(int64(after)), memoryCopy,
+			0x41, 8, 0x42, sleb(int64(slotAt)<<32), 0x20, 1, 0xad, 0x42, sleb(int64(before+after)), 0x7c, 0x84, 0x37, 3, 0,
+		))
+		addView(m.slot.view, body(0x41, 8, 0x29, 3, 0))
+	}
+
+	if m.flush.view != "" {
+		// call flush(before); if it returned nonzero, output after, else both
+		before, after := len(m.flush.before), len(m.flush.after)
+		at := addData([]byte(m.flush.before + m.flush.after))
+		addView(m.flush.view, body(
+			0x41, sleb(int64(at)), 0x41, sleb(int64(before)), 0x10, 0,
+			0x04, i64, 0x42, sleb(int64(at+before)<<32|int64(after)),
+			0x05, 0x42, sleb(int64(at)<<32|int64(before+after)), 0x0b,
+		))
+	}
+
+	if m.liveAllocs {
+		funcTypes = append(funcTypes, []byte{4})
+		exports = append(exports, export("hudl_live_allocs", 0x00, len(funcTypes)))
+		bodies = append(bodies, body(0x41, allocsAt, 0x28, 2, 0))
+	}
+
+	if m.noWASI {
+		// A local function at index 0 keeps every other index unchanged.
+		funcTypes = append([][]byte{{3}}, funcTypes...)
+		bodies = append([][]byte{body(0x41, 0)}, bodies...)
+	}
+
+	mod := []byte{0x00, 'a', 's', 'm', 0x01, 0x00, 0x00, 0x00}
+	mod = append(mod, section(1, types)...)
+	if !m.noWASI {
+		mod = append(mod, section(2, imports)...)
+	}
+	mod = append(mod, section(3, vec(funcTypes...))...)
+	mod = append(mod, section(5, vec([]byte{0x00, 1}))...)
+	mod = append(mod, section(7, vec(exports...))...)
+	mod = append(mod, section(10, vec(bodies...))...)
+	if len(data) > 0 {
+		mod = append(mod, section(11, vec(data...))...)
+	}
+	for _, sectionName := range sortedKeys(m.custom) {
+		mod = append(mod, section(0, cat(name(sectionName), []byte(m.custom[sectionName])))...)
+	}
+	return mod
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func section(id byte, content []byte) []byte {
+	return cat([]byte{id}, uleb(len(content)), content)
+}
+
+func vec(items ...[]byte) []byte {
+	return cat(append([][]byte{uleb(len(items))}, items...)...)
+}
+
+func name(s string) []byte {
+	return cat(uleb(len(s)), []byte(s))
+}
+
+func export(exportName string, kind byte, index int) []byte {
+	return cat(name(exportName), []byte{kind}, uleb(index))
+}
+
+// body encodes a function body with no locals; instr may mix opcodes and
+// pre-encoded immediates.
+func body(instr ...any) []byte {
+	code := []byte{0x00}
+	for _, in := range instr {
+		switch v := in.(type) {
+		case int:
+			code = append(code, byte(v))
+		case []byte:
+			code = append(code, v...)
+		}
+	}
+	code = append(code, 0x0b)
+	return cat(uleb(len(code)), code)
+}
+
+func cat(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func uleb(v int) []byte {
+	return binary.AppendUvarint(nil, uint64(v))
+}
+
+func sleb(v int64) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if (v == 0 && b&0x40 == 0) || (v == -1 && b&0x40 != 0) {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}
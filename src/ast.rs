@@ -1,31 +1,72 @@
-use std::collections::HashMap;
+use std::collections::{BTreeSet, HashMap, HashSet};
 
 #[derive(Debug, PartialEq, Clone)]
 pub struct Param {
     pub name: String,
     pub type_name: String,
     pub repeated: bool,
+    /// Declared `optional`: when absent (and without a default) the param is
+    /// `null` rather than the proto3 zero value.
+    pub optional: bool,
     pub default_value: Option<String>,
 }
 
-#[derive(Debug, PartialEq)]
+/// The index of a component's `// param: map<string, string> attrs`, which
+/// receives the attributes an invocation passes beyond the declared params.
+pub fn attrs_param(params: &[Param]) -> Option<usize> {
+    params
+        .iter()
+        .position(|p| p.name == "attrs" && !p.repeated && p.type_name.starts_with("map<"))
+}
+
+/// The attributes an invocation of a component with an `attrs` param
+/// forwards to it, in order: the `#id` and `.class` shorthands, then the
+/// args that aren't declared params, by name. Values are as written, so a
+/// dynamic one is still a backtick expression.
+pub fn forwarded_attrs(el: &Element, params: &[Param]) -> Vec<(String, String)> {
+    let mut attrs = Vec::new();
+    if let Some(id) = &el.id {
+        attrs.push(("id".to_string(), id.clone()));
+    }
+    if !el.classes.is_empty() {
+        attrs.push(("class".to_string(), el.classes.join(" ")));
+    }
+    let mut extra: Vec<_> = el
+        .attributes
+        .iter()
+        .filter(|(name, _)| !params.iter().any(|p| &p.name == *name))
+        .map(|(name, value)| (name.clone(), value.clone()))
+        .collect();
+    extra.sort();
+    attrs.extend(extra);
+    attrs
+}
+
+#[derive(Debug, PartialEq, Clone)]
 pub struct Root {
     pub nodes: Vec<Node>,
     pub css: Option<String>,
     pub name: Option<String>,      // Component name from // name: comment
     pub params: Vec<Param>,        // Component parameters from // param: comments
     pub imports: Vec<String>,      // Files imported via 'import { ... }'
+    pub doctype: Option<String>,   // From `doctype html`, emitted as <!DOCTYPE html>
+    pub content_type: Option<String>, // From // content-type: comment, else text/html
+    pub data_message: Option<String>, // From // data: comment, the message params decode as
+    pub warnings: Vec<String>,     // Lenient diagnostics, e.g. unknown CSS properties
 }
 
-#[derive(Debug, PartialEq)]
+#[derive(Debug, PartialEq, Clone)]
 pub enum Node {
     Element(Element),
     Text(Text),
     ControlFlow(ControlFlow),
     ContentSlot, // Special token #content
+    /// `flush`: output up to here may be sent to the client before the rest
+    /// renders
+    Flush,
 }
 
-#[derive(Debug, PartialEq)]
+#[derive(Debug, PartialEq, Clone)]
 pub struct Element {
     pub tag: String,
     pub id: Option<String>,
@@ -38,6 +79,34 @@ pub struct Element {
     /// Key is the Hudl attribute name (e.g., "on:click", ".active", "let:count")
     /// Value is (expression, modifiers) where modifiers is a list like ["once", "prevent"]
     pub datastar: Vec<DatastarAttr>,
+    /// Explicit fragment marker (`fragment=name`); see `Element::fragment_name`
+    pub fragment: Option<String>,
+    /// 1-based line of the element in its .hudl file, when transformed with
+    /// the source (`transform_with_metadata`)
+    pub line: Option<usize>,
+    /// 1-based column of the element's name on `line`
+    pub column: Option<usize>,
+    /// CEL expression computing the tag name at render time, for
+    /// `el tag=`expr``; `tag` is then "el". See `safe_names::safe_tag_name`.
+    pub dynamic_tag: Option<String>,
+    /// CEL expressions from `...`attrs`` spreads, each evaluating to a map
+    /// of attribute names to values, written after the static attributes
+    pub spreads: Vec<String>,
+    /// Inside an `svg` or `math` subtree (foreign content), where tags are
+    /// case-sensitive, void-element rules don't apply and tags aren't
+    /// checked against the known HTML ones
+    pub foreign: bool,
+}
+
+impl Element {
+    /// The fragment this element defines, if any: the explicit `fragment`
+    /// marker, falling back to the element's id.
+    pub fn fragment_name(&self) -> Option<&str> {
+        self.fragment
+            .as_deref()
+            .filter(|f| !f.is_empty())
+            .or(self.id.as_deref())
+    }
 }
 
 /// A Datastar reactive attribute
@@ -51,12 +120,15 @@ pub struct DatastarAttr {
     pub modifiers: Vec<String>,
 }
 
-#[derive(Debug, PartialEq)]
+#[derive(Debug, PartialEq, Clone)]
 pub struct Text {
     pub content: String,
+    /// Set for text inside a `raw { ... }` block: interpolated expressions
+    /// are output without HTML escaping.
+    pub raw: bool,
 }
 
-#[derive(Debug, PartialEq)]
+#[derive(Debug, PartialEq, Clone)]
 pub enum ControlFlow {
     If {
         condition: String,
@@ -64,6 +136,9 @@ pub enum ControlFlow {
         else_block: Option<Vec<Node>>,
     },
     Each {
+        /// Key variable when iterating a map (`each key value \`m\``), in
+        /// sorted key order; `binding` is then the value
+        key: Option<String>,
         binding: String,   // Loop variable name (e.g., "item")
         iterable: String,  // CEL expression for the collection
         body: Vec<Node>,
@@ -75,10 +150,11 @@ pub enum ControlFlow {
     },
 }
 
-/// Switch case: (pattern, children)
-/// Pattern is either an enum value like "STATUS_ACTIVE" or a CEL expression
-#[derive(Debug, PartialEq)]
-pub struct SwitchCase(pub String, pub Vec<Node>);
+/// Switch case: (patterns, children)
+/// Each pattern is either an enum value like "STATUS_ACTIVE" or a CEL
+/// expression; the case matches if any of them does (`case "admin" "owner"`)
+#[derive(Debug, PartialEq, Clone)]
+pub struct SwitchCase(pub Vec<String>, pub Vec<Node>);
 
 // Helpers for tests
 impl Node {
@@ -102,6 +178,358 @@ impl Node {
     }
 }
 
+/// Collect the fragment elements of a view, in document order.
+///
+/// Elements inside `each` bodies are skipped since they depend on loop
+/// variables (and their ids are not unique). The first element wins if two
+/// share a fragment name.
+pub fn collect_fragments(nodes: &[Node]) -> Vec<(String, &Node)> {
+    let mut fragments = Vec::new();
+    collect_fragments_into(nodes, &mut fragments);
+    fragments
+}
+
+/// Check that every view and fragment gets an export of its own. Any
+/// element id names a fragment, so an id can turn into another export's
+/// name: `#clock` in `Dashboard` and a view called `DashboardClock` would
+/// both be exported as `DashboardClock`, as would `#live-clock` and
+/// `#live_clock` in `Dashboard`.
+pub fn check_fragment_names(views: &[(String, Root)]) -> Result<(), String> {
+    let mut exports: HashMap<String, String> = views
+        .iter()
+        .map(|(name, _)| (name.clone(), format!("view {}", name)))
+        .collect();
+    for (view, root) in views {
+        for (fragment, _) in collect_fragments(&root.nodes) {
+            let export = fragment_function_name(view, &fragment);
+            let owner = format!("fragment '{}' of {}", fragment, view);
+            if let Some(existing) = exports.insert(export.clone(), owner.clone()) {
+                return Err(format!("{} and {} are both exported as {}", existing, owner, export));
+            }
+        }
+    }
+    Ok(())
+}
+
+fn collect_fragments_into<'a>(nodes: &'a [Node], fragments: &mut Vec<(String, &'a Node)>) {
+    for node in nodes {
+        match node {
+            Node::Element(el) => {
+                if let Some(name) = el.fragment_name() {
+                    if !fragments.iter().any(|(n, _)| n == name) {
+                        fragments.push((name.to_string(), node));
+                    }
+                }
+                collect_fragments_into(&el.children, fragments);
+            }
+            Node::ControlFlow(ControlFlow::If { then_block, else_block, .. }) => {
+                collect_fragments_into(then_block, fragments);
+                if let Some(else_nodes) = else_block {
+                    collect_fragments_into(else_nodes, fragments);
+                }
+            }
+            Node::ControlFlow(ControlFlow::Switch { cases, default, .. }) => {
+                for SwitchCase(_, children) in cases {
+                    collect_fragments_into(children, fragments);
+                }
+                if let Some(def_nodes) = default {
+                    collect_fragments_into(def_nodes, fragments);
+                }
+            }
+            _ => {}
+        }
+    }
+}
+
+/// Name of the generated render function for a fragment of a view:
+/// `Dashboard` + `live-clock` → `DashboardLiveClock`.
+pub fn fragment_function_name(view_name: &str, fragment: &str) -> String {
+    let mut name = view_name.to_string();
+    for part in fragment.split(|c: char| !c.is_ascii_alphanumeric()) {
+        let mut chars = part.chars();
+        if let Some(first) = chars.next() {
+            name.push(first.to_ascii_uppercase());
+            name.extend(chars);
+        }
+    }
+    name
+}
+
+/// The data field paths read by the expressions in `nodes`, sorted and
+/// deduplicated, e.g. `["user.name", "user.orders"]`. Paths start at one of
+/// `params`; a field read through an `each` binding is reported under the
+/// iterated path (`user.orders.total`). Signals and locals are ignored.
+pub fn referenced_fields(nodes: &[Node], params: &[Param]) -> Vec<String> {
+    let scope: HashMap<String, String> = params.iter().map(|p| (p.name.clone(), p.name.clone())).collect();
+    let mut fields = BTreeSet::new();
+    collect_field_refs(nodes, &scope, &mut fields);
+    fields.into_iter().collect()
+}
+
+/// `scope` maps each variable in scope to the data path it stands for.
+fn collect_field_refs(nodes: &[Node], scope: &HashMap<String, String>, fields: &mut BTreeSet<String>) {
+    for node in nodes {
+        match node {
+            Node::Element(el) => {
+                // Dynamic classes were split on whitespace; rejoin them
+                let classes = el.classes.join(" ");
+                let values = std::iter::once(&classes)
+                    .chain(el.id.iter())
+                    .chain(el.attributes.values())
+                    .chain(el.styles.iter().map(|(_, v)| v))
+                    .chain(el.datastar.iter().filter_map(|d| d.value.as_ref()));
+                for value in values {
+                    add_interpolated_refs(value, scope, fields);
+                }
+                for expr in el.dynamic_tag.iter().chain(&el.spreads) {
+                    add_expr_refs(expr, scope, fields);
+                }
+                collect_field_refs(&el.children, scope, fields);
+            }
+            Node::Text(text) => add_interpolated_refs(&text.content, scope, fields),
+            Node::ControlFlow(ControlFlow::If { condition, then_block, else_block }) => {
+                add_expr_refs(condition, scope, fields);
+                collect_field_refs(then_block, scope, fields);
+                if let Some(else_nodes) = else_block {
+                    collect_field_refs(else_nodes, scope, fields);
+                }
+            }
+            Node::ControlFlow(ControlFlow::Each { key, binding, iterable, body }) => {
+                add_expr_refs(iterable, scope, fields);
+                let mut inner = scope.clone();
+                if let Some(key) = key {
+                    inner.remove(key);
+                }
+                let is_path = iterable.trim().chars().all(|c| c.is_alphanumeric() || c == '_' || c == '.');
+                match expr_paths(iterable, scope).pop() {
+                    Some(path) if is_path => {
+                        inner.insert(binding.clone(), path);
+                    }
+                    _ => {
+                        // Not a plain field path: the binding shadows
+                        // whatever it named outside the loop
+                        inner.remove(binding);
+                    }
+                }
+                collect_field_refs(body, &inner, fields);
+            }
+            Node::ControlFlow(ControlFlow::Switch { expr, cases, default }) => {
+                add_expr_refs(expr, scope, fields);
+                for SwitchCase(_, children) in cases {
+                    collect_field_refs(children, scope, fields);
+                }
+                if let Some(def_nodes) = default {
+                    collect_field_refs(def_nodes, scope, fields);
+                }
+            }
+            Node::ContentSlot | Node::Flush => {}
+        }
+    }
+}
+
+/// Add the refs of each `expr` in a string with backtick interpolations.
+fn add_interpolated_refs(s: &str, scope: &HashMap<String, String>, fields: &mut BTreeSet<String>) {
+    for expr in s.split('`').skip(1).step_by(2) {
+        add_expr_refs(expr, scope, fields);
+    }
+}
+
+fn add_expr_refs(expr: &str, scope: &HashMap<String, String>, fields: &mut BTreeSet<String>) {
+    fields.extend(expr_paths(expr, scope));
+}
+
+/// The data paths named by the identifier chains (`a.b.c`) of a CEL
+/// expression whose root is in scope.
+fn expr_paths(expr: &str, scope: &HashMap<String, String>) -> Vec<String> {
+    let mut paths = Vec::new();
+    for segments in expr_chains(expr) {
+        if let Some((root, rest)) = segments.split_first() {
+            if let Some(prefix) = scope.get(root) {
+                let mut path = prefix.clone();
+                for segment in rest {
+                    path.push('.');
+                    path.push_str(segment);
+                }
+                paths.push(path);
+            }
+        }
+    }
+    paths
+}
+
+/// The identifier chains (`a.b.c`) of a CEL expression that start at a
+/// variable, split into segments. A chain that ends in a call
+/// (`a.b.size()`) names its receiver, so a plain function call (`size(x)`)
+/// yields no chain; string literals are skipped.
+fn expr_chains(expr: &str) -> Vec<Vec<String>> {
+    let chars: Vec<char> = expr.chars().collect();
+    let mut chains = Vec::new();
+    let mut i = 0;
+    while i < chars.len() {
+        let c = chars[i];
+        if c == '"' || c == '\'' {
+            // Skip the literal, honoring escapes
+            i += 1;
+            while i < chars.len() && chars[i] != c {
+                if chars[i] == '\\' {
+                    i += 1;
+                }
+                i += 1;
+            }
+            i += 1;
+            continue;
+        }
+        if !(c.is_alphabetic() || c == '_') {
+            i += 1;
+            continue;
+        }
+
+        // Members of other values (`f(x).y`, `a[0].b`) and signals (`$x`)
+        // aren't data paths
+        let prev = chars[..i].iter().rev().find(|c| !c.is_whitespace());
+        let is_member = matches!(prev, Some('.') | Some('$') | Some(')') | Some(']'));
+
+        let mut segments = Vec::new();
+        loop {
+            let start = i;
+            while i < chars.len() && (chars[i].is_alphanumeric() || chars[i] == '_') {
+                i += 1;
+            }
+            segments.push(chars[start..i].iter().collect::<String>());
+            if i + 1 < chars.len() && chars[i] == '.' && (chars[i + 1].is_alphabetic() || chars[i + 1] == '_') {
+                i += 1;
+            } else {
+                break;
+            }
+        }
+        if is_member {
+            continue;
+        }
+        if chars[i..].iter().find(|c| !c.is_whitespace()) == Some(&'(') {
+            segments.pop();
+        }
+        if !segments.is_empty() {
+            chains.push(segments);
+        }
+    }
+    chains
+}
+
+/// Mismatches between a view's `// param:` lines and its body, from
+/// `check_params`.
+#[derive(Debug, Default, PartialEq)]
+pub struct ParamCheck {
+    /// Declared params that no expression reads
+    pub unused: Vec<String>,
+    /// Names read by expressions that are neither params, loop variables nor
+    /// known constants, sorted
+    pub undeclared: Vec<String>,
+}
+
+/// CEL literals that look like identifiers.
+const CEL_LITERALS: [&str; 4] = ["true", "false", "null", "in"];
+
+/// Compare a view's declared params with the names its expressions read.
+/// `known` are other names bound when rendering, such as enum constants. A
+/// view without params reads its data's fields directly, so no name is
+/// undeclared in it.
+pub fn check_params(nodes: &[Node], params: &[Param], known: &[&str]) -> ParamCheck {
+    let fields = referenced_fields(nodes, params);
+    let unused = params
+        .iter()
+        .filter(|p| !fields.iter().any(|f| f == &p.name || f.starts_with(&format!("{}.", p.name))))
+        .map(|p| p.name.clone())
+        .collect();
+
+    let mut undeclared = BTreeSet::new();
+    if !params.is_empty() {
+        let scope: HashSet<String> = params
+            .iter()
+            .map(|p| p.name.clone())
+            .chain(known.iter().map(|k| k.to_string()))
+            .collect();
+        collect_undeclared(nodes, &scope, &mut undeclared);
+    }
+    ParamCheck { unused, undeclared: undeclared.into_iter().collect() }
+}
+
+fn collect_undeclared(nodes: &[Node], scope: &HashSet<String>, names: &mut BTreeSet<String>) {
+    for node in nodes {
+        match node {
+            Node::Element(el) => {
+                let classes = el.classes.join(" ");
+                let values = std::iter::once(&classes)
+                    .chain(el.id.iter())
+                    .chain(el.attributes.values())
+                    .chain(el.styles.iter().map(|(_, v)| v))
+                    .chain(el.datastar.iter().filter_map(|d| d.value.as_ref()));
+                for value in values {
+                    for expr in value.split('`').skip(1).step_by(2) {
+                        add_undeclared(expr, scope, names);
+                    }
+                }
+                for expr in el.dynamic_tag.iter().chain(&el.spreads) {
+                    add_undeclared(expr, scope, names);
+                }
+                collect_undeclared(&el.children, scope, names);
+            }
+            Node::Text(text) => {
+                for expr in text.content.split('`').skip(1).step_by(2) {
+                    add_undeclared(expr, scope, names);
+                }
+            }
+            Node::ControlFlow(ControlFlow::If { condition, then_block, else_block }) => {
+                add_undeclared(condition, scope, names);
+                collect_undeclared(then_block, scope, names);
+                if let Some(else_nodes) = else_block {
+                    collect_undeclared(else_nodes, scope, names);
+                }
+            }
+            Node::ControlFlow(ControlFlow::Each { key, binding, iterable, body }) => {
+                add_undeclared(iterable, scope, names);
+                let mut inner = scope.clone();
+                inner.extend(key.iter().cloned());
+                inner.insert(binding.clone());
+                inner.insert(format!("{}_idx", binding));
+                collect_undeclared(body, &inner, names);
+            }
+            Node::ControlFlow(ControlFlow::Switch { expr, cases, default }) => {
+                add_undeclared(expr, scope, names);
+                for SwitchCase(_, children) in cases {
+                    collect_undeclared(children, scope, names);
+                }
+                if let Some(def_nodes) = default {
+                    collect_undeclared(def_nodes, scope, names);
+                }
+            }
+            Node::ContentSlot | Node::Flush => {}
+        }
+    }
+}
+
+fn add_undeclared(expr: &str, scope: &HashSet<String>, names: &mut BTreeSet<String>) {
+    for chain in expr_chains(expr) {
+        let root = &chain[0];
+        if !scope.contains(root) && !CEL_LITERALS.contains(&root.as_str()) {
+            names.insert(root.clone());
+        }
+    }
+}
+
+/// HTML void elements, which have no content and no closing tag.
+pub const VOID_ELEMENTS: [&str; 14] = [
+    "area", "base", "br", "col", "embed", "hr", "img", "input", "link", "meta", "param",
+    "source", "track", "wbr",
+];
+
+/// Check whether a tag is an HTML void element.
+pub fn is_void_element(tag: &str) -> bool {
+    VOID_ELEMENTS.contains(&tag)
+}
+
+/// Elements whose subtree is foreign content (SVG and MathML).
+pub const FOREIGN_ROOTS: [&str; 2] = ["svg", "math"];
+
 /// Convert a Datastar reactive attribute to HTML attribute name and value
 pub fn datastar_attr_to_html(attr: &DatastarAttr) -> (String, Option<String>) {
     let mut html_name = String::from("data-");
@@ -56,6 +56,9 @@ impl CompiledExpr {
 #[derive(Default)]
 pub struct EvalContext {
     variables: HashMap<String, CelValue>,
+    /// The render's locale, kept apart from the `locale` variable so that
+    /// components get it even where a param shadows the variable.
+    locale: String,
 }
 
 impl EvalContext {
@@ -63,9 +66,21 @@ impl EvalContext {
     pub fn new() -> Self {
         EvalContext {
             variables: HashMap::new(),
+            locale: String::new(),
         }
     }
 
+    /// Set the render's locale and bind it to the `locale` variable.
+    pub fn set_locale(&mut self, locale: &str) {
+        self.locale = locale.to_string();
+        self.add_string("locale", locale);
+    }
+
+    /// The locale set by `set_locale`, or "".
+    pub fn locale(&self) -> &str {
+        &self.locale
+    }
+
     /// Add a string variable.
     pub fn add_string(&mut self, name: &str, value: impl Into<String>) {
         self.variables.insert(name.to_string(), CelValue::String(Arc::new(value.into())));
@@ -114,6 +129,7 @@ impl EvalContext {
     pub fn child(&self) -> Self {
         EvalContext {
             variables: self.variables.clone(),
+            locale: self.locale.clone(),
         }
     }
 
@@ -144,6 +160,34 @@ pub fn cel_to_string(value: &CelValue) -> String {
     }
 }
 
+/// Convert a JSON value into a CEL value (used for JSON render requests).
+pub fn json_to_cel(value: &serde_json::Value) -> CelValue {
+    match value {
+        serde_json::Value::Null => CelValue::Null,
+        serde_json::Value::Bool(b) => CelValue::Bool(*b),
+        serde_json::Value::Number(n) => {
+            if let Some(i) = n.as_i64() {
+                CelValue::Int(i)
+            } else if let Some(u) = n.as_u64() {
+                CelValue::UInt(u)
+            } else {
+                CelValue::Float(n.as_f64().unwrap_or_default())
+            }
+        }
+        serde_json::Value::String(s) => CelValue::String(Arc::new(s.clone())),
+        serde_json::Value::Array(items) => {
+            CelValue::List(Arc::new(items.iter().map(json_to_cel).collect()))
+        }
+        serde_json::Value::Object(fields) => {
+            let map: HashMap<Key, CelValue> = fields
+                .iter()
+                .map(|(k, v)| (Key::String(Arc::new(k.clone())), json_to_cel(v)))
+                .collect();
+            CelValue::Map(CelMap { map: Arc::new(map) })
+        }
+    }
+}
+
 /// Check if a CEL value is truthy (for conditionals).
 pub fn is_truthy(value: &CelValue) -> bool {
     match value {
@@ -175,6 +219,35 @@ pub fn html_escape(s: &str) -> String {
     result
 }
 
+/// Escape `&` in literal template text unless it starts a character
+/// reference, so authors can write `&copy;` or `&#8212;` but a bare `&`
+/// still becomes `&amp;`.
+pub fn escape_bare_ampersands(s: &str) -> String {
+    let mut result = String::with_capacity(s.len());
+    for (i, c) in s.char_indices() {
+        if c == '&' && !starts_with_char_ref(&s[i + 1..]) {
+            result.push_str("&amp;");
+        } else {
+            result.push(c);
+        }
+    }
+    result
+}
+
+/// Whether `s` (the text after an `&`) begins with `name;`, `#123;` or `#x7f;`.
+fn starts_with_char_ref(s: &str) -> bool {
+    let Some(end) = s.find(';') else { return false };
+    let body = &s[..end];
+    if let Some(num) = body.strip_prefix('#') {
+        match num.strip_prefix(['x', 'X']) {
+            Some(hex) => !hex.is_empty() && hex.chars().all(|c| c.is_ascii_hexdigit()),
+            None => !num.is_empty() && num.chars().all(|c| c.is_ascii_digit()),
+        }
+    } else {
+        body.starts_with(|c: char| c.is_ascii_alphabetic()) && body.chars().all(|c| c.is_ascii_alphanumeric())
+    }
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -234,6 +307,15 @@ mod tests {
         assert_eq!(html_escape("\"quoted\""), "&quot;quoted&quot;");
     }
 
+    #[test]
+    fn test_escape_bare_ampersands() {
+        assert_eq!(escape_bare_ampersands("&copy; 2024"), "&copy; 2024");
+        assert_eq!(escape_bare_ampersands("&#169; &#xA9;"), "&#169; &#xA9;");
+        assert_eq!(escape_bare_ampersands("Tom & Jerry"), "Tom &amp; Jerry");
+        assert_eq!(escape_bare_ampersands("R&D"), "R&amp;D");
+        assert_eq!(escape_bare_ampersands("&#; &x y;"), "&amp;#; &amp;x y;");
+    }
+
     #[test]
     fn test_ternary() {
         let expr = CompiledExpr::compile("active ? \"yes\" : \"no\"").unwrap();
@@ -255,4 +337,21 @@ mod tests {
         let result = expr.evaluate(&ctx).unwrap();
         assert_eq!(result, CelValue::Bool(true));
     }
+
+    #[test]
+    fn test_json_to_cel() {
+        let json: serde_json::Value =
+            serde_json::from_str(r#"{"user": {"name": "Ann"}, "items": [1, 2]}"#).unwrap();
+        let expr = CompiledExpr::compile("user.name + ':' + string(size(items))").unwrap();
+        let mut ctx = EvalContext::new();
+        if let CelValue::Map(map) = json_to_cel(&json) {
+            for (key, value) in map.map.iter() {
+                if let Key::String(name) = key {
+                    ctx.add_value(name, value.clone());
+                }
+            }
+        }
+        let result = expr.evaluate(&ctx).unwrap();
+        assert_eq!(cel_to_string(&result), "Ann:2");
+    }
 }
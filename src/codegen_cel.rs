@@ -6,10 +6,11 @@
 //! - Evaluates CEL expressions at runtime
 //! - Generates scoped CSS for component styles
 
-use crate::ast::{Node, Root, SwitchCase, datastar_attr_to_html, Param};
+use crate::ast::{Node, Root, SwitchCase, check_fragment_names, collect_fragments, datastar_attr_to_html, fragment_function_name, is_void_element, Param};
 use crate::proto::{ProtoField, ProtoSchema, ProtoType};
+use crate::transformer::{check_component_args, check_component_cycles, check_each_bindings, check_known_tags};
 use std::collections::hash_map::DefaultHasher;
-use std::collections::HashMap;
+use std::collections::{HashMap, HashSet};
 use std::hash::{Hash, Hasher};
 
 /// Generate a unique scope ID for a component based on its name
@@ -20,11 +21,39 @@ fn generate_scope_id(component_name: &str) -> String {
     format!("h{:x}", hash & 0xFFFFFF) // 6 hex chars for readability
 }
 
+/// How generated views serialize void elements and boolean attributes.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default)]
+pub enum Serialization {
+    /// `<br>`, `<input checked>`
+    #[default]
+    Html5,
+    /// `<br />`, `<input checked="checked" />`, for XML pipelines and email clients
+    Xhtml,
+}
+
+/// Code generation options.
+#[derive(Debug, Clone, Default)]
+pub struct Options {
+    pub serialization: Serialization,
+    /// Reject tags that are not HTML/SVG elements, custom elements or views
+    pub strict: bool,
+}
+
 /// Generate the WASM library code using CEL with proto input.
 pub fn generate_wasm_lib_cel(
     views: Vec<(String, Root)>,
     schema: &ProtoSchema,
 ) -> Result<String, String> {
+    generate_wasm_lib_cel_with_options(views, schema, &Options::default())
+}
+
+/// Generate the WASM library code with explicit code generation options.
+pub fn generate_wasm_lib_cel_with_options(
+    views: Vec<(String, Root)>,
+    schema: &ProtoSchema,
+    opts: &Options,
+) -> Result<String, String> {
+    let serialization = opts.serialization;
     let mut code = String::new();
 
     // Standard imports
@@ -65,6 +94,113 @@ pub fn generate_wasm_lib_cel(
     code.push_str("    unsafe { let _ = Vec::from_raw_parts(p, s, s); }\n");
     code.push_str("}\n\n");
 
+    // Count live heap allocations so the host can tell a leaking view from
+    // a growing pool (hudl_live_allocs)
+    code.push_str("struct CountingAlloc;\n\n");
+    code.push_str("static LIVE_ALLOCS: std::sync::atomic::AtomicU32 = std::sync::atomic::AtomicU32::new(0);\n\n");
+    code.push_str("unsafe impl std::alloc::GlobalAlloc for CountingAlloc {\n");
+    code.push_str("    unsafe fn alloc(&self, layout: std::alloc::Layout) -> *mut u8 {\n");
+    code.push_str("        let p = std::alloc::System.alloc(layout);\n");
+    code.push_str("        if !p.is_null() {\n");
+    code.push_str("            LIVE_ALLOCS.fetch_add(1, std::sync::atomic::Ordering::Relaxed);\n");
+    code.push_str("        }\n");
+    code.push_str("        p\n");
+    code.push_str("    }\n\n");
+    code.push_str("    unsafe fn dealloc(&self, p: *mut u8, layout: std::alloc::Layout) {\n");
+    code.push_str("        std::alloc::System.dealloc(p, layout);\n");
+    code.push_str("        LIVE_ALLOCS.fetch_sub(1, std::sync::atomic::Ordering::Relaxed);\n");
+    code.push_str("    }\n\n");
+    code.push_str("    unsafe fn realloc(&self, p: *mut u8, layout: std::alloc::Layout, new_size: usize) -> *mut u8 {\n");
+    code.push_str("        std::alloc::System.realloc(p, layout, new_size)\n");
+    code.push_str("    }\n");
+    code.push_str("}\n\n");
+    code.push_str("#[global_allocator]\nstatic GLOBAL: CountingAlloc = CountingAlloc;\n\n");
+    code.push_str("#[no_mangle]\npub extern \"C\" fn hudl_live_allocs() -> u32 {\n");
+    code.push_str("    LIVE_ALLOCS.load(std::sync::atomic::Ordering::Relaxed)\n");
+    code.push_str("}\n\n");
+
+    // CSP nonce for inline <style>/<script>, set by the host before a render
+    code.push_str("thread_local! {\n");
+    code.push_str("    static CSP_NONCE: std::cell::RefCell<String> = std::cell::RefCell::new(String::new());\n");
+    code.push_str("}\n\n");
+
+    code.push_str("#[no_mangle]\npub extern \"C\" fn hudl_set_nonce(p: *const u8, l: usize) {\n");
+    code.push_str("    let nonce = if l > 0 {\n");
+    code.push_str("        String::from_utf8_lossy(unsafe { slice::from_raw_parts(p, l) }).into_owned()\n");
+    code.push_str("    } else {\n");
+    code.push_str("        String::new()\n");
+    code.push_str("    };\n");
+    code.push_str("    CSP_NONCE.with(|n| *n.borrow_mut() = nonce);\n");
+    code.push_str("}\n\n");
+
+    code.push_str("fn csp_nonce_attr() -> String {\n");
+    code.push_str("    CSP_NONCE.with(|n| {\n");
+    code.push_str("        let n = n.borrow();\n");
+    code.push_str("        if n.is_empty() {\n");
+    code.push_str("            String::new()\n");
+    code.push_str("        } else {\n");
+    code.push_str("            format!(\" nonce=\\\"{}\\\"\", n.replace('&', \"&amp;\").replace('\"', \"&quot;\").replace('<', \"&lt;\"))\n");
+    code.push_str("        }\n");
+    code.push_str("    })\n");
+    code.push_str("}\n\n");
+
+    // Negotiated locale, set by the host before a render and bound as `locale`
+    code.push_str("thread_local! {\n");
+    code.push_str("    static LOCALE: std::cell::RefCell<String> = std::cell::RefCell::new(String::new());\n");
+    code.push_str("}\n\n");
+
+    code.push_str("#[no_mangle]\npub extern \"C\" fn hudl_set_locale(p: *const u8, l: usize) {\n");
+    code.push_str("    let locale = if l > 0 {\n");
+    code.push_str("        String::from_utf8_lossy(unsafe { slice::from_raw_parts(p, l) }).into_owned()\n");
+    code.push_str("    } else {\n");
+    code.push_str("        String::new()\n");
+    code.push_str("    };\n");
+    code.push_str("    LOCALE.with(|l| *l.borrow_mut() = locale);\n");
+    code.push_str("}\n\n");
+
+    code.push_str("fn locale_value() -> CelValue {\n");
+    code.push_str("    LOCALE.with(|l| CelValue::String(Arc::new(l.borrow().clone())))\n");
+    code.push_str("}\n\n");
+
+    // Pre-rendered HTML for the next render's #content slot, set by the host
+    // when composing a layout; taken (and so cleared) by that render
+    code.push_str("thread_local! {\n");
+    code.push_str("    static CONTENT_SLOT: std::cell::RefCell<String> = std::cell::RefCell::new(String::new());\n");
+    code.push_str("}\n\n");
+
+    code.push_str("#[no_mangle]\npub extern \"C\" fn hudl_set_content(p: *const u8, l: usize) {\n");
+    code.push_str("    let content = if l > 0 {\n");
+    code.push_str("        String::from_utf8_lossy(unsafe { slice::from_raw_parts(p, l) }).into_owned()\n");
+    code.push_str("    } else {\n");
+    code.push_str("        String::new()\n");
+    code.push_str("    };\n");
+    code.push_str("    CONTENT_SLOT.with(|c| *c.borrow_mut() = content);\n");
+    code.push_str("}\n\n");
+
+    // Flush points: the output rendered so far goes to the host's hudl.flush
+    // import, which streams it to the client and returns nonzero if it took
+    // it. Only modules with a `flush` node call it, so only they import it.
+    code.push_str("#[link(wasm_import_module = \"hudl\")]\n");
+    code.push_str("extern \"C\" {\n");
+    code.push_str("    #[link_name = \"flush\"]\n");
+    code.push_str("    fn host_flush(p: *const u8, l: usize) -> u32;\n");
+    code.push_str("}\n\n");
+
+    code.push_str("thread_local! {\n");
+    code.push_str("    static FLUSH_OUT: std::cell::Cell<*const String> = std::cell::Cell::new(std::ptr::null());\n");
+    code.push_str("}\n\n");
+
+    // A component renders into its caller's buffer, which is only the
+    // view's output if the caller isn't filling another component's slot
+    code.push_str("fn flush_output(r: &mut String) {\n");
+    code.push_str("    if r.is_empty() || !FLUSH_OUT.with(|o| std::ptr::eq(o.get(), r)) {\n");
+    code.push_str("        return;\n");
+    code.push_str("    }\n");
+    code.push_str("    if unsafe { host_flush(r.as_ptr(), r.len()) } != 0 {\n");
+    code.push_str("        r.clear();\n");
+    code.push_str("    }\n");
+    code.push_str("}\n\n");
+
     code.push_str("fn pack(p: *const u8, l: usize) -> u64 {\n");
     code.push_str("    ((p as u64) << 32) | (l as u64)\n");
     code.push_str("}\n\n");
@@ -77,6 +213,18 @@ pub fn generate_wasm_lib_cel(
 
     // CEL evaluation helpers
     code.push_str(CEL_HELPERS);
+    code.push_str(SAFE_NAMES_SRC);
+
+    // Enum constants, bound in every render context so templates can write
+    // `status == STATUS_ACTIVE`. Enum values decode as ints to match.
+    let enum_constants = schema.enum_constants();
+    if !enum_constants.is_empty() {
+        code.push_str("const HUDL_ENUM_CONSTANTS: &[(&str, i64)] = &[\n");
+        for (name, number) in &enum_constants {
+            code.push_str(&format!("    (\"{}\", {}),\n", name, number));
+        }
+        code.push_str("];\n\n");
+    }
 
     // Generate message decoders for all messages in schema
     for (name, msg) in &schema.messages {
@@ -89,20 +237,111 @@ pub fn generate_wasm_lib_cel(
         component_params.insert(name.clone(), root.params.clone());
     }
 
-    // Generate view render functions
+    if opts.strict {
+        let components: HashSet<&str> = component_params.keys().map(|k| k.as_str()).collect();
+        for (name, root) in &views {
+            check_known_tags(&root.nodes, &components).map_err(|e| format!("{}: {}", name, e))?;
+        }
+    }
+    check_component_cycles(&views)?;
+    check_component_args(&views)?;
+    check_each_bindings(&views, schema)?;
+    check_fragment_names(&views)?;
+
+    // List views and their data messages in a custom section, so hosts can
+    // enumerate them without the templates
+    let mut view_schemas = Vec::new();
+    for (name, root) in &views {
+        view_schemas.extend(crate::proto::view_schemas(name, root, schema)?);
+    }
+    let views_json = serde_json::to_string(&view_schemas).map_err(|e| e.to_string())?;
+    code.push_str("#[link_section = \"hudl.views\"]\n#[used]\n");
+    code.push_str(&format!(
+        "static HUDL_VIEWS: [u8; {}] = *b\"{}\";\n",
+        views_json.len(),
+        escape_byte_string(views_json.as_bytes())
+    ));
+
+    // Generate view render functions, hoisting repeated literals into consts
+    let mut view_code = String::new();
     for (name, root) in views {
-        generate_view_function(&mut code, &name, &root, schema, &component_params)?;
+        generate_view_function(&mut view_code, &name, &root, schema, &component_params, serialization)?;
     }
+    code.push_str(&intern_literals(&view_code));
 
     Ok(code)
 }
 
+/// A `push_str` literal used at least this many times is interned...
+const INTERN_MIN_USES: usize = 3;
+/// ...if it is at least this long, so short tags like `"<p"` stay inline.
+const INTERN_MIN_LEN: usize = 16;
+
+/// Rewrite the `push_str("...")` literals in generated `code` that repeat
+/// often enough into `const HUDL_LIT_n: &str`s declared ahead of it, so a
+/// common class string or fragment is stored once in the module.
+fn intern_literals(code: &str) -> String {
+    const OPEN: &str = "push_str(\"";
+
+    // The literals, as escaped in the source, with their byte ranges
+    let mut literals: Vec<(usize, usize)> = Vec::new();
+    let mut search = 0;
+    while let Some(pos) = code[search..].find(OPEN) {
+        let start = search + pos + OPEN.len();
+        let bytes = code.as_bytes();
+        let mut end = start;
+        while end < bytes.len() && bytes[end] != b'"' {
+            end += if bytes[end] == b'\\' { 2 } else { 1 };
+        }
+        let end = end.min(bytes.len());
+        if code[end..].starts_with("\")") {
+            literals.push((start, end));
+        }
+        search = end;
+    }
+
+    let mut counts: HashMap<&str, usize> = HashMap::new();
+    for &(start, end) in &literals {
+        *counts.entry(&code[start..end]).or_insert(0) += 1;
+    }
+    // Number consts in order of first use, so output is stable
+    let mut names: HashMap<&str, String> = HashMap::new();
+    let mut consts = String::new();
+    for &(start, end) in &literals {
+        let lit = &code[start..end];
+        if lit.len() >= INTERN_MIN_LEN && counts[lit] >= INTERN_MIN_USES && !names.contains_key(lit) {
+            let name = format!("HUDL_LIT_{}", names.len());
+            consts.push_str(&format!("const {}: &str = \"{}\";\n", name, lit));
+            names.insert(lit, name);
+        }
+    }
+    if names.is_empty() {
+        return code.to_string();
+    }
+
+    let mut result = consts;
+    let mut last = 0;
+    for &(start, end) in &literals {
+        if let Some(name) = names.get(&code[start..end]) {
+            // Replace the quotes along with the literal
+            result.push_str(&code[last..start - 1]);
+            result.push_str(name);
+            last = end + 1;
+        }
+    }
+    result.push_str(&code[last..]);
+    result
+}
+
 /// Fallback: Generate without proto schema (for backward compatibility)
 pub fn generate_wasm_lib_cel_simple(views: Vec<(String, Root)>) -> Result<String, String> {
     let schema = ProtoSchema::default();
     generate_wasm_lib_cel(views, &schema)
 }
 
+/// Computed tag and attribute name checks, shared with the interpreter.
+const SAFE_NAMES_SRC: &str = include_str!("safe_names.rs");
+
 const CEL_HELPERS: &str = r#"
 fn cel_eval(expr: &str, ctx: &Context) -> CelValue {
     match Program::compile(expr) {
@@ -151,6 +390,47 @@ fn cel_to_string(v: &CelValue) -> String {
     }
 }
 
+/// Tag name for `el tag=`expr`` (see `safe_tag_name`).
+fn safe_tag(v: &CelValue) -> String {
+    safe_tag_name(&cel_to_string(v)).to_string()
+}
+
+/// Attributes from `...`attrs`` spreads, in key order. Names that aren't
+/// safe attribute names are dropped (see `safe_attr_name`), as are
+/// `class` (see `spread_class`) and the element's `own` attributes, which
+/// win. Values are escaped.
+fn spread_attrs(spreads: &[CelValue], own: &[&str]) -> String {
+    let mut attrs = String::new();
+    for v in spreads {
+        let CelValue::Map(m) = v else { continue };
+        for (key, value) in sorted_entries(m) {
+            let Key::String(name) = key else { continue };
+            if safe_attr_name(name) && name.as_str() != "class" && !own.contains(&name.as_str()) && !matches!(value, CelValue::Null) {
+                attrs.push_str(&format!(" {}=\"{}\"", name, html_escape(&cel_to_string(value))));
+            }
+        }
+    }
+    attrs
+}
+
+/// The `class` attribute of an element with spreads: its own `classes`
+/// followed by each spread's `class` entry, or nothing if both are empty.
+fn spread_class(classes: &str, spreads: &[CelValue]) -> String {
+    let mut all = classes.to_string();
+    for v in spreads {
+        let CelValue::Map(m) = v else { continue };
+        let Some(class) = m.map.get(&Key::String(Arc::new("class".to_string()))) else { continue };
+        let class = if matches!(class, CelValue::Null) { String::new() } else { cel_to_string(class) };
+        if !class.is_empty() {
+            if !all.is_empty() {
+                all.push(' ');
+            }
+            all.push_str(&class);
+        }
+    }
+    if all.is_empty() { String::new() } else { format!(" class=\"{}\"", html_escape(&all)) }
+}
+
 fn html_escape(s: &str) -> String {
     let mut result = String::with_capacity(s.len());
     for c in s.chars() {
@@ -166,6 +446,13 @@ fn html_escape(s: &str) -> String {
     result
 }
 
+/// A map's entries in key order, for deterministic `each key value` output.
+fn sorted_entries(m: &CelMap) -> Vec<(&Key, &CelValue)> {
+    let mut entries: Vec<(&Key, &CelValue)> = m.map.iter().collect();
+    entries.sort_by(|a, b| a.0.cmp(b.0));
+    entries
+}
+
 fn proto_value_to_cel(v: &ProtoValue) -> CelValue {
     match v {
         ProtoValue::Varint(n) => CelValue::Int(*n as i64),
@@ -494,72 +781,40 @@ fn generate_value_conversion(
             code.push_str(&format!("{}}}\n", indent));
         }
         ProtoType::Map(key_type, value_type) => {
-            // Maps are encoded as repeated message with key=1, value=2
-            code.push_str(&format!("{}match {} {{\n", indent, var_name));
-            code.push_str(&format!(
-                "{}    ProtoValue::Repeated(items) => {{\n",
-                indent
-            ));
+            // Maps are encoded as repeated message with key=1, value=2; a
+            // single entry arrives as one message
+            code.push_str(&format!("{}{{\n", indent));
             code.push_str(&format!(
-                "{}        let mut map_entries: HashMap<Key, CelValue> = HashMap::new();\n",
-                indent
+                "{}    let map_items: Vec<&ProtoValue> = match {} {{ ProtoValue::Repeated(items) => items.iter().collect(), single => vec![single] }};\n",
+                indent, var_name
             ));
             code.push_str(&format!(
-                "{}        for item in items {{\n",
+                "{}    let mut map_entries: HashMap<Key, CelValue> = HashMap::new();\n",
                 indent
             ));
+            code.push_str(&format!("{}    for item in map_items {{\n", indent));
             code.push_str(&format!(
-                "{}            if let ProtoValue::Bytes(b) = item {{\n",
-                indent
-            ));
-            code.push_str(&format!(
-                "{}                let entry = decode_proto_message(b);\n",
-                indent
-            ));
-            code.push_str(&format!(
-                "{}                if let (Some(k), Some(v)) = (entry.get(&1), entry.get(&2)) {{\n",
+                "{}        let ProtoValue::Bytes(b) = item else {{ continue }};\n",
                 indent
             ));
+            code.push_str(&format!("{}        let entry = decode_proto_message(b);\n", indent));
             // Handle key based on key_type
-            match key_type.as_ref() {
-                ProtoType::String => {
-                    code.push_str(&format!(
-                        "{}                    if let ProtoValue::Bytes(kb) = k {{\n",
-                        indent
-                    ));
-                    code.push_str(&format!(
-                        "{}                        let key_str = String::from_utf8_lossy(kb).to_string();\n",
-                        indent
-                    ));
-                    code.push_str(&format!(
-                        "{}                        map_entries.insert(Key::String(Arc::new(key_str)), proto_value_to_cel(v));\n",
-                        indent
-                    ));
-                    code.push_str(&format!("{}                    }}\n", indent));
-                }
-                _ => {
-                    code.push_str(&format!(
-                        "{}                    if let ProtoValue::Varint(ki) = k {{\n",
-                        indent
-                    ));
-                    code.push_str(&format!(
-                        "{}                        map_entries.insert(Key::Int(*ki as i64), proto_value_to_cel(v));\n",
-                        indent
-                    ));
-                    code.push_str(&format!("{}                    }}\n", indent));
-                }
-            }
-            code.push_str(&format!("{}                }}\n", indent));
-            code.push_str(&format!("{}            }}\n", indent));
+            let key_expr = match key_type.as_ref() {
+                ProtoType::String => "match entry.get(&1) { Some(ProtoValue::Bytes(kb)) => Key::String(Arc::new(String::from_utf8_lossy(kb).to_string())), _ => continue }",
+                _ => "match entry.get(&1) { Some(ProtoValue::Varint(ki)) => Key::Int(*ki as i64), _ => continue }",
+            };
+            code.push_str(&format!("{}        let key = {};\n", indent, key_expr));
+            code.push_str(&format!("{}        if let Some(v) = entry.get(&2) {{\n", indent));
+            code.push_str(&format!("{}            map_entries.insert(key, ", indent));
+            generate_inline_value_conversion(code, value_type, "v", schema)?;
+            code.push_str(");\n");
             code.push_str(&format!("{}        }}\n", indent));
+            code.push_str(&format!("{}    }}\n", indent));
             code.push_str(&format!(
-                "{}        CelValue::Map(CelMap {{ map: Arc::new(map_entries) }})\n",
+                "{}    CelValue::Map(CelMap {{ map: Arc::new(map_entries) }})\n",
                 indent
             ));
-            code.push_str(&format!("{}    }}\n", indent));
-            code.push_str(&format!("{}    _ => CelValue::Null,\n", indent));
             code.push_str(&format!("{}}}\n", indent));
-            let _ = value_type; // Silence unused warning
         }
     }
 
@@ -612,18 +867,76 @@ fn collect_scoped_styles(nodes: &[Node], scope_class: &str) -> Vec<String> {
     css_rules
 }
 
+/// The opening of an `each` loop over `iterable`, binding `_idx` and `_item`
+/// (and `_key` for a map, whose entries go in sorted key order so output is
+/// stable). The caller closes both braces.
+fn each_loop_header(pad: &str, is_map: bool, iterable: &str, ctx_var: &str) -> String {
+    let (pattern, items) = if is_map {
+        ("CelValue::Map(map)", "(_idx, (_key, _item)) in sorted_entries(&map).into_iter().enumerate()")
+    } else {
+        ("CelValue::List(list)", "(_idx, _item) in list.iter().enumerate()")
+    };
+    format!(
+        "{pad}if let {} = cel_eval(\"{}\", {}) {{\n{pad}    for {} {{\n",
+        pattern,
+        escape_string(iterable),
+        ctx_var,
+        items,
+        pad = pad
+    )
+}
+
 fn generate_view_function(
     code: &mut String,
     name: &str,
     root: &Root,
     schema: &ProtoSchema,
     component_params: &HashMap<String, Vec<Param>>,
+    serialization: Serialization,
 ) -> Result<(), String> {
-    let fn_name = name.to_lowercase();
     let scope_class = format!("h-{}", generate_scope_id(name));
 
+    generate_render_function(code, name, &root.nodes, &root.params, root.doctype.as_deref(), &scope_class, schema, component_params, serialization)?;
+
+    // Fragments get their own exports so a single subtree (e.g. #clock) can be
+    // re-rendered for SSE patches. They share the view's params and scope class.
+    for (fragment, node) in collect_fragments(&root.nodes) {
+        let fragment_fn = fragment_function_name(name, &fragment);
+        generate_render_function(
+            code,
+            &fragment_fn,
+            std::slice::from_ref(node),
+            &root.params,
+            None,
+            &scope_class,
+            schema,
+            component_params,
+            serialization,
+        )?;
+    }
+
+    Ok(())
+}
+
+/// Generate the internal render function and exported WASM entry point for
+/// a list of nodes, decoding `params` from the proto input. A `doctype` is
+/// emitted before anything else by the entry point only, so a view used as a
+/// component doesn't repeat it inside its caller.
+fn generate_render_function(
+    code: &mut String,
+    name: &str,
+    nodes: &[Node],
+    params: &[Param],
+    doctype: Option<&str>,
+    scope_class: &str,
+    schema: &ProtoSchema,
+    component_params: &HashMap<String, Vec<Param>>,
+    serialization: Serialization,
+) -> Result<(), String> {
+    let fn_name = name.to_lowercase();
+
     // Collect all scoped styles from the component
-    let css_rules = collect_scoped_styles(&root.nodes, &scope_class);
+    let css_rules = collect_scoped_styles(nodes, scope_class);
 
     // Internal render function - takes proto data and pre-rendered content slot
     code.push_str(&format!(
@@ -637,7 +950,7 @@ fn generate_view_function(
         // Escape quotes for Rust string literal
         let escaped_css = all_css.replace('\\', "\\\\").replace('"', "\\\"");
         code.push_str(&format!(
-            "    r.push_str(\"<style>{}</style>\");\n",
+            "    r.push_str(\"<style\");\n    r.push_str(&csp_nonce_attr());\n    r.push_str(\">{}</style>\");\n",
             escaped_css
         ));
     }
@@ -645,9 +958,15 @@ fn generate_view_function(
     // Always decode proto fields for use in param and loop contexts
     code.push_str("    let _proto_fields = decode_proto_message(proto_data);\n");
     code.push_str("    let mut ctx = Context::default();\n");
+    code.push_str("    let _ = ctx.add_variable(\"locale\", locale_value());\n");
+    if !schema.enum_constants().is_empty() {
+        code.push_str("    for (name, number) in HUDL_ENUM_CONSTANTS {\n");
+        code.push_str("        let _ = ctx.add_variable(*name, CelValue::Int(*number));\n");
+        code.push_str("    }\n");
+    }
 
     // Decode parameters
-    for (i, param) in root.params.iter().enumerate() {
+    for (i, param) in params.iter().enumerate() {
         let field_num = i + 1;
         let field_name = &param.name;
         
@@ -655,7 +974,12 @@ fn generate_view_function(
         code.push_str(&format!("    // Param {}: {}\n", field_num, field_name));
         code.push_str(&format!("    if let Some(v) = _proto_fields.get(&{}) {{\n", field_num));
         
-        let proto_type = ProtoSchema::parse_type(&param.type_name);
+        let mut proto_type = ProtoSchema::parse_type(&param.type_name);
+        if let ProtoType::Message(ref name) = proto_type {
+            if schema.enums.contains_key(name) {
+                proto_type = ProtoType::Enum(name.clone());
+            }
+        }
 
         if param.repeated {
             code.push_str("        let list = match v {\n");
@@ -693,6 +1017,9 @@ fn generate_view_function(
                 code.push_str(&format!("        let _ = ctx.add_variable(\"{}\", CelValue::Int({}));\n", field_name, default));
             } else if proto_type.cel_type() == "double" {
                 code.push_str(&format!("        let _ = ctx.add_variable(\"{}\", CelValue::Float({}));\n", field_name, default));
+            } else if let ProtoType::Enum(_) = proto_type {
+                let number = schema.enum_constant(default).map(|n| n as i64).or_else(|| default.parse().ok()).unwrap_or(0);
+                code.push_str(&format!("        let _ = ctx.add_variable(\"{}\", CelValue::Int({}));\n", field_name, number));
             } else {
                 code.push_str(&format!("        let _ = ctx.add_variable(\"{}\", CelValue::Null);\n", field_name));
             }
@@ -702,6 +1029,8 @@ fn generate_view_function(
             code.push_str(" else {\n");
             if param.repeated {
                 code.push_str(&format!("        let _ = ctx.add_variable(\"{}\", CelValue::List(Arc::new(Vec::new())));\n", field_name));
+            } else if param.optional {
+                code.push_str(&format!("        let _ = ctx.add_variable(\"{}\", CelValue::Null);\n", field_name));
             } else {
                 // We don't have get_default_value_ext in generated code directly, 
                 // but we can generate the specific default.
@@ -710,6 +1039,7 @@ fn generate_view_function(
                     ProtoType::String => code.push_str(&format!("        let _ = ctx.add_variable(\"{}\", CelValue::String(Arc::new(String::new())));\n", field_name)),
                     ProtoType::Bool => code.push_str(&format!("        let _ = ctx.add_variable(\"{}\", CelValue::Bool(false));\n", field_name)),
                     ProtoType::Message(_) => code.push_str(&format!("        let _ = ctx.add_variable(\"{}\", CelValue::Null);\n", field_name)),
+                    ProtoType::Map(_, _) => code.push_str(&format!("        let _ = ctx.add_variable(\"{}\", CelValue::Map(CelMap {{ map: Arc::new(HashMap::new()) }}));\n", field_name)),
                     _ => code.push_str(&format!("        let _ = ctx.add_variable(\"{}\", CelValue::Int(0));\n", field_name)),
                 }
             }
@@ -717,8 +1047,8 @@ fn generate_view_function(
         }
     }
 
-    for node in &root.nodes {
-        generate_node_cel_scoped(code, node, 1, "r", &scope_class, component_params)?;
+    for node in nodes {
+        generate_node_cel_scoped(code, node, 1, "r", scope_class, component_params, serialization)?;
     }
 
     code.push_str("}\n");
@@ -734,8 +1064,14 @@ fn generate_view_function(
     code.push_str("        &[]\n");
     code.push_str("    };\n\n");
 
+    code.push_str("    let content = CONTENT_SLOT.with(|c| mem::take(&mut *c.borrow_mut()));\n");
     code.push_str("    let mut out = String::new();\n");
-    code.push_str(&format!("    render_{}(&mut out, proto_data, \"\");\n", fn_name));
+    if let Some(doctype) = doctype {
+        code.push_str(&format!("    out.push_str(\"<!DOCTYPE {}>\");\n", escape_string(doctype)));
+    }
+    code.push_str("    FLUSH_OUT.with(|o| o.set(&out));\n");
+    code.push_str(&format!("    render_{}(&mut out, proto_data, &content);\n", fn_name));
+    code.push_str("    FLUSH_OUT.with(|o| o.set(std::ptr::null()));\n");
     code.push_str("    let result_ptr = out.as_ptr();\n");
     code.push_str("    let result_len = out.len();\n");
     code.push_str("    mem::forget(out);\n");
@@ -745,11 +1081,21 @@ fn generate_view_function(
     Ok(())
 }
 
+/// A `flush` node hands the view's output so far to the host. Children of a
+/// component invocation render into its slot content, which can't be sent
+/// ahead of the component, so a flush among them is dropped.
+fn generate_flush(code: &mut String, pad: &str, out_var: &str) {
+    if out_var == "r" {
+        code.push_str(pad);
+        code.push_str("flush_output(r);\n");
+    }
+}
+
 #[allow(dead_code)]
 fn generate_node_cel(code: &mut String, node: &Node, indent: usize) -> Result<(), String> {
     // Delegate to scoped version with empty scope (no scoping)
     let empty_map = HashMap::new();
-    generate_node_cel_scoped(code, node, indent, "r", "", &empty_map)
+    generate_node_cel_scoped(code, node, indent, "r", "", &empty_map, Serialization::default())
 }
 
 fn generate_node_cel_scoped(
@@ -758,7 +1104,8 @@ fn generate_node_cel_scoped(
     indent: usize, 
     out_var: &str,
     scope_class: &str, 
-    component_params: &HashMap<String, Vec<Param>>
+    component_params: &HashMap<String, Vec<Param>>,
+    serialization: Serialization,
 ) -> Result<(), String> {
     let pad = "    ".repeat(indent);
 
@@ -767,6 +1114,7 @@ fn generate_node_cel_scoped(
             code.push_str(&pad);
             code.push_str(&format!("{}.push_str(content_html);\n", out_var));
         }
+        Node::Flush => generate_flush(code, &pad, out_var),
         Node::Element(el) => {
             // Check if this is a component invocation
             if let Some(params) = component_params.get(&el.tag) {
@@ -780,52 +1128,12 @@ fn generate_node_cel_scoped(
                 // Pre-render children for the content slot
                 code.push_str("    let mut invocation_content = String::new();\n");
                 for child in &el.children {
-                    generate_node_cel_scoped(code, child, indent + 1, "invocation_content", scope_class, component_params)?;
+                    generate_node_cel_scoped(code, child, indent + 1, "invocation_content", scope_class, component_params, serialization)?;
                 }
 
                 code.push_str(&pad);
                 code.push_str("    let mut component_proto = Vec::new();\n");
-                
-                // Encode each parameter
-                for (i, param) in params.iter().enumerate() {
-                    let field_num = i + 1;
-                    let proto_type = ProtoSchema::parse_type(&param.type_name);
-                    // Use a string representation of the type for the generated code
-                    let type_lit = match proto_type {
-                        ProtoType::String => "Some(&ProtoType::String)",
-                        ProtoType::Bool => "Some(&ProtoType::Bool)",
-                        ProtoType::Int32 => "Some(&ProtoType::Int32)",
-                        ProtoType::Int64 => "Some(&ProtoType::Int64)",
-                        ProtoType::Double => "Some(&ProtoType::Double)",
-                        ProtoType::Float => "Some(&ProtoType::Float)",
-                        _ => "None",
-                    };
-
-                    if let Some(attr_val) = el.attributes.get(&param.name) {
-                        if attr_val.contains('`') {
-                            // Dynamic value
-                            let expr = attr_val.trim_matches('`');
-                            code.push_str(&pad);
-                            code.push_str(&format!(
-                                "    encode_field(&mut component_proto, {}, &cel_eval(\"{}\", &ctx), {});\n",
-                                field_num, escape_string(expr), type_lit
-                            ));
-                        } else {
-                            // Static value - wrap in CelValue
-                            code.push_str(&pad);
-                            code.push_str(&format!(
-                                "    encode_field(&mut component_proto, {}, &CelValue::String(Arc::new(\"{}\".to_string())), {});\n",
-                                field_num, escape_string(attr_val), type_lit
-                            ));
-                        }
-                    } else if let Some(default) = &param.default_value {
-                        code.push_str(&pad);
-                        code.push_str(&format!(
-                            "    encode_field(&mut component_proto, {}, &CelValue::String(Arc::new(\"{}\".to_string())), {});\n",
-                            field_num, escape_string(default), type_lit
-                        ));
-                    }
-                }
+                generate_component_args(code, el, params, &pad, "&ctx");
 
                 code.push_str(&pad);
                 code.push_str(&format!(
@@ -840,8 +1148,18 @@ fn generate_node_cel_scoped(
             }
 
             // Opening tag
-            code.push_str(&pad);
-            code.push_str(&format!("{}.push_str(\"<{}\");\n", out_var, el.tag));
+            push_open_tag(code, el, &pad, "&ctx", out_var);
+            if needs_csp_nonce(el) {
+                code.push_str(&pad);
+                code.push_str(&format!("{}.push_str(&csp_nonce_attr());\n", out_var));
+            }
+            if !el.spreads.is_empty() {
+                let spreads: Vec<String> = el.spreads.iter()
+                    .map(|expr| format!("cel_eval(\"{}\", &ctx)", escape_string(expr)))
+                    .collect();
+                code.push_str(&pad);
+                code.push_str(&format!("let _spreads = [{}];\n", spreads.join(", ")));
+            }
 
             // ID attribute
             if let Some(id) = &el.id {
@@ -851,11 +1169,15 @@ fn generate_node_cel_scoped(
 
             // Class attribute - include scope class if element has styles
             let has_scope_class = !el.styles.is_empty() && !scope_class.is_empty();
-            if !el.classes.is_empty() || has_scope_class {
-                let mut all_classes = el.classes.clone();
-                if has_scope_class {
-                    all_classes.push(scope_class.to_string());
-                }
+            let mut all_classes = el.classes.clone();
+            if has_scope_class {
+                all_classes.push(scope_class.to_string());
+            }
+            if !el.spreads.is_empty() {
+                // A spread's class is appended to the element's own
+                code.push_str(&pad);
+                code.push_str(&format!("{}.push_str(&spread_class(\"{}\", &_spreads));\n", out_var, all_classes.join(" ")));
+            } else if !all_classes.is_empty() {
                 code.push_str(&pad);
                 code.push_str(&format!(
                     "{}.push_str(\" class=\\\"{}\\\"\");\n",
@@ -868,13 +1190,22 @@ fn generate_node_cel_scoped(
             for (key, value) in &el.attributes {
                 if value.contains('`') {
                     // Dynamic attribute with CEL
-                    generate_dynamic_attr_with_ctx(code, key, value, &pad, "&ctx", out_var)?;
+                    generate_dynamic_attr_with_ctx(code, key, value, &pad, "&ctx", out_var, serialization)?;
                 } else {
                     // Static attribute
                     code.push_str(&pad);
-                    code.push_str(&format!("{}.push_str(\" {}=\\\"{}\\\"\");\n", out_var, key, value));
+                    code.push_str(&format!(
+                        "{}.push_str(\" {}=\\\"{}\\\"\");\n",
+                        out_var,
+                        key,
+                        escape_string(&crate::cel::html_escape(value))
+                    ));
                 }
             }
+            if !el.spreads.is_empty() {
+                code.push_str(&pad);
+                code.push_str(&format!("{}.push_str(&spread_attrs(&_spreads, &[{}]));\n", out_var, own_attr_names(el)));
+            }
 
             // Datastar attributes
             for attr in &el.datastar {
@@ -884,28 +1215,32 @@ fn generate_node_cel_scoped(
                 code.push_str(&html_attr);
                 if let Some(val) = html_val {
                     code.push_str("=\\\"");
-                    code.push_str(&val.replace('"', "&quot;"));
+                    code.push_str(&escape_string(&val.replace('"', "&quot;")));
                     code.push_str("\\\"");
                 }
                 code.push_str("\");\n");
             }
 
-            // Close opening tag
+            // Close opening tag; void elements have no children or closing tag
+            if is_void_element(&el.tag) && !el.foreign {
+                code.push_str(&pad);
+                code.push_str(&format!("{}.push_str(\"{}\");\n", out_var, void_tag_end(serialization)));
+                return Ok(());
+            }
             code.push_str(&pad);
             code.push_str(&format!("{}.push_str(\">\");\n", out_var));
 
             // Children
             for child in &el.children {
-                generate_node_cel_scoped(code, child, indent + 1, out_var, scope_class, component_params)?;
+                generate_node_cel_scoped(code, child, indent + 1, out_var, scope_class, component_params, serialization)?;
             }
 
             // Closing tag
-            code.push_str(&pad);
-            code.push_str(&format!("{}.push_str(\"</{}>\");\n", out_var, el.tag));
+            push_close_tag(code, el, &pad, out_var);
         }
 
         Node::Text(t) => {
-            generate_text_with_interpolation_ctx(code, &t.content, &pad, "&ctx", out_var)?;
+            generate_text_with_interpolation_ctx(code, &t.content, t.raw, &pad, "&ctx", out_var)?;
         }
 
         Node::ControlFlow(cf) => match cf {
@@ -921,40 +1256,53 @@ fn generate_node_cel_scoped(
                 ));
 
                 for child in then_block {
-                    generate_node_cel_scoped(code, child, indent + 1, out_var, scope_class, component_params)?;
+                    generate_node_cel_scoped(code, child, indent + 1, out_var, scope_class, component_params, serialization)?;
                 }
 
                 code.push_str(&pad);
                 code.push_str("}");
 
-                if let Some(else_nodes) = else_block {
+                // An else block holding only an `if` is an `else if` chain
+                let mut else_block = else_block;
+                while let Some(else_nodes) = else_block {
+                    if let [Node::ControlFlow(crate::ast::ControlFlow::If { condition, then_block, else_block: next })] = else_nodes.as_slice() {
+                        code.push_str(&format!(
+                            " else if cel_truthy(&cel_eval(\"{}\", &ctx)) {{\n",
+                            escape_string(condition)
+                        ));
+                        for child in then_block {
+                            generate_node_cel_scoped(code, child, indent + 1, out_var, scope_class, component_params, serialization)?;
+                        }
+                        code.push_str(&pad);
+                        code.push_str("}");
+                        else_block = next;
+                        continue;
+                    }
                     code.push_str(" else {\n");
                     for child in else_nodes {
-                        generate_node_cel_scoped(code, child, indent + 1, out_var, scope_class, component_params)?;
+                        generate_node_cel_scoped(code, child, indent + 1, out_var, scope_class, component_params, serialization)?;
                     }
                     code.push_str(&pad);
                     code.push_str("}");
+                    break;
                 }
                 code.push_str("\n");
             }
 
             crate::ast::ControlFlow::Each {
+                key,
                 binding,
                 iterable,
                 body,
             } => {
-                code.push_str(&pad);
-                code.push_str(&format!(
-                    "if let CelValue::List(list) = cel_eval(\"{}\", &ctx) {{\n",
-                    escape_string(iterable)
-                ));
-                code.push_str(&pad);
-                code.push_str("    for (_idx, _item) in list.iter().enumerate() {\n");
+                code.push_str(&each_loop_header(&pad, key.is_some(), iterable, "&ctx"));
                 code.push_str(&pad);
                 code.push_str("        // Create fresh context for loop iteration (Context doesn't impl Clone)\n");
                 code.push_str(&pad);
                 code.push_str("        let mut loop_ctx = Context::default();\n");
                 code.push_str(&pad);
+                code.push_str("        let _ = loop_ctx.add_variable(\"locale\", locale_value());\n");
+                code.push_str(&pad);
                 code.push_str("        for (k, v) in &_proto_fields {\n");
                 code.push_str(&pad);
                 code.push_str("            let _ = loop_ctx.add_variable(&k.to_string(), proto_value_to_cel(v));\n");
@@ -970,9 +1318,16 @@ fn generate_node_cel_scoped(
                     "        let _ = loop_ctx.add_variable(\"{}_idx\", CelValue::Int(_idx as i64));\n",
                     binding
                 ));
+                if let Some(key) = key {
+                    code.push_str(&pad);
+                    code.push_str(&format!(
+                        "        let _ = loop_ctx.add_variable(\"{}\", CelValue::from(_key.clone()));\n",
+                        key
+                    ));
+                }
 
                 for child in body {
-                    generate_node_cel_with_ctx_scoped(code, child, indent + 2, "&loop_ctx", out_var, scope_class, component_params)?;
+                    generate_node_cel_with_ctx_scoped(code, child, indent + 2, "&loop_ctx", out_var, scope_class, component_params, serialization)?;
                 }
 
                 code.push_str(&pad);
@@ -995,7 +1350,7 @@ fn generate_node_cel_scoped(
                 ));
 
                 let mut first = true;
-                for SwitchCase(pattern, children) in cases {
+                for SwitchCase(patterns, children) in cases {
                     code.push_str(&pad);
                     if first {
                         code.push_str("    if ");
@@ -1004,14 +1359,11 @@ fn generate_node_cel_scoped(
                         code.push_str("    else if ");
                     }
 
-                    // Compare switch value to pattern (as string for enum values)
-                    code.push_str(&format!(
-                        "cel_to_string(&_switch_val) == \"{}\" {{\n",
-                        pattern
-                    ));
+                    // Compare switch value to the patterns (as strings for enum values)
+                    code.push_str(&format!("{} {{\n", switch_case_condition(patterns)));
 
                     for child in children {
-                        generate_node_cel_scoped(code, child, indent + 2, out_var, scope_class, component_params)?;
+                        generate_node_cel_scoped(code, child, indent + 2, out_var, scope_class, component_params, serialization)?;
                     }
 
                     code.push_str(&pad);
@@ -1027,7 +1379,7 @@ fn generate_node_cel_scoped(
                     }
 
                     for child in def_nodes {
-                        generate_node_cel_scoped(code, child, indent + 2, out_var, scope_class, component_params)?;
+                        generate_node_cel_scoped(code, child, indent + 2, out_var, scope_class, component_params, serialization)?;
                     }
 
                     code.push_str(&pad);
@@ -1052,7 +1404,7 @@ fn generate_node_cel_with_ctx(
     ctx_var: &str,
 ) -> Result<(), String> {
     let empty_map = HashMap::new();
-    generate_node_cel_with_ctx_scoped(code, node, indent, ctx_var, "r", "", &empty_map)
+    generate_node_cel_with_ctx_scoped(code, node, indent, ctx_var, "r", "", &empty_map, Serialization::default())
 }
 
 /// Generate node with custom context and scope class for scoped styles.
@@ -1064,6 +1416,7 @@ fn generate_node_cel_with_ctx_scoped(
     out_var: &str,
     scope_class: &str,
     component_params: &HashMap<String, Vec<Param>>,
+    serialization: Serialization,
 ) -> Result<(), String> {
     let pad = "    ".repeat(indent);
 
@@ -1072,6 +1425,7 @@ fn generate_node_cel_with_ctx_scoped(
             code.push_str(&pad);
             code.push_str(&format!("{}.push_str(content_html);\n", out_var));
         }
+        Node::Flush => generate_flush(code, &pad, out_var),
         Node::Element(el) => {
             // Check if this is a component invocation
             if let Some(params) = component_params.get(&el.tag) {
@@ -1083,49 +1437,12 @@ fn generate_node_cel_with_ctx_scoped(
                 // Pre-render children for the content slot
                 code.push_str("    let mut invocation_content = String::new();\n");
                 for child in &el.children {
-                    generate_node_cel_with_ctx_scoped(code, child, indent + 1, ctx_var, "invocation_content", scope_class, component_params)?;
+                    generate_node_cel_with_ctx_scoped(code, child, indent + 1, ctx_var, "invocation_content", scope_class, component_params, serialization)?;
                 }
 
                 code.push_str(&pad);
                 code.push_str("    let mut component_proto = Vec::new();\n");
-                
-                // Encode each parameter
-                for (i, param) in params.iter().enumerate() {
-                    let field_num = i + 1;
-                    let proto_type = ProtoSchema::parse_type(&param.type_name);
-                    let type_lit = match proto_type {
-                        ProtoType::String => "Some(&ProtoType::String)",
-                        ProtoType::Bool => "Some(&ProtoType::Bool)",
-                        ProtoType::Int32 => "Some(&ProtoType::Int32)",
-                        ProtoType::Int64 => "Some(&ProtoType::Int64)",
-                        ProtoType::Double => "Some(&ProtoType::Double)",
-                        ProtoType::Float => "Some(&ProtoType::Float)",
-                        _ => "None",
-                    };
-
-                    if let Some(attr_val) = el.attributes.get(&param.name) {
-                        if attr_val.contains('`') {
-                            let expr = attr_val.trim_matches('`');
-                            code.push_str(&pad);
-                            code.push_str(&format!(
-                                "    encode_field(&mut component_proto, {}, &cel_eval(\"{}\", {}), {});\n",
-                                field_num, escape_string(expr), ctx_var, type_lit
-                            ));
-                        } else {
-                            code.push_str(&pad);
-                            code.push_str(&format!(
-                                "    encode_field(&mut component_proto, {}, &CelValue::String(Arc::new(\"{}\".to_string())), {});\n",
-                                field_num, escape_string(attr_val), type_lit
-                            ));
-                        }
-                    } else if let Some(default) = &param.default_value {
-                        code.push_str(&pad);
-                        code.push_str(&format!(
-                            "    encode_field(&mut component_proto, {}, &CelValue::String(Arc::new(\"{}\".to_string())), {});\n",
-                            field_num, escape_string(default), type_lit
-                        ));
-                    }
-                }
+                generate_component_args(code, el, params, &pad, ctx_var);
 
                 code.push_str(&pad);
                 code.push_str(&format!(
@@ -1139,8 +1456,18 @@ fn generate_node_cel_with_ctx_scoped(
                 return Ok(());
             }
 
-            code.push_str(&pad);
-            code.push_str(&format!("{}.push_str(\"<{}\");\n", out_var, el.tag));
+            push_open_tag(code, el, &pad, ctx_var, out_var);
+            if needs_csp_nonce(el) {
+                code.push_str(&pad);
+                code.push_str(&format!("{}.push_str(&csp_nonce_attr());\n", out_var));
+            }
+            if !el.spreads.is_empty() {
+                let spreads: Vec<String> = el.spreads.iter()
+                    .map(|expr| format!("cel_eval(\"{}\", {})", escape_string(expr), ctx_var))
+                    .collect();
+                code.push_str(&pad);
+                code.push_str(&format!("let _spreads = [{}];\n", spreads.join(", ")));
+            }
 
             if let Some(id) = &el.id {
                 code.push_str(&pad);
@@ -1149,11 +1476,15 @@ fn generate_node_cel_with_ctx_scoped(
 
             // Class attribute - include scope class if element has styles
             let has_scope_class = !el.styles.is_empty() && !scope_class.is_empty();
-            if !el.classes.is_empty() || has_scope_class {
-                let mut all_classes = el.classes.clone();
-                if has_scope_class {
-                    all_classes.push(scope_class.to_string());
-                }
+            let mut all_classes = el.classes.clone();
+            if has_scope_class {
+                all_classes.push(scope_class.to_string());
+            }
+            if !el.spreads.is_empty() {
+                // A spread's class is appended to the element's own
+                code.push_str(&pad);
+                code.push_str(&format!("{}.push_str(&spread_class(\"{}\", &_spreads));\n", out_var, all_classes.join(" ")));
+            } else if !all_classes.is_empty() {
                 code.push_str(&pad);
                 code.push_str(&format!(
                     "{}.push_str(\" class=\\\"{}\\\"\");\n",
@@ -1164,12 +1495,21 @@ fn generate_node_cel_with_ctx_scoped(
 
             for (key, value) in &el.attributes {
                 if value.contains('`') {
-                    generate_dynamic_attr_with_ctx(code, key, value, &pad, ctx_var, out_var)?;
+                    generate_dynamic_attr_with_ctx(code, key, value, &pad, ctx_var, out_var, serialization)?;
                 } else {
                     code.push_str(&pad);
-                    code.push_str(&format!("{}.push_str(\" {}=\\\"{}\\\"\");\n", out_var, key, value));
+                    code.push_str(&format!(
+                        "{}.push_str(\" {}=\\\"{}\\\"\");\n",
+                        out_var,
+                        key,
+                        escape_string(&crate::cel::html_escape(value))
+                    ));
                 }
             }
+            if !el.spreads.is_empty() {
+                code.push_str(&pad);
+                code.push_str(&format!("{}.push_str(&spread_attrs(&_spreads, &[{}]));\n", out_var, own_attr_names(el)));
+            }
 
             // Datastar attributes
             for attr in &el.datastar {
@@ -1179,25 +1519,29 @@ fn generate_node_cel_with_ctx_scoped(
                 code.push_str(&html_attr);
                 if let Some(val) = html_val {
                     code.push_str("=\\\"");
-                    code.push_str(&val.replace('"', "&quot;"));
+                    code.push_str(&escape_string(&val.replace('"', "&quot;")));
                     code.push_str("\\\"");
                 }
                 code.push_str("\");\n");
             }
 
+            if is_void_element(&el.tag) && !el.foreign {
+                code.push_str(&pad);
+                code.push_str(&format!("{}.push_str(\"{}\");\n", out_var, void_tag_end(serialization)));
+                return Ok(());
+            }
             code.push_str(&pad);
             code.push_str(&format!("{}.push_str(\">\");\n", out_var));
 
             for child in &el.children {
-                generate_node_cel_with_ctx_scoped(code, child, indent + 1, ctx_var, out_var, scope_class, component_params)?;
+                generate_node_cel_with_ctx_scoped(code, child, indent + 1, ctx_var, out_var, scope_class, component_params, serialization)?;
             }
 
-            code.push_str(&pad);
-            code.push_str(&format!("{}.push_str(\"</{}>\");\n", out_var, el.tag));
+            push_close_tag(code, el, &pad, out_var);
         }
 
         Node::Text(t) => {
-            generate_text_with_interpolation_ctx(code, &t.content, &pad, ctx_var, out_var)?;
+            generate_text_with_interpolation_ctx(code, &t.content, t.raw, &pad, ctx_var, out_var)?;
         }
 
         Node::ControlFlow(cf) => match cf {
@@ -1214,46 +1558,51 @@ fn generate_node_cel_with_ctx_scoped(
                 ));
 
                 for child in then_block {
-                    generate_node_cel_with_ctx_scoped(code, child, indent + 1, ctx_var, out_var, scope_class, component_params)?;
+                    generate_node_cel_with_ctx_scoped(code, child, indent + 1, ctx_var, out_var, scope_class, component_params, serialization)?;
                 }
 
                 code.push_str(&pad);
                 code.push_str("}");
 
-                if let Some(else_nodes) = else_block {
+                // An else block holding only an `if` is an `else if` chain
+                let mut else_block = else_block;
+                while let Some(else_nodes) = else_block {
+                    if let [Node::ControlFlow(crate::ast::ControlFlow::If { condition, then_block, else_block: next })] = else_nodes.as_slice() {
+                        code.push_str(&format!(
+                            " else if cel_truthy(&cel_eval(\"{}\", {})) {{\n",
+                            escape_string(condition),
+                            ctx_var
+                        ));
+                        for child in then_block {
+                            generate_node_cel_with_ctx_scoped(code, child, indent + 1, ctx_var, out_var, scope_class, component_params, serialization)?;
+                        }
+                        code.push_str(&pad);
+                        code.push_str("}");
+                        else_block = next;
+                        continue;
+                    }
                     code.push_str(" else {\n");
                     for child in else_nodes {
-                        generate_node_cel_with_ctx_scoped(code, child, indent + 1, ctx_var, out_var, scope_class, component_params)?;
+                        generate_node_cel_with_ctx_scoped(code, child, indent + 1, ctx_var, out_var, scope_class, component_params, serialization)?;
                     }
                     code.push_str(&pad);
                     code.push_str("}");
+                    break;
                 }
                 code.push_str("\n");
             }
 
             crate::ast::ControlFlow::Each {
+                key,
                 binding,
                 iterable,
                 body,
             } => {
+                code.push_str(&each_loop_header(&pad, key.is_some(), iterable, ctx_var));
                 code.push_str(&pad);
-                code.push_str(&format!(
-                    "if let CelValue::List(list) = cel_eval(\"{}\", {}) {{\n",
-                    escape_string(iterable),
-                    ctx_var
-                ));
-                code.push_str(&pad);
-                code.push_str("    for (_idx, _item) in list.iter().enumerate() {\n");
-                code.push_str(&pad);
-                code.push_str("        // Create fresh context for nested loop (Context doesn't impl Clone)\n");
-                code.push_str(&pad);
-                code.push_str("        let mut inner_ctx = Context::default();\n");
+                code.push_str("        // Scope over the enclosing context, so outer loop variables stay visible\n");
                 code.push_str(&pad);
-                code.push_str("        for (k, v) in &_proto_fields {\n");
-                code.push_str(&pad);
-                code.push_str("            let _ = inner_ctx.add_variable(&k.to_string(), proto_value_to_cel(v));\n");
-                code.push_str(&pad);
-                code.push_str("        }\n");
+                code.push_str(&format!("        let mut inner_ctx = Context::new_inner_scope({});\n", ctx_var));
                 code.push_str(&pad);
                 code.push_str(&format!(
                     "        let _ = inner_ctx.add_variable(\"{}\", _item.clone());\n",
@@ -1264,9 +1613,16 @@ fn generate_node_cel_with_ctx_scoped(
                     "        let _ = inner_ctx.add_variable(\"{}_idx\", CelValue::Int(_idx as i64));\n",
                     binding
                 ));
+                if let Some(key) = key {
+                    code.push_str(&pad);
+                    code.push_str(&format!(
+                        "        let _ = inner_ctx.add_variable(\"{}\", CelValue::from(_key.clone()));\n",
+                        key
+                    ));
+                }
 
                 for child in body {
-                    generate_node_cel_with_ctx_scoped(code, child, indent + 2, "&inner_ctx", out_var, scope_class, component_params)?;
+                    generate_node_cel_with_ctx_scoped(code, child, indent + 2, "&inner_ctx", out_var, scope_class, component_params, serialization)?;
                 }
 
                 code.push_str(&pad);
@@ -1290,7 +1646,7 @@ fn generate_node_cel_with_ctx_scoped(
                 ));
 
                 let mut first = true;
-                for SwitchCase(pattern, children) in cases {
+                for SwitchCase(patterns, children) in cases {
                     code.push_str(&pad);
                     if first {
                         code.push_str("    if ");
@@ -1299,13 +1655,10 @@ fn generate_node_cel_with_ctx_scoped(
                         code.push_str("    else if ");
                     }
 
-                    code.push_str(&format!(
-                        "cel_to_string(&_switch_val) == \"{}\" {{\n",
-                        pattern
-                    ));
+                    code.push_str(&format!("{} {{\n", switch_case_condition(patterns)));
 
                     for child in children {
-                        generate_node_cel_with_ctx_scoped(code, child, indent + 2, ctx_var, out_var, scope_class, component_params)?;
+                        generate_node_cel_with_ctx_scoped(code, child, indent + 2, ctx_var, out_var, scope_class, component_params, serialization)?;
                     }
 
                     code.push_str(&pad);
@@ -1321,7 +1674,7 @@ fn generate_node_cel_with_ctx_scoped(
                     }
 
                     for child in def_nodes {
-                        generate_node_cel_with_ctx_scoped(code, child, indent + 2, ctx_var, out_var, scope_class, component_params)?;
+                        generate_node_cel_with_ctx_scoped(code, child, indent + 2, ctx_var, out_var, scope_class, component_params, serialization)?;
                     }
 
                     code.push_str(&pad);
@@ -1337,6 +1690,63 @@ fn generate_node_cel_with_ctx_scoped(
     Ok(())
 }
 
+/// The attribute names an element writes itself, as a generated-code list of
+/// string literals, so `spread_attrs` leaves them alone.
+fn own_attr_names(el: &crate::ast::Element) -> String {
+    let mut names: Vec<String> = el.attributes.keys().cloned().collect();
+    names.extend(el.id.as_ref().map(|_| "id".to_string()));
+    names.extend(el.datastar.iter().map(|attr| datastar_attr_to_html(attr).0));
+    names.sort();
+    names.iter().map(|n| format!("\"{}\"", escape_string(n))).collect::<Vec<_>>().join(", ")
+}
+
+/// Inline `<style>` and `<script>` elements (no `src`) carry the CSP nonce.
+fn needs_csp_nonce(el: &crate::ast::Element) -> bool {
+    el.tag == "style" || (el.tag == "script" && !el.attributes.contains_key("src"))
+}
+
+/// End of a void element's tag: `>` in HTML5, ` />` in XHTML.
+fn void_tag_end(serialization: Serialization) -> &'static str {
+    match serialization {
+        Serialization::Html5 => ">",
+        Serialization::Xhtml => " />",
+    }
+}
+
+/// Emit `<tag` for an element. A dynamic tag (`el tag=`expr``) is computed
+/// into `dyn_tag` inside a block that `push_close_tag` closes.
+fn push_open_tag(code: &mut String, el: &crate::ast::Element, pad: &str, ctx_var: &str, out_var: &str) {
+    code.push_str(pad);
+    match &el.dynamic_tag {
+        Some(expr) => {
+            code.push_str("{\n");
+            code.push_str(pad);
+            code.push_str(&format!("let dyn_tag = safe_tag(&cel_eval(\"{}\", {}));\n", escape_string(expr), ctx_var));
+            code.push_str(pad);
+            code.push_str(&format!("{}.push_str(\"<\");\n", out_var));
+            code.push_str(pad);
+            code.push_str(&format!("{}.push_str(&dyn_tag);\n", out_var));
+        }
+        None => code.push_str(&format!("{}.push_str(\"<{}\");\n", out_var, el.tag)),
+    }
+}
+
+/// Emit `</tag>` for an element, closing a dynamic tag's block.
+fn push_close_tag(code: &mut String, el: &crate::ast::Element, pad: &str, out_var: &str) {
+    code.push_str(pad);
+    if el.dynamic_tag.is_some() {
+        code.push_str(&format!("{}.push_str(\"</\");\n", out_var));
+        code.push_str(pad);
+        code.push_str(&format!("{}.push_str(&dyn_tag);\n", out_var));
+        code.push_str(pad);
+        code.push_str(&format!("{}.push_str(\">\");\n", out_var));
+        code.push_str(pad);
+        code.push_str("}\n");
+    } else {
+        code.push_str(&format!("{}.push_str(\"</{}>\");\n", out_var, el.tag));
+    }
+}
+
 fn generate_dynamic_attr_with_ctx(
     code: &mut String,
     key: &str,
@@ -1344,6 +1754,7 @@ fn generate_dynamic_attr_with_ctx(
     pad: &str,
     ctx_var: &str,
     out_var: &str,
+    serialization: Serialization,
 ) -> Result<(), String> {
     // For boolean attributes like checked=`is_checked`
     let is_boolean_attr = matches!(
@@ -1372,14 +1783,17 @@ fn generate_dynamic_attr_with_ctx(
             ctx_var
         ));
         code.push_str(pad);
-        code.push_str(&format!("    {}.push_str(\" {}\");\n", out_var, key));
+        match serialization {
+            Serialization::Html5 => code.push_str(&format!("    {}.push_str(\" {}\");\n", out_var, key)),
+            Serialization::Xhtml => code.push_str(&format!("    {}.push_str(\" {}=\\\"{}\\\"\");\n", out_var, key, key)),
+        }
         code.push_str(pad);
         code.push_str("}\n");
     } else {
         // Dynamic value
         code.push_str(pad);
         code.push_str(&format!("{}.push_str(\" {}=\\\"\");\n", out_var, key));
-        generate_text_with_interpolation_ctx(code, value, pad, ctx_var, out_var)?;
+        generate_text_with_interpolation_ctx(code, value, false, pad, ctx_var, out_var)?;
         code.push_str(pad);
         code.push_str(&format!("{}.push_str(\"\\\"\");\n", out_var));
     }
@@ -1390,6 +1804,7 @@ fn generate_dynamic_attr_with_ctx(
 fn generate_text_with_interpolation_ctx(
     code: &mut String,
     content: &str,
+    raw: bool,
     pad: &str,
     ctx_var: &str,
     out_var: &str,
@@ -1400,8 +1815,9 @@ fn generate_text_with_interpolation_ctx(
         if i % 2 == 0 {
             // Static text
             if !part.is_empty() {
+                let text = if raw { part.to_string() } else { crate::cel::escape_bare_ampersands(part) };
                 code.push_str(pad);
-                code.push_str(&format!("{}.push_str(\"{}\");\n", out_var, escape_string(part)));
+                code.push_str(&format!("{}.push_str(\"{}\");\n", out_var, escape_string(&text)));
             }
         } else {
             // CEL expression
@@ -1419,6 +1835,14 @@ fn generate_text_with_interpolation_ctx(
                         escape_string(inner),
                         ctx_var
                     ));
+                } else if raw {
+                    // Inside a raw { } block - unescaped
+                    code.push_str(&format!(
+                        "{}.push_str(&cel_to_string(&cel_eval(\"{}\", {})));\n",
+                        out_var,
+                        escape_string(part),
+                        ctx_var
+                    ));
                 } else {
                     // Normal expression - HTML escaped
                     code.push_str(&format!(
@@ -1507,10 +1931,125 @@ fn generate_inline_value_conversion(
     Ok(())
 }
 
+/// Encode a component invocation's args into `component_proto`, one field
+/// per declared param in order. A component with an `attrs` param gets the
+/// invocation's other attributes in it (see `ast::forwarded_attrs`), over
+/// the entries of an explicit `attrs=` map.
+fn generate_component_args(code: &mut String, el: &crate::ast::Element, params: &[Param], pad: &str, ctx_var: &str) {
+    let attrs_param = crate::ast::attrs_param(params);
+    for (i, param) in params.iter().enumerate() {
+        let field_num = i + 1;
+        let proto_type = ProtoSchema::parse_type(&param.type_name);
+        // Use a string representation of the type for the generated code
+        let type_lit = proto_type_lit(&proto_type).map_or("None".to_string(), |t| format!("Some(&{})", t));
+
+        if attrs_param == Some(i) {
+            let forwarded = crate::ast::forwarded_attrs(el, params);
+            if !forwarded.is_empty() {
+                code.push_str(pad);
+                code.push_str("    let mut forwarded: HashMap<Key, CelValue> = HashMap::new();\n");
+                if let Some(attrs) = el.attributes.get(&param.name).filter(|v| v.contains('`')) {
+                    code.push_str(pad);
+                    code.push_str(&format!(
+                        "    if let CelValue::Map(m) = cel_eval(\"{}\", {}) {{ forwarded.extend(m.map.iter().map(|(k, v)| (k.clone(), v.clone()))); }}\n",
+                        escape_string(attrs.trim_matches('`')), ctx_var
+                    ));
+                }
+                for (name, value) in &forwarded {
+                    let value_expr = if value.contains('`') {
+                        format!("cel_to_string(&cel_eval(\"{}\", {}))", escape_string(value.trim_matches('`')), ctx_var)
+                    } else {
+                        format!("\"{}\".to_string()", escape_string(value))
+                    };
+                    code.push_str(pad);
+                    code.push_str(&format!(
+                        "    forwarded.insert(Key::String(Arc::new(\"{}\".to_string())), CelValue::String(Arc::new({})));\n",
+                        escape_string(name), value_expr
+                    ));
+                }
+                code.push_str(pad);
+                code.push_str(&format!(
+                    "    encode_field(&mut component_proto, {}, &CelValue::Map(CelMap {{ map: Arc::new(forwarded) }}), {});\n",
+                    field_num, type_lit
+                ));
+                continue;
+            }
+        }
+
+        if let Some(attr_val) = el.attributes.get(&param.name) {
+            if attr_val.contains('`') {
+                // Dynamic value
+                let expr = attr_val.trim_matches('`');
+                code.push_str(pad);
+                code.push_str(&format!(
+                    "    encode_field(&mut component_proto, {}, &cel_eval(\"{}\", {}), {});\n",
+                    field_num, escape_string(expr), ctx_var, type_lit
+                ));
+            } else {
+                // Static value - wrap in CelValue
+                code.push_str(pad);
+                code.push_str(&format!(
+                    "    encode_field(&mut component_proto, {}, &CelValue::String(Arc::new(\"{}\".to_string())), {});\n",
+                    field_num, escape_string(attr_val), type_lit
+                ));
+            }
+        } else if let Some(default) = &param.default_value {
+            code.push_str(pad);
+            code.push_str(&format!(
+                "    encode_field(&mut component_proto, {}, &CelValue::String(Arc::new(\"{}\".to_string())), {});\n",
+                field_num, escape_string(default), type_lit
+            ));
+        }
+    }
+}
+
+/// The generated-code `ProtoType` for a param type `encode_field` needs to
+/// know, or None to let it infer one from the value.
+fn proto_type_lit(proto_type: &ProtoType) -> Option<String> {
+    let lit = match proto_type {
+        ProtoType::String => "ProtoType::String",
+        ProtoType::Bool => "ProtoType::Bool",
+        ProtoType::Int32 => "ProtoType::Int32",
+        ProtoType::Int64 => "ProtoType::Int64",
+        ProtoType::Double => "ProtoType::Double",
+        ProtoType::Float => "ProtoType::Float",
+        ProtoType::Map(key, value) => {
+            return Some(format!("ProtoType::Map(Box::new({}), Box::new({}))", proto_type_lit(key)?, proto_type_lit(value)?));
+        }
+        _ => return None,
+    };
+    Some(lit.to_string())
+}
+
+/// The condition matching `_switch_val` against any of a case's patterns.
+fn switch_case_condition(patterns: &[String]) -> String {
+    patterns
+        .iter()
+        .map(|p| format!("cel_to_string(&_switch_val) == \"{}\"", escape_string(p)))
+        .collect::<Vec<_>>()
+        .join(" || ")
+}
+
 fn escape_string(s: &str) -> String {
     s.replace('\\', "\\\\").replace('"', "\\\"")
 }
 
+/// Escape bytes for a byte string literal (`b"..."`), which only takes
+/// ASCII: anything else is written as `\xNN`, so the literal holds exactly
+/// `bytes.len()` bytes.
+fn escape_byte_string(bytes: &[u8]) -> String {
+    let mut out = String::with_capacity(bytes.len());
+    for &b in bytes {
+        match b {
+            b'\\' => out.push_str("\\\\"),
+            b'"' => out.push_str("\\\""),
+            0x20..=0x7e => out.push(b as char),
+            _ => out.push_str(&format!("\\x{:02x}", b)),
+        }
+    }
+    out
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -1536,6 +2075,43 @@ el {
         assert!(rust_code.contains("Hello"));
     }
 
+    #[test]
+    fn test_escape_byte_string() {
+        // One escape per byte, so the literal is as long as the JSON
+        assert_eq!(escape_byte_string("é\"\\x".as_bytes()), r#"\xc3\xa9\"\\x"#);
+    }
+
+    #[test]
+    fn test_repeated_literals_are_interned() {
+        let input = r#"
+el {
+    span.badge.badge-secondary "one"
+    span.badge.badge-secondary "two"
+    span.badge.badge-secondary "three"
+    b.label.label-primary "twice"
+    b.label.label-primary "twice"
+}
+        "#;
+
+        let doc = parser::parse(input).unwrap();
+        let root = transformer::transform(&doc).unwrap();
+        let rust_code = generate_wasm_lib_cel(vec![("Badges".to_string(), root)], &ProtoSchema::default())
+            .expect("Codegen failed");
+
+        assert!(
+            rust_code.contains(r#"const HUDL_LIT_0: &str = " class=\"badge badge-secondary\"";"#),
+            "Code: {}",
+            rust_code
+        );
+        assert_eq!(rust_code.matches("r.push_str(HUDL_LIT_0);").count(), 3);
+        assert!(!rust_code.contains("badge-secondary\\\"\");"));
+
+        // Two uses, or a short literal, stay inline
+        assert!(!rust_code.contains("HUDL_LIT_1"));
+        assert_eq!(rust_code.matches(r#"r.push_str(" class=\"label label-primary\"");"#).count(), 2);
+        assert!(rust_code.contains(r#"r.push_str("<span");"#));
+    }
+
     #[test]
     fn test_generate_with_cel() {
         let input = r#"
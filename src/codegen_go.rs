@@ -5,11 +5,60 @@ pub struct GoOptions {
     pub package_name: String,
     pub pb_import_path: String,
     pub pb_package_name: String,
+    /// Also emit a `<View>Data` struct, `New<View>Data` constructor and
+    /// `<View>With` method per view with params (opt-in).
+    pub data_types: bool,
+    /// Take a `context.Context` as the first parameter of every method and
+    /// render with `RenderBytesContext` (opt-in).
+    pub context: bool,
+    /// Return `([]byte, error)` and render with `RenderRaw` instead of
+    /// returning a string (opt-in).
+    pub bytes: bool,
+    /// Indentation of the generated source, one unit per nesting level.
+    pub indent: Indent,
+}
+
+/// Indentation style of generated Go source. gofmt would turn it into tabs,
+/// so spaces only matter when reading the output unformatted.
+#[derive(Debug, Clone, Copy, PartialEq, Default)]
+pub enum Indent {
+    #[default]
+    Tabs,
+    Spaces(usize),
+}
+
+impl Indent {
+    fn at(self, depth: usize) -> String {
+        match self {
+            Indent::Tabs => "\t".repeat(depth),
+            Indent::Spaces(width) => " ".repeat(width * depth),
+        }
+    }
+}
+
+impl GoOptions {
+    /// Append one line of Go at the given nesting depth.
+    fn line(&self, code: &mut String, depth: usize, text: &str) {
+        code.push_str(&self.indent.at(depth));
+        code.push_str(text);
+        code.push('\n');
+    }
 }
 
 pub fn generate_go_wrapper(
     views: Vec<(String, Vec<Param>)>,
     opts: GoOptions,
+) -> String {
+    let views = views.into_iter().map(|(name, params)| (name, params, Vec::new())).collect();
+    generate_go_wrapper_with_fragments(views, opts)
+}
+
+/// Generate the Go wrapper, including a method per fragment of each view.
+/// Fragments are given as render function names (see `ast::fragment_function_name`)
+/// and take the same parameters as their view.
+pub fn generate_go_wrapper_with_fragments(
+    views: Vec<(String, Vec<Param>, Vec<String>)>,
+    opts: GoOptions,
 ) -> String {
     let mut code = String::new();
 
@@ -18,9 +67,13 @@ pub fn generate_go_wrapper(
     let mut needs_pb = false;
     let mut needs_fmt = false;
 
-    for (_, params) in &views {
+    for (_, params, _) in &views {
         for p in params {
-            let pt = ProtoSchema::parse_type(&p.type_name);
+            // A map needs whatever its values do
+            let pt = match ProtoSchema::parse_type(&p.type_name) {
+                ProtoType::Map(_, value) => *value,
+                pt => pt,
+            };
             match pt {
                 ProtoType::Message(_) => {
                     needs_proto = true;
@@ -40,31 +93,40 @@ pub fn generate_go_wrapper(
 
     // Imports
     code.push_str("import (\n");
+    if opts.context {
+        opts.line(&mut code, 1, "\"context\"");
+    }
     if needs_fmt {
-        code.push_str("\t\"fmt\"\n");
+        opts.line(&mut code, 1, "\"fmt\"");
     }
-    code.push_str("\t\"github.com/njreid/hudl/pkg/hudl\"\n");
-    code.push_str("\t\"google.golang.org/protobuf/encoding/protowire\"\n");
+    opts.line(&mut code, 1, "\"github.com/njreid/hudl/pkg/hudl\"");
+    opts.line(&mut code, 1, "\"google.golang.org/protobuf/encoding/protowire\"");
     if needs_proto {
-        code.push_str("\t\"google.golang.org/protobuf/proto\"\n");
+        opts.line(&mut code, 1, "\"google.golang.org/protobuf/proto\"");
     }
     if needs_pb && !opts.pb_import_path.is_empty() {
-        code.push_str(&format!("\t\"{}\"\n", opts.pb_import_path));
+        opts.line(&mut code, 1, &format!("\"{}\"", opts.pb_import_path));
     }
     code.push_str(")\n\n");
 
     // Views struct
     code.push_str("type Views struct {\n");
-    code.push_str("\truntime *hudl.Runtime\n");
+    opts.line(&mut code, 1, "runtime *hudl.Runtime");
     code.push_str("}\n\n");
 
     code.push_str("func NewViews(rt *hudl.Runtime) *Views {\n");
-    code.push_str("\treturn &Views{runtime: rt}\n");
+    opts.line(&mut code, 1, "return &Views{runtime: rt}");
     code.push_str("}\n\n");
 
     // Generate methods for each view
-    for (view_name, params) in views {
+    for (view_name, params, fragments) in views {
         generate_view_method(&mut code, &view_name, &params, &opts);
+        if opts.data_types && !params.is_empty() {
+            generate_view_data_type(&mut code, &view_name, &params, &opts);
+        }
+        for fragment_fn in fragments {
+            generate_view_method(&mut code, &fragment_fn, &params, &opts);
+        }
     }
 
     code
@@ -73,34 +135,131 @@ pub fn generate_go_wrapper(
 fn generate_view_method(code: &mut String, view_name: &str, params: &[Param], opts: &GoOptions) {
     // Function signature
     code.push_str(&format!("func (v *Views) {}(", view_name));
-    
-    for (i, param) in params.iter().enumerate() {
-        if i > 0 {
-            code.push_str(", ");
-        }
-        let go_type = map_hudl_type_to_go(&param.type_name, param.repeated, &opts.pb_package_name);
-        code.push_str(&format!("{} {}", param.name, go_type));
+
+    let mut args = Vec::new();
+    if opts.context {
+        args.push("ctx context.Context".to_string());
+    }
+    for param in params {
+        let go_type = param_go_type(param, &opts.pb_package_name);
+        args.push(format!("{} {}", param.name, go_type));
     }
-    
-    code.push_str(") (string, error) {\n");
+    code.push_str(&args.join(", "));
+
+    code.push_str(&format!(") ({}, error) {{\n", return_type(opts)));
+
+    let render_fn = if opts.bytes { "RenderRaw" } else { "RenderBytes" };
+    let render_call = |payload: &str| {
+        if opts.context {
+            format!("return v.runtime.{}Context(ctx, \"{}\", {})", render_fn, view_name, payload)
+        } else {
+            format!("return v.runtime.{}(\"{}\", {})", render_fn, view_name, payload)
+        }
+    };
 
     // Serialization logic
     if params.is_empty() {
-        code.push_str(&format!("\treturn v.runtime.RenderBytes(\"{}\", nil)\n", view_name));
+        opts.line(code, 1, &render_call("nil"));
     } else {
-        code.push_str("\tvar b []byte\n");
-        
+        opts.line(code, 1, "var b []byte");
+
         for (i, param) in params.iter().enumerate() {
             let field_num = (i + 1) as u32; // Field numbers are 1-based index of param
-            generate_param_serialization(code, param, field_num);
+            generate_param_serialization(code, param, field_num, opts);
         }
 
-        code.push_str(&format!("\treturn v.runtime.RenderBytes(\"{}\", b)\n", view_name));
+        opts.line(code, 1, &render_call("b"));
     }
 
     code.push_str("}\n\n");
 }
 
+fn generate_view_data_type(code: &mut String, view_name: &str, params: &[Param], opts: &GoOptions) {
+    let type_name = format!("{}Data", view_name);
+
+    // Struct with one field per param
+    code.push_str(&format!("// {} holds the parameters of the {} view.\n", type_name, view_name));
+    code.push_str(&format!("type {} struct {{\n", type_name));
+    for param in params {
+        let go_type = param_go_type(param, &opts.pb_package_name);
+        opts.line(code, 1, &format!("{} {}", go_field_name(&param.name), go_type));
+    }
+    code.push_str("}\n\n");
+
+    // Constructor applying declared defaults
+    code.push_str(&format!("// New{} returns a {} with the view's declared defaults.\n", type_name, type_name));
+    code.push_str(&format!("func New{}() *{} {{\n", type_name, type_name));
+    opts.line(code, 1, &format!("return &{}{{", type_name));
+    for param in params {
+        if let Some(default) = go_default_literal(param) {
+            opts.line(code, 2, &format!("{}: {},", go_field_name(&param.name), default));
+        }
+    }
+    opts.line(code, 1, "}");
+    code.push_str("}\n\n");
+
+    // Render method taking the struct
+    code.push_str(&format!("// {}With renders {} from d.\n", view_name, view_name));
+    let (ctx_param, mut args) = if opts.context {
+        ("ctx context.Context, ", vec!["ctx".to_string()])
+    } else {
+        ("", Vec::new())
+    };
+    code.push_str(&format!("func (v *Views) {}With({}d *{}) ({}, error) {{\n", view_name, ctx_param, type_name, return_type(opts)));
+    args.extend(params.iter().map(|p| format!("d.{}", go_field_name(&p.name))));
+    opts.line(code, 1, &format!("return v.{}({})", view_name, args.join(", ")));
+    code.push_str("}\n\n");
+}
+
+/// Go type of the rendered output returned by generated methods.
+fn return_type(opts: &GoOptions) -> &'static str {
+    if opts.bytes { "[]byte" } else { "string" }
+}
+
+/// Exported Go field name for a param: `user_name` → `UserName`.
+fn go_field_name(name: &str) -> String {
+    name.split('_')
+        .map(|part| {
+            let mut chars = part.chars();
+            match chars.next() {
+                Some(first) => first.to_uppercase().chain(chars).collect(),
+                None => String::new(),
+            }
+        })
+        .collect()
+}
+
+/// Go literal for a param's declared default, for scalar types only.
+fn go_default_literal(param: &Param) -> Option<String> {
+    let default = param.default_value.as_ref()?;
+    // Optional params are pointers; nil already selects the template default
+    if param.repeated || param.optional {
+        return None;
+    }
+    match ProtoSchema::parse_type(&param.type_name) {
+        ProtoType::String => Some(format!("{:?}", default)),
+        ProtoType::Bool
+        | ProtoType::Int32 | ProtoType::Int64 | ProtoType::Uint32 | ProtoType::Uint64
+        | ProtoType::Sint32 | ProtoType::Sint64 | ProtoType::Fixed32 | ProtoType::Fixed64
+        | ProtoType::Sfixed32 | ProtoType::Sfixed64 | ProtoType::Float | ProtoType::Double => {
+            Some(default.clone())
+        }
+        _ => None,
+    }
+}
+
+/// Go type of a view param. Optional scalars are pointers so that nil can
+/// leave the field unset.
+fn param_go_type(param: &Param, pb_pkg: &str) -> String {
+    let go_type = map_hudl_type_to_go(&param.type_name, param.repeated, pb_pkg);
+    // Maps can already be nil
+    if param.optional && !param.repeated && !go_type.starts_with('*') && !go_type.starts_with("map[") {
+        format!("*{}", go_type)
+    } else {
+        go_type
+    }
+}
+
 fn map_hudl_type_to_go(type_name: &str, repeated: bool, pb_pkg: &str) -> String {
     let pt = ProtoSchema::parse_type(type_name);
     let base_type = match pt {
@@ -113,6 +272,14 @@ fn map_hudl_type_to_go(type_name: &str, repeated: bool, pb_pkg: &str) -> String
         ProtoType::Float => "float32".to_string(),
         ProtoType::Double => "float64".to_string(),
         ProtoType::Bytes => "[]byte".to_string(),
+        ProtoType::Map(_, _) => {
+            let (key, value) = type_name[4..type_name.len() - 1].split_once(',').unwrap_or(("string", "string"));
+            format!(
+                "map[{}]{}",
+                map_hudl_type_to_go(key.trim(), false, pb_pkg),
+                map_hudl_type_to_go(value.trim(), false, pb_pkg)
+            )
+        }
         ProtoType::Message(_) | ProtoType::Enum(_) => {
             if !pb_pkg.is_empty() {
                 if matches!(pt, ProtoType::Message(_)) {
@@ -146,27 +313,48 @@ fn qualified_go_type(type_name: &str, pb_pkg: &str) -> String {
     }
 }
 
-fn generate_param_serialization(code: &mut String, param: &Param, field_num: u32) {
+fn generate_param_serialization(code: &mut String, param: &Param, field_num: u32, opts: &GoOptions) {
     let name = &param.name;
     let proto_type = ProtoSchema::parse_type(&param.type_name);
 
-    if param.repeated {
-        code.push_str(&format!("\tfor _, v := range {} {{\n", name));
-        generate_single_value_serialization(code, "v", &proto_type, field_num);
-        code.push_str("\t}\n");
+    if let ProtoType::Map(key_type, value_type) = &proto_type {
+        // Each entry is a nested message with the key as field 1 and the
+        // value as field 2
+        opts.line(code, 1, &format!("for k, v := range {} {{", name));
+        opts.line(code, 2, "var entry []byte");
+        generate_single_value_serialization(code, 2, "entry", "k", key_type, 1, opts);
+        generate_single_value_serialization(code, 2, "entry", "v", value_type, 2, opts);
+        opts.line(code, 2, &format!("b = protowire.AppendTag(b, {}, protowire.BytesType)", field_num));
+        opts.line(code, 2, "b = protowire.AppendBytes(b, entry)");
+        opts.line(code, 1, "}");
+    } else if param.repeated {
+        opts.line(code, 1, &format!("for _, v := range {} {{", name));
+        generate_single_value_serialization(code, 2, "b", "v", &proto_type, field_num, opts);
+        opts.line(code, 1, "}");
+    } else if param.optional {
+        // Leave unset fields out so the template default (or null) applies
+        opts.line(code, 1, &format!("if {} != nil {{", name));
+        let value = if matches!(proto_type, ProtoType::Message(_)) {
+            name.to_string()
+        } else {
+            format!("*{}", name)
+        };
+        generate_single_value_serialization(code, 2, "b", &value, &proto_type, field_num, opts);
+        opts.line(code, 1, "}");
     } else {
-        generate_single_value_serialization(code, name, &proto_type, field_num);
+        generate_single_value_serialization(code, 1, "b", name, &proto_type, field_num, opts);
     }
 }
 
-fn generate_single_value_serialization(code: &mut String, var_name: &str, proto_type: &ProtoType, field_num: u32) {
+/// Append one field holding `var_name` to the Go byte slice `buf`.
+fn generate_single_value_serialization(code: &mut String, depth: usize, buf: &str, var_name: &str, proto_type: &ProtoType, field_num: u32, opts: &GoOptions) {
     match proto_type {
         ProtoType::String => {
-            code.push_str(&format!("\tb = protowire.AppendTag(b, {}, protowire.BytesType)\n", field_num));
-            code.push_str(&format!("\tb = protowire.AppendString(b, {})\n", var_name));
+            opts.line(code, depth, &format!("{buf} = protowire.AppendTag({buf}, {}, protowire.BytesType)", field_num));
+            opts.line(code, depth, &format!("{buf} = protowire.AppendString({buf}, {})", var_name));
         }
         ProtoType::Int32 | ProtoType::Int64 | ProtoType::Uint32 | ProtoType::Uint64 | ProtoType::Bool | ProtoType::Enum(_) => {
-            code.push_str(&format!("\tb = protowire.AppendTag(b, {}, protowire.VarintType)\n", field_num));
+            opts.line(code, depth, &format!("{buf} = protowire.AppendTag({buf}, {}, protowire.VarintType)", field_num));
             let cast = match proto_type {
                 ProtoType::Bool => {
                     format!("func() uint64 {{ if {} {{ return 1 }}; return 0 }}()", var_name)
@@ -174,13 +362,16 @@ fn generate_single_value_serialization(code: &mut String, var_name: &str, proto_
                 ProtoType::Enum(_) => format!("uint64({})", var_name),
                 _ => format!("uint64({})", var_name),
             };
-            code.push_str(&format!("\tb = protowire.AppendVarint(b, {})\n", cast));
+            opts.line(code, depth, &format!("{buf} = protowire.AppendVarint({buf}, {})", cast));
         }
         ProtoType::Message(_) => {
-            code.push_str(&format!("\tbytesVal, err := proto.Marshal({})\n", var_name));
-            code.push_str("\tif err != nil {\n\t\treturn \"\", fmt.Errorf(\"failed to marshal param: %w\", err)\n\t}\n");
-            code.push_str(&format!("\tb = protowire.AppendTag(b, {}, protowire.BytesType)\n", field_num));
-            code.push_str("\tb = protowire.AppendBytes(b, bytesVal)\n");
+            opts.line(code, depth, &format!("bytesVal, err := proto.Marshal({})", var_name));
+            let zero = if opts.bytes { "nil" } else { "\"\"" };
+            opts.line(code, depth, "if err != nil {");
+            opts.line(code, depth + 1, &format!("return {}, fmt.Errorf(\"failed to marshal param: %w\", err)", zero));
+            opts.line(code, depth, "}");
+            opts.line(code, depth, &format!("{buf} = protowire.AppendTag({buf}, {}, protowire.BytesType)", field_num));
+            opts.line(code, depth, &format!("{buf} = protowire.AppendBytes({buf}, bytesVal)"));
         }
         _ => {}
     }
@@ -190,13 +381,43 @@ fn generate_single_value_serialization(code: &mut String, var_name: &str, proto_
 mod tests {
     use super::*;
     use crate::ast::Param;
+    use std::path::Path;
+    use std::process::Command;
+
+    /// Type-check generated Go against the real runtime by vetting it as a
+    /// package of this module. `_go-check` keeps it out of `./...`. Skipped
+    /// when `go` isn't installed.
+    fn assert_go_compiles(name: &str, code: &str) {
+        let root = Path::new(env!("CARGO_MANIFEST_DIR"));
+        let dir = root.join("target").join("_go-check").join(name);
+        std::fs::create_dir_all(&dir).unwrap();
+        std::fs::write(dir.join("views.go"), code).unwrap();
+
+        let output = match Command::new("go")
+            .args(["vet", &format!("./target/_go-check/{}", name)])
+            .current_dir(root)
+            .output()
+        {
+            Ok(output) => output,
+            Err(e) => {
+                eprintln!("skipping Go compile check for {}: {}", name, e);
+                return;
+            }
+        };
+        assert!(
+            output.status.success(),
+            "generated Go does not compile:\n{}\n{}",
+            String::from_utf8_lossy(&output.stderr),
+            code
+        );
+    }
 
     #[test]
     fn test_generate_go_basic() {
         let views = vec![
             ("HomePage".to_string(), vec![
-                Param { name: "title".to_string(), type_name: "string".to_string(), repeated: false, default_value: None },
-                Param { name: "description".to_string(), type_name: "string".to_string(), repeated: false, default_value: None },
+                Param { name: "title".to_string(), type_name: "string".to_string(), repeated: false, optional: false, default_value: None },
+                Param { name: "description".to_string(), type_name: "string".to_string(), repeated: false, optional: false, default_value: None },
             ]),
             ("StaticPage".to_string(), vec![]),
         ];
@@ -205,6 +426,10 @@ mod tests {
             package_name: "views".to_string(),
             pb_import_path: "myapp/pb".to_string(),
             pb_package_name: "pb".to_string(),
+            data_types: false,
+            context: false,
+            bytes: false,
+            indent: Indent::Tabs,
         };
 
         let code = generate_go_wrapper(views, opts);
@@ -226,9 +451,9 @@ mod tests {
     fn test_generate_go_no_unnecessary_imports_mixed_scalars() {
         let views = vec![
             ("MixedView".to_string(), vec![
-                Param { name: "count".to_string(), type_name: "int32".to_string(), repeated: false, default_value: None },
-                Param { name: "active".to_string(), type_name: "bool".to_string(), repeated: false, default_value: None },
-                Param { name: "tags".to_string(), type_name: "string".to_string(), repeated: true, default_value: None },
+                Param { name: "count".to_string(), type_name: "int32".to_string(), repeated: false, optional: false, default_value: None },
+                Param { name: "active".to_string(), type_name: "bool".to_string(), repeated: false, optional: false, default_value: None },
+                Param { name: "tags".to_string(), type_name: "string".to_string(), repeated: true, optional: false, default_value: None },
             ]),
         ];
 
@@ -236,6 +461,10 @@ mod tests {
             package_name: "views".to_string(),
             pb_import_path: "myapp/pb".to_string(),
             pb_package_name: "pb".to_string(),
+            data_types: false,
+            context: false,
+            bytes: false,
+            indent: Indent::Tabs,
         };
 
         let code = generate_go_wrapper(views, opts);
@@ -250,7 +479,7 @@ mod tests {
     fn test_generate_go_repeated() {
         let views = vec![
             ("ListView".to_string(), vec![
-                Param { name: "items".to_string(), type_name: "string".to_string(), repeated: true, default_value: None },
+                Param { name: "items".to_string(), type_name: "string".to_string(), repeated: true, optional: false, default_value: None },
             ]),
         ];
 
@@ -258,6 +487,10 @@ mod tests {
             package_name: "views".to_string(),
             pb_import_path: "".to_string(),
             pb_package_name: "pb".to_string(),
+            data_types: false,
+            context: false,
+            bytes: false,
+            indent: Indent::Tabs,
         };
 
         let code = generate_go_wrapper(views, opts);
@@ -271,8 +504,8 @@ mod tests {
     fn test_generate_go_types() {
         let views = vec![
             ("TypesView".to_string(), vec![
-                Param { name: "count".to_string(), type_name: "int32".to_string(), repeated: false, default_value: None },
-                Param { name: "active".to_string(), type_name: "bool".to_string(), repeated: false, default_value: None },
+                Param { name: "count".to_string(), type_name: "int32".to_string(), repeated: false, optional: false, default_value: None },
+                Param { name: "active".to_string(), type_name: "bool".to_string(), repeated: false, optional: false, default_value: None },
             ]),
         ];
 
@@ -280,6 +513,10 @@ mod tests {
             package_name: "views".to_string(),
             pb_import_path: "".to_string(),
             pb_package_name: "pb".to_string(),
+            data_types: false,
+            context: false,
+            bytes: false,
+            indent: Indent::Tabs,
         };
 
         let code = generate_go_wrapper(views, opts);
@@ -289,11 +526,90 @@ mod tests {
         assert!(code.contains("if active { return 1 }; return 0"));
     }
 
+    #[test]
+    fn test_generate_go_data_types() {
+        let views = vec![
+            ("HomePage".to_string(), vec![
+                Param { name: "title".to_string(), type_name: "string".to_string(), repeated: false, optional: false, default_value: Some("Welcome".to_string()) },
+                Param { name: "item_count".to_string(), type_name: "int32".to_string(), repeated: false, optional: false, default_value: None },
+            ]),
+            ("StaticPage".to_string(), vec![]),
+        ];
+
+        let opts = GoOptions {
+            package_name: "views".to_string(),
+            pb_import_path: "".to_string(),
+            pb_package_name: "pb".to_string(),
+            data_types: true,
+            context: false,
+            bytes: false,
+            indent: Indent::Tabs,
+        };
+
+        let code = generate_go_wrapper(views, opts);
+
+        assert!(code.contains("type HomePageData struct {\n\tTitle string\n\tItemCount int32\n}"));
+        assert!(code.contains("func NewHomePageData() *HomePageData {"));
+        assert!(code.contains("\t\tTitle: \"Welcome\",\n"));
+        assert!(code.contains("func (v *Views) HomePageWith(d *HomePageData) (string, error) {"));
+        assert!(code.contains("return v.HomePage(d.Title, d.ItemCount)"));
+        // Views without params get no data type
+        assert!(!code.contains("StaticPageData"));
+    }
+
+    #[test]
+    fn test_generate_go_data_types_opt_in() {
+        let views = vec![
+            ("HomePage".to_string(), vec![
+                Param { name: "title".to_string(), type_name: "string".to_string(), repeated: false, optional: false, default_value: None },
+            ]),
+        ];
+
+        let opts = GoOptions {
+            package_name: "views".to_string(),
+            pb_import_path: "".to_string(),
+            pb_package_name: "pb".to_string(),
+            data_types: false,
+            context: false,
+            bytes: false,
+            indent: Indent::Tabs,
+        };
+
+        let code = generate_go_wrapper(views, opts);
+
+        assert!(!code.contains("HomePageData"));
+    }
+
+    #[test]
+    fn test_generate_go_fragments() {
+        let views = vec![
+            ("Dashboard".to_string(), vec![
+                Param { name: "time".to_string(), type_name: "string".to_string(), repeated: false, optional: false, default_value: None },
+            ], vec!["DashboardClock".to_string()]),
+        ];
+
+        let opts = GoOptions {
+            package_name: "views".to_string(),
+            pb_import_path: "".to_string(),
+            pb_package_name: "pb".to_string(),
+            data_types: false,
+            context: false,
+            bytes: false,
+            indent: Indent::Tabs,
+        };
+
+        let code = generate_go_wrapper_with_fragments(views, opts);
+
+        assert!(code.contains("func (v *Views) Dashboard(time string)"));
+        assert!(code.contains("func (v *Views) DashboardClock(time string)"));
+        assert!(code.contains("v.runtime.RenderBytes(\"DashboardClock\", b)"));
+    }
+
     #[test]
     fn test_generate_go_message() {
         let views = vec![
             ("UserPage".to_string(), vec![
-                Param { name: "user".to_string(), type_name: "User".to_string(), repeated: false, default_value: None },
+                Param { name: "user".to_string(), type_name: "User".to_string(), repeated: false, optional: false, default_value: None },
             ]),
         ];
 
@@ -301,6 +617,10 @@ mod tests {
             package_name: "views".to_string(),
             pb_import_path: "myapp/pb".to_string(),
             pb_package_name: "pb".to_string(),
+            data_types: false,
+            context: false,
+            bytes: false,
+            indent: Indent::Tabs,
         };
 
         let code = generate_go_wrapper(views, opts);
@@ -312,4 +632,150 @@ mod tests {
         assert!(code.contains("proto.Marshal(user)"));
         assert!(code.contains("fmt.Errorf"));
     }
-}
\ No newline at end of file
+
+    #[test]
+    fn test_generate_go_map() {
+        let views = vec![
+            ("Button".to_string(), vec![
+                Param { name: "label".to_string(), type_name: "string".to_string(), repeated: false, optional: false, default_value: None },
+                Param { name: "attrs".to_string(), type_name: "map<string, string>".to_string(), repeated: false, optional: true, default_value: None },
+            ]),
+        ];
+
+        let opts = GoOptions {
+            package_name: "views".to_string(),
+            pb_import_path: "".to_string(),
+            pb_package_name: "pb".to_string(),
+            data_types: false,
+            context: false,
+            bytes: false,
+            indent: Indent::Tabs,
+        };
+
+        let code = generate_go_wrapper(views, opts);
+
+        assert!(code.contains("func (v *Views) Button(label string, attrs map[string]string)"), "Code: {}", code);
+        assert!(code.contains(concat!(
+            "\tfor k, v := range attrs {\n",
+            "\t\tvar entry []byte\n",
+            "\t\tentry = protowire.AppendTag(entry, 1, protowire.BytesType)\n",
+            "\t\tentry = protowire.AppendString(entry, k)\n",
+            "\t\tentry = protowire.AppendTag(entry, 2, protowire.BytesType)\n",
+            "\t\tentry = protowire.AppendString(entry, v)\n",
+            "\t\tb = protowire.AppendTag(b, 2, protowire.BytesType)\n",
+            "\t\tb = protowire.AppendBytes(b, entry)\n",
+            "\t}\n",
+        )), "Code: {}", code);
+        assert_go_compiles("map", &code);
+    }
+
+    #[test]
+    fn test_generate_go_context_param() {
+        let views = vec![
+            ("HomePage".to_string(), vec![
+                Param { name: "title".to_string(), type_name: "string".to_string(), repeated: false, optional: false, default_value: None },
+            ], vec!["HomePageHero".to_string()]),
+            ("StaticPage".to_string(), vec![], vec![]),
+        ];
+
+        let opts = GoOptions {
+            package_name: "views".to_string(),
+            pb_import_path: "".to_string(),
+            pb_package_name: "pb".to_string(),
+            data_types: true,
+            context: true,
+            bytes: false,
+            indent: Indent::Tabs,
+        };
+
+        let code = generate_go_wrapper_with_fragments(views, opts);
+
+        assert!(code.contains("import (\n\t\"context\"\n"));
+        assert!(code.contains("func (v *Views) HomePage(ctx context.Context, title string) (string, error) {"));
+        assert!(code.contains("return v.runtime.RenderBytesContext(ctx, \"HomePage\", b)"));
+        assert!(code.contains("func (v *Views) HomePageHero(ctx context.Context, title string)"));
+        assert!(code.contains("func (v *Views) StaticPage(ctx context.Context) (string, error) {"));
+        assert!(code.contains("return v.runtime.RenderBytesContext(ctx, \"StaticPage\", nil)"));
+        assert!(code.contains("func (v *Views) HomePageWith(ctx context.Context, d *HomePageData) (string, error) {"));
+        assert!(code.contains("return v.HomePage(ctx, d.Title)"));
+        assert!(!code.contains("RenderBytes(\""));
+
+        // ctx is in scope wherever it is passed on
+        assert_go_compiles("context_param", &code);
+    }
+
+    #[test]
+    fn test_generate_go_nested_indent() {
+        let views = || vec![
+            ("TeamPage".to_string(), vec![
+                Param { name: "members".to_string(), type_name: "User".to_string(), repeated: true, optional: false, default_value: None },
+            ]),
+        ];
+        let opts = |indent| GoOptions {
+            package_name: "views".to_string(),
+            pb_import_path: "myapp/pb".to_string(),
+            pb_package_name: "pb".to_string(),
+            data_types: false,
+            context: false,
+            bytes: false,
+            indent,
+        };
+
+        // Each block nests one level deeper than the one it's in
+        let code = generate_go_wrapper(views(), opts(Indent::Tabs));
+        assert!(code.contains(concat!(
+            "\tfor _, v := range members {\n",
+            "\t\tbytesVal, err := proto.Marshal(v)\n",
+            "\t\tif err != nil {\n",
+            "\t\t\treturn \"\", fmt.Errorf(\"failed to marshal param: %w\", err)\n",
+            "\t\t}\n",
+            "\t\tb = protowire.AppendTag(b, 1, protowire.BytesType)\n",
+        )), "Code: {}", code);
+
+        let code = generate_go_wrapper(views(), opts(Indent::Spaces(4)));
+        assert!(code.contains(concat!(
+            "    for _, v := range members {\n",
+            "        bytesVal, err := proto.Marshal(v)\n",
+            "        if err != nil {\n",
+            "            return \"\", fmt.Errorf(\"failed to marshal param: %w\", err)\n",
+            "        }\n",
+        )), "Code: {}", code);
+        assert!(code.contains("import (\n    \"fmt\"\n"));
+        assert!(!code.contains('\t'));
+    }
+
+    #[test]
+    fn test_generate_go_bytes_return() {
+        let views = || vec![
+            ("UserPage".to_string(), vec![
+                Param { name: "user".to_string(), type_name: "User".to_string(), repeated: false, optional: false, default_value: None },
+            ], vec![]),
+        ];
+        let opts = |bytes, context| GoOptions {
+            package_name: "views".to_string(),
+            pb_import_path: "myapp/pb".to_string(),
+            pb_package_name: "pb".to_string(),
+            data_types: true,
+            context,
+            bytes,
+            indent: Indent::Tabs,
+        };
+
+        let code = generate_go_wrapper_with_fragments(views(), opts(true, false));
+        assert!(code.contains("func (v *Views) UserPage(user *pb.User) ([]byte, error) {"));
+        assert!(code.contains("return v.runtime.RenderRaw(\"UserPage\", b)"));
+        assert!(code.contains("return nil, fmt.Errorf(\"failed to marshal param: %w\", err)"));
+        assert!(code.contains("func (v *Views) UserPageWith(d *UserPageData) ([]byte, error) {"));
+
+        // Serialization is shared with the string style; only the return differs
+        let strings = generate_go_wrapper_with_fragments(views(), opts(false, false));
+        let as_strings = code
+            .replace("[]byte, error", "string, error")
+            .replace("RenderRaw(", "RenderBytes(")
+            .replace("return nil, fmt", "return \"\", fmt");
+        assert_eq!(as_strings, strings);
+
+        let code = generate_go_wrapper_with_fragments(views(), opts(true, true));
+        assert!(code.contains("return v.runtime.RenderRawContext(ctx, \"UserPage\", b)"));
+    }
+}
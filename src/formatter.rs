@@ -177,6 +177,8 @@ fn format_node_no_leading_protos(output: &mut String, node: &KdlNode, depth: usi
     let node_name = node.name().value();
     let is_each = node_name == "each" || node_name == "__hudl_each";
     let is_switch = node_name == "switch" || node_name == "__hudl_switch";
+    // Every `each` argument but the expression is a variable name
+    let each_vars = node.entries().iter().filter(|e| e.name().is_none()).count().saturating_sub(1);
 
     let mut arg_index = 0;
     for entry in node.entries() {
@@ -193,7 +195,7 @@ fn format_node_no_leading_protos(output: &mut String, node: &KdlNode, depth: usi
         let context = if entry.name().is_some() {
             EntryContext::Property
         } else if is_each {
-            let ctx = if arg_index == 0 { EntryContext::EachVarName } else { EntryContext::EachExpression };
+            let ctx = if arg_index < each_vars { EntryContext::EachVarName } else { EntryContext::EachExpression };
             arg_index += 1;
             ctx
         } else if is_switch && arg_index == 0 {
@@ -528,6 +530,16 @@ fn format_node(output: &mut String, node: &KdlNode, depth: usize, options: &Form
         }
     }
 
+    // Text between children is written back as a bare string
+    if node.name().value() == "__hudl_text" {
+        output.push_str(&indent);
+        if let Some(entry) = node.entries().first() {
+            format_entry(output, entry, EntryContext::Content);
+        }
+        output.push('\n');
+        return;
+    }
+
     // Check for binding shorthand to normalize
     let bind_info = find_bind_entry(node).or_else(|| find_bind_in_tilde_children(node));
 
@@ -551,6 +563,8 @@ fn format_node(output: &mut String, node: &KdlNode, depth: usize, options: &Form
     let node_name = node.name().value();
     let is_each = node_name == "each" || node_name == "__hudl_each";
     let is_switch = node_name == "switch" || node_name == "__hudl_switch";
+    // Every `each` argument but the expression is a variable name
+    let each_vars = node.entries().iter().filter(|e| e.name().is_none()).count().saturating_sub(1);
 
     // Format entries (arguments and properties), skipping ~bind entries
     let mut arg_index = 0;
@@ -571,8 +585,8 @@ fn format_node(output: &mut String, node: &KdlNode, depth: usize, options: &Form
             // Named property
             EntryContext::Property
         } else if is_each {
-            // each <varname> <expression>
-            let ctx = if arg_index == 0 {
+            // each <varname> <expression>, or each <key> <value> <expression>
+            let ctx = if arg_index < each_vars {
                 EntryContext::EachVarName
             } else {
                 EntryContext::EachExpression
@@ -1132,6 +1146,16 @@ el { div "ok" }"#;
         assert!(formatted.contains("}\n        span"), "span should follow tilde block: {}", formatted);
     }
 
+    #[test]
+    fn test_format_text_between_children() {
+        let input = "el {\n    p {\n        \"Hello \"\n        b \"world\"\n        `name`\n    }\n}";
+        let doc = parse(input).unwrap();
+        let options = FormatOptions::new(4, true);
+        let formatted = format(&doc, &options);
+        assert!(formatted.contains("        \"Hello \"\n        b \"world\"\n        `name`\n"), "Text should stay bare and in order: {}", formatted);
+        assert!(!formatted.contains("text"), "Internal marker should not leak: {}", formatted);
+    }
+
     #[test]
     fn test_format_bind_shorthand_from_inline() {
         // Inline ~bind="username" should become ~>username
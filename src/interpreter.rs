@@ -4,7 +4,7 @@
 //! directly and renders HTML by evaluating CEL expressions at runtime.
 //! This enables hot-reload during development without recompilation.
 
-use crate::ast::{ControlFlow, Element, Node, Root, SwitchCase};
+use crate::ast::{ControlFlow, Element, Node, Root, SwitchCase, Text};
 use crate::cel::{self, CompiledExpr, EvalContext};
 use crate::proto::{ProtoSchema};
 use cel_interpreter::Value as CelValue;
@@ -38,6 +38,20 @@ pub fn render(
     schema: &ProtoSchema,
     data_bytes: &[u8],
     components: &HashMap<String, &Root>,
+) -> Result<String, RenderError> {
+    render_with_content(root, schema, data_bytes, components, None, "")
+}
+
+/// Render like `render`, filling the template's `#content` slot with
+/// pre-rendered HTML (inserted as is, without escaping) and binding `locale`
+/// (empty for none) to the `locale` variable.
+pub fn render_with_content(
+    root: &Root,
+    schema: &ProtoSchema,
+    data_bytes: &[u8],
+    components: &HashMap<String, &Root>,
+    content_html: Option<&str>,
+    locale: &str,
 ) -> Result<String, RenderError> {
     // Decode proto wire format into a map of parameters
     let params_map = schema.decode_params_to_cel(data_bytes, &root.params);
@@ -48,7 +62,7 @@ pub fn render(
         .map(|(k, v)| (Key::String(Arc::new(k)), v))
         .collect();
     
-    render_with_values(root, schema, CelValue::Map(cel_interpreter::objects::Map { map: Arc::new(cel_map) }), components, None)
+    render_with_values(root, schema, CelValue::Map(cel_interpreter::objects::Map { map: Arc::new(cel_map) }), components, content_html, locale)
 }
 
 /// Render a template AST with pre-decoded CelValues (for textproto-based preview).
@@ -59,6 +73,7 @@ pub fn render(
 /// * `data` - Pre-decoded CelValue (typically a Map from textproto parsing)
 /// * `components` - Map of component names to their ASTs
 /// * `content_nodes` - Nodes to insert into the #content slot
+/// * `locale` - The request's locale, bound to `locale` (empty for none)
 ///
 /// # Returns
 /// Rendered HTML string, or an error.
@@ -68,32 +83,135 @@ pub fn render_with_values(
     data: CelValue,
     components: &HashMap<String, &Root>,
     content_html: Option<&str>,
+    locale: &str,
 ) -> Result<String, RenderError> {
     let mut ctx = EvalContext::new();
+    ctx.set_locale(locale);
+
+    // Enum constants first, so a param of the same name shadows them
+    add_enum_constants(&mut ctx, schema);
 
     // If data is a map, add each top-level field as a separate variable
     if let CelValue::Map(ref map) = data {
         for (key, value) in map.map.iter() {
             if let Key::String(name) = key {
-                ctx.add_value(name, value.clone());
+                ctx.add_value(name, enum_param_value(root, schema, name, value.clone()));
             }
         }
     }
 
-    // Add enum constants to the context
-    for (_, proto_enum) in &schema.enums {
-        for ev in &proto_enum.values {
-            ctx.add_string(&ev.name, &ev.name);
-        }
-    }
-
     // Render the AST
     let mut output = String::new();
+    if let Some(doctype) = &root.doctype {
+        output.push_str(&format!("<!DOCTYPE {}>", doctype));
+    }
     render_nodes(&root.nodes, &ctx, schema, &mut output, components, content_html)?;
 
     Ok(output)
 }
 
+/// Render a single fragment of a template (see `ast::collect_fragments`) with
+/// proto wire-format data. The fragment sees the same parameters as the view.
+pub fn render_fragment(
+    root: &Root,
+    fragment: &str,
+    schema: &ProtoSchema,
+    data_bytes: &[u8],
+    components: &HashMap<String, &Root>,
+    locale: &str,
+) -> Result<String, RenderError> {
+    let node = crate::ast::collect_fragments(&root.nodes)
+        .into_iter()
+        .find(|(name, _)| name == fragment)
+        .map(|(_, node)| node)
+        .ok_or_else(|| RenderError {
+            message: format!("Fragment '{}' not found", fragment),
+        })?;
+
+    let params_map = schema.decode_params_to_cel(data_bytes, &root.params);
+
+    let mut ctx = EvalContext::new();
+    ctx.set_locale(locale);
+    add_enum_constants(&mut ctx, schema);
+    for (name, value) in params_map {
+        ctx.add_value(&name, value);
+    }
+
+    let mut output = String::new();
+    render_node(node, &ctx, schema, &mut output, components, None)?;
+    Ok(output)
+}
+
+/// A copy of `root` that renders an `<!-- file:line -->` comment before each
+/// element whose source line is known, for tracing dev mode output back to
+/// the template. `file` is the template's file name, e.g. `card.hudl`.
+pub fn with_source_comments(root: &Root, file: &str) -> Root {
+    let mut annotated = root.clone();
+    annotated.nodes = source_comment_nodes(&root.nodes, file);
+    annotated
+}
+
+fn source_comment_nodes(nodes: &[Node], file: &str) -> Vec<Node> {
+    let mut result = Vec::with_capacity(nodes.len());
+    for node in nodes {
+        match node {
+            Node::Element(el) => {
+                if let Some(line) = el.line {
+                    result.push(Node::Text(Text { content: format!("<!-- {}:{} -->", file, line), raw: true }));
+                }
+                let mut el = el.clone();
+                el.children = source_comment_nodes(&el.children, file);
+                result.push(Node::Element(el));
+            }
+            Node::ControlFlow(cf) => {
+                let mut cf = cf.clone();
+                match &mut cf {
+                    ControlFlow::If { then_block, else_block, .. } => {
+                        *then_block = source_comment_nodes(then_block, file);
+                        if let Some(else_nodes) = else_block {
+                            *else_nodes = source_comment_nodes(else_nodes, file);
+                        }
+                    }
+                    ControlFlow::Each { body, .. } => *body = source_comment_nodes(body, file),
+                    ControlFlow::Switch { cases, default, .. } => {
+                        for SwitchCase(_, case_nodes) in cases.iter_mut() {
+                            *case_nodes = source_comment_nodes(case_nodes, file);
+                        }
+                        if let Some(def_nodes) = default {
+                            *def_nodes = source_comment_nodes(def_nodes, file);
+                        }
+                    }
+                }
+                result.push(Node::ControlFlow(cf));
+            }
+            Node::Text(_) | Node::ContentSlot | Node::Flush => result.push(node.clone()),
+        }
+    }
+    result
+}
+
+/// Bind each enum constant to its number, so `status == STATUS_ACTIVE`
+/// compares against the defined value.
+fn add_enum_constants(ctx: &mut EvalContext, schema: &ProtoSchema) {
+    for (name, number) in schema.enum_constants() {
+        ctx.add_int(name, number as i64);
+    }
+}
+
+/// Enum params are ints, as when decoded from proto. JSON data may name the
+/// constant instead ("STATUS_ACTIVE"), so map it to its number.
+fn enum_param_value(root: &Root, schema: &ProtoSchema, name: &str, value: CelValue) -> CelValue {
+    let is_enum = root.params.iter()
+        .any(|p| p.name == name && !p.repeated && schema.enums.contains_key(&p.type_name));
+    match value {
+        CelValue::String(ref s) if is_enum => match schema.enum_constant(s) {
+            Some(number) => CelValue::Int(number as i64),
+            None => value,
+        },
+        _ => value,
+    }
+}
+
 /// Render a list of AST nodes into the output string.
 fn render_nodes(
     nodes: &[Node],
@@ -120,7 +238,7 @@ fn render_node(
 ) -> Result<(), RenderError> {
     match node {
         Node::Element(el) => render_element(el, ctx, schema, output, components, content_html),
-        Node::Text(text) => render_text(&text.content, ctx, output),
+        Node::Text(text) => render_text(&text.content, text.raw, ctx, output),
         Node::ControlFlow(cf) => render_control_flow(cf, ctx, schema, output, components, content_html),
         Node::ContentSlot => {
             if let Some(html) = content_html {
@@ -128,6 +246,8 @@ fn render_node(
             }
             Ok(())
         }
+        // The dev server returns the whole page, so there is nothing to flush
+        Node::Flush => Ok(()),
     }
 }
 
@@ -145,6 +265,7 @@ fn render_element(
         // Component invocation
         // 1. Prepare data for the component
         let mut comp_ctx = EvalContext::new();
+        comp_ctx.set_locale(ctx.locale());
 
         // Pass arguments as fields in the new context
         // Syntax: Component key=value
@@ -157,6 +278,26 @@ fn render_element(
             }
         }
 
+        // A component with an `attrs` param gets the invocation's other
+        // attributes in it, over the entries of an explicit `attrs=` map
+        if crate::ast::attrs_param(&comp_root.params).is_some() {
+            let mut attrs = HashMap::new();
+            if let Some(expr) = el.attributes.get("attrs").filter(|v| v.contains('`')) {
+                if let CelValue::Map(map) = evaluate_cel(expr.trim_matches('`'), ctx)? {
+                    for (key, value) in map.map.iter() {
+                        if let Key::String(name) = key {
+                            attrs.insert(name.to_string(), value.clone());
+                        }
+                    }
+                }
+            }
+            for (name, value) in crate::ast::forwarded_attrs(el, &comp_root.params) {
+                let value = if value.contains('`') { render_interpolated_string(&value, ctx)? } else { value };
+                attrs.insert(name, CelValue::String(Arc::new(value)));
+            }
+            comp_ctx.add_map("attrs", attrs);
+        }
+
         // 2. Pre-render children using the CURRENT context
         let mut invocation_html = String::new();
         render_nodes(&el.children, ctx, schema, &mut invocation_html, components, content_html)?;
@@ -166,16 +307,15 @@ fn render_element(
     }
 
     // Standard HTML element
-    // Void elements (no closing tag)
-    let void_elements = [
-        "area", "base", "br", "col", "embed", "hr", "img", "input", "link", "meta", "param",
-        "source", "track", "wbr",
-    ];
-    let is_void = void_elements.contains(&el.tag.as_str());
+    let tag = match &el.dynamic_tag {
+        Some(expr) => crate::safe_names::safe_tag_name(&cel::cel_to_string(&evaluate_cel(expr, ctx)?)).to_string(),
+        None => el.tag.clone(),
+    };
+    let is_void = crate::ast::is_void_element(&tag) && !el.foreign;
 
     // Opening tag
     output.push('<');
-    output.push_str(&el.tag);
+    output.push_str(&tag);
 
     // ID attribute
     if let Some(id) = &el.id {
@@ -184,10 +324,31 @@ fn render_element(
         output.push('"');
     }
 
+    // Spread maps; their classes are appended to the element's own
+    let mut spreads = Vec::new();
+    for expr in &el.spreads {
+        if let CelValue::Map(map) = evaluate_cel(expr, ctx)? {
+            spreads.push(map);
+        }
+    }
+    let mut classes = el.classes.join(" ");
+    for map in &spreads {
+        match map.map.get(&Key::String(Arc::new("class".to_string()))) {
+            Some(CelValue::Null) | None => {}
+            Some(class) => {
+                let class = cel::cel_to_string(class);
+                if !class.is_empty() && !classes.is_empty() {
+                    classes.push(' ');
+                }
+                classes.push_str(&class);
+            }
+        }
+    }
+
     // Class attribute
-    if !el.classes.is_empty() {
+    if !classes.is_empty() {
         output.push_str(" class=\"");
-        output.push_str(&el.classes.join(" "));
+        output.push_str(&cel::html_escape(&classes));
         output.push('"');
     }
 
@@ -215,7 +376,28 @@ fn render_element(
             output.push(' ');
             output.push_str(key);
             output.push_str("=\"");
-            output.push_str(value);
+            output.push_str(&cel::html_escape(value));
+            output.push('"');
+        }
+    }
+
+    // Spread attributes, in key order. The element's own attributes win.
+    for map in &spreads {
+        let mut entries: Vec<_> = map.map.iter().collect();
+        entries.sort_by(|a, b| a.0.cmp(b.0));
+        for (key, value) in entries {
+            let Key::String(name) = key else { continue };
+            let own = name.as_str() == "class"
+                || (name.as_str() == "id" && el.id.is_some())
+                || el.attributes.contains_key(name.as_str())
+                || el.datastar.iter().any(|attr| crate::ast::datastar_attr_to_html(attr).0 == **name);
+            if own || !crate::safe_names::safe_attr_name(name) || matches!(value, CelValue::Null) {
+                continue;
+            }
+            output.push(' ');
+            output.push_str(name);
+            output.push_str("=\"");
+            output.push_str(&cel::html_escape(&cel::cel_to_string(value)));
             output.push('"');
         }
     }
@@ -245,7 +427,7 @@ fn render_element(
 
         // Closing tag
         output.push_str("</");
-        output.push_str(&el.tag);
+        output.push_str(&tag);
         output.push('>');
     }
 
@@ -253,20 +435,22 @@ fn render_element(
 }
 
 /// Render text content, evaluating CEL interpolations.
-fn render_text(content: &str, ctx: &EvalContext, output: &mut String) -> Result<(), RenderError> {
+fn render_text(content: &str, raw: bool, ctx: &EvalContext, output: &mut String) -> Result<(), RenderError> {
     let parts: Vec<&str> = content.split('`').collect();
     for (i, part) in parts.iter().enumerate() {
         if i % 2 == 0 {
             // Static text
-            if !part.is_empty() {
+            if raw {
                 output.push_str(part);
+            } else {
+                output.push_str(&cel::escape_bare_ampersands(part));
             }
         } else {
             // CEL expression
             let result = evaluate_cel(part, ctx)?;
 
-            // Check for raw() function
-            if part.starts_with("raw(") && part.ends_with(')') {
+            // Check for raw() function or a raw { } block
+            if raw || (part.starts_with("raw(") && part.ends_with(')')) {
                 // raw() - no escaping
                 output.push_str(&cel::cel_to_string(&result));
             } else {
@@ -300,21 +484,45 @@ fn render_control_flow(
             }
         }
         ControlFlow::Each {
+            key,
             binding,
             iterable,
             body,
         } => {
             let list_val = evaluate_cel(iterable, ctx)?;
-            if let CelValue::List(items) = list_val {
-                for (index, item) in items.iter().enumerate() {
-                    let mut child_ctx = ctx.child();
-                    child_ctx.add_value(binding, item.clone());
-                    child_ctx.add_int(&format!("{}_idx", binding), index as i64);
-
-                    // If the item is a map, also add its fields directly
-                    // (some templates access fields directly on the binding)
-                    render_nodes(body, &child_ctx, schema, output, components, content_html)?;
+            match (key, list_val) {
+                (None, CelValue::List(items)) => {
+                    for (index, item) in items.iter().enumerate() {
+                        let mut child_ctx = ctx.child();
+                        child_ctx.add_value(binding, item.clone());
+                        child_ctx.add_int(&format!("{}_idx", binding), index as i64);
+
+                        // If the item is a map, also add its fields directly
+                        // (some templates access fields directly on the binding)
+                        render_nodes(body, &child_ctx, schema, output, components, content_html)?;
+                    }
+                }
+                (Some(key), CelValue::Map(map)) => {
+                    // Sorted keys, as in compiled views
+                    let mut entries: Vec<_> = map.map.iter().collect();
+                    entries.sort_by(|a, b| a.0.cmp(b.0));
+                    for (index, (k, v)) in entries.into_iter().enumerate() {
+                        let mut child_ctx = ctx.child();
+                        child_ctx.add_value(key, CelValue::from(k.clone()));
+                        child_ctx.add_value(binding, v.clone());
+                        child_ctx.add_int(&format!("{}_idx", binding), index as i64);
+                        render_nodes(body, &child_ctx, schema, output, components, content_html)?;
+                    }
                 }
+                // The bindings don't fit the collection; compiled views
+                // reject this when the iterable's type is known
+                (Some(_), CelValue::List(_)) | (None, CelValue::Map(_)) => {
+                    let is_map = key.is_none();
+                    let message = crate::transformer::each_binding_error(key.as_deref(), binding, iterable.trim(), is_map)
+                        .unwrap_or_default();
+                    return Err(RenderError { message });
+                }
+                _ => {}
             }
         }
         ControlFlow::Switch {
@@ -326,10 +534,17 @@ fn render_control_flow(
             let switch_str = cel::cel_to_string(&switch_val);
 
             let mut matched = false;
-            for SwitchCase(pattern, children) in cases {
+            for SwitchCase(patterns, children) in cases {
                 // Handle enum patterns (like ACTIVE) or string patterns (like "ACTIVE")
-                let clean_pattern = pattern.trim_matches('"');
-                if switch_str == clean_pattern {
+                let is_match = patterns.iter().any(|pattern| {
+                    let clean_pattern = pattern.trim_matches('"');
+                    let enum_match = match (&switch_val, schema.enum_constant(clean_pattern)) {
+                        (CelValue::Int(n), Some(number)) => *n == number as i64,
+                        _ => false,
+                    };
+                    switch_str == clean_pattern || enum_match
+                });
+                if is_match {
                     render_nodes(children, ctx, schema, output, components, content_html)?;
                     matched = true;
                     break;
@@ -383,6 +598,293 @@ mod tests {
         (root, schema)
     }
 
+    #[test]
+    fn test_render_fragment() {
+        let (root, schema) = parse_template(r#"
+// param: string time
+el {
+    div {
+        h1 "Dashboard"
+        span#clock `time`
+    }
+}
+"#);
+        // Field 1 (time) = "12:00"
+        let data = [0x0a, 0x05, b'1', b'2', b':', b'0', b'0'];
+        let html = render_fragment(&root, "clock", &schema, &data, &HashMap::new(), "").unwrap();
+        assert_eq!(html, "<span id=\"clock\">12:00</span>");
+
+        let err = render_fragment(&root, "missing", &schema, &data, &HashMap::new(), "").unwrap_err();
+        assert!(err.message.contains("not found"));
+    }
+
+    #[test]
+    fn test_render_raw_block() {
+        let (root, schema) = parse_template(r#"
+// param: string html
+el {
+    raw {
+        div `html`
+    }
+    span `html`
+}
+"#);
+        // Field 1 (html) = "<b>hi</b>"
+        let data = [&[0x0a, 0x09][..], b"<b>hi</b>"].concat();
+        let html = render(&root, &schema, &data, &HashMap::new()).unwrap();
+        assert_eq!(html, "<div><b>hi</b></div><span>&lt;b&gt;hi&lt;/b&gt;</span>");
+    }
+
+    #[test]
+    fn test_render_with_source_comments() {
+        let (root, schema) = parse_template(r#"
+// param: bool open
+el {
+    div.card {
+        if `open` {
+            h2 "Open"
+        } else {
+            p "Closed"
+        }
+    }
+}
+"#);
+        let annotated = with_source_comments(&root, "card.hudl");
+        let html = render(&annotated, &schema, &[], &HashMap::new()).unwrap();
+        assert_eq!(
+            html,
+            "<!-- card.hudl:4 --><div class=\"card\"><!-- card.hudl:8 --><p>Closed</p></div>"
+        );
+
+        // Field 1 (open) = true
+        let html = render(&annotated, &schema, &[0x08, 0x01], &HashMap::new()).unwrap();
+        assert!(html.contains("<!-- card.hudl:6 --><h2>Open</h2>"), "HTML: {}", html);
+
+        // The original tree is untouched
+        let html = render(&root, &schema, &[], &HashMap::new()).unwrap();
+        assert_eq!(html, "<div class=\"card\"><p>Closed</p></div>");
+    }
+
+    #[test]
+    fn test_render_flush_point_writes_nothing() {
+        let (root, schema) = parse_template(r#"
+el {
+    header "Top"
+    flush
+    main "Rest"
+}
+"#);
+        let html = render_with_values(&root, &schema, cel::json_to_cel(&serde_json::json!({})), &HashMap::new(), None, "").unwrap();
+        assert_eq!(html, "<header>Top</header><main>Rest</main>");
+    }
+
+    #[test]
+    fn test_render_dynamic_tag() {
+        let (root, schema) = parse_template(r#"
+// param: string tag
+el {
+    el tag=`tag` class="title" "Hi"
+}
+"#);
+        let render = |tag: &str| {
+            render_with_values(&root, &schema, cel::json_to_cel(&serde_json::json!({"tag": tag})), &HashMap::new(), None, "").unwrap()
+        };
+        assert_eq!(render("h3"), "<h3 class=\"title\">Hi</h3>");
+        // Anything outside the allow-list is denied
+        assert_eq!(render("img src=x onerror=alert(1)"), "<div class=\"title\">Hi</div>");
+        assert_eq!(render("script"), "<div class=\"title\">Hi</div>");
+    }
+
+    #[test]
+    fn test_render_svg_keeps_casing() {
+        let (root, schema) = parse_template(r#"
+el {
+    svg viewBox="0 0 24 24" {
+        linearGradient#g { stop offset="1" }
+        path d="M0 0h24"
+    }
+}
+"#);
+        let html = render_with_values(&root, &schema, cel::json_to_cel(&serde_json::json!({})), &HashMap::new(), None, "").unwrap();
+        assert_eq!(
+            html,
+            "<svg viewBox=\"0 0 24 24\"><linearGradient id=\"g\"><stop offset=\"1\"></stop></linearGradient><path d=\"M0 0h24\"></path></svg>"
+        );
+    }
+
+    #[test]
+    fn test_render_attribute_spread() {
+        let (root, schema) = parse_template(r#"
+el {
+    a href="/" ...`attrs` "Home"
+}
+"#);
+        let data = cel::json_to_cel(&serde_json::json!({
+            "attrs": {"title": "Say \"hi\"", "aria-label": "home", "x onclick": "alert(1)", "onclick": "alert(1)"}
+        }));
+        let html = render_with_values(&root, &schema, data, &HashMap::new(), None, "").unwrap();
+        assert_eq!(html, "<a href=\"/\" aria-label=\"home\" title=\"Say &quot;hi&quot;\">Home</a>");
+    }
+
+    #[test]
+    fn test_render_static_attribute_escaped() {
+        let (root, schema) = parse_template(r#"
+el {
+    a href="/search?q=a&b=<c>" "Search"
+}
+"#);
+        let html = render_with_values(&root, &schema, cel::json_to_cel(&serde_json::json!({})), &HashMap::new(), None, "").unwrap();
+        assert_eq!(html, "<a href=\"/search?q=a&amp;b=&lt;c&gt;\">Search</a>");
+    }
+
+    #[test]
+    fn test_render_component_forwards_attrs() {
+        let (button, schema) = parse_template(r#"
+// name: Button
+// param: string label
+// param: map<string, string> attrs
+el {
+    button.btn type="button" ...`attrs` `label`
+}
+"#);
+        let (page, _) = parse_template(r#"
+el {
+    Button#save.btn-primary label="Save" data-id=`item.id` type="submit"
+}
+"#);
+        let mut components = HashMap::new();
+        components.insert("Button".to_string(), &button);
+        let data = cel::json_to_cel(&serde_json::json!({"item": {"id": 7}}));
+        let html = render_with_values(&page, &schema, data, &components, None, "").unwrap();
+        // The root keeps its own type, and appends the forwarded class
+        assert_eq!(html, "<button class=\"btn btn-primary\" type=\"button\" data-id=\"7\" id=\"save\">Save</button>");
+    }
+
+    #[test]
+    fn test_render_locale_reaches_components() {
+        let (greeting, schema) = parse_template(r#"
+// name: Greeting
+el {
+    p lang=`locale` "Bonjour"
+}
+"#);
+        let (page, _) = parse_template(r#"
+el {
+    main lang=`locale` {
+        Greeting
+    }
+}
+"#);
+        let mut components = HashMap::new();
+        components.insert("Greeting".to_string(), &greeting);
+        let data = cel::json_to_cel(&serde_json::json!({}));
+        let html = render_with_values(&page, &schema, data, &components, None, "fr-CA").unwrap();
+        assert_eq!(html, "<main lang=\"fr-CA\"><p lang=\"fr-CA\">Bonjour</p></main>");
+    }
+
+    #[test]
+    fn test_render_each_map_in_key_order() {
+        let (root, schema) = parse_template(r#"
+el {
+    dl {
+        each name count `counts` {
+            dt `name`
+            dd "`count` (`count_idx`)"
+        }
+    }
+}
+"#);
+        let data = cel::json_to_cel(&serde_json::json!({
+            "counts": {"pears": 2, "apples": 5, "figs": 1}
+        }));
+        let html = render_with_values(&root, &schema, data, &HashMap::new(), None, "").unwrap();
+        assert_eq!(
+            html,
+            "<dl><dt>apples</dt><dd>5 (0)</dd><dt>figs</dt><dd>1 (1)</dd><dt>pears</dt><dd>2 (2)</dd></dl>"
+        );
+    }
+
+    #[test]
+    fn test_render_each_bindings_must_fit_collection() {
+        let (root, schema) = parse_template(r#"
+el {
+    each count `counts` {
+        span `count`
+    }
+}
+"#);
+        let data = cel::json_to_cel(&serde_json::json!({"counts": {"pears": 2}}));
+        let err = render_with_values(&root, &schema, data, &HashMap::new(), None, "").unwrap_err();
+        assert_eq!(err.message, "each count `counts`: `counts` is a map, so bind a key and value: each key count `counts`");
+
+        let (root, schema) = parse_template(r#"
+el {
+    each i name `names` {
+        span `name`
+    }
+}
+"#);
+        let data = cel::json_to_cel(&serde_json::json!({"names": ["a"]}));
+        let err = render_with_values(&root, &schema, data, &HashMap::new(), None, "").unwrap_err();
+        assert_eq!(err.message, "each i name `names`: `names` is a list, so bind one item: each name `names`");
+    }
+
+    #[test]
+    fn test_render_each_map_is_deterministic() {
+        let (root, schema) = parse_template(r#"
+el {
+    each code label `errors` {
+        span "`code`=`label`"
+    }
+}
+"#);
+        // Each render gets a separately built map (with its own hash seed),
+        // filled in a different order
+        let render_errors = |pairs: &[(i64, &str)]| {
+            let mut errors = HashMap::new();
+            for (code, label) in pairs {
+                errors.insert(Key::Int(*code), CelValue::String(Arc::new(label.to_string())));
+            }
+            let mut data = HashMap::new();
+            data.insert(Key::String(Arc::new("errors".to_string())), CelValue::Map(cel_interpreter::objects::Map { map: Arc::new(errors) }));
+            let data = CelValue::Map(cel_interpreter::objects::Map { map: Arc::new(data) });
+            render_with_values(&root, &schema, data, &HashMap::new(), None, "").unwrap()
+        };
+        let mut pairs = vec![(404, "missing"), (10, "ten"), (9, "nine"), (500, "broken"), (42, "answer")];
+        let first = render_errors(&pairs);
+        pairs.reverse();
+        let second = render_errors(&pairs);
+        assert_eq!(first, second);
+
+        // Integer keys sort numerically (9 before 10), not as text
+        assert_eq!(
+            first,
+            "<span>9=nine</span><span>10=ten</span><span>42=answer</span><span>404=missing</span><span>500=broken</span>"
+        );
+    }
+
+    #[test]
+    fn test_render_optional_param_is_null() {
+        let (root, schema) = parse_template(r#"
+// param: optional string subtitle
+// param: bool compact #true
+el {
+    if `subtitle == null` {
+        span "none"
+    }
+    if `compact` {
+        small "compact"
+    }
+}
+"#);
+        let html = render(&root, &schema, &[], &HashMap::new()).unwrap();
+        assert_eq!(html, "<span>none</span><small>compact</small>");
+
+        // Field 1 (subtitle) = "Hi"
+        let html = render(&root, &schema, &[0x0a, 0x02, b'H', b'i'], &HashMap::new()).unwrap();
+        assert_eq!(html, "<small>compact</small>");
+    }
+
     #[test]
     fn test_render_static_html() {
         let content = r#"
@@ -529,6 +1031,64 @@ el {
         assert!(!html.contains("Is Inactive"));
     }
 
+    #[test]
+    fn test_render_if_enum_constant() {
+        let content = r#"
+/**
+enum Status {
+    STATUS_UNKNOWN = 0;
+    STATUS_ACTIVE = 1;
+}
+*/
+// name: StatusIf
+// param: Status status
+el {
+    if `status == STATUS_ACTIVE` {
+        span "Active"
+    } else {
+        span "Not active"
+    }
+}
+"#;
+        let (root, schema) = parse_template(content);
+
+        let html = render(&root, &schema, &[8, 1], &HashMap::new()).unwrap();
+        assert!(html.contains("<span>Active</span>"));
+
+        let html = render(&root, &schema, &[], &HashMap::new()).unwrap();
+        assert!(html.contains("Not active"));
+
+        // JSON data names the constant rather than its number
+        let data = cel::json_to_cel(&serde_json::json!({"status": "STATUS_ACTIVE"}));
+        let html = render_with_values(&root, &schema, data, &HashMap::new(), None, "").unwrap();
+        assert!(html.contains("<span>Active</span>"));
+    }
+
+    #[test]
+    fn test_render_element_if_attribute() {
+        let content = r#"
+// name: LinkIf
+// param: string url
+el {
+    div {
+        a href=`url` if=`url != ""` "Visit"
+        span "always"
+    }
+}
+"#;
+        let (root, schema) = parse_template(content);
+
+        let data = cel::json_to_cel(&serde_json::json!({"url": "/home"}));
+        let html = render_with_values(&root, &schema, data, &HashMap::new(), None, "").unwrap();
+        assert!(html.contains("<a href=\"/home\">Visit</a>"));
+        assert!(!html.contains(" if="));
+
+        let data = cel::json_to_cel(&serde_json::json!({"url": ""}));
+        let html = render_with_values(&root, &schema, data, &HashMap::new(), None, "").unwrap();
+        assert!(!html.contains("<a"));
+        assert!(html.contains("<span>always</span>"));
+    }
+
     #[test]
     fn test_render_switch_default() {
         let content = r#"
@@ -559,6 +1119,23 @@ el {
         assert!(!html.contains("Active"));
     }
 
+    #[test]
+    fn test_render_switch_multiple_values() {
+        let (root, schema) = parse_template(r#"
+el {
+    switch `role` {
+        case "admin" "superadmin" { span "Staff" }
+        default { span "Member" }
+    }
+}
+"#);
+        for (role, expected) in [("admin", "Staff"), ("superadmin", "Staff"), ("guest", "Member")] {
+            let data = cel::json_to_cel(&serde_json::json!({ "role": role }));
+            let html = render_with_values(&root, &schema, data, &HashMap::new(), None, "").unwrap();
+            assert_eq!(html, format!("<span>{}</span>", expected));
+        }
+    }
+
     #[test]
     fn test_render_nested_if() {
         let content = r#"
@@ -743,6 +1320,35 @@ el {
         assert!(html.contains(r#"class="foo bar""#));
     }
 
+    #[test]
+    fn test_render_doctype_first() {
+        let content = r#"
+// name: Page
+el {
+    doctype html
+    html lang=en {
+        body "Hi"
+    }
+}
+"#;
+        let (root, schema) = parse_template(content);
+        let html = render(&root, &schema, &[], &HashMap::new()).unwrap();
+        assert!(html.starts_with("<!DOCTYPE html><html"), "got: {}", html);
+    }
+
+    #[test]
+    fn test_render_text_entities() {
+        let content = r#"
+// name: Footer
+el {
+    p "&copy; 2024 Tom & Jerry"
+}
+"#;
+        let (root, schema) = parse_template(content);
+        let html = render(&root, &schema, &[], &HashMap::new()).unwrap();
+        assert!(html.contains("<p>&copy; 2024 Tom &amp; Jerry</p>"), "got: {}", html);
+    }
+
     #[test]
     fn test_render_id_attribute() {
         let content = r#"
@@ -6,5 +6,6 @@ pub mod formatter;
 pub mod interpreter;
 pub mod parser;
 pub mod proto;
+pub mod safe_names;
 pub mod textproto;
 pub mod transformer;
@@ -3,6 +3,7 @@ use std::fs;
 use std::path::Path;
 use std::process::Command;
 use hudlc::{parser, transformer, codegen_cel, codegen_go, proto::ProtoSchema};
+use hudlc::ast::{check_params, collect_fragments, fragment_function_name};
 
 fn main() {
     let args: Vec<String> = env::args().collect();
@@ -14,7 +15,7 @@ fn main() {
     match args[1].as_str() {
         "generate-go" => {
             if args.len() < 3 {
-                println!("Usage: hudlc generate-go <directory> [--package <name>] [--pb-import <path>] [--pb-package <name>] [-o <output.go>]");
+                println!("Usage: hudlc generate-go <directory> [--package <name>] [--pb-import <path>] [--pb-package <name>] [--data-types] [--context] [--bytes] [-o <output.go>]");
                 std::process::exit(1);
             }
             let dir_path = &args[2];
@@ -22,6 +23,9 @@ fn main() {
             let mut package_name = "views".to_string();
             let mut pb_import = "".to_string();
             let mut pb_package = "pb".to_string();
+            let mut data_types = false;
+            let mut context = false;
+            let mut bytes = false;
 
             let mut i = 3;
             while i < args.len() {
@@ -38,16 +42,33 @@ fn main() {
                     "--pb-package" => {
                         if i + 1 < args.len() { pb_package = args[i+1].clone(); i += 1; }
                     }
+                    "--data-types" => data_types = true,
+                    "--context" => context = true,
+                    "--bytes" => bytes = true,
                     _ => {}
                 }
                 i += 1;
             }
 
-            if let Err(e) = run_generate_go(dir_path, &out_path, package_name, pb_import, pb_package) {
+            if let Err(e) = run_generate_go(dir_path, &out_path, package_name, pb_import, pb_package, data_types, context, bytes) {
                 eprintln!("Generate failed: {}", e);
                 std::process::exit(1);
             }
         }
+        "check" => {
+            if args.len() < 3 {
+                println!("Usage: hudlc check <directory>");
+                std::process::exit(1);
+            }
+            match run_check(&args[2]) {
+                Ok(true) => {}
+                Ok(false) => std::process::exit(1),
+                Err(e) => {
+                    eprintln!("Check failed: {}", e);
+                    std::process::exit(1);
+                }
+            }
+        }
         _ => {
             // Default: build WASM
             let dir_path = &args[1];
@@ -58,8 +79,24 @@ fn main() {
                     out_path = args[pos + 1].clone();
                 }
             }
+            let manifest_path = args.iter()
+                .position(|x| x == "--manifest")
+                .and_then(|pos| args.get(pos + 1))
+                .map(String::as_str);
+
+            let opts = codegen_cel::Options {
+                serialization: if args.iter().any(|x| x == "--xhtml") {
+                    codegen_cel::Serialization::Xhtml
+                } else {
+                    codegen_cel::Serialization::Html5
+                },
+                strict: args.iter().any(|x| x == "--strict"),
+            };
+            let transform_opts = transformer::TransformOptions {
+                semantic_defaults: args.iter().any(|x| x == "--a11y-defaults"),
+            };
 
-            if let Err(e) = run_build(dir_path, &out_path) {
+            if let Err(e) = run_build(dir_path, &out_path, manifest_path, &opts, &transform_opts) {
                 eprintln!("Build failed: {}", e);
                 std::process::exit(1);
             }
@@ -69,11 +106,47 @@ fn main() {
 
 fn print_usage() {
     println!("Usage:");
-    println!("  hudlc <directory> [-o output.wasm]   Compile to WASM");
+    println!("  hudlc <directory> [-o output.wasm] [--manifest views.json] [--xhtml] [--strict] [--a11y-defaults]   Compile to WASM");
     println!("  hudlc generate-go <directory> ...    Generate Go wrapper");
+    println!("  hudlc check <directory>              Report unused and undeclared params");
+}
+
+/// Check each view's `// param:` lines against its body, printing unused
+/// params and transform warnings (such as unknown CSS properties) as
+/// warnings and undeclared names as errors. Returns false if there were
+/// errors.
+fn run_check(dir: &str) -> Result<bool, Box<dyn std::error::Error>> {
+    let mut paths: Vec<_> = fs::read_dir(dir)?
+        .map(|entry| entry.map(|e| e.path()))
+        .collect::<Result<_, _>>()?;
+    paths.retain(|p| p.extension().and_then(|s| s.to_str()) == Some("hudl"));
+    paths.sort();
+
+    let mut ok = true;
+    for path in &paths {
+        let content = fs::read_to_string(path)?;
+        let doc = parser::parse(&content).map_err(|e| format!("Parse error in {}: {}", path.display(), e))?;
+        let root = transformer::transform_with_metadata(&doc, &content)?;
+        let schema = ProtoSchema::from_template(&content, path.parent()).unwrap_or_default();
+        let constants: Vec<&str> = schema.enum_constants().into_iter().map(|(name, _)| name).collect();
+
+        for warning in &root.warnings {
+            println!("{}: warning: {}", path.display(), warning);
+        }
+        let result = check_params(&root.nodes, &root.params, &constants);
+        for name in &result.unused {
+            println!("{}: warning: param '{}' is declared but never used", path.display(), name);
+        }
+        for name in &result.undeclared {
+            println!("{}: error: '{}' is not a declared param", path.display(), name);
+            ok = false;
+        }
+    }
+    println!("Checked {} view(s)", paths.len());
+    Ok(ok)
 }
 
-fn run_generate_go(dir: &str, output: &str, pkg: String, pb_imp: String, pb_pkg: String) -> Result<(), Box<dyn std::error::Error>> {
+fn run_generate_go(dir: &str, output: &str, pkg: String, pb_imp: String, pb_pkg: String, data_types: bool, context: bool, bytes: bool) -> Result<(), Box<dyn std::error::Error>> {
     let mut views = Vec::new();
     let mut view_params = Vec::new();
 
@@ -92,8 +165,12 @@ fn run_generate_go(dir: &str, output: &str, pkg: String, pb_imp: String, pb_pkg:
                 path.file_stem().unwrap().to_string_lossy().to_string()
             });
             
+            let fragments = collect_fragments(&root.nodes)
+                .into_iter()
+                .map(|(fragment, _)| fragment_function_name(&name, &fragment))
+                .collect();
+            view_params.push((name, root.params.clone(), fragments));
             views.push(root);
-            view_params.push((name, views.last().unwrap().params.clone()));
         }
     }
 
@@ -101,15 +178,25 @@ fn run_generate_go(dir: &str, output: &str, pkg: String, pb_imp: String, pb_pkg:
         package_name: pkg,
         pb_import_path: pb_imp,
         pb_package_name: pb_pkg,
+        data_types,
+        context,
+        bytes,
+        indent: codegen_go::Indent::Tabs,
     };
 
-    let code = codegen_go::generate_go_wrapper(view_params, opts);
+    let code = codegen_go::generate_go_wrapper_with_fragments(view_params, opts);
     fs::write(output, code)?;
     println!("Generated {}", output);
     Ok(())
 }
 
-fn run_build(dir: &str, output: &str) -> Result<(), Box<dyn std::error::Error>> {
+fn run_build(
+    dir: &str,
+    output: &str,
+    manifest: Option<&str>,
+    opts: &codegen_cel::Options,
+    transform_opts: &transformer::TransformOptions,
+) -> Result<(), Box<dyn std::error::Error>> {
     let mut views = Vec::new();
     let mut combined_schema = ProtoSchema::default();
 
@@ -148,6 +235,9 @@ fn run_build(dir: &str, output: &str) -> Result<(), Box<dyn std::error::Error>>
 
             let doc = parser::parse(&content).map_err(|e| format!("Parse error in {}: {}", path.display(), e))?;
             let root = transformer::transform_with_metadata(&doc, &content)?;
+            for warning in &root.warnings {
+                println!("{}: warning: {}", path.display(), warning);
+            }
 
             // Use component name from metadata if available, otherwise derive from filename
             let func_name = root.name.clone().unwrap_or_else(|| {
@@ -171,9 +261,17 @@ fn run_build(dir: &str, output: &str) -> Result<(), Box<dyn std::error::Error>>
     if views.is_empty() {
         return Err("No .hudl files found".into());
     }
+    if transform_opts.semantic_defaults {
+        transformer::apply_semantic_defaults(&mut views);
+    }
 
     println!("Found {} view(s)", views.len());
 
+    if let Some(manifest) = manifest {
+        fs::write(manifest, hudlc::proto::views_manifest(&views, &combined_schema)?)?;
+        println!("Wrote manifest {}", manifest);
+    }
+
     // 2. Setup temporary Cargo project
     let build_dir = Path::new("hudl_build");
     if build_dir.exists() {
@@ -204,7 +302,7 @@ lto = true
     fs::write(build_dir.join("Cargo.toml"), cargo_toml)?;
 
     // 3. Generate the Rust library source using CEL codegen
-    let lib_source = codegen_cel::generate_wasm_lib_cel(views, &combined_schema)?;
+    let lib_source = codegen_cel::generate_wasm_lib_cel_with_options(views, &combined_schema, opts)?;
     fs::write(build_dir.join("src/lib.rs"), &lib_source)?;
 
     // 4. Build WASM using cargo
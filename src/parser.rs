@@ -13,6 +13,11 @@ pub fn pre_parse(input: &str) -> String {
     let mut result = String::with_capacity(input.len() * 2);
     let chars: Vec<char> = input.chars().collect();
     let mut i = 0;
+    // Node boundaries, so a string standing alone as a node (text between
+    // child elements) can be marked as text rather than read as a node name
+    let mut at_node_start = true;
+    let mut node_name = String::new();
+    let mut blocks: Vec<String> = Vec::new();
 
     while i < chars.len() {
         let c = chars[i];
@@ -46,6 +51,65 @@ pub fn pre_parse(input: &str) -> String {
             }
         }
 
+        let starts_node = at_node_start && !c.is_whitespace();
+        if starts_node {
+            at_node_start = false;
+            // Raw strings are left alone: quoted node names are written as
+            // raw strings, so a second pass must not mistake them for text.
+            let is_string = c == '"' || c == '`';
+            if is_string && !blocks.iter().any(|b| is_literal_block(b)) {
+                result.push_str("__hudl_text ");
+            }
+            node_name = chars[i..].iter()
+                .take_while(|ch| !ch.is_whitespace() && **ch != '{' && **ch != ';')
+                .collect();
+        }
+        match c {
+            '{' => {
+                blocks.push(std::mem::take(&mut node_name));
+                at_node_start = true;
+            }
+            '}' => {
+                blocks.pop();
+                at_node_start = true;
+            }
+            '\n' | ';' => at_node_start = true,
+            _ => {}
+        }
+
+        // Handle multi-line strings ("""), kept whole so their line breaks
+        // survive; KDL strips the closing delimiter's indentation from each
+        // line. Like other strings, they become raw strings if they contain
+        // backticks.
+        if c == '"' && chars[i..].starts_with(&['"', '"', '"']) {
+            let start = i;
+            i += 3;
+            let mut has_backtick = false;
+            while i < chars.len() && !chars[i..].starts_with(&['"', '"', '"']) {
+                if chars[i] == '`' {
+                    has_backtick = true;
+                }
+                if chars[i] == '\\' && i + 1 < chars.len() {
+                    i += 1;
+                }
+                i += 1;
+            }
+
+            if i < chars.len() {
+                i += 3; // closing quotes
+                let content: String = chars[start..i].iter().collect();
+                if has_backtick {
+                    result.push('#');
+                    result.push_str(&content);
+                    result.push('#');
+                } else {
+                    result.push_str(&content);
+                }
+                continue;
+            }
+            i = start;
+        }
+
         // Handle quoted strings - if they contain backticks, wrap in raw strings
         if c == '"' {
             let start = i;
@@ -106,12 +170,21 @@ pub fn pre_parse(input: &str) -> String {
                 i += 1;
             }
             let signal: String = chars[start..i].iter().collect();
-            result.push('"');
-            result.push_str(&signal);
-            result.push('"');
+            push_quoted(&mut result, &signal, starts_node);
             continue;
         }
 
+        // Handle attribute spreads: ...`attrs` becomes a property the
+        // transformer collects
+        if c == '.' && !starts_node && chars[i..].starts_with(&['.', '.', '.', '`']) {
+            if let Some(len) = chars[i + 4..].iter().position(|ch| *ch == '`') {
+                let expr: String = chars[i + 3..i + 5 + len].iter().collect();
+                result.push_str(&format!("__hudl_spread=#\"{}\"#", expr));
+                i += 5 + len;
+                continue;
+            }
+        }
+
         // Handle standalone backtick expressions - wrap in raw strings
         if c == '`' {
             let start = i;
@@ -191,9 +264,7 @@ pub fn pre_parse(input: &str) -> String {
                               });
 
                 if !is_plain {
-                    result.push('"');
-                    result.push_str(&full_ident);
-                    result.push('"');
+                    push_quoted(&mut result, &full_ident, starts_node);
                 } else {
                     result.push_str(&full_ident);
                 }
@@ -201,7 +272,8 @@ pub fn pre_parse(input: &str) -> String {
             }
         }
 
-        // Handle } else -> }\nelse for KDL compatibility
+        // Handle } else -> };else for KDL compatibility. A `;` rather than a
+        // newline keeps every node on its source line.
         if c == '}' {
             result.push(c);
             let mut k = i + 1;
@@ -213,7 +285,7 @@ pub fn pre_parse(input: &str) -> String {
             if k + 4 <= chars.len() {
                 let next4: String = chars[k..k+4].iter().collect();
                 if next4 == "else" && (k + 4 >= chars.len() || !is_ident_char(chars[k + 4])) {
-                    result.push('\n');
+                    result.push(';');
                     i = k - 1;
                 }
             }
@@ -237,9 +309,7 @@ pub fn pre_parse(input: &str) -> String {
                               full_name[1..].chars().all(is_ident_char);
 
                 if !is_plain {
-                    result.push('"');
-                    result.push_str(&full_name);
-                    result.push('"');
+                    push_quoted(&mut result, &full_name, starts_node);
                 } else {
                     result.push_str(&full_name);
                 }
@@ -320,7 +390,10 @@ pub fn pre_parse(input: &str) -> String {
                     i += 1;
                 }
                 if i < chars.len() && chars[i].is_ascii_alphabetic() {
-                    result.push('_');
+                    // Property values are quoted instead, since `key=_10px`
+                    // would be quoted again on a second pass
+                    let quote = prev == Some('=');
+                    result.push(if quote { '"' } else { '_' });
                     for c in &chars[start..i] {
                         result.push(*c);
                     }
@@ -328,6 +401,9 @@ pub fn pre_parse(input: &str) -> String {
                         result.push(chars[i]);
                         i += 1;
                     }
+                    if quote {
+                        result.push('"');
+                    }
                     continue;
                 } else {
                     for c in &chars[start..i] {
@@ -345,10 +421,35 @@ pub fn pre_parse(input: &str) -> String {
     result
 }
 
+/// Blocks whose children are names or values rather than markup, so a
+/// string at the start of a line there is not text. Names may already be
+/// quoted or carry their `__hudl_` prefix when the input was preprocessed
+/// before.
+fn is_literal_block(name: &str) -> bool {
+    let name = name.trim_matches(|c| c == '#' || c == '"');
+    let name = name.strip_prefix("__hudl_").unwrap_or(name);
+    matches!(name, "css" | "style" | "~" | "import")
+}
+
 fn is_ident_start(c: char) -> bool {
     c.is_ascii_alphabetic() || c == '_'
 }
 
+/// Quotes a name the preprocessor could not leave bare. Node names use raw
+/// strings so they stay distinguishable from text nodes when the output is
+/// preprocessed again.
+fn push_quoted(result: &mut String, name: &str, node_name: bool) {
+    if node_name {
+        result.push_str("#\"");
+        result.push_str(name);
+        result.push_str("\"#");
+    } else {
+        result.push('"');
+        result.push_str(name);
+        result.push('"');
+    }
+}
+
 fn is_ident_char(c: char) -> bool {
     c.is_ascii_alphanumeric() || c == '_' || c == '-'
 }
@@ -380,7 +481,7 @@ mod tests {
     #[test]
     fn test_else_handling() {
         let result = pre_parse("} else {");
-        assert!(result.contains("}\n__hudl_else"));
+        assert!(result.contains("};__hudl_else"));
     }
 
     #[test]
@@ -412,4 +513,80 @@ mod tests {
         let result = pre_parse("div 10px");
         assert!(result.contains("_10px"));
     }
+
+    #[test]
+    fn test_attribute_values_with_digits_and_underscores() {
+        assert_eq!(pre_parse("meta charset=utf-8"), r#"meta charset="utf-8""#);
+        assert_eq!(pre_parse("html lang=en"), r#"html lang="en""#);
+        assert_eq!(pre_parse("a target=_blank"), r#"a target="_blank""#);
+    }
+
+    #[test]
+    fn test_attribute_spread() {
+        assert_eq!(
+            pre_parse("button type=submit ...`props.attrs`"),
+            r##"button type="submit" __hudl_spread=#"`props.attrs`"#"##
+        );
+    }
+
+    #[test]
+    fn test_standalone_strings_marked_as_text() {
+        let result = pre_parse("p {\n    \"Hello \"\n    b \"world\"\n    `name`\n}");
+        assert!(result.contains("__hudl_text \"Hello \""));
+        assert!(result.contains("b \"world\""));
+        assert!(result.contains("__hudl_text #\"`name`\"#"));
+
+        // Strings in css, style, tilde and import blocks are left alone
+        let result = pre_parse("import {\n    \"./layout\"\n}\ncss {\n    \".card p\" { color red }\n}");
+        assert!(!result.contains("__hudl_text"));
+    }
+
+    #[test]
+    fn test_pre_parse_is_idempotent() {
+        let corpus = [
+            "div 10px\nspan width=10px height=.5em",
+            "#main.container { }\n.card\n#app",
+            "a target=_blank lang=en charset=utf-8",
+            "button ~on:click=\"handler()\" ~show",
+            "input~>query~debounce:300ms",
+            "if `x > 0` {\n    p \"yes\"\n} else {\n    p \"no\"\n}",
+            "p {\n    \"Hello \"\n    b `name`\n    `greeting`\n    $count\n}",
+            "css {\n    .card:hover { color red }\n}\nimport {\n    \"./layout\"\n}",
+            r##"div data-signals=#"{"count": 0}"# title="a `b` c""##,
+            "el {\n    #content\n}",
+        ];
+        for input in corpus {
+            let once = pre_parse(input);
+            assert_eq!(pre_parse(&once), once, "input: {}", input);
+        }
+
+        let examples = concat!(env!("CARGO_MANIFEST_DIR"), "/examples");
+        for entry in walk(std::path::Path::new(examples)) {
+            let input = std::fs::read_to_string(&entry).unwrap();
+            let once = pre_parse(&input);
+            assert_eq!(pre_parse(&once), once, "file: {}", entry.display());
+        }
+    }
+
+    fn walk(dir: &std::path::Path) -> Vec<std::path::PathBuf> {
+        let mut files = Vec::new();
+        for entry in std::fs::read_dir(dir).unwrap() {
+            let path = entry.unwrap().path();
+            if path.is_dir() {
+                files.extend(walk(&path));
+            } else if path.extension().map_or(false, |e| e == "hudl") {
+                files.push(path);
+            }
+        }
+        files
+    }
+
+    #[test]
+    fn test_json_attribute_values_untouched() {
+        let raw = r##"div data-signals=#"{"count": 0, "name": "x"}"#"##;
+        assert_eq!(pre_parse(raw), raw);
+
+        let quoted = r#"div data-signals="{\"count\": 0}""#;
+        assert_eq!(pre_parse(quoted), quoted);
+    }
 }
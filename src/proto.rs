@@ -377,6 +377,11 @@ impl ProtoSchema {
             "bool" => ProtoType::Bool,
             "string" => ProtoType::String,
             "bytes" => ProtoType::Bytes,
+            // A `// param: map<string, string> attrs` type
+            other if other.starts_with("map<") && other.ends_with('>') => {
+                let (key, value) = other[4..other.len() - 1].split_once(',').unwrap_or(("string", "string"));
+                ProtoType::Map(Box::new(Self::parse_type(key.trim())), Box::new(Self::parse_type(value.trim())))
+            }
             // Assume anything else is a message reference
             other => ProtoType::Message(other.to_string()),
         }
@@ -436,6 +441,24 @@ impl ProtoSchema {
         self.enums.get(name)
     }
 
+    /// Every enum constant and its number, sorted by name. Templates compare
+    /// enum values against these (`status == STATUS_ACTIVE`) as ints.
+    pub fn enum_constants(&self) -> Vec<(&str, i32)> {
+        let mut constants: Vec<(&str, i32)> = self.enums.values()
+            .flat_map(|e| e.values.iter().map(|ev| (ev.name.as_str(), ev.number)))
+            .collect();
+        constants.sort();
+        constants
+    }
+
+    /// The number of an enum constant, if `name` is one.
+    pub fn enum_constant(&self, name: &str) -> Option<i32> {
+        self.enums.values()
+            .flat_map(|e| e.values.iter())
+            .find(|ev| ev.name == name)
+            .map(|ev| ev.number)
+    }
+
     /// Resolve a field path on a message type.
     ///
     /// Returns the final field type, or an error if the path is invalid.
@@ -1002,6 +1025,22 @@ fn encode_field(buf: &mut Vec<u8>, field_num: u32, val: &CelValue, field_type: O
                 encode_field(buf, field_num, item, field_type);
             }
         }
+        CelValue::Map(m) if matches!(field_type, Some(ProtoType::Map(_, _))) => {
+            // Map entries are repeated messages with key=1, value=2
+            let Some(ProtoType::Map(key_type, value_type)) = field_type else { return };
+            for (k, v) in m.map.iter() {
+                let mut entry = Vec::new();
+                match k {
+                    Key::String(s) => encode_field(&mut entry, 1, &CelValue::String(s.clone()), Some(key_type.as_ref())),
+                    Key::Int(i) => encode_field(&mut entry, 1, &CelValue::Int(*i), Some(key_type.as_ref())),
+                    _ => continue,
+                }
+                encode_field(&mut entry, 2, v, Some(value_type.as_ref()));
+                encode_varint(buf, field_num, WIRE_LENGTH_DELIMITED);
+                encode_raw_varint(buf, entry.len() as u64);
+                buf.extend_from_slice(&entry);
+            }
+        }
         CelValue::Map(_) => {
             // Nested message
             let nested_type = if let Some(ProtoType::Message(name)) = field_type {
@@ -1158,7 +1197,121 @@ pub fn decode_raw_message(data: &[u8]) -> HashMap<u32, RawProtoValue> {
     fields
 }
 
+/// A renderable view (or fragment export) and the message its data is
+/// encoded as. Compiled modules list these in their `hudl.views` section.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct ViewSchema {
+    pub name: String,
+    /// Empty for views without params
+    pub message: String,
+    /// From the template's `// content-type:`; empty means text/html
+    #[serde(rename = "contentType", default, skip_serializing_if = "String::is_empty")]
+    pub content_type: String,
+    /// Data field paths the view reads; see `ast::referenced_fields`
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub fields: Vec<String>,
+    /// The view's `// param:` declarations, in order
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub params: Vec<ViewParam>,
+}
+
+/// A declared view param, as listed in `ViewSchema`.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct ViewParam {
+    pub name: String,
+    #[serde(rename = "type")]
+    pub type_name: String,
+    #[serde(default, skip_serializing_if = "std::ops::Not::not")]
+    pub repeated: bool,
+    #[serde(default, skip_serializing_if = "std::ops::Not::not")]
+    pub optional: bool,
+}
+
+impl From<&Param> for ViewParam {
+    fn from(p: &Param) -> Self {
+        ViewParam {
+            name: p.name.clone(),
+            type_name: p.type_name.clone(),
+            repeated: p.repeated,
+            optional: p.optional,
+        }
+    }
+}
+
+/// The manifest `hudlc --manifest` writes: every view and fragment export
+/// with its data message, params and content type, as pretty JSON, for
+/// tooling that needs the listing without loading the module.
+pub fn views_manifest(views: &[(String, crate::ast::Root)], schema: &ProtoSchema) -> Result<String, String> {
+    let mut listing = Vec::new();
+    for (name, root) in views {
+        listing.extend(view_schemas(name, root, schema)?);
+    }
+    listing.sort_by(|a, b| a.name.cmp(&b.name));
+    serde_json::to_string_pretty(&listing).map_err(|e| e.to_string())
+}
+
+/// List a view and its fragment exports with the view's data message and
+/// the fields each reads.
+pub fn view_schemas(name: &str, root: &crate::ast::Root, schema: &ProtoSchema) -> Result<Vec<ViewSchema>, String> {
+    let message = schema
+        .view_message(&root.params, root.data_message.as_deref())
+        .map_err(|e| format!("{}: {}", name, e))?
+        .unwrap_or_default();
+    let content_type = root.content_type.clone().unwrap_or_default();
+    let params: Vec<ViewParam> = root.params.iter().map(ViewParam::from).collect();
+    let mut views = vec![ViewSchema {
+        name: name.to_string(),
+        message: message.clone(),
+        content_type: content_type.clone(),
+        fields: crate::ast::referenced_fields(&root.nodes, &root.params),
+        params: params.clone(),
+    }];
+    for (fragment, node) in crate::ast::collect_fragments(&root.nodes) {
+        views.push(ViewSchema {
+            name: crate::ast::fragment_function_name(name, &fragment),
+            message: message.clone(),
+            content_type: content_type.clone(),
+            fields: crate::ast::referenced_fields(std::slice::from_ref(node), &root.params),
+            params: params.clone(),
+        });
+    }
+    Ok(views)
+}
+
 impl ProtoSchema {
+    /// The message matching a view's params, which are encoded as fields
+    /// 1, 2, 3... in declaration order. A view whose params match several
+    /// messages must name one with `// data:`, passed as `declared`.
+    pub fn view_message(&self, params: &[Param], declared: Option<&str>) -> Result<Option<String>, String> {
+        let matches = |name: &str| {
+            self.messages.get(name).is_some_and(|msg| {
+                msg.fields.len() >= params.len()
+                    && params.iter().enumerate().all(|(i, p)| {
+                        msg.fields.iter().any(|f| f.number == i as u32 + 1 && f.name == p.name)
+                    })
+            })
+        };
+        if let Some(name) = declared {
+            if !matches(name) {
+                return Err(format!("// data: {} is not a message whose fields 1, 2, 3... are the view's params", name));
+            }
+            return Ok(Some(name.to_string()));
+        }
+        if params.is_empty() {
+            return Ok(None);
+        }
+        let mut names: Vec<&String> = self.messages.keys().filter(|name| matches(name)).collect();
+        names.sort();
+        match names.as_slice() {
+            [] => Ok(None),
+            [name] => Ok(Some(name.to_string())),
+            several => Err(format!(
+                "params match several messages ({}); name one with // data: <message>",
+                several.iter().map(|n| n.as_str()).collect::<Vec<_>>().join(", ")
+            )),
+        }
+    }
+
     /// Decode a proto message into a CEL Map value using schema information.
     pub fn decode_message_to_cel(&self, data: &[u8], message_name: &str) -> CelValue {
         self.decode_message_to_cel_ext(data, message_name, false)
@@ -1214,6 +1367,8 @@ impl ProtoSchema {
                     // Missing field - use default value for proto3 semantics
                     if param.repeated {
                         CelValue::List(Arc::new(Vec::new()))
+                    } else if param.optional {
+                        CelValue::Null
                     } else {
                         self.get_default_value_ext(&refined_type, true)
                     }
@@ -1319,7 +1474,7 @@ impl ProtoSchema {
             ProtoType::Enum(name) => match raw {
                 RawProtoValue::Varint(n) => {
                     if enums_as_ints {
-                        return CelValue::String(Arc::new(n.to_string()));
+                        return CelValue::Int(*n as i64);
                     }
                     // Try to resolve enum value name
                     if let Some(en) = self.get_enum(name) {
@@ -1333,6 +1488,29 @@ impl ProtoSchema {
                 }
                 _ => CelValue::Null,
             },
+            ProtoType::Map(key_type, value_type) => {
+                // Entries are repeated messages with key=1, value=2
+                let entries: Vec<&RawProtoValue> = match raw {
+                    RawProtoValue::Repeated(items) => items.iter().collect(),
+                    single => vec![single],
+                };
+                let mut cel_map: HashMap<Key, CelValue> = HashMap::new();
+                for entry in entries {
+                    let RawProtoValue::Bytes(b) = entry else { continue };
+                    let fields = decode_raw_message(b);
+                    let key = match (key_type.as_ref(), fields.get(&1)) {
+                        (ProtoType::String, Some(RawProtoValue::Bytes(k))) => Key::String(Arc::new(String::from_utf8_lossy(k).to_string())),
+                        (_, Some(RawProtoValue::Varint(n))) => Key::Int(*n as i64),
+                        _ => continue,
+                    };
+                    let value = match fields.get(&2) {
+                        Some(v) => self.decode_value_to_cel_ext(v, value_type, enums_as_ints),
+                        None => self.get_default_value_ext(value_type, enums_as_ints),
+                    };
+                    cel_map.insert(key, value);
+                }
+                CelValue::Map(CelMap { map: Arc::new(cel_map) })
+            }
             _ => self.decode_raw_to_cel(raw),
         }
     }
@@ -1359,7 +1537,7 @@ impl ProtoSchema {
             ProtoType::Bytes => CelValue::Bytes(Arc::new(Vec::new())),
             ProtoType::Enum(enum_name) => {
                 if enums_as_ints {
-                    return CelValue::String(Arc::new("0".to_string()));
+                    return CelValue::Int(0);
                 }
                 // Default enum value is the one with number 0
                 if let Some(proto_enum) = self.get_enum(enum_name) {
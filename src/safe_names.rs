@@ -0,0 +1,36 @@
+// Checks on tag and attribute names computed at render time, from
+// `el tag=`expr`` and `...`attrs`` spreads. The interpreter calls these
+// directly and generated views embed this file (see `SAFE_NAMES_SRC`), so
+// dev and prod accept exactly the same names. Keep it free of crate imports.
+
+/// Tags a computed tag name may render as. Elements whose content isn't
+/// parsed as ordinary HTML (script, style, textarea, title, iframe...) and
+/// void elements are left out.
+pub const DYNAMIC_TAGS: &[&str] = &[
+    "a", "abbr", "address", "article", "aside", "b", "blockquote", "button", "caption", "cite",
+    "code", "dd", "del", "details", "dfn", "div", "dl", "dt", "em", "fieldset", "figcaption",
+    "figure", "footer", "h1", "h2", "h3", "h4", "h5", "h6", "header", "i", "ins", "kbd", "label",
+    "legend", "li", "main", "mark", "nav", "ol", "p", "pre", "q", "s", "section", "small", "span",
+    "strong", "sub", "summary", "sup", "table", "tbody", "td", "tfoot", "th", "thead", "tr", "u",
+    "ul", "var",
+];
+
+/// Tag rendered when a computed tag name isn't in `DYNAMIC_TAGS`.
+pub const DYNAMIC_TAG_FALLBACK: &str = "div";
+
+/// The tag to render for a computed tag name: the name itself if it is in
+/// `DYNAMIC_TAGS`, or `DYNAMIC_TAG_FALLBACK`.
+pub fn safe_tag_name(name: &str) -> &str {
+    if DYNAMIC_TAGS.contains(&name) { name } else { DYNAMIC_TAG_FALLBACK }
+}
+
+/// Whether a key from a spread may be written as an attribute name: an ASCII
+/// letter followed by letters, digits, `-`, `_`, `:` or `.`, so a value can
+/// never inject markup, and not an `on*` event handler, whose value would run
+/// as script. Other keys are dropped.
+pub fn safe_attr_name(name: &str) -> bool {
+    let mut chars = name.chars();
+    let well_formed = chars.next().is_some_and(|c| c.is_ascii_alphabetic())
+        && chars.all(|c| c.is_ascii_alphanumeric() || matches!(c, '-' | '_' | ':' | '.'));
+    well_formed && !name.get(..2).is_some_and(|prefix| prefix.eq_ignore_ascii_case("on"))
+}
@@ -1,17 +1,62 @@
-use kdl::{KdlDocument, KdlNode};
+use kdl::{KdlDocument, KdlEntry, KdlNode};
 use regex::Regex;
 use crate::ast::{ControlFlow, SwitchCase, Root, Node, Element, Text, DatastarAttr, Param};
-use std::collections::HashMap;
+use std::collections::{HashMap, HashSet};
+
+/// Offsets where each line of the preprocessed source starts, so elements
+/// can record their position. Empty when the source isn't known.
+#[derive(Default)]
+struct LineTable(Vec<usize>);
+
+impl LineTable {
+    fn new(source: &str) -> Self {
+        LineTable(std::iter::once(0).chain(source.match_indices('\n').map(|(i, _)| i + 1)).collect())
+    }
+
+    /// The 1-based source line of an offset, if the source is known.
+    fn line(&self, offset: usize) -> Option<usize> {
+        (!self.0.is_empty()).then(|| self.0.partition_point(|&start| start <= offset))
+    }
+
+    /// The 1-based column of an offset within its line, if the source is known.
+    fn column(&self, offset: usize) -> Option<usize> {
+        self.line(offset).map(|line| offset - self.0[line - 1] + 1)
+    }
+
+    /// `line N: ` to start a message about a node, or nothing if the source
+    /// isn't known.
+    fn at(&self, node: &KdlNode) -> String {
+        self.line(node.span().offset()).map(|line| format!("line {}: ", line)).unwrap_or_default()
+    }
+}
 
 pub fn transform(doc: &KdlDocument) -> Result<Root, String> {
+    transform_root(doc, false, &LineTable::default())
+}
+
+/// Transform a document. With `bare_root` (a `// fragment` template) its
+/// top-level nodes are the view's content, as if inside an `el` block.
+fn transform_root(doc: &KdlDocument, bare_root: bool, lines: &LineTable) -> Result<Root, String> {
     let mut nodes = Vec::new();
+    let mut bare_nodes = Vec::new();
     let mut css = None;
     let mut imports = Vec::new();
+    let mut fragments = HashMap::new();
+    let mut doctype = None;
+    let mut warnings = Vec::new();
     let name = None;
     let params = Vec::new();
 
+    check_control_flow(doc.nodes(), false, lines)?;
+
     for node in doc.nodes() {
         match node.name().value() {
+            "fragment" => {
+                let fragment = process_local_fragment(node, lines)?;
+                if fragments.insert(fragment.name.clone(), fragment).is_some() {
+                    return Err(format!("Duplicate fragment '{}'", node_arg(node).unwrap_or_default()));
+                }
+            }
             "__hudl_import" => {
                 if let Some(children) = node.children() {
                     for import_node in children.nodes() {
@@ -24,25 +69,375 @@ pub fn transform(doc: &KdlDocument) -> Result<Root, String> {
                     let mut view_nodes = Vec::new();
                     for child in children.nodes() {
                         if child.name().value() == "__hudl_css" {
-                            css = Some(process_css(child)?);
+                            css = Some(process_css(child, lines, &mut warnings));
+                        } else if child.name().value() == "doctype" {
+                            // Output starts with the doctype, so nothing may be rendered before it
+                            if !view_nodes.is_empty() || doctype.is_some() {
+                                return Err(format!(
+                                    "{}'doctype' must come first, before the root element",
+                                    lines.at(child)
+                                ));
+                            }
+                            doctype = Some(node_arg(child).unwrap_or_else(|| "html".to_string()));
                         } else {
                             view_nodes.push(child.clone());
                         }
                     }
-                    nodes.append(&mut transform_block(&view_nodes)?);
+                    nodes.append(&mut transform_block(&view_nodes, lines)?);
                 }
             }
+            "__hudl_css" if bare_root => {
+                css = Some(process_css(node, lines, &mut warnings));
+            }
+            _ if bare_root => bare_nodes.push(node.clone()),
+            _ => {}
+        }
+    }
+    nodes.append(&mut transform_block(&bare_nodes, lines)?);
+    if !fragments.is_empty() {
+        nodes = expand_fragments(nodes, &fragments, 0)?;
+    }
+    Ok(Root { nodes, css, name, params, imports, doctype, content_type: None, data_message: None, warnings })
+}
+
+/// A file-local fragment: `fragment Name param other="default" { ... }`.
+/// Invocations (`Name param=value { ... }`) are inlined by `expand_fragments`;
+/// an invocation's children fill the fragment's `#content` slot.
+struct LocalFragment {
+    name: String,
+    /// Parameter names with an optional default (as a CEL expression)
+    params: Vec<(String, Option<String>)>,
+    body: Vec<Node>,
+}
+
+/// Maximum nesting of fragment invocations, to catch recursive fragments.
+const MAX_FRAGMENT_DEPTH: usize = 16;
+
+/// Reject `case`/`default` outside a `switch` and `else` not directly after
+/// an `if`, which would otherwise be rendered as bogus elements.
+fn check_control_flow(nodes: &[KdlNode], in_switch: bool, lines: &LineTable) -> Result<(), String> {
+    // Whether the previous node was an `if` or `else if`, which an `else`
+    // may follow
+    let mut after_if = false;
+    let mut seen_default = false;
+    for node in nodes {
+        let name = node.name().value();
+        match name {
+            "__hudl_case" | "__hudl_default" if !in_switch => {
+                return Err(format!(
+                    "{}'{}' must be inside a 'switch'",
+                    lines.at(node),
+                    name.trim_start_matches("__hudl_")
+                ));
+            }
+            // Only one branch of a switch renders, so a case after the
+            // default could only ever be dead code
+            "__hudl_case" | "__hudl_default" if seen_default => {
+                return Err(format!(
+                    "{}'{}' after 'default'; 'default' must be the last branch of a 'switch'",
+                    lines.at(node),
+                    name.trim_start_matches("__hudl_")
+                ));
+            }
+            "__hudl_default" => seen_default = true,
+            "__hudl_else" if !after_if => {
+                return Err(format!(
+                    "{}'else' must directly follow an 'if' block",
+                    lines.at(node)
+                ));
+            }
             _ => {}
         }
+        if let Some(children) = node.children() {
+            check_control_flow(children.nodes(), name == "__hudl_switch", lines)?;
+        }
+        after_if = name == "__hudl_if" || (name == "__hudl_else" && is_else_if(node));
+    }
+    Ok(())
+}
+
+fn node_arg(node: &KdlNode) -> Option<String> {
+    node.entries().iter()
+        .find(|e| e.name().is_none())
+        .and_then(|e| e.value().as_string())
+        .map(|s| s.to_string())
+}
+
+fn process_local_fragment(node: &KdlNode, lines: &LineTable) -> Result<LocalFragment, String> {
+    let mut positional = node.entries().iter()
+        .filter(|e| e.name().is_none())
+        .map(|e| e.value().as_string().map(|s| s.to_string()));
+
+    let name = positional.next().flatten().ok_or("fragment missing name")?;
+    let mut params = Vec::new();
+    for param in positional {
+        let param = param.ok_or_else(|| format!("fragment '{}' has an invalid parameter", name))?;
+        params.push((param, None));
+    }
+    for entry in node.entries() {
+        if let Some(key) = entry.name() {
+            let value = entry.value().as_string().unwrap_or_default();
+            params.push((key.value().to_string(), Some(fragment_arg_to_cel(&name, value)?)));
+        }
+    }
+
+    let body = if let Some(children) = node.children() {
+        transform_block(children.nodes(), lines)?
+    } else {
+        Vec::new()
+    };
+
+    Ok(LocalFragment { name, params, body })
+}
+
+/// Convert a fragment argument to a CEL expression: `` `expr` `` is used as-is,
+/// anything else becomes a string literal.
+fn fragment_arg_to_cel(fragment: &str, value: &str) -> Result<String, String> {
+    if value.len() >= 2 && value.starts_with('`') && value.ends_with('`') && value.matches('`').count() == 2 {
+        return Ok(format!("({})", &value[1..value.len() - 1]));
+    }
+    if value.contains('`') {
+        return Err(format!(
+            "fragment '{}': arguments must be a plain string or a single `expression`",
+            fragment
+        ));
+    }
+    Ok(format!("\"{}\"", value.replace('\\', "\\\\").replace('"', "\\\"")))
+}
+
+/// Inline invocations of local fragments, substituting arguments into the
+/// fragment body's expressions.
+fn expand_fragments(
+    nodes: Vec<Node>,
+    fragments: &HashMap<String, LocalFragment>,
+    depth: usize,
+) -> Result<Vec<Node>, String> {
+    if depth > MAX_FRAGMENT_DEPTH {
+        return Err("Fragment invocations nested too deeply (recursive fragment?)".to_string());
+    }
+
+    let mut result = Vec::new();
+    for node in nodes {
+        match node {
+            Node::Element(el) if fragments.contains_key(&el.tag) => {
+                let fragment = &fragments[&el.tag];
+                let mut args = HashMap::new();
+                for (param, default) in &fragment.params {
+                    let arg = match el.attributes.get(param) {
+                        Some(value) => fragment_arg_to_cel(&fragment.name, value)?,
+                        None => default.clone().ok_or_else(|| {
+                            format!("fragment '{}' invoked without argument '{}'", fragment.name, param)
+                        })?,
+                    };
+                    args.insert(param.clone(), arg);
+                }
+                let mut body = fragment.body.clone();
+                substitute_nodes(&mut body, &args);
+                // Slot children belong to the caller, so they are filled in
+                // after substitution and keep the caller's bindings
+                if !el.children.is_empty() && !fill_content_slot(&mut body, &el.children) {
+                    return Err(format!(
+                        "fragment '{}' invoked with children but has no #content slot",
+                        fragment.name
+                    ));
+                }
+                result.append(&mut expand_fragments(body, fragments, depth + 1)?);
+            }
+            Node::Element(mut el) => {
+                el.children = expand_fragments(el.children, fragments, depth)?;
+                result.push(Node::Element(el));
+            }
+            Node::ControlFlow(ControlFlow::If { condition, then_block, else_block }) => {
+                result.push(Node::ControlFlow(ControlFlow::If {
+                    condition,
+                    then_block: expand_fragments(then_block, fragments, depth)?,
+                    else_block: else_block.map(|b| expand_fragments(b, fragments, depth)).transpose()?,
+                }));
+            }
+            Node::ControlFlow(ControlFlow::Each { key, binding, iterable, body }) => {
+                result.push(Node::ControlFlow(ControlFlow::Each {
+                    key,
+                    binding,
+                    iterable,
+                    body: expand_fragments(body, fragments, depth)?,
+                }));
+            }
+            Node::ControlFlow(ControlFlow::Switch { expr, cases, default }) => {
+                let cases = cases.into_iter()
+                    .map(|SwitchCase(pattern, children)| {
+                        Ok(SwitchCase(pattern, expand_fragments(children, fragments, depth)?))
+                    })
+                    .collect::<Result<Vec<_>, String>>()?;
+                result.push(Node::ControlFlow(ControlFlow::Switch {
+                    expr,
+                    cases,
+                    default: default.map(|d| expand_fragments(d, fragments, depth)).transpose()?,
+                }));
+            }
+            other => result.push(other),
+        }
     }
-    Ok(Root { nodes, css, name, params, imports })
+    Ok(result)
+}
+
+/// Replace every `#content` slot in a fragment body with the invocation's
+/// children. Returns whether any slot was found.
+fn fill_content_slot(nodes: &mut Vec<Node>, children: &[Node]) -> bool {
+    let mut found = false;
+    let mut i = 0;
+    while i < nodes.len() {
+        if matches!(nodes[i], Node::ContentSlot) {
+            nodes.splice(i..=i, children.iter().cloned());
+            i += children.len();
+            found = true;
+            continue;
+        }
+        match &mut nodes[i] {
+            Node::Element(el) => found |= fill_content_slot(&mut el.children, children),
+            Node::ControlFlow(ControlFlow::If { then_block, else_block, .. }) => {
+                found |= fill_content_slot(then_block, children);
+                if let Some(else_nodes) = else_block {
+                    found |= fill_content_slot(else_nodes, children);
+                }
+            }
+            Node::ControlFlow(ControlFlow::Each { body, .. }) => found |= fill_content_slot(body, children),
+            Node::ControlFlow(ControlFlow::Switch { cases, default, .. }) => {
+                for SwitchCase(_, case_nodes) in cases.iter_mut() {
+                    found |= fill_content_slot(case_nodes, children);
+                }
+                if let Some(def_nodes) = default {
+                    found |= fill_content_slot(def_nodes, children);
+                }
+            }
+            Node::Text(_) | Node::ContentSlot | Node::Flush => {}
+        }
+        i += 1;
+    }
+    found
+}
+
+/// Substitute fragment arguments into every expression in a subtree.
+fn substitute_nodes(nodes: &mut [Node], args: &HashMap<String, String>) {
+    for node in nodes {
+        match node {
+            Node::Text(t) => t.content = substitute_interpolations(&t.content, args),
+            Node::Element(el) => {
+                if let Some(expr) = &mut el.dynamic_tag {
+                    *expr = substitute_expr(expr, args);
+                }
+                for expr in &mut el.spreads {
+                    *expr = substitute_expr(expr, args);
+                }
+                if let Some(id) = &mut el.id {
+                    *id = substitute_interpolations(id, args);
+                }
+                for class in &mut el.classes {
+                    *class = substitute_interpolations(class, args);
+                }
+                for (_, value) in &mut el.styles {
+                    *value = substitute_interpolations(value, args);
+                }
+                for value in el.attributes.values_mut() {
+                    *value = substitute_interpolations(value, args);
+                }
+                for attr in &mut el.datastar {
+                    if let Some(value) = &mut attr.value {
+                        *value = substitute_interpolations(value, args);
+                    }
+                }
+                substitute_nodes(&mut el.children, args);
+            }
+            Node::ControlFlow(ControlFlow::If { condition, then_block, else_block }) => {
+                *condition = substitute_expr(condition, args);
+                substitute_nodes(then_block, args);
+                if let Some(else_nodes) = else_block {
+                    substitute_nodes(else_nodes, args);
+                }
+            }
+            Node::ControlFlow(ControlFlow::Each { key, binding, iterable, body }) => {
+                *iterable = substitute_expr(iterable, args);
+                // The loop bindings shadow parameters of the same name
+                let mut inner = args.clone();
+                inner.remove(binding.as_str());
+                if let Some(key) = key {
+                    inner.remove(key.as_str());
+                }
+                substitute_nodes(body, &inner);
+            }
+            Node::ControlFlow(ControlFlow::Switch { expr, cases, default }) => {
+                *expr = substitute_expr(expr, args);
+                for SwitchCase(_, children) in cases.iter_mut() {
+                    substitute_nodes(children, args);
+                }
+                if let Some(def_nodes) = default {
+                    substitute_nodes(def_nodes, args);
+                }
+            }
+            Node::ContentSlot | Node::Flush => {}
+        }
+    }
+}
+
+/// Substitute arguments inside the backtick-delimited parts of a string.
+fn substitute_interpolations(s: &str, args: &HashMap<String, String>) -> String {
+    s.split('`')
+        .enumerate()
+        .map(|(i, part)| if i % 2 == 1 { substitute_expr(part, args) } else { part.to_string() })
+        .collect::<Vec<_>>()
+        .join("`")
+}
+
+/// Replace identifiers in a CEL expression that name fragment parameters.
+/// Field accesses (`x.name`) and string literals are left alone.
+fn substitute_expr(expr: &str, args: &HashMap<String, String>) -> String {
+    let chars: Vec<char> = expr.chars().collect();
+    let mut result = String::with_capacity(expr.len());
+    let mut i = 0;
+
+    while i < chars.len() {
+        let c = chars[i];
+        if c == '"' || c == '\'' {
+            // Copy string literal verbatim
+            let quote = c;
+            result.push(c);
+            i += 1;
+            while i < chars.len() {
+                result.push(chars[i]);
+                if chars[i] == '\\' && i + 1 < chars.len() {
+                    i += 1;
+                    result.push(chars[i]);
+                } else if chars[i] == quote {
+                    i += 1;
+                    break;
+                }
+                i += 1;
+            }
+            continue;
+        }
+        if c.is_ascii_alphabetic() || c == '_' {
+            let start = i;
+            while i < chars.len() && (chars[i].is_ascii_alphanumeric() || chars[i] == '_') {
+                i += 1;
+            }
+            let ident: String = chars[start..i].iter().collect();
+            let prev = chars[..start].iter().rev().find(|c| !c.is_whitespace());
+            match args.get(&ident) {
+                Some(arg) if prev != Some(&'.') => result.push_str(arg),
+                _ => result.push_str(&ident),
+            }
+            continue;
+        }
+        result.push(c);
+        i += 1;
+    }
+    result
 }
 
 /// Extract component metadata from raw content (before KDL parsing)
 pub fn extract_metadata(content: &str) -> (Option<String>, Vec<Param>) {
     let name_re = Regex::new(r"//\s*name:\s*(\w+)").unwrap();
-    // param: [repeated] <type> <name> [default]
-    let param_re = Regex::new(r#"//\s*param:\s*(repeated\s+)?([\w.]+)\s+(\w+)(?:\s+(.*))?"#).unwrap();
+    // param: [repeated|optional] <type> <name> [default]
+    let param_re = Regex::new(r#"//\s*param:\s*(?:(repeated|optional)\s+)?(map\s*<\s*\w+\s*,\s*[\w.]+\s*>|[\w.]+)\s+(\w+)(?:\s+(.*))?"#).unwrap();
 
     let mut name = None;
     let mut params = Vec::new();
@@ -52,22 +447,17 @@ pub fn extract_metadata(content: &str) -> (Option<String>, Vec<Param>) {
             name = Some(caps[1].to_string());
         }
         if let Some(caps) = param_re.captures(line) {
-            let repeated = caps.get(1).is_some();
-            let type_name = caps[2].to_string();
+            let modifier = caps.get(1).map(|m| m.as_str());
+            // `map< string , string >` is kept as `map<string,string>`
+            let type_name: String = caps[2].split_whitespace().collect();
             let param_name = caps[3].to_string();
-            let default_value = caps.get(4).map(|m| {
-                let s = m.as_str().trim();
-                if s.starts_with('"') && s.ends_with('"') {
-                    s[1..s.len()-1].to_string()
-                } else {
-                    s.to_string()
-                }
-            });
-            
+            let default_value = caps.get(4).map(|m| parse_param_default(m.as_str()));
+
             params.push(Param {
                 name: param_name,
                 type_name,
-                repeated,
+                repeated: modifier == Some("repeated"),
+                optional: modifier == Some("optional"),
                 default_value,
             });
         }
@@ -76,15 +466,211 @@ pub fn extract_metadata(content: &str) -> (Option<String>, Vec<Param>) {
     (name, params)
 }
 
+/// Normalize a `// param:` default: strings are unquoted and KDL-style
+/// `#true`/`#false` become `true`/`false`. Numbers are kept as written.
+fn parse_param_default(raw: &str) -> String {
+    let s = raw.trim();
+    if s.len() >= 2 && s.starts_with('"') && s.ends_with('"') {
+        return s[1..s.len() - 1].to_string();
+    }
+    s.strip_prefix('#').filter(|b| *b == "true" || *b == "false").unwrap_or(s).to_string()
+}
+
 /// Transform with metadata extraction from raw content
 pub fn transform_with_metadata(doc: &KdlDocument, raw_content: &str) -> Result<Root, String> {
-    let mut root = transform(doc)?;
+    // The preprocessor keeps newlines where they were, so lines counted in
+    // its output are source lines
+    let lines = LineTable::new(&crate::parser::pre_parse(raw_content));
+    let mut root = transform_root(doc, is_fragment_template(raw_content), &lines)?;
     let (name, params) = extract_metadata(raw_content);
     root.name = name;
     root.params = params;
+    root.content_type = extract_content_type(raw_content);
+    root.data_message = extract_data_message(raw_content);
     Ok(root)
 }
 
+/// Whether the template declares `// fragment`: a partial, such as an SSE
+/// patch or a component body, written as bare top-level nodes without an
+/// `el` block. They render as they are, with no wrapper.
+pub fn is_fragment_template(content: &str) -> bool {
+    let re = Regex::new(r"^\s*//\s*fragment\s*$").unwrap();
+    content.lines().any(|line| re.is_match(line))
+}
+
+/// The view's declared `// content-type:` (e.g. image/svg+xml), which hosts
+/// send as the response's Content-Type instead of text/html.
+pub fn extract_content_type(content: &str) -> Option<String> {
+    let re = Regex::new(r"//\s*content-type:\s*(\S+)").unwrap();
+    content.lines().find_map(|line| re.captures(line).map(|caps| caps[1].to_string()))
+}
+
+/// The view's declared `// data:` message, for when its params match more
+/// than one message in the schema.
+pub fn extract_data_message(content: &str) -> Option<String> {
+    let re = Regex::new(r"//\s*data:\s*([\w.]+)").unwrap();
+    content.lines().find_map(|line| re.captures(line).map(|caps| caps[1].to_string()))
+}
+
+/// Opt-in passes hudlc runs over the transformed views.
+#[derive(Debug, Clone, Default)]
+pub struct TransformOptions {
+    /// Fill in accessible defaults; see `apply_semantic_defaults`. Off by
+    /// default so output matches the template exactly.
+    pub semantic_defaults: bool,
+}
+
+/// Fill in attributes authors commonly forget:
+/// - a `button` without `type` gets `type="button"`, unless it is inside a
+///   `form` (or names one with `form=`), where the HTML default of submit is
+///   usually intended;
+/// - an `a` with a click handler but no `href` gets `role="button"`.
+///
+/// Explicit attributes are never overridden. Being inside a form carries
+/// across components, so this runs over all the views together: a view used
+/// as a component inside a form counts as inside it everywhere, as does
+/// content passed to a component that puts its `#content` in a form.
+pub fn apply_semantic_defaults(views: &mut [(String, Root)]) {
+    let components: HashSet<String> = views.iter().map(|(name, _)| name.clone()).collect();
+    let mut used_in_form = HashSet::new();
+    let mut wraps_in_form = HashSet::new();
+    // Each pass can only add names, so this settles
+    loop {
+        let found = (used_in_form.len(), wraps_in_form.len());
+        for (name, root) in views.iter() {
+            let mut slot_in_form = false;
+            form_uses(&root.nodes, false, &components, &wraps_in_form, &mut used_in_form, &mut slot_in_form);
+            if used_in_form.contains(name) {
+                form_uses(&root.nodes, true, &components, &wraps_in_form, &mut used_in_form, &mut false);
+            }
+            if slot_in_form {
+                wraps_in_form.insert(name.clone());
+            }
+        }
+        if (used_in_form.len(), wraps_in_form.len()) == found {
+            break;
+        }
+    }
+    for (name, root) in views.iter_mut() {
+        semantic_defaults(&mut root.nodes, used_in_form.contains(name), &wraps_in_form);
+    }
+}
+
+/// Record the components invoked inside a form in `used_in_form`, and set
+/// `slot_in_form` if the `#content` slot is inside one.
+fn form_uses(
+    nodes: &[Node],
+    in_form: bool,
+    components: &HashSet<String>,
+    wraps_in_form: &HashSet<String>,
+    used_in_form: &mut HashSet<String>,
+    slot_in_form: &mut bool,
+) {
+    for node in nodes {
+        match node {
+            Node::Element(el) => {
+                if in_form && components.contains(&el.tag) {
+                    used_in_form.insert(el.tag.clone());
+                }
+                let in_form = in_form || el.tag == "form" || wraps_in_form.contains(&el.tag);
+                form_uses(&el.children, in_form, components, wraps_in_form, used_in_form, slot_in_form);
+            }
+            Node::ControlFlow(ControlFlow::If { then_block, else_block, .. }) => {
+                form_uses(then_block, in_form, components, wraps_in_form, used_in_form, slot_in_form);
+                if let Some(else_nodes) = else_block {
+                    form_uses(else_nodes, in_form, components, wraps_in_form, used_in_form, slot_in_form);
+                }
+            }
+            Node::ControlFlow(ControlFlow::Each { body, .. }) => {
+                form_uses(body, in_form, components, wraps_in_form, used_in_form, slot_in_form)
+            }
+            Node::ControlFlow(ControlFlow::Switch { cases, default, .. }) => {
+                for SwitchCase(_, case_nodes) in cases {
+                    form_uses(case_nodes, in_form, components, wraps_in_form, used_in_form, slot_in_form);
+                }
+                if let Some(def_nodes) = default {
+                    form_uses(def_nodes, in_form, components, wraps_in_form, used_in_form, slot_in_form);
+                }
+            }
+            Node::ContentSlot => *slot_in_form |= in_form,
+            Node::Text(_) | Node::Flush => {}
+        }
+    }
+}
+
+fn semantic_defaults(nodes: &mut [Node], in_form: bool, wraps_in_form: &HashSet<String>) {
+    for node in nodes {
+        match node {
+            Node::Element(el) => {
+                match el.tag.as_str() {
+                    "button" if !in_form && !el.attributes.contains_key("form") => {
+                        el.attributes.entry("type".to_string()).or_insert_with(|| "button".to_string());
+                    }
+                    "a" if !el.attributes.contains_key("href") && has_click_handler(el) => {
+                        el.attributes.entry("role".to_string()).or_insert_with(|| "button".to_string());
+                    }
+                    _ => {}
+                }
+                let in_form = in_form || el.tag == "form" || wraps_in_form.contains(&el.tag);
+                semantic_defaults(&mut el.children, in_form, wraps_in_form);
+            }
+            Node::ControlFlow(ControlFlow::If { then_block, else_block, .. }) => {
+                semantic_defaults(then_block, in_form, wraps_in_form);
+                if let Some(else_nodes) = else_block {
+                    semantic_defaults(else_nodes, in_form, wraps_in_form);
+                }
+            }
+            Node::ControlFlow(ControlFlow::Each { body, .. }) => semantic_defaults(body, in_form, wraps_in_form),
+            Node::ControlFlow(ControlFlow::Switch { cases, default, .. }) => {
+                for SwitchCase(_, case_nodes) in cases {
+                    semantic_defaults(case_nodes, in_form, wraps_in_form);
+                }
+                if let Some(def_nodes) = default {
+                    semantic_defaults(def_nodes, in_form, wraps_in_form);
+                }
+            }
+            Node::Text(_) | Node::ContentSlot | Node::Flush => {}
+        }
+    }
+}
+
+/// Mark every element in an `svg` or `math` subtree as foreign content.
+fn mark_foreign(nodes: &mut [Node]) {
+    for node in nodes {
+        match node {
+            Node::Element(el) => {
+                el.foreign = true;
+                mark_foreign(&mut el.children);
+            }
+            Node::ControlFlow(ControlFlow::If { then_block, else_block, .. }) => {
+                mark_foreign(then_block);
+                if let Some(else_nodes) = else_block {
+                    mark_foreign(else_nodes);
+                }
+            }
+            Node::ControlFlow(ControlFlow::Each { body, .. }) => mark_foreign(body),
+            Node::ControlFlow(ControlFlow::Switch { cases, default, .. }) => {
+                for SwitchCase(_, case_nodes) in cases {
+                    mark_foreign(case_nodes);
+                }
+                if let Some(def_nodes) = default {
+                    mark_foreign(def_nodes);
+                }
+            }
+            Node::Text(_) | Node::ContentSlot | Node::Flush => {}
+        }
+    }
+}
+
+/// Whether an element handles clicks, via `~on:click` or a plain
+/// `onclick`/`data-on-click` attribute.
+fn has_click_handler(el: &Element) -> bool {
+    el.datastar.iter().any(|d| d.name == "on:click")
+        || el.attributes.keys().any(|k| {
+            k == "onclick" || k.starts_with("data-on-click") || k.starts_with("data-on:click")
+        })
+}
+
 /// Process a style block inside an element
 /// Returns Vec<(property, value)> for the element's styles
 fn process_element_style(node: &KdlNode) -> Result<Vec<(String, String)>, String> {
@@ -98,7 +684,7 @@ fn process_element_style(node: &KdlNode) -> Result<Vec<(String, String)>, String
             let val = prop.entries().get(0)
                 .map(|e| {
                     if let Some(s) = e.value().as_string() {
-                        s.to_string()
+                        strip_unit_prefix(e, s).to_string()
                     } else if let Some(b) = e.value().as_bool() {
                         b.to_string()
                     } else if let Some(i) = e.value().as_integer() {
@@ -111,15 +697,8 @@ fn process_element_style(node: &KdlNode) -> Result<Vec<(String, String)>, String
                 })
                 .unwrap_or_default();
 
-            // Handle numeric values with _ prefix (for KDL compatibility)
-            let clean_val = if val.starts_with('_') {
-                val[1..].to_string()
-            } else {
-                val
-            };
-
-            if !prop_name.is_empty() && !clean_val.is_empty() {
-                styles.push((prop_name.to_string(), clean_val));
+            if !prop_name.is_empty() && !val.is_empty() {
+                styles.push((prop_name.to_string(), val));
             }
         }
     }
@@ -127,7 +706,386 @@ fn process_element_style(node: &KdlNode) -> Result<Vec<(String, String)>, String
     Ok(styles)
 }
 
-fn process_css(node: &KdlNode) -> Result<String, String> {
+/// HTML and SVG element names accepted by `check_known_tags`.
+const KNOWN_TAGS: &[&str] = &[
+    // HTML
+    "a", "abbr", "address", "area", "article", "aside", "audio", "b", "base", "bdi", "bdo",
+    "blockquote", "body", "br", "button", "canvas", "caption", "cite", "code", "col", "colgroup",
+    "data", "datalist", "dd", "del", "details", "dfn", "dialog", "div", "dl", "dt", "em", "embed",
+    "fieldset", "figcaption", "figure", "footer", "form", "h1", "h2", "h3", "h4", "h5", "h6",
+    "head", "header", "hgroup", "hr", "html", "i", "iframe", "img", "input", "ins", "kbd", "label",
+    "legend", "li", "link", "main", "map", "mark", "menu", "meta", "meter", "nav", "noscript",
+    "object", "ol", "optgroup", "option", "output", "p", "param", "picture", "pre", "progress", "q",
+    "rp", "rt", "ruby", "s", "samp", "script", "search", "section", "select", "slot", "small",
+    "source", "span", "strong", "style", "sub", "summary", "sup", "table", "tbody", "td",
+    "template", "textarea", "tfoot", "th", "thead", "time", "title", "tr", "track", "u", "ul",
+    "var", "video", "wbr",
+    // SVG
+    "svg", "g", "path", "circle", "ellipse", "line", "polyline", "polygon", "rect", "text",
+    "tspan", "defs", "use", "symbol", "clipPath", "mask", "pattern", "linearGradient",
+    "radialGradient", "stop", "filter", "foreignObject", "marker", "image",
+    // MathML
+    "math",
+];
+
+/// Strict mode: reject tags that are not known HTML/SVG elements or one of
+/// `components`. Custom elements (names with a dash) and anything inside an
+/// `svg` or `math` element are always allowed.
+pub fn check_known_tags(nodes: &[Node], components: &HashSet<&str>) -> Result<(), String> {
+    for node in nodes {
+        match node {
+            Node::Element(el) => {
+                let tag = el.tag.as_str();
+                let known = el.dynamic_tag.is_some() || el.foreign || tag.contains('-') || KNOWN_TAGS.contains(&tag);
+                if !known && !components.contains(tag) {
+                    let mut err = format!("unknown tag '{}'", tag);
+                    if let Some(suggestion) = closest_tag(tag, components) {
+                        err.push_str(&format!(" (did you mean '{}'?)", suggestion));
+                    }
+                    return Err(err);
+                }
+                check_known_tags(&el.children, components)?;
+            }
+            Node::ControlFlow(ControlFlow::If { then_block, else_block, .. }) => {
+                check_known_tags(then_block, components)?;
+                if let Some(else_nodes) = else_block {
+                    check_known_tags(else_nodes, components)?;
+                }
+            }
+            Node::ControlFlow(ControlFlow::Each { body, .. }) => check_known_tags(body, components)?,
+            Node::ControlFlow(ControlFlow::Switch { cases, default, .. }) => {
+                for SwitchCase(_, case_nodes) in cases {
+                    check_known_tags(case_nodes, components)?;
+                }
+                if let Some(def_nodes) = default {
+                    check_known_tags(def_nodes, components)?;
+                }
+            }
+            Node::Text(_) | Node::ContentSlot | Node::Flush => {}
+        }
+    }
+    Ok(())
+}
+
+/// Reject components that invoke each other in a cycle (`A -> B -> A`), which
+/// would recurse without end when rendered. A view invoking itself is allowed:
+/// that is how recursive components such as trees are written, and the data
+/// bounds the recursion.
+pub fn check_component_cycles(views: &[(String, Root)]) -> Result<(), String> {
+    let names: HashSet<&str> = views.iter().map(|(name, _)| name.as_str()).collect();
+    let mut graph: HashMap<&str, Vec<&str>> = HashMap::new();
+    for (name, root) in views {
+        let mut invoked = HashSet::new();
+        collect_invocations(&root.nodes, &names, &mut invoked);
+        invoked.remove(name.as_str());
+        let mut invoked: Vec<&str> = invoked.into_iter().collect();
+        invoked.sort();
+        graph.insert(name.as_str(), invoked);
+    }
+
+    let mut sorted: Vec<&str> = names.iter().copied().collect();
+    sorted.sort();
+    let mut done = HashSet::new();
+    for name in sorted {
+        let mut path = Vec::new();
+        if let Some(cycle) = find_cycle(name, &graph, &mut path, &mut done) {
+            return Err(format!("component cycle: {}", cycle.join(" -> ")));
+        }
+    }
+    Ok(())
+}
+
+fn collect_invocations<'a>(nodes: &[Node], names: &HashSet<&'a str>, invoked: &mut HashSet<&'a str>) {
+    for node in nodes {
+        match node {
+            Node::Element(el) => {
+                if let Some(name) = names.get(el.tag.as_str()) {
+                    invoked.insert(*name);
+                }
+                collect_invocations(&el.children, names, invoked);
+            }
+            Node::ControlFlow(ControlFlow::If { then_block, else_block, .. }) => {
+                collect_invocations(then_block, names, invoked);
+                if let Some(else_nodes) = else_block {
+                    collect_invocations(else_nodes, names, invoked);
+                }
+            }
+            Node::ControlFlow(ControlFlow::Each { body, .. }) => collect_invocations(body, names, invoked),
+            Node::ControlFlow(ControlFlow::Switch { cases, default, .. }) => {
+                for SwitchCase(_, case_nodes) in cases {
+                    collect_invocations(case_nodes, names, invoked);
+                }
+                if let Some(def_nodes) = default {
+                    collect_invocations(def_nodes, names, invoked);
+                }
+            }
+            Node::Text(_) | Node::ContentSlot | Node::Flush => {}
+        }
+    }
+}
+
+/// Depth-first search from `name`, returning the first cycle found as a path
+/// that starts and ends with the same component.
+fn find_cycle<'a>(
+    name: &'a str,
+    graph: &HashMap<&'a str, Vec<&'a str>>,
+    path: &mut Vec<&'a str>,
+    done: &mut HashSet<&'a str>,
+) -> Option<Vec<String>> {
+    if let Some(start) = path.iter().position(|n| *n == name) {
+        let mut cycle: Vec<String> = path[start..].iter().map(|n| n.to_string()).collect();
+        cycle.push(name.to_string());
+        return Some(cycle);
+    }
+    if done.contains(name) {
+        return None;
+    }
+
+    path.push(name);
+    for next in graph.get(name).into_iter().flatten() {
+        if let Some(cycle) = find_cycle(next, graph, path, done) {
+            return Some(cycle);
+        }
+    }
+    path.pop();
+    done.insert(name);
+    None
+}
+
+/// Check every component invocation's arguments against the params the
+/// component declares. Params that are not optional, repeated or defaulted
+/// must be passed, and each argument must name a param. All problems are
+/// reported, one per line, located by the invocation's line and column
+/// (`Page:12:9: StatCard`), or by the path of tags to it (`Page > div >
+/// StatCard`) when the views were transformed without their source.
+pub fn check_component_args(views: &[(String, Root)]) -> Result<(), String> {
+    let params: HashMap<&str, &[Param]> = views.iter()
+        .map(|(name, root)| (name.as_str(), root.params.as_slice()))
+        .collect();
+    let mut errors = Vec::new();
+    for (name, root) in views {
+        let mut path = vec![name.clone()];
+        collect_arg_errors(name, &root.nodes, &params, &mut path, &mut errors);
+    }
+    if errors.is_empty() {
+        Ok(())
+    } else {
+        Err(errors.join("\n"))
+    }
+}
+
+fn collect_arg_errors(view: &str, nodes: &[Node], params: &HashMap<&str, &[Param]>, path: &mut Vec<String>, errors: &mut Vec<String>) {
+    for node in nodes {
+        match node {
+            Node::Element(el) => {
+                path.push(el.tag.clone());
+                if let Some(declared) = params.get(el.tag.as_str()) {
+                    let at = match (el.line, el.column) {
+                        (Some(line), Some(column)) => format!("{}:{}:{}: {}", view, line, column, el.tag),
+                        _ => path.join(" > "),
+                    };
+                    // A component with an `attrs` param takes any other arg
+                    // as a forwarded attribute, and `attrs` itself is optional
+                    let attrs_param = crate::ast::attrs_param(declared);
+                    for (i, p) in declared.iter().enumerate() {
+                        let required = !p.optional && !p.repeated && p.default_value.is_none() && attrs_param != Some(i);
+                        if required && !el.attributes.contains_key(&p.name) {
+                            errors.push(format!("{}: missing required arg '{}'", at, p.name));
+                        }
+                    }
+                    let mut args: Vec<&String> = el.attributes.keys().collect();
+                    args.sort();
+                    for arg in args {
+                        if attrs_param.is_some() || declared.iter().any(|p| &p.name == arg) {
+                            continue;
+                        }
+                        let mut err = format!("{}: unknown arg '{}'", at, arg);
+                        let closest = declared.iter()
+                            .map(|p| (edit_distance(arg, &p.name), p.name.as_str()))
+                            .filter(|(d, _)| *d <= 2)
+                            .min();
+                        if let Some((_, suggestion)) = closest {
+                            err.push_str(&format!(" (did you mean '{}'?)", suggestion));
+                        }
+                        errors.push(err);
+                    }
+                }
+                collect_arg_errors(view, &el.children, params, path, errors);
+                path.pop();
+            }
+            Node::ControlFlow(ControlFlow::If { then_block, else_block, .. }) => {
+                collect_arg_errors(view, then_block, params, path, errors);
+                if let Some(else_nodes) = else_block {
+                    collect_arg_errors(view, else_nodes, params, path, errors);
+                }
+            }
+            Node::ControlFlow(ControlFlow::Each { body, .. }) => collect_arg_errors(view, body, params, path, errors),
+            Node::ControlFlow(ControlFlow::Switch { cases, default, .. }) => {
+                for SwitchCase(_, case_nodes) in cases {
+                    collect_arg_errors(view, case_nodes, params, path, errors);
+                }
+                if let Some(def_nodes) = default {
+                    collect_arg_errors(view, def_nodes, params, path, errors);
+                }
+            }
+            Node::Text(_) | Node::ContentSlot | Node::Flush => {}
+        }
+    }
+}
+
+/// Check that every `each` over a param, or a field path from one, binds
+/// what the collection holds: one item for a list, a key and value for a map.
+/// Other iterables are only known when rendering, where the interpreter
+/// reports the same mismatch. All problems are reported, one per line.
+pub fn check_each_bindings(views: &[(String, Root)], schema: &crate::proto::ProtoSchema) -> Result<(), String> {
+    let mut errors = Vec::new();
+    for (name, root) in views {
+        collect_each_errors(name, &root.nodes, &root.params, schema, &mut Vec::new(), &mut errors);
+    }
+    if errors.is_empty() {
+        Ok(())
+    } else {
+        Err(errors.join("\n"))
+    }
+}
+
+/// The message for an `each` whose bindings don't fit its collection, if
+/// they don't. `is_map` is what the iterable turned out to hold.
+pub fn each_binding_error(key: Option<&str>, binding: &str, iterable: &str, is_map: bool) -> Option<String> {
+    match (key, is_map) {
+        (Some(key), false) => Some(format!(
+            "each {} {} `{}`: `{}` is a list, so bind one item: each {} `{}`",
+            key, binding, iterable, iterable, binding, iterable
+        )),
+        (None, true) => Some(format!(
+            "each {} `{}`: `{}` is a map, so bind a key and value: each key {} `{}`",
+            binding, iterable, iterable, binding, iterable
+        )),
+        _ => None,
+    }
+}
+
+fn collect_each_errors(
+    view: &str,
+    nodes: &[Node],
+    params: &[Param],
+    schema: &crate::proto::ProtoSchema,
+    bound: &mut Vec<String>,
+    errors: &mut Vec<String>,
+) {
+    for node in nodes {
+        match node {
+            Node::Element(el) => collect_each_errors(view, &el.children, params, schema, bound, errors),
+            Node::ControlFlow(ControlFlow::If { then_block, else_block, .. }) => {
+                collect_each_errors(view, then_block, params, schema, bound, errors);
+                if let Some(else_nodes) = else_block {
+                    collect_each_errors(view, else_nodes, params, schema, bound, errors);
+                }
+            }
+            Node::ControlFlow(ControlFlow::Each { key, binding, iterable, body }) => {
+                if let Some(is_map) = collection_kind(iterable.trim(), params, schema, bound) {
+                    if let Some(err) = each_binding_error(key.as_deref(), binding, iterable.trim(), is_map) {
+                        errors.push(format!("{}: {}", view, err));
+                    }
+                }
+                let outer = bound.len();
+                bound.push(binding.clone());
+                bound.extend(key.iter().cloned());
+                collect_each_errors(view, body, params, schema, bound, errors);
+                bound.truncate(outer);
+            }
+            Node::ControlFlow(ControlFlow::Switch { cases, default, .. }) => {
+                for SwitchCase(_, case_nodes) in cases {
+                    collect_each_errors(view, case_nodes, params, schema, bound, errors);
+                }
+                if let Some(def_nodes) = default {
+                    collect_each_errors(view, def_nodes, params, schema, bound, errors);
+                }
+            }
+            Node::Text(_) | Node::ContentSlot | Node::Flush => {}
+        }
+    }
+}
+
+/// Whether `path` (`items`, `order.lines`) names a map (`Some(true)`) or a
+/// list (`Some(false)`), following message fields from a param. `None` when
+/// it is anything else or can't be told, such as a loop variable.
+fn collection_kind(path: &str, params: &[Param], schema: &crate::proto::ProtoSchema, bound: &[String]) -> Option<bool> {
+    use crate::proto::{ProtoSchema, ProtoType};
+
+    let mut segments = path.split('.');
+    let first = segments.next()?;
+    if bound.iter().any(|b| b == first) {
+        return None;
+    }
+    let param = params.iter().find(|p| p.name == first)?;
+    let (mut repeated, mut ty) = (param.repeated, ProtoSchema::parse_type(&param.type_name));
+    for segment in segments {
+        let field = match (&ty, repeated) {
+            (ProtoType::Message(message), false) => schema.messages.get(message)?.fields.iter().find(|f| f.name == segment)?,
+            _ => return None,
+        };
+        repeated = field.repeated;
+        ty = field.field_type.clone();
+    }
+    match ty {
+        _ if repeated => Some(false),
+        ProtoType::Map(_, _) => Some(true),
+        _ => None,
+    }
+}
+
+/// The known tag or component closest to `tag`, if it is a plausible typo.
+fn closest_tag<'a>(tag: &str, components: &HashSet<&'a str>) -> Option<&'a str> {
+    let max_distance = if tag.len() <= 3 { 1 } else { 2 };
+    KNOWN_TAGS.iter().copied()
+        .chain(components.iter().copied())
+        .map(|candidate| (edit_distance(&tag.to_lowercase(), &candidate.to_lowercase()), candidate))
+        .filter(|(d, _)| *d <= max_distance)
+        .min()
+        .map(|(_, candidate)| candidate)
+}
+
+/// Optimal string alignment distance: Levenshtein plus adjacent swaps, so
+/// `dvi` is one edit from `div`.
+fn edit_distance(a: &str, b: &str) -> usize {
+    let a: Vec<char> = a.chars().collect();
+    let b: Vec<char> = b.chars().collect();
+    let mut d = vec![vec![0; b.len() + 1]; a.len() + 1];
+    for (i, row) in d.iter_mut().enumerate() {
+        row[0] = i;
+    }
+    for j in 0..=b.len() {
+        d[0][j] = j;
+    }
+    for i in 1..=a.len() {
+        for j in 1..=b.len() {
+            let cost = if a[i - 1] == b[j - 1] { 0 } else { 1 };
+            d[i][j] = (d[i - 1][j] + 1).min(d[i][j - 1] + 1).min(d[i - 1][j - 1] + cost);
+            if i > 1 && j > 1 && a[i - 1] == b[j - 2] && a[i - 2] == b[j - 1] {
+                d[i][j] = d[i][j].min(d[i - 2][j - 2] + 1);
+            }
+        }
+    }
+    d[a.len()][b.len()]
+}
+
+/// Undo the preparser's `_` prefix on numbers with units (`10px` → `_10px`)
+/// in `val`, the string value of `entry`. Only bare tokens carry it: quoted
+/// strings like `"_5"`, and other values that happen to start with `_`, like
+/// `_blank`, are kept.
+fn strip_unit_prefix<'a>(entry: &KdlEntry, val: &'a str) -> &'a str {
+    let quoted = entry.format().is_some_and(|f| f.value_repr.starts_with(['"', '#']));
+    match val.strip_prefix('_') {
+        Some(rest) if !quoted && rest.starts_with(|c: char| c.is_ascii_digit() || c == '.') => rest,
+        _ => val,
+    }
+}
+
+/// Render a `css { }` block. Property names are lowercased and checked
+/// against `KNOWN_CSS_PROPERTIES`; unknown ones are kept but reported in
+/// `warnings`, since browsers add properties faster than this list does.
+fn process_css(node: &KdlNode, lines: &LineTable, warnings: &mut Vec<String>) -> String {
     let mut css_output = String::new();
     if let Some(children) = node.children() {
         for rule in children.nodes() {
@@ -138,28 +1096,146 @@ fn process_css(node: &KdlNode) -> Result<String, String> {
 
             if let Some(props) = rule.children() {
                 for prop in props.nodes() {
-                    let prop_name = prop.name().value();
-                    let val = prop.entries().get(0)
-                        .and_then(|e| e.value().as_string())
-                        .unwrap_or("");
-
-                    // Handle numeric values with _ prefix
-                    let clean_val = if val.starts_with('_') {
-                        &val[1..]
-                    } else {
-                        val
-                    };
+                    let at = lines.at(prop);
+                    let prop_name = css_property_name(prop.name().value(), &at, warnings);
+                    let val = css_value(prop, &at, warnings);
 
-                    css_output.push_str(&format!("{}: {}; ", prop_name, clean_val));
+                    css_output.push_str(&format!("{}: {}; ", prop_name, val));
                 }
             }
 
             css_output.push_str("}\n");
         }
     }
-    Ok(css_output)
+    css_output
+}
+
+/// Lowercase a CSS property name, warning if it isn't a known property.
+/// Custom properties (`--brand`) are case-sensitive and vendor-prefixed ones
+/// (`-webkit-box-orient`) are open-ended, so neither is checked. Warnings
+/// start with `at`, the property's `line N: ` prefix.
+fn css_property_name(name: &str, at: &str, warnings: &mut Vec<String>) -> String {
+    if name.starts_with("--") {
+        return name.to_string();
+    }
+    let lower = name.to_ascii_lowercase();
+    if !lower.starts_with('-') && !KNOWN_CSS_PROPERTIES.contains(&lower.as_str()) {
+        let mut warning = format!("{}unknown CSS property '{}'", at, name);
+        if let Some(suggestion) = closest_css_property(&lower) {
+            warning.push_str(&format!(" (did you mean '{}'?)", suggestion));
+        }
+        warnings.push(warning);
+    }
+    lower
 }
 
+/// The known CSS property closest to `name`, if it is a plausible typo.
+fn closest_css_property(name: &str) -> Option<&'static str> {
+    KNOWN_CSS_PROPERTIES.iter().copied()
+        .map(|candidate| (edit_distance(name, candidate), candidate))
+        .filter(|(d, _)| *d <= 2)
+        .min()
+        .map(|(_, candidate)| candidate)
+}
+
+/// A declaration's value: its arguments joined by spaces, so `margin 0 auto`
+/// needs no quotes. A unit written apart from its number (`1.2 rem`) would
+/// be invalid CSS, so it is joined back on with a warning.
+fn css_value(prop: &KdlNode, at: &str, warnings: &mut Vec<String>) -> String {
+    let mut parts: Vec<String> = Vec::new();
+    let mut after_number = false;
+    for entry in prop.entries() {
+        let value = entry.value();
+        if let Some(s) = value.as_string() {
+            if after_number && CSS_UNITS.contains(&s) {
+                let number = parts.pop().unwrap_or_default();
+                warnings.push(format!(
+                    "{}space between '{}' and its unit '{}' in '{}'; using {}{}",
+                    at, number, s, prop.name().value(), number, s
+                ));
+                parts.push(format!("{}{}", number, s));
+            } else {
+                parts.push(strip_unit_prefix(entry, s).to_string());
+            }
+            after_number = false;
+        } else if let Some(i) = value.as_integer() {
+            parts.push(i.to_string());
+            after_number = true;
+        } else if let Some(f) = value.as_float() {
+            parts.push(f.to_string());
+            after_number = true;
+        }
+    }
+    parts.join(" ")
+}
+
+/// CSS units `css_value` rejoins with a number written apart from them.
+const CSS_UNITS: &[&str] = &[
+    "px", "em", "rem", "%", "ch", "ex", "lh", "vh", "vw", "vmin", "vmax", "dvh", "dvw", "svh",
+    "svw", "lvh", "lvw", "pt", "pc", "cm", "mm", "in", "fr", "s", "ms", "deg", "rad", "grad",
+    "turn", "dpi", "dppx",
+];
+
+/// CSS property names accepted without a warning by `process_css`.
+const KNOWN_CSS_PROPERTIES: &[&str] = &[
+    "accent-color", "align-content", "align-items", "align-self", "all", "animation",
+    "animation-delay", "animation-direction", "animation-duration", "animation-fill-mode",
+    "animation-iteration-count", "animation-name", "animation-play-state",
+    "animation-timing-function", "appearance", "aspect-ratio", "backdrop-filter",
+    "backface-visibility", "background", "background-attachment", "background-blend-mode",
+    "background-clip", "background-color", "background-image", "background-origin",
+    "background-position", "background-position-x", "background-position-y", "background-repeat",
+    "background-size", "block-size", "border", "border-block", "border-block-end",
+    "border-block-start", "border-bottom", "border-bottom-color", "border-bottom-left-radius",
+    "border-bottom-right-radius", "border-bottom-style", "border-bottom-width", "border-collapse",
+    "border-color", "border-image", "border-inline", "border-inline-end", "border-inline-start",
+    "border-left", "border-left-color", "border-left-style", "border-left-width", "border-radius",
+    "border-right", "border-right-color", "border-right-style", "border-right-width",
+    "border-spacing", "border-style", "border-top", "border-top-color", "border-top-left-radius",
+    "border-top-right-radius", "border-top-style", "border-top-width", "border-width", "bottom",
+    "box-decoration-break", "box-shadow", "box-sizing", "break-after", "break-before",
+    "break-inside", "caption-side", "caret-color", "clear", "clip", "clip-path", "color",
+    "color-scheme", "column-count", "column-fill", "column-gap", "column-rule", "column-span",
+    "column-width", "columns", "contain", "container", "container-name", "container-type",
+    "content", "content-visibility", "counter-increment", "counter-reset", "counter-set",
+    "cursor", "direction", "display", "empty-cells", "fill", "fill-opacity", "filter", "flex",
+    "flex-basis", "flex-direction", "flex-flow", "flex-grow", "flex-shrink", "flex-wrap", "float",
+    "font", "font-display", "font-family", "font-feature-settings", "font-kerning", "font-size",
+    "font-size-adjust", "font-stretch", "font-style", "font-variant", "font-variant-numeric",
+    "font-variation-settings", "font-weight", "gap", "grid", "grid-area", "grid-auto-columns",
+    "grid-auto-flow", "grid-auto-rows", "grid-column", "grid-column-end", "grid-column-start",
+    "grid-row", "grid-row-end", "grid-row-start", "grid-template", "grid-template-areas",
+    "grid-template-columns", "grid-template-rows", "height", "hyphens", "image-rendering",
+    "inline-size", "inset", "inset-block", "inset-inline", "isolation", "justify-content",
+    "justify-items", "justify-self", "left", "letter-spacing", "line-break", "line-clamp",
+    "line-height", "list-style", "list-style-image", "list-style-position", "list-style-type",
+    "margin", "margin-block", "margin-block-end", "margin-block-start", "margin-bottom",
+    "margin-inline", "margin-inline-end", "margin-inline-start", "margin-left", "margin-right",
+    "margin-top", "mask", "mask-image", "max-block-size", "max-height", "max-inline-size",
+    "max-width", "min-block-size", "min-height", "min-inline-size", "min-width", "mix-blend-mode",
+    "object-fit", "object-position", "opacity", "order", "orphans", "outline", "outline-color",
+    "outline-offset", "outline-style", "outline-width", "overflow", "overflow-anchor",
+    "overflow-wrap", "overflow-x", "overflow-y", "overscroll-behavior", "padding",
+    "padding-block", "padding-block-end", "padding-block-start", "padding-bottom",
+    "padding-inline", "padding-inline-end", "padding-inline-start", "padding-left",
+    "padding-right", "padding-top", "page-break-after", "page-break-before", "page-break-inside",
+    "perspective", "perspective-origin", "place-content", "place-items", "place-self",
+    "pointer-events", "position", "quotes", "resize", "right", "rotate", "row-gap", "scale",
+    "scroll-behavior", "scroll-margin", "scroll-margin-top", "scroll-padding",
+    "scroll-padding-top", "scroll-snap-align", "scroll-snap-type", "scrollbar-color",
+    "scrollbar-gutter", "scrollbar-width", "shape-outside", "stroke", "stroke-dasharray",
+    "stroke-dashoffset", "stroke-linecap", "stroke-linejoin", "stroke-opacity", "stroke-width",
+    "tab-size", "table-layout", "text-align", "text-align-last", "text-decoration",
+    "text-decoration-color", "text-decoration-line", "text-decoration-style",
+    "text-decoration-thickness", "text-indent", "text-overflow", "text-rendering", "text-shadow",
+    "text-transform", "text-underline-offset", "text-wrap", "top", "touch-action", "transform",
+    "transform-origin", "transform-style", "transition", "transition-delay",
+    "transition-duration", "transition-property", "transition-timing-function", "translate",
+    "unicode-bidi", "user-select", "vertical-align", "view-transition-name", "visibility",
+    "white-space", "widows", "width", "will-change", "word-break", "word-spacing", "word-wrap",
+    "writing-mode", "z-index", "zoom",
+];
+
 fn parse_selector(input: &str) -> (String, Option<String>, Vec<String>, Vec<DatastarAttr>) {
     let mut tag = "div".to_string();
     let mut id = None;
@@ -235,7 +1311,47 @@ fn parse_selector(input: &str) -> (String, Option<String>, Vec<String>, Vec<Data
     (tag, id, classes, datastar)
 }
 
-fn transform_block(nodes: &[KdlNode]) -> Result<Vec<Node>, String> {
+/// Build an `if` from its condition and node, consuming the `else` that
+/// follows it in `rest`, if any. An `else if` becomes an `if` alone in the
+/// else block, so a chain nests; codegen emits it as `else if`.
+fn transform_if<'a>(
+    condition: &str,
+    node: &KdlNode,
+    rest: &mut std::iter::Peekable<std::slice::Iter<'a, KdlNode>>,
+    lines: &LineTable,
+) -> Result<Node, String> {
+    let then_block = if let Some(children) = node.children() {
+        transform_block(children.nodes(), lines)?
+    } else {
+        Vec::new()
+    };
+
+    let mut else_block = None;
+    if let Some(else_node) = rest.next_if(|n| n.name().value() == "__hudl_else") {
+        if is_else_if(else_node) {
+            let else_condition = else_node.entries().get(1)
+                .and_then(|e| e.value().as_string())
+                .ok_or("else if node missing condition")?;
+            else_block = Some(vec![transform_if(else_condition, else_node, rest, lines)?]);
+        } else if let Some(children) = else_node.children() {
+            else_block = Some(transform_block(children.nodes(), lines)?);
+        }
+    }
+
+    Ok(Node::ControlFlow(ControlFlow::If {
+        condition: condition.trim_matches('`').to_string(),
+        then_block,
+        else_block,
+    }))
+}
+
+/// Whether an `else` node is an `else if`, which the preprocessor leaves as
+/// `__hudl_else __hudl_if <condition>`.
+fn is_else_if(node: &KdlNode) -> bool {
+    node.entries().get(0).and_then(|e| e.value().as_string()) == Some("__hudl_if")
+}
+
+fn transform_block(nodes: &[KdlNode], lines: &LineTable) -> Result<Vec<Node>, String> {
     let mut result = Vec::new();
     let mut iter = nodes.iter().peekable();
 
@@ -245,59 +1361,33 @@ fn transform_block(nodes: &[KdlNode]) -> Result<Vec<Node>, String> {
             "__hudl_if" => {
                 let condition = node.entries().get(0)
                     .and_then(|e| e.value().as_string())
-                    .ok_or("if node missing condition")?
-                    .to_string();
-                
-                let clean_cond = condition.trim_matches('`').to_string();
-
-                let then_block = if let Some(children) = node.children() {
-                    transform_block(children.nodes())?
-                } else {
-                    Vec::new()
-                };
+                    .ok_or("if node missing condition")?;
 
-                let mut else_block = None;
-                let mut has_else = false;
-                if let Some(next_node) = iter.peek() {
-                    if next_node.name().value() == "__hudl_else" {
-                        has_else = true;
-                    }
-                }
-
-                if has_else {
-                    let next_node = iter.next().unwrap(); // consume else
-                    if let Some(children) = next_node.children() {
-                        else_block = Some(transform_block(children.nodes())?);
-                    }
-                }
-
-                result.push(Node::ControlFlow(ControlFlow::If {
-                    condition: clean_cond,
-                    then_block,
-                    else_block,
-                }));
+                result.push(transform_if(condition, node, &mut iter, lines)?);
             }
             "__hudl_each" => {
                 // New syntax: each binding `iterable` { ... }
-                // Two positional arguments: binding name and CEL expression
+                // Two positional arguments: binding name and CEL expression,
+                // or three for a map: key name, value name and expression
                 let args: Vec<String> = node.entries().iter()
                     .filter_map(|e| if e.name().is_none() { e.value().as_string().map(|s| s.to_string()) } else { None })
                     .collect();
 
-                if args.len() != 2 {
-                    return Err("each expects 2 arguments: binding `iterable`".to_string());
-                }
-
-                let binding = args[0].clone();
-                let iterable = args[1].trim_matches('`').to_string();
+                let (key, binding, iterable) = match args.as_slice() {
+                    [binding, iterable] => (None, binding.clone(), iterable),
+                    [key, value, iterable] => (Some(key.clone()), value.clone(), iterable),
+                    _ => return Err("each expects 2 arguments: binding `iterable` (or key value `map` for a map)".to_string()),
+                };
+                let iterable = iterable.trim_matches('`').to_string();
 
                 let body = if let Some(children) = node.children() {
-                    transform_block(children.nodes())?
+                    transform_block(children.nodes(), lines)?
                 } else {
                     Vec::new()
                 };
 
                 result.push(Node::ControlFlow(ControlFlow::Each {
+                    key,
                     binding,
                     iterable,
                     body,
@@ -317,23 +1407,33 @@ fn transform_block(nodes: &[KdlNode]) -> Result<Vec<Node>, String> {
                     for child in children.nodes() {
                         match child.name().value() {
                             "__hudl_case" => {
-                                // Pattern can be a bare identifier (enum value) or string
-                                let pattern = child.entries().get(0)
-                                    .and_then(|e| e.value().as_string())
-                                    .ok_or("case missing pattern")?
-                                    .to_string();
+                                // Patterns can be bare identifiers (enum values) or strings
+                                let patterns: Vec<String> = child.entries().iter()
+                                    .filter_map(|e| e.value().as_string())
+                                    .map(str::to_string)
+                                    .collect();
+                                if patterns.is_empty() {
+                                    return Err("case missing pattern".to_string());
+                                }
+                                for (i, pattern) in patterns.iter().enumerate() {
+                                    let repeated = patterns[..i].contains(pattern)
+                                        || cases.iter().any(|case: &SwitchCase| case.0.contains(pattern));
+                                    if repeated {
+                                        return Err(format!("duplicate case '{}' in switch `{}`", pattern, expr));
+                                    }
+                                }
 
                                 let case_children = if let Some(block) = child.children() {
-                                    transform_block(block.nodes())?
+                                    transform_block(block.nodes(), lines)?
                                 } else {
                                     Vec::new()
                                 };
 
-                                cases.push(SwitchCase(pattern, case_children));
+                                cases.push(SwitchCase(patterns, case_children));
                             }
                             "__hudl_default" => {
                                 let def_children = if let Some(block) = child.children() {
-                                    transform_block(block.nodes())?
+                                    transform_block(block.nodes(), lines)?
                                 } else {
                                     Vec::new()
                                 };
@@ -356,22 +1456,49 @@ fn transform_block(nodes: &[KdlNode]) -> Result<Vec<Node>, String> {
             "__hudl_content" => {
                 result.push(Node::ContentSlot);
             }
+            "flush" => {
+                if node.entries().iter().next().is_some() || node.children().is_some() {
+                    return Err("flush takes no arguments or children".to_string());
+                }
+                result.push(Node::Flush);
+            }
+            "__hudl_text" => {
+                // A string on its own line: text, kept in source order among the children
+                let content = node_arg(node).ok_or("text node missing content")?;
+                result.push(Node::Text(Text { content, raw: false }));
+            }
+            "raw" => {
+                // Trusted block: disable escaping of interpolations in the subtree
+                if let Some(children) = node.children() {
+                    let mut raw_nodes = transform_block(children.nodes(), lines)?;
+                    mark_raw(&mut raw_nodes);
+                    result.append(&mut raw_nodes);
+                }
+            }
             _ => {
-                result.push(transform_node(node)?);
+                result.push(transform_node(node, lines)?);
             }
         }
     }
     Ok(result)
 }
 
-fn transform_node(node: &KdlNode) -> Result<Node, String> {
+fn transform_node(node: &KdlNode, lines: &LineTable) -> Result<Node, String> {
     let name = node.name().value();
+    let line = lines.line(node.name().span().offset());
+    let column = lines.column(node.name().span().offset());
 
     let (mut tag, mut id, mut classes, mut datastar) = parse_selector(name);
+    // `el tag=`expr`` computes its tag name when rendered
+    let is_dynamic = tag == "__hudl_el";
+    let mut dynamic_tag = None;
+    let mut spreads = Vec::new();
 
     let mut attributes = HashMap::new();
     let mut children = Vec::new();
     let mut styles = Vec::new();
+    let mut fragment = None;
+    let mut condition = None;
 
     let mut is_special_link = false;
     let mut special_attr = String::new();
@@ -395,7 +1522,10 @@ fn transform_node(node: &KdlNode) -> Result<Node, String> {
     for entry in node.entries() {
         if let Some(prop_name) = entry.name() {
             let key = prop_name.value();
-            let val = entry.value().as_string().unwrap_or_default().to_string();
+            let mut val = strip_unit_prefix(entry, entry.value().as_string().unwrap_or_default()).to_string();
+            if is_client_expr(entry) {
+                val = escape_client_expr(&val);
+            }
 
             // Check for inline tilde attributes: ~on:click="expr"
             if key.starts_with('~') {
@@ -404,7 +1534,21 @@ fn transform_node(node: &KdlNode) -> Result<Node, String> {
             } else {
                 match key {
                     "id" => id = Some(val),
+                    "fragment" => fragment = Some(val),
+                    // The preprocessor rewrites the `if` keyword, so `if=` arrives as `__hudl_if`
+                    "__hudl_if" | "if" => condition = Some(val.trim_matches('`').to_string()),
                     "class" => classes.extend(val.split_whitespace().map(|s| s.to_string())),
+                    "tag" if is_dynamic => {
+                        if !(val.len() > 1 && val.starts_with('`') && val.ends_with('`')) {
+                            return Err(format!(
+                                "el tag= takes an expression like tag=`heading_tag`, got {:?}; write a fixed tag name as the node name",
+                                val
+                            ));
+                        }
+                        dynamic_tag = Some(val.trim_matches('`').to_string());
+                    }
+                    // The preprocessor rewrites `...`attrs`` to `__hudl_spread`
+                    "__hudl_spread" => spreads.push(val.trim_matches('`').to_string()),
                     _ => { attributes.insert(key.to_string(), val); }
                 }
             }
@@ -414,7 +1558,7 @@ fn transform_node(node: &KdlNode) -> Result<Node, String> {
                 if is_special_link && first_arg {
                     attributes.insert(special_attr.clone(), v.to_string());
                 } else {
-                    children.push(Node::Text(Text { content: v.to_string() }));
+                    children.push(Node::Text(Text { content: v.to_string(), raw: false }));
                 }
             }
             first_arg = false;
@@ -431,18 +1575,28 @@ fn transform_node(node: &KdlNode) -> Result<Node, String> {
                     styles.append(&mut process_element_style(child)?);
                 }
                 "~" => {
-                    // Tilde block - extract datastar attributes
-                    datastar.append(&mut process_tilde_block(child)?);
+                    // Tilde block - extract datastar and structured data-* attributes
+                    datastar.append(&mut process_tilde_block(child, &mut attributes)?);
                 }
                 _ => {
                     non_special_nodes.push(child.clone());
                 }
             }
         }
-        children.append(&mut transform_block(&non_special_nodes)?);
+        children.append(&mut transform_block(&non_special_nodes, lines)?);
+    }
+    if crate::ast::FOREIGN_ROOTS.contains(&tag.as_str()) {
+        mark_foreign(&mut children);
     }
 
-    Ok(Node::Element(Element {
+    if is_dynamic {
+        if dynamic_tag.is_none() {
+            return Err("el inside a view needs a tag expression, e.g. el tag=`heading_tag`".to_string());
+        }
+        tag = "el".to_string();
+    }
+
+    let element = Node::Element(Element {
         tag,
         id,
         classes,
@@ -450,7 +1604,63 @@ fn transform_node(node: &KdlNode) -> Result<Node, String> {
         children,
         styles,
         datastar,
-    }))
+        fragment,
+        line,
+        column,
+        dynamic_tag,
+        spreads,
+        foreign: false,
+    });
+
+    // Element-level `if=` wraps the element (and its children) in a conditional.
+    // Inside an `each` body this is evaluated once per item.
+    match condition {
+        Some(condition) => Ok(Node::ControlFlow(ControlFlow::If {
+            condition,
+            then_block: vec![element],
+            else_block: None,
+        })),
+        None => Ok(element),
+    }
+}
+
+/// Mark every text node in a subtree as raw (unescaped).
+fn mark_raw(nodes: &mut [Node]) {
+    for node in nodes {
+        match node {
+            Node::Text(t) => t.raw = true,
+            Node::Element(el) => mark_raw(&mut el.children),
+            Node::ControlFlow(ControlFlow::If { then_block, else_block, .. }) => {
+                mark_raw(then_block);
+                if let Some(else_nodes) = else_block {
+                    mark_raw(else_nodes);
+                }
+            }
+            Node::ControlFlow(ControlFlow::Each { body, .. }) => mark_raw(body),
+            Node::ControlFlow(ControlFlow::Switch { cases, default, .. }) => {
+                for SwitchCase(_, children) in cases.iter_mut() {
+                    mark_raw(children);
+                }
+                if let Some(def_nodes) = default {
+                    mark_raw(def_nodes);
+                }
+            }
+            Node::ContentSlot | Node::Flush => {}
+        }
+    }
+}
+
+/// Whether an entry's value is annotated `(js)`: a client-side expression,
+/// emitted as written rather than evaluated on the server.
+fn is_client_expr(entry: &KdlEntry) -> bool {
+    entry.ty().is_some_and(|ty| ty.value() == "js")
+}
+
+/// Encode a client-side expression for output as an attribute value. Its
+/// backticks (JS template literals) become `&#96;` so no later stage reads
+/// them as CEL; the browser decodes them back before Datastar sees the value.
+fn escape_client_expr(expr: &str) -> String {
+    expr.replace('&', "&amp;").replace('`', "&#96;")
 }
 
 /// Parse an inline tilde attribute like "on:click~once~prevent" with value "expr"
@@ -473,12 +1683,26 @@ fn parse_attr_name_and_modifiers(input: &str) -> (String, Vec<String>) {
 }
 
 /// Process a tilde block: ~ { on:click "expr"; show $visible; .active $cond }
-fn process_tilde_block(node: &KdlNode) -> Result<Vec<DatastarAttr>, String> {
+///
+/// Entries named `data-*` with a child block are structured values: the block is
+/// JSON-encoded into a plain attribute, e.g. `data-signals { count 0 }` becomes
+/// `data-signals="{&quot;count&quot;:0}"`.
+fn process_tilde_block(
+    node: &KdlNode,
+    attributes: &mut HashMap<String, String>,
+) -> Result<Vec<DatastarAttr>, String> {
     let mut attrs = Vec::new();
 
     if let Some(children) = node.children() {
         for child in children.nodes() {
             let name_raw = child.name().value();
+
+            if name_raw.starts_with("data-") && child.children().is_some() {
+                let json = serde_json::to_string(&kdl_block_to_json(child)?)
+                    .map_err(|e| format!("Failed to encode {} as JSON: {}", name_raw, e))?;
+                attributes.insert(name_raw.to_string(), json);
+                continue;
+            }
             let (name, modifiers) = parse_attr_name_and_modifiers(name_raw);
 
             // Get the value (first positional argument) - handle all types
@@ -488,7 +1712,7 @@ fn process_tilde_block(node: &KdlNode) -> Result<Vec<DatastarAttr>, String> {
                 .map(|e| {
                     let v = e.value();
                     if let Some(s) = v.as_string() {
-                        s.to_string()
+                        if is_client_expr(e) { escape_client_expr(s) } else { s.to_string() }
                     } else if let Some(i) = v.as_integer() {
                         i.to_string()
                     } else if let Some(f) = v.as_float() {
@@ -511,3 +1735,53 @@ fn process_tilde_block(node: &KdlNode) -> Result<Vec<DatastarAttr>, String> {
 
     Ok(attrs)
 }
+
+/// Convert a KDL node into a JSON value for structured `data-*` attributes.
+///
+/// - A node with a child block becomes an object keyed by child name, or an
+///   array if every child is named `-`.
+/// - A node with a single argument becomes that scalar; several arguments
+///   become an array.
+fn kdl_block_to_json(node: &KdlNode) -> Result<serde_json::Value, String> {
+    if let Some(children) = node.children() {
+        let nodes = children.nodes();
+        if !nodes.is_empty() && nodes.iter().all(|n| n.name().value() == "-") {
+            let items = nodes.iter().map(kdl_block_to_json).collect::<Result<Vec<_>, _>>()?;
+            return Ok(serde_json::Value::Array(items));
+        }
+
+        let mut map = serde_json::Map::new();
+        for child in nodes {
+            // The preparser prefixes Hudl keywords; restore the original key
+            let key = child.name().value();
+            let key = key.strip_prefix("__hudl_").unwrap_or(key);
+            map.insert(key.to_string(), kdl_block_to_json(child)?);
+        }
+        return Ok(serde_json::Value::Object(map));
+    }
+
+    let mut values: Vec<serde_json::Value> = node.entries().iter()
+        .filter(|e| e.name().is_none())
+        .map(|e| kdl_value_to_json(e.value()))
+        .collect();
+
+    match values.len() {
+        0 => Ok(serde_json::Value::Null),
+        1 => Ok(values.remove(0)),
+        _ => Ok(serde_json::Value::Array(values)),
+    }
+}
+
+fn kdl_value_to_json(value: &kdl::KdlValue) -> serde_json::Value {
+    if let Some(s) = value.as_string() {
+        serde_json::Value::String(s.to_string())
+    } else if let Some(i) = value.as_integer() {
+        serde_json::Value::from(i as i64)
+    } else if let Some(f) = value.as_float() {
+        serde_json::Value::from(f)
+    } else if let Some(b) = value.as_bool() {
+        serde_json::Value::Bool(b)
+    } else {
+        serde_json::Value::Null
+    }
+}
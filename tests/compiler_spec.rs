@@ -44,6 +44,80 @@ el {
     assert_eq!(span.classes, vec!["text-bold".to_string()]);
 }
 
+#[test]
+fn test_hyphenated_custom_element_tags() {
+    let input = r#"
+el {
+    div {
+        my-widget
+        my-widget.active
+        my-widget#main
+        sl-button#save.primary.large "Save"
+        el-dialog.modal
+    }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform(&doc).expect("Failed to transform");
+    let div = root.nodes[0].as_element().unwrap();
+    let child = |i: usize| div.children[i].as_element().unwrap();
+
+    assert_eq!(child(0).tag, "my-widget");
+    assert!(child(0).classes.is_empty());
+    assert_eq!(child(0).id, None);
+
+    assert_eq!(child(1).tag, "my-widget");
+    assert_eq!(child(1).classes, vec!["active".to_string()]);
+
+    assert_eq!(child(2).tag, "my-widget");
+    assert_eq!(child(2).id, Some("main".to_string()));
+
+    assert_eq!(child(3).tag, "sl-button");
+    assert_eq!(child(3).id, Some("save".to_string()));
+    assert_eq!(child(3).classes, vec!["primary".to_string(), "large".to_string()]);
+
+    // A keyword prefix doesn't make it a keyword
+    assert_eq!(child(4).tag, "el-dialog");
+    assert_eq!(child(4).classes, vec!["modal".to_string()]);
+
+    let rust_code = codegen_cel::generate_wasm_lib_cel(vec![("Widgets".to_string(), root)], &ProtoSchema::default())
+        .expect("Codegen failed");
+    assert!(rust_code.contains("<my-widget"));
+    assert!(rust_code.contains("</sl-button>"));
+}
+
+#[test]
+fn test_text_between_children_keeps_source_order() {
+    let input = r#"
+el {
+    p "Hi, " {
+        b "you"
+        " and "
+        i `name`
+        "!"
+    }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform(&doc).expect("Failed to transform");
+    let p = root.nodes[0].as_element().unwrap();
+
+    assert_eq!(p.children.len(), 5);
+    assert_eq!(p.children[0].as_text().unwrap().content, "Hi, ");
+    assert_eq!(p.children[1].as_element().unwrap().tag, "b");
+    assert_eq!(p.children[2].as_text().unwrap().content, " and ");
+    assert_eq!(p.children[3].as_element().unwrap().tag, "i");
+    assert_eq!(p.children[4].as_text().unwrap().content, "!");
+
+    let rust_code = codegen_cel::generate_wasm_lib_cel(vec![("Greeting".to_string(), root)], &ProtoSchema::default())
+        .expect("Codegen failed");
+    let pos = |needle: &str| rust_code.find(needle).unwrap_or_else(|| panic!("missing {}", needle));
+    let (b, and, i) = (pos("r.push_str(\"<b\")"), pos("r.push_str(\" and \")"), pos("r.push_str(\"<i\")"));
+    assert!(b < and && and < i, "Code: {}", rust_code);
+}
+
 #[test]
 fn test_attributes() {
     let input = r#"
@@ -61,6 +135,40 @@ el {
     assert_eq!(el.attributes.get("placeholder").unwrap(), "Enter username");
 }
 
+#[test]
+fn test_attribute_values_preserved_exactly() {
+    let input = r#"
+el {
+    html lang=en {
+        meta charset=utf-8
+        a target=_blank href="/docs" "Docs"
+        img width=10px
+        input value="_5"
+    }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform(&doc).expect("Failed to transform");
+
+    let html = root.nodes[0].as_element().unwrap();
+    assert_eq!(html.attributes.get("lang").unwrap(), "en");
+
+    let meta = html.children[0].as_element().unwrap();
+    assert_eq!(meta.attributes.get("charset").unwrap(), "utf-8");
+
+    let a = html.children[1].as_element().unwrap();
+    assert_eq!(a.attributes.get("target").unwrap(), "_blank");
+
+    // Numbers with units lose the preparser's `_` prefix
+    let img = html.children[2].as_element().unwrap();
+    assert_eq!(img.attributes.get("width").unwrap(), "10px");
+
+    // but a quoted value is kept as written
+    let input = html.children[3].as_element().unwrap();
+    assert_eq!(input.attributes.get("value").unwrap(), "_5");
+}
+
 #[test]
 fn test_element_style_transformation() {
     let input = r#"
@@ -103,6 +211,35 @@ el {
     assert!(css.contains("#header { border-bottom: 1px solid black; }"));
 }
 
+#[test]
+fn test_css_properties_are_checked_and_normalized() {
+    let input = r##"
+el {
+    css {
+        .card {
+            Font-Size 1.2 rem
+            colr red
+            margin 0 auto
+            --Brand-Color "#333"
+        }
+    }
+}
+    "##;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform_with_metadata(&doc, input).expect("Failed to transform");
+
+    let css = root.css.expect("CSS should be extracted");
+    assert_eq!(css, ".card { font-size: 1.2rem; colr: red; margin: 0 auto; --Brand-Color: #333; }\n");
+    assert_eq!(
+        root.warnings,
+        vec![
+            "line 5: space between '1.2' and its unit 'rem' in 'Font-Size'; using 1.2rem".to_string(),
+            "line 6: unknown CSS property 'colr' (did you mean 'color'?)".to_string(),
+        ]
+    );
+}
+
 #[test]
 fn test_control_flow_if_with_cel() {
     let input = r#"
@@ -146,6 +283,172 @@ el {
     }
 }
 
+#[test]
+fn test_control_flow_each_over_method_call() {
+    let input = r#"
+el {
+    each feature `GetFeatures()` {
+        li `feature.name`
+    }
+    each feature `data.ActiveFeatures()` {
+        li `feature.name`
+    }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform(&doc).expect("Failed to transform");
+
+    // The collection expression is kept verbatim, call parentheses included
+    let iterables: Vec<&str> = root.nodes.iter().map(|node| {
+        match node.as_control_flow().expect("Should be control flow") {
+            hudlc::ast::ControlFlow::Each { binding, iterable, .. } => {
+                assert_eq!(binding, "feature");
+                iterable.as_str()
+            }
+            _ => panic!("Expected Each node"),
+        }
+    }).collect();
+    assert_eq!(iterables, vec!["GetFeatures()", "data.ActiveFeatures()"]);
+}
+
+#[test]
+fn test_control_flow_each_over_map() {
+    let input = r#"
+el {
+    each sku qty `stock` {
+        li "`sku`: `qty`"
+    }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform(&doc).expect("Failed to transform");
+
+    let cf = root.nodes[0].as_control_flow().expect("Should be control flow");
+    if let hudlc::ast::ControlFlow::Each { key, binding, iterable, .. } = cf {
+        assert_eq!(key.as_deref(), Some("sku"));
+        assert_eq!(binding, "qty");
+        assert_eq!(iterable, "stock");
+    } else {
+        panic!("Expected Each node");
+    }
+
+    // Compiled views iterate the map in sorted key order
+    let rust_code = codegen_cel::generate_wasm_lib_cel(vec![("Stock".to_string(), root)], &ProtoSchema::default())
+        .expect("Codegen failed");
+    assert!(rust_code.contains("fn sorted_entries(m: &CelMap)"));
+    assert!(rust_code.contains("entries.sort_by(|a, b| a.0.cmp(b.0));"));
+    assert!(
+        rust_code.contains(r#"if let CelValue::Map(map) = cel_eval("stock", &ctx) {"#),
+        "Code: {}",
+        rust_code
+    );
+    assert!(rust_code.contains("for (_idx, (_key, _item)) in sorted_entries(&map).into_iter().enumerate() {"));
+    assert!(rust_code.contains(r#"add_variable("sku", CelValue::from(_key.clone()))"#));
+}
+
+#[test]
+fn test_each_bindings_checked_against_collection() {
+    let input = r#"
+// name: Stock
+// param: map<string, int32> stock
+// param: repeated string skus
+el {
+    each qty `stock` {
+        li `qty`
+    }
+    each i sku `skus` {
+        li `sku`
+    }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform_with_metadata(&doc, input).expect("Failed to transform");
+    let err = codegen_cel::generate_wasm_lib_cel(vec![("Stock".to_string(), root)], &ProtoSchema::default()).unwrap_err();
+    assert_eq!(
+        err,
+        "Stock: each qty `stock`: `stock` is a map, so bind a key and value: each key qty `stock`\n\
+         Stock: each i sku `skus`: `skus` is a list, so bind one item: each sku `skus`"
+    );
+}
+
+#[test]
+fn test_each_attribute_references_loop_variable() {
+    let input = r#"
+// param: string title
+el {
+    each row `rows` {
+        tr data-id=`row.id` title=`title` {
+            each cell `row.cells` {
+                td data-row=`row.id` data-col=`cell_idx` `cell`
+            }
+        }
+    }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform(&doc).expect("Failed to transform");
+    let rust_code = codegen_cel::generate_wasm_lib_cel(vec![("Grid".to_string(), root)], &ProtoSchema::default())
+        .expect("Codegen failed");
+
+    // Attribute expressions evaluate in the loop's context, which binds the
+    // loop variable and still holds the params
+    let bind = rust_code.find(r#"loop_ctx.add_variable("row", _item.clone())"#).expect("row is bound");
+    let attr = rust_code.find(r#"cel_eval_safe("row.id", &loop_ctx)"#)
+        .unwrap_or_else(|| panic!("Code: {}", rust_code));
+    assert!(bind < attr);
+    assert!(rust_code.contains(r#"cel_eval_safe("title", &loop_ctx)"#));
+
+    // The nested loop scopes over the outer one, so row stays visible
+    assert!(rust_code.contains("let mut inner_ctx = Context::new_inner_scope(&loop_ctx);"));
+    assert!(rust_code.contains(r#"cel_eval_safe("row.id", &inner_ctx)"#));
+    assert!(rust_code.contains(r#"cel_eval_safe("cell_idx", &inner_ctx)"#));
+}
+
+#[test]
+fn test_else_if_chain() {
+    let input = r#"
+el {
+    if `system_load >= 80` {
+        p.red "High"
+    } else if `system_load >= 50` {
+        p.yellow "Medium"
+    } else {
+        p.green "Low"
+    }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform(&doc).expect("Failed to transform");
+
+    assert_eq!(root.nodes.len(), 1);
+    let Some(hudlc::ast::ControlFlow::If { condition, else_block, .. }) = root.nodes[0].as_control_flow() else {
+        panic!("Expected If node");
+    };
+    assert_eq!(condition, "system_load >= 80");
+    let else_nodes = else_block.as_ref().expect("Expected else block");
+    assert_eq!(else_nodes.len(), 1);
+    let Some(hudlc::ast::ControlFlow::If { condition, else_block, .. }) = else_nodes[0].as_control_flow() else {
+        panic!("Expected else if");
+    };
+    assert_eq!(condition, "system_load >= 50");
+    let last = else_block.as_ref().expect("Expected final else");
+    assert_eq!(last[0].as_element().expect("Expected element").tag, "p");
+
+    let rust_code = codegen_cel::generate_wasm_lib_cel(vec![("Load".to_string(), root)], &ProtoSchema::default())
+        .expect("Codegen failed");
+    let chain = r#"if cel_truthy(&cel_eval("system_load >= 80", &ctx)) {"#;
+    let start = rust_code.find(chain).unwrap_or_else(|| panic!("Code: {}", rust_code));
+    let rest = &rust_code[start..];
+    let else_if = rest.find(r#"} else if cel_truthy(&cel_eval("system_load >= 50", &ctx)) {"#).expect("else if branch");
+    let else_ = rest.find("} else {").expect("else branch");
+    assert!(else_if < else_);
+}
+
 #[test]
 fn test_transform_nested_if_else() {
     let input = r#"
@@ -194,6 +497,73 @@ el { div "User" }
     assert_eq!(root.params[0].type_name, "UserProfile");
 }
 
+#[test]
+fn test_param_defaults_and_optional() {
+    let input = r#"
+// name: Settings
+// param: bool is_admin false
+// param: int32 page_size 25
+// param: double ratio 0.5
+// param: optional string subtitle
+// param: string title "Home"
+
+el { div "Settings" }
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform_with_metadata(&doc, input).expect("Failed to transform");
+
+    assert_eq!(root.params.len(), 5);
+
+    let is_admin = &root.params[0];
+    assert_eq!(is_admin.type_name, "bool");
+    assert_eq!(is_admin.default_value, Some("false".to_string()));
+    assert!(!is_admin.optional);
+
+    assert_eq!(root.params[1].default_value, Some("25".to_string()));
+    assert_eq!(root.params[2].default_value, Some("0.5".to_string()));
+
+    let subtitle = &root.params[3];
+    assert_eq!(subtitle.name, "subtitle");
+    assert_eq!(subtitle.type_name, "string");
+    assert!(subtitle.optional);
+    assert!(!subtitle.repeated);
+    assert_eq!(subtitle.default_value, None);
+
+    assert_eq!(root.params[4].default_value, Some("Home".to_string()));
+}
+
+#[test]
+fn test_codegen_go_optional_param_is_pointer() {
+    use hudlc::codegen_go;
+
+    let input = r#"
+// name: Header
+// param: string title
+// param: optional string subtitle
+
+el { h1 `title` }
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform_with_metadata(&doc, input).expect("Failed to transform");
+
+    let opts = codegen_go::GoOptions {
+        package_name: "views".to_string(),
+        pb_import_path: "".to_string(),
+        pb_package_name: "pb".to_string(),
+        data_types: false,
+        context: false,
+        bytes: false,
+        indent: codegen_go::Indent::Tabs,
+    };
+    let code = codegen_go::generate_go_wrapper(vec![("Header".to_string(), root.params)], opts);
+
+    assert!(code.contains("func (v *Views) Header(title string, subtitle *string)"));
+    assert!(code.contains("\tif subtitle != nil {\n"));
+    assert!(code.contains("protowire.AppendString(b, *subtitle)"));
+}
+
 #[test]
 fn test_codegen_basic() {
     let input = r#"
@@ -248,6 +618,24 @@ el {
     assert!(rust_code.contains(".push_str(\" disabled\")"));
 }
 
+#[test]
+fn test_codegen_static_attribute_escaped() {
+    let input = r#"
+el {
+    a href="/search?q=a&b=<c>" "Search"
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform(&doc).expect("Failed to transform");
+    let views = vec![("TestView".to_string(), root)];
+    let schema = ProtoSchema::default();
+    let rust_code = codegen_cel::generate_wasm_lib_cel(views, &schema).expect("Codegen failed");
+
+    // The same escaping as dynamic attribute values
+    assert!(rust_code.contains(r#".push_str(" href=\"/search?q=a&amp;b=&lt;c&gt;\"");"#), "Code: {}", rust_code);
+}
+
 #[test]
 fn test_codegen_multi_interpolation() {
     let input = r#"
@@ -343,10 +731,76 @@ el {
 
     let cf = root.nodes[0].as_control_flow().unwrap();
     if let hudlc::ast::ControlFlow::Switch { cases, .. } = cf {
-        assert_eq!(cases[0].0, "STATUS_ACTIVE");
+        assert_eq!(cases[0].0, vec!["STATUS_ACTIVE"]);
+    } else {
+        panic!("Expected Switch node");
+    }
+}
+
+#[test]
+fn test_switch_case_with_multiple_values() {
+    let input = r#"
+el {
+    switch `role` {
+        case "admin" "superadmin" { span "Staff" }
+        case "guest" { span "Guest" }
+        default { span "Member" }
+    }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform(&doc).expect("Failed to transform");
+
+    let cf = root.nodes[0].as_control_flow().unwrap();
+    if let hudlc::ast::ControlFlow::Switch { cases, default, .. } = cf {
+        assert_eq!(cases[0].0, vec!["admin", "superadmin"]);
+        assert_eq!(cases[1].0, vec!["guest"]);
+        assert!(default.is_some());
     } else {
         panic!("Expected Switch node");
     }
+
+    let rust_code = codegen_cel::generate_wasm_lib_cel(vec![("TestView".to_string(), root)], &ProtoSchema::default())
+        .expect("Codegen failed");
+    assert!(
+        rust_code.contains(r#"if cel_to_string(&_switch_val) == "admin" || cel_to_string(&_switch_val) == "superadmin" {"#),
+        "Code: {}",
+        rust_code
+    );
+    assert!(rust_code.contains(r#"else if cel_to_string(&_switch_val) == "guest" {"#));
+}
+
+#[test]
+fn test_switch_default_must_be_last() {
+    let input = r#"
+el {
+    switch `role` {
+        default { span "Member" }
+        case "admin" { span "Staff" }
+    }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let err = transformer::transform_with_metadata(&doc, input).unwrap_err();
+    assert!(err.contains("line 5: 'case' after 'default'"), "Error: {}", err);
+}
+
+#[test]
+fn test_switch_duplicate_case_value() {
+    let input = r#"
+el {
+    switch `role` {
+        case "admin" "owner" { span "Staff" }
+        case "owner" { span "Owner" }
+    }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let err = transformer::transform(&doc).unwrap_err();
+    assert!(err.contains("duplicate case 'owner' in switch `role`"), "Error: {}", err);
 }
 
 #[test]
@@ -393,6 +847,10 @@ el {
         package_name: "views".to_string(),
         pb_import_path: "".to_string(),
         pb_package_name: "pb".to_string(),
+        data_types: false,
+        context: false,
+        bytes: false,
+        indent: codegen_go::Indent::Tabs,
     };
 
     let code = codegen_go::generate_go_wrapper(views, opts);
@@ -520,4 +978,1231 @@ fn test_codegen_slots() {
 
             }
 
-    
\ No newline at end of file
+    
+#[test]
+fn test_codegen_fragment_functions() {
+    let input = r#"
+// name: Dashboard
+// param: string time
+el {
+    div {
+        h1 "Dashboard"
+        span#clock `time`
+        section fragment=stats {
+            p "Stats"
+        }
+    }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform_with_metadata(&doc, input).expect("Failed to transform");
+    let views = vec![("Dashboard".to_string(), root)];
+    let schema = ProtoSchema::default();
+    let rust_code = codegen_cel::generate_wasm_lib_cel(views, &schema).expect("Codegen failed");
+
+    // Full view plus one export per fragment
+    assert!(rust_code.contains("pub extern \"C\" fn Dashboard("));
+    assert!(rust_code.contains("pub extern \"C\" fn DashboardClock("));
+    assert!(rust_code.contains("pub extern \"C\" fn DashboardStats("));
+
+    // The fragment function renders only its subtree
+    let clock_fn = rust_code.split("fn render_dashboardclock").nth(1).expect("Missing render_dashboardclock");
+    let clock_fn = clock_fn.split("#[no_mangle]").next().unwrap();
+    assert!(clock_fn.contains("<span"));
+    assert!(!clock_fn.contains("<h1"));
+    assert!(!clock_fn.contains(" fragment="), "fragment marker should not be rendered");
+}
+
+#[test]
+fn test_codegen_fragment_name_collisions() {
+    let dashboard = r#"
+// name: Dashboard
+el {
+    span#clock "12:00"
+    span#live-clock "12:00"
+}
+    "#;
+    let clock = r#"
+// name: DashboardClock
+el {
+    p "Clock"
+}
+    "#;
+    let transform = |input: &str| {
+        let doc = parser::parse(input).expect("Failed to parse");
+        transformer::transform_with_metadata(&doc, input).expect("Failed to transform")
+    };
+
+    // An id turning into another view's name
+    let views = vec![("Dashboard".to_string(), transform(dashboard)), ("DashboardClock".to_string(), transform(clock))];
+    let err = codegen_cel::generate_wasm_lib_cel(views, &ProtoSchema::default()).unwrap_err();
+    assert!(err.contains("view DashboardClock and fragment 'clock' of Dashboard are both exported as DashboardClock"), "Error: {}", err);
+
+    // Two ids turning into the same name
+    let both = dashboard.replace("span#clock", "span#live_clock");
+    let views = vec![("Dashboard".to_string(), transform(&both))];
+    let err = codegen_cel::generate_wasm_lib_cel(views, &ProtoSchema::default()).unwrap_err();
+    assert!(err.contains("exported as DashboardLiveClock"), "Error: {}", err);
+}
+
+#[test]
+fn test_raw_block_marks_subtree_unescaped() {
+    let input = r#"
+el {
+    div {
+        raw {
+            article {
+                p `post.body_html`
+            }
+        }
+        p `post.title`
+    }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform(&doc).expect("Failed to transform");
+
+    let div = root.nodes[0].as_element().unwrap();
+    assert_eq!(div.children.len(), 2, "raw wrapper should not emit an element");
+
+    // raw subtree: article > p > text
+    let article = div.children[0].as_element().expect("Expected article");
+    assert_eq!(article.tag, "article");
+    let raw_text = article.children[0].as_element().unwrap().children[0].as_text().unwrap();
+    assert!(raw_text.raw, "text inside raw block should be flagged");
+
+    // Sibling outside the raw block keeps escaping
+    let sibling_text = div.children[1].as_element().unwrap().children[0].as_text().unwrap();
+    assert!(!sibling_text.raw, "sibling text should not be flagged");
+
+    let views = vec![("Post".to_string(), root)];
+    let rust_code = codegen_cel::generate_wasm_lib_cel(views, &ProtoSchema::default()).expect("Codegen failed");
+    assert!(rust_code.contains("push_str(&cel_to_string(&cel_eval(\"post.body_html\""));
+    assert!(rust_code.contains("cel_eval_safe(\"post.title\""));
+}
+
+#[test]
+fn test_local_fragment_expansion() {
+    let input = r#"
+fragment Badge label color="gray" {
+    span.badge title=`"badge-" + color` `label`
+}
+
+el {
+    div {
+        Badge label="New" color="green"
+        Badge label=`user.role`
+    }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform(&doc).expect("Failed to transform");
+
+    let div = root.nodes[0].as_element().unwrap();
+    assert_eq!(div.children.len(), 2, "each invocation should expand to the fragment body");
+
+    let first = div.children[0].as_element().expect("Expected expanded span");
+    assert_eq!(first.tag, "span");
+    assert_eq!(first.attributes.get("title").unwrap(), "`\"badge-\" + \"green\"`");
+    assert_eq!(first.children[0].as_text().unwrap().content, "`\"New\"`");
+
+    let second = div.children[1].as_element().expect("Expected expanded span");
+    assert_eq!(second.tag, "span");
+    assert_eq!(second.attributes.get("title").unwrap(), "`\"badge-\" + \"gray\"`");
+    assert_eq!(second.children[0].as_text().unwrap().content, "`(user.role)`");
+}
+
+#[test]
+fn test_local_fragment_substitutes_styles() {
+    let input = r#"
+fragment Swatch color {
+    span {
+        style { background `color` }
+    }
+}
+
+el {
+    Swatch color="red"
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform(&doc).expect("Failed to transform");
+
+    let span = root.nodes[0].as_element().unwrap();
+    assert_eq!(span.styles, vec![("background".to_string(), "`\"red\"`".to_string())]);
+}
+
+#[test]
+fn test_local_fragment_field_macro_with_slot() {
+    let input = r#"
+fragment Field name title error {
+    label for=`name` {
+        span `title`
+        #content
+        if `size(error) > 0` {
+            small.validation-msg `error`
+        }
+    }
+}
+
+el {
+    form {
+        Field name="username" title="Username" error=`username_error` {
+            input type="text" name="username" value=`username`
+        }
+        Field name="email" title="Email" error=`email_error` {
+            input type="email" name="email" value=`email`
+        }
+    }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform(&doc).expect("Failed to transform");
+
+    let form = root.nodes[0].as_element().unwrap();
+    assert_eq!(form.children.len(), 2, "each field should expand to one label");
+
+    for (field, param) in [("username", "username"), ("email", "email")] {
+        let label = form.children.iter()
+            .filter_map(|n| n.as_element())
+            .find(|el| el.attributes.get("for").map(|v| v.contains(field)).unwrap_or(false))
+            .unwrap_or_else(|| panic!("missing label for {}", field));
+        assert_eq!(label.tag, "label");
+        assert_eq!(label.children.len(), 3, "title, slot input and error check");
+
+        // The slot child keeps the caller's bindings
+        let input = label.children[1].as_element().expect("Expected slotted input");
+        assert_eq!(input.tag, "input");
+        assert_eq!(input.attributes.get("value").unwrap(), &format!("`{}`", param));
+
+        match &label.children[2] {
+            hudlc::ast::Node::ControlFlow(hudlc::ast::ControlFlow::If { condition, .. }) => {
+                assert_eq!(condition, &format!("size(({}_error)) > 0", field));
+            }
+            other => panic!("Expected if, got {:?}", other),
+        }
+    }
+}
+
+#[test]
+fn test_local_fragment_children_without_slot() {
+    let input = r#"
+fragment Badge label {
+    span `label`
+}
+
+el {
+    Badge label="New" {
+        b "extra"
+    }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let err = transformer::transform(&doc).unwrap_err();
+    assert!(err.contains("has no #content slot"), "Error: {}", err);
+}
+
+#[test]
+fn test_local_fragment_missing_argument() {
+    let input = r#"
+fragment Badge label {
+    span `label`
+}
+
+el {
+    Badge
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let err = transformer::transform(&doc).unwrap_err();
+    assert!(err.contains("without argument 'label'"), "Error: {}", err);
+}
+
+#[test]
+fn test_fragment_template_renders_bare_nodes() {
+    let input = r#"
+// name: CartRows
+// fragment
+// param: repeated string items
+
+each item `items` {
+    li `item`
+}
+li.total "Total"
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform_with_metadata(&doc, input).expect("Failed to transform");
+
+    assert_eq!(root.nodes.len(), 2);
+    assert!(matches!(root.nodes[0], hudlc::ast::Node::ControlFlow(hudlc::ast::ControlFlow::Each { .. })));
+    let total = root.nodes[1].as_element().expect("Expected element");
+    assert_eq!(total.tag, "li");
+
+    // Without the directive, nodes outside `el` are not rendered
+    let plain = input.replace("// fragment\n", "");
+    let doc = parser::parse(&plain).expect("Failed to parse");
+    let root = transformer::transform_with_metadata(&doc, &plain).expect("Failed to transform");
+    assert!(root.nodes.is_empty());
+}
+
+#[test]
+fn test_orphan_case_is_rejected() {
+    let input = r#"
+el {
+    div {
+        case "admin" { span "Admin" }
+    }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let err = transformer::transform_with_metadata(&doc, input).unwrap_err();
+    assert!(err.contains("line 4: 'case' must be inside a 'switch'"), "Error: {}", err);
+}
+
+#[test]
+fn test_orphan_else_is_rejected() {
+    let input = r#"
+el {
+    div "always"
+    else {
+        span "never"
+    }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let err = transformer::transform_with_metadata(&doc, input).unwrap_err();
+    assert!(err.contains("line 4: 'else' must directly follow an 'if' block"), "Error: {}", err);
+}
+
+#[test]
+fn test_if_else_and_switch_pass_validation() {
+    let input = r#"
+el {
+    if `ok` {
+        span "yes"
+    } else {
+        span "no"
+    }
+    switch `role` {
+        case "admin" { span "Admin" }
+        default { span "User" }
+    }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform(&doc).expect("Failed to transform");
+    assert_eq!(root.nodes.len(), 2);
+}
+
+#[test]
+fn test_serialization_modes() {
+    let input = r#"
+el {
+    div {
+        br
+        input type="checkbox" checked=`done`
+    }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform(&doc).expect("Failed to transform");
+
+    let html5 = codegen_cel::generate_wasm_lib_cel(vec![("Todo".to_string(), root)], &ProtoSchema::default())
+        .expect("Codegen failed");
+    assert!(html5.contains(r#"push_str("<br");"#), "Code: {}", html5);
+    assert!(html5.contains(r#"push_str(">");"#));
+    assert!(html5.contains(r#"push_str(" checked");"#));
+    assert!(!html5.contains("</br>"));
+    assert!(!html5.contains("</input>"));
+    assert!(!html5.contains(" />"));
+
+    let root = transformer::transform(&doc).expect("Failed to transform");
+    let opts = codegen_cel::Options { serialization: codegen_cel::Serialization::Xhtml, ..Default::default() };
+    let xhtml = codegen_cel::generate_wasm_lib_cel_with_options(vec![("Todo".to_string(), root)], &ProtoSchema::default(), &opts)
+        .expect("Codegen failed");
+    assert!(xhtml.contains(r#"push_str(" />");"#), "Code: {}", xhtml);
+    assert!(xhtml.contains(r#"push_str(" checked=\"checked\"");"#));
+    assert!(!xhtml.contains("</br>"));
+}
+
+#[test]
+fn test_strict_mode_rejects_unknown_tag() {
+    let input = r#"
+el {
+    section {
+        dvi "typo"
+    }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform(&doc).expect("Failed to transform");
+    let views = vec![("Page".to_string(), root)];
+
+    let opts = codegen_cel::Options { strict: true, ..Default::default() };
+    let err = codegen_cel::generate_wasm_lib_cel_with_options(views, &ProtoSchema::default(), &opts).unwrap_err();
+    assert_eq!(err, "Page: unknown tag 'dvi' (did you mean 'div'?)");
+}
+
+#[test]
+fn test_strict_mode_allows_custom_elements_and_views() {
+    let page = r#"
+el {
+    div {
+        sl-button "Save"
+        StatCard title="Users"
+    }
+}
+    "#;
+    let card = r#"
+// param: string title
+el {
+    article.card
+}
+    "#;
+
+    let views = vec![
+        ("Page".to_string(), transformer::transform(&parser::parse(page).unwrap()).unwrap()),
+        ("StatCard".to_string(), transformer::transform_with_metadata(&parser::parse(card).unwrap(), card).unwrap()),
+    ];
+
+    let opts = codegen_cel::Options { strict: true, ..Default::default() };
+    codegen_cel::generate_wasm_lib_cel_with_options(views, &ProtoSchema::default(), &opts)
+        .expect("custom elements and views should pass strict mode");
+}
+
+#[test]
+fn test_svg_foreign_content_keeps_casing() {
+    let input = r#"
+el {
+    svg viewBox="0 0 10 10" {
+        defs {
+            linearGradient#fade gradientUnits="userSpaceOnUse" {
+                stop offset="0"
+            }
+            filter#blur {
+                feGaussianBlur stdDeviation="2"
+            }
+        }
+        clipPath#clip { rect width="10" height="10" }
+    }
+    math { mi "x" }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform(&doc).expect("Failed to transform");
+    let svg = root.nodes[0].as_element().unwrap();
+    assert!(!svg.foreign);
+    assert!(svg.children.iter().all(|n| n.as_element().unwrap().foreign));
+
+    // Strict mode accepts any tag inside svg and math
+    let opts = codegen_cel::Options { strict: true, ..Default::default() };
+    let rust_code = codegen_cel::generate_wasm_lib_cel_with_options(vec![("Icon".to_string(), root)], &ProtoSchema::default(), &opts)
+        .expect("Codegen failed");
+    for expected in [
+        r#"r.push_str("<svg");"#,
+        r#"r.push_str(" viewBox=\"0 0 10 10\"");"#,
+        r#"r.push_str("<linearGradient");"#,
+        r#"r.push_str(" gradientUnits=\"userSpaceOnUse\"");"#,
+        r#"r.push_str("</linearGradient>");"#,
+        r#"r.push_str("<feGaussianBlur");"#,
+        r#"r.push_str(" stdDeviation=\"2\"");"#,
+        r#"r.push_str("</clipPath>");"#,
+        r#"r.push_str("<mi");"#,
+    ] {
+        assert!(rust_code.contains(expected), "missing {} in: {}", expected, rust_code);
+    }
+}
+
+#[test]
+fn test_csp_nonce_on_inline_style_and_script() {
+    let input = r#"
+el {
+    div {
+        style { color "red" }
+        script "init()"
+        script src="/app.js"
+    }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform(&doc).expect("Failed to transform");
+    let rust_code = codegen_cel::generate_wasm_lib_cel(vec![("Page".to_string(), root)], &ProtoSchema::default())
+        .expect("Codegen failed");
+
+    assert!(rust_code.contains("pub extern \"C\" fn hudl_set_nonce(p: *const u8, l: usize)"));
+    // Scoped <style> and the inline script get the nonce; the external script doesn't
+    assert!(rust_code.contains("r.push_str(\"<style\");\n    r.push_str(&csp_nonce_attr());"), "Code: {}", rust_code);
+    assert_eq!(rust_code.matches("push_str(&csp_nonce_attr());").count(), 2);
+}
+
+#[test]
+fn test_exports_set_locale_and_binds_it() {
+    let input = r#"
+el {
+    html lang=`locale` { p "Hello" }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform(&doc).expect("Failed to transform");
+    let rust_code = codegen_cel::generate_wasm_lib_cel(vec![("Page".to_string(), root)], &ProtoSchema::default())
+        .expect("Codegen failed");
+
+    assert!(rust_code.contains("pub extern \"C\" fn hudl_set_locale(p: *const u8, l: usize)"));
+    // Bound before the params, so a param named locale shadows it
+    assert!(rust_code.contains("let mut ctx = Context::default();\n    let _ = ctx.add_variable(\"locale\", locale_value());"), "Code: {}", rust_code);
+}
+
+#[test]
+fn test_exports_fill_content_slot_from_host() {
+    let input = r#"
+// name: AppLayout
+el {
+    main { #content }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform_with_metadata(&doc, input).expect("Failed to transform");
+    let rust_code = codegen_cel::generate_wasm_lib_cel(vec![("AppLayout".to_string(), root)], &ProtoSchema::default())
+        .expect("Codegen failed");
+
+    assert!(rust_code.contains("pub extern \"C\" fn hudl_set_content(p: *const u8, l: usize)"));
+    // The export takes the slot content, so it only applies to one render
+    assert!(rust_code.contains("let content = CONTENT_SLOT.with(|c| mem::take(&mut *c.borrow_mut()));\n    let mut out = String::new();\n    FLUSH_OUT.with(|o| o.set(&out));\n    render_applayout(&mut out, proto_data, &content);"), "Code: {}", rust_code);
+}
+
+#[test]
+fn test_doctype_emitted_first() {
+    let input = r#"
+el {
+    doctype html
+    html lang=en {
+        head { title "Home" }
+        body { style { color "red" } }
+    }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform(&doc).expect("Failed to transform");
+    assert_eq!(root.doctype.as_deref(), Some("html"));
+    assert_eq!(root.nodes.len(), 1);
+    assert_eq!(root.nodes[0].as_element().unwrap().tag, "html");
+
+    let rust_code = codegen_cel::generate_wasm_lib_cel(vec![("Page".to_string(), root)], &ProtoSchema::default())
+        .expect("Codegen failed");
+    // Only the entry point writes it, so Page used as a component doesn't
+    let entry = &rust_code[rust_code.find("pub extern \"C\" fn Page(").expect("entry point")..];
+    let doctype = entry.find("out.push_str(\"<!DOCTYPE html>\");").expect("doctype emitted");
+    assert!(doctype < entry.find("render_page(&mut out").unwrap(), "doctype must precede the view");
+    let render_fn = &rust_code[rust_code.find("fn render_page(").expect("render fn")..];
+    let render_fn = &render_fn[..render_fn.find("\n}\n").unwrap()];
+    assert!(!render_fn.contains("DOCTYPE"), "render fn: {}", render_fn);
+}
+
+#[test]
+fn test_flush_point() {
+    let input = r#"
+el {
+    html {
+        head { title "Shop" }
+        flush
+        body { p "Products" }
+    }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform(&doc).expect("Failed to transform");
+    let html = root.nodes[0].as_element().unwrap();
+    assert_eq!(html.children.len(), 3);
+    assert_eq!(html.children[1], hudlc::ast::Node::Flush);
+
+    // The output so far goes to the host between </head> and <body>
+    let rust_code = codegen_cel::generate_wasm_lib_cel(vec![("Page".to_string(), root)], &ProtoSchema::default())
+        .expect("Codegen failed");
+    let pos = |needle: &str| rust_code.find(needle).unwrap_or_else(|| panic!("missing {}", needle));
+    let flush = pos("flush_output(r);");
+    assert!(pos(r#"r.push_str("</head>");"#) < flush && flush < pos(r#"r.push_str("<body");"#));
+    assert!(rust_code.contains("#[link(wasm_import_module = \"hudl\")]"));
+    // Only the view's own output is flushed, not a buffer it renders into
+    assert!(rust_code.contains("FLUSH_OUT.with(|o| o.set(&out));\n    render_page(&mut out, proto_data, &content);"), "Code: {}", rust_code);
+
+    let doc = parser::parse("el {\n    flush \"now\"\n}\n").expect("Failed to parse");
+    let err = transformer::transform(&doc).unwrap_err();
+    assert!(err.contains("flush takes no arguments"), "Error: {}", err);
+}
+
+#[test]
+fn test_multiline_text_block() {
+    let input = r#"
+el {
+    div {
+        pre """
+            line one
+              indented
+            line three
+            """
+        p {
+            """
+            Hello `name`,
+            welcome back
+            """
+        }
+    }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform(&doc).expect("Failed to transform");
+    let div = root.nodes[0].as_element().unwrap();
+
+    // Line breaks survive; indentation is relative to the closing quotes
+    let pre = div.children[0].as_element().unwrap();
+    assert_eq!(pre.children.len(), 1);
+    assert_eq!(pre.children[0].as_text().unwrap().content, "line one\n  indented\nline three");
+
+    // A block is a single text node, interpolations included
+    let p = div.children[1].as_element().unwrap();
+    assert_eq!(p.children.len(), 1);
+    assert_eq!(p.children[0].as_text().unwrap().content, "Hello `name`,\nwelcome back");
+}
+
+#[test]
+fn test_dynamic_tag_name() {
+    let input = r#"
+el {
+    el tag=`"h" + string(level)` class="title" {
+        "Heading"
+    }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform(&doc).expect("Failed to transform");
+    let heading = root.nodes[0].as_element().unwrap();
+    assert_eq!(heading.tag, "el");
+    assert_eq!(heading.dynamic_tag.as_deref(), Some(r#""h" + string(level)"#));
+    assert_eq!(heading.classes, vec!["title"]);
+    assert!(!heading.attributes.contains_key("tag"));
+
+    // The tag is computed once, checked, and reused for the closing tag
+    let rust_code = codegen_cel::generate_wasm_lib_cel(vec![("Heading".to_string(), root)], &ProtoSchema::default())
+        .expect("Codegen failed");
+    assert!(rust_code.contains(r#"let dyn_tag = safe_tag(&cel_eval("\"h\" + string(level)", &ctx));"#), "Code: {}", rust_code);
+    assert!(rust_code.contains("fn safe_tag(v: &CelValue) -> String {"));
+    assert!(rust_code.contains("pub fn safe_tag_name(name: &str) -> &str {"));
+    assert_eq!(rust_code.matches("r.push_str(&dyn_tag);").count(), 2);
+
+    // Only rejected at compile time when the value isn't an expression
+    for input in ["el {\n    el tag=\"h2\"\n}\n", "el {\n    el \"Title\"\n}\n"] {
+        let doc = parser::parse(input).expect("Failed to parse");
+        let err = transformer::transform(&doc).unwrap_err();
+        assert!(err.contains("tag="), "Error: {}", err);
+    }
+}
+
+#[test]
+fn test_safe_tag_name() {
+    use hudlc::safe_names::safe_tag_name;
+    assert_eq!(safe_tag_name("h2"), "h2");
+    assert_eq!(safe_tag_name("a"), "a");
+    for unsafe_name in ["", "2h", "script src=x", "a><script", "my-widget", "h1\n", "H1"] {
+        assert_eq!(safe_tag_name(unsafe_name), "div", "{:?}", unsafe_name);
+    }
+    // Well-formed names outside the allow-list fall back too
+    for unsafe_name in ["script", "style", "iframe", "textarea", "title", "img", "object"] {
+        assert_eq!(safe_tag_name(unsafe_name), "div", "{:?}", unsafe_name);
+    }
+}
+
+#[test]
+fn test_attribute_spread() {
+    let input = r#"
+el {
+    button type="button" ...`props.attrs` ...`extra` "Save"
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform(&doc).expect("Failed to transform");
+    let button = root.nodes[0].as_element().unwrap();
+    assert_eq!(button.spreads, vec!["props.attrs", "extra"]);
+    assert_eq!(button.attributes.get("type").map(String::as_str), Some("button"));
+    assert!(!button.attributes.contains_key("__hudl_spread"));
+
+    // Spreads are written after the element's own attributes, which win
+    let rust_code = codegen_cel::generate_wasm_lib_cel(vec![("Button".to_string(), root)], &ProtoSchema::default())
+        .expect("Codegen failed");
+    assert!(rust_code.contains(r#"let _spreads = [cel_eval("props.attrs", &ctx), cel_eval("extra", &ctx)];"#), "Code: {}", rust_code);
+    assert!(rust_code.contains(r#"r.push_str(&spread_class("", &_spreads));"#));
+    assert!(rust_code.contains(r#"r.push_str(&spread_attrs(&_spreads, &["type"]));"#));
+    assert!(rust_code.contains("fn spread_attrs(spreads: &[CelValue], own: &[&str]) -> String {"));
+    // The generated check is the one the interpreter uses
+    assert!(rust_code.contains(include_str!("../src/safe_names.rs")));
+}
+
+#[test]
+fn test_component_forwards_attrs() {
+    let button = r#"
+// param: string label
+// param: map<string, string> attrs
+el {
+    button.btn type="button" ...`attrs` `label`
+}
+    "#;
+    let page = r#"
+el {
+    Button#save.btn-primary label="Save" data-id=`item.id` type="submit"
+}
+    "#;
+
+    let button_root = transformer::transform_with_metadata(&parser::parse(button).unwrap(), button).unwrap();
+    assert_eq!(button_root.params[1].type_name, "map<string,string>");
+    assert_eq!(hudlc::ast::attrs_param(&button_root.params), Some(1));
+    let page_root = transformer::transform(&parser::parse(page).unwrap()).unwrap();
+    let invocation = page_root.nodes[0].as_element().unwrap();
+    assert_eq!(hudlc::ast::forwarded_attrs(invocation, &button_root.params), vec![
+        ("id".to_string(), "save".to_string()),
+        ("class".to_string(), "btn-primary".to_string()),
+        ("data-id".to_string(), "`item.id`".to_string()),
+        ("type".to_string(), "submit".to_string()),
+    ]);
+
+    let views = vec![("Button".to_string(), button_root), ("Page".to_string(), page_root)];
+    // Undeclared args are forwarded rather than unknown, and `attrs` itself
+    // isn't required
+    transformer::check_component_args(&views).expect("forwarded args should be accepted");
+
+    let rust_code = codegen_cel::generate_wasm_lib_cel(views, &ProtoSchema::default()).expect("Codegen failed");
+    assert!(rust_code.contains(r#"forwarded.insert(Key::String(Arc::new("class".to_string())), CelValue::String(Arc::new("btn-primary".to_string())));"#), "Code: {}", rust_code);
+    assert!(rust_code.contains(r#"forwarded.insert(Key::String(Arc::new("data-id".to_string())), CelValue::String(Arc::new(cel_to_string(&cel_eval("item.id", &ctx)))));"#));
+    assert!(rust_code.contains("encode_field(&mut component_proto, 2, &CelValue::Map(CelMap { map: Arc::new(forwarded) }), Some(&ProtoType::Map(Box::new(ProtoType::String), Box::new(ProtoType::String))));"));
+    // The root appends forwarded classes to its own and keeps its own type
+    assert!(rust_code.contains(r#"r.push_str(&spread_class("btn", &_spreads));"#));
+    assert!(rust_code.contains(r#"r.push_str(&spread_attrs(&_spreads, &["type"]));"#));
+}
+
+#[test]
+fn test_safe_attr_name() {
+    use hudlc::safe_names::safe_attr_name;
+    for name in ["title", "aria-label", "data-id", "data-on-click", "xml:lang", "x.y", "h_1", "o"] {
+        assert!(safe_attr_name(name), "{:?}", name);
+    }
+    for name in ["", "1a", "-x", "a b", "a\"", "a>", "on=x", "a/", "onclick", "onLoad", "ONERROR", "on"] {
+        assert!(!safe_attr_name(name), "{:?}", name);
+    }
+}
+
+#[test]
+fn test_doctype_after_element_is_rejected() {
+    let input = r#"
+el {
+    div "first"
+    doctype html
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let err = transformer::transform_with_metadata(&doc, input).unwrap_err();
+    assert!(err.contains("line 4: 'doctype' must come first"), "Error: {}", err);
+}
+
+#[test]
+fn test_text_entities_preserved_and_bare_ampersand_escaped() {
+    let input = r#"
+el {
+    footer {
+        p "&copy; 2024 Tom & Jerry"
+        raw { p "A & B" }
+    }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform(&doc).expect("Failed to transform");
+    let rust_code = codegen_cel::generate_wasm_lib_cel(vec![("Footer".to_string(), root)], &ProtoSchema::default())
+        .expect("Codegen failed");
+
+    assert!(rust_code.contains(r#"push_str("&copy; 2024 Tom &amp; Jerry");"#), "Code: {}", rust_code);
+    assert!(!rust_code.contains("&amp;copy;"));
+    // Text in a raw block is output as written
+    assert!(rust_code.contains(r#"push_str("A & B");"#));
+}
+
+#[test]
+fn test_views_section_lists_data_message() {
+    let input = r#"
+/**
+message UserCardData {
+    UserProfile user = 1;
+}
+*/
+// name: UserCard
+// param: UserProfile user
+
+el {
+    div {
+        span fragment=badge "User"
+    }
+}
+    "#;
+
+    let schema = ProtoSchema::from_template(input, None).expect("Failed to parse proto block");
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform_with_metadata(&doc, input).expect("Failed to transform");
+    let rust_code = codegen_cel::generate_wasm_lib_cel(vec![("UserCard".to_string(), root)], &schema)
+        .expect("Codegen failed");
+
+    assert!(rust_code.contains("#[link_section = \"hudl.views\"]"));
+    assert!(
+        rust_code.contains(r#"[{\"name\":\"UserCard\",\"message\":\"UserCardData\",\"params\":[{\"name\":\"user\",\"type\":\"UserProfile\"}]},{\"name\":\"UserCardBadge\",\"message\":\"UserCardData\",\"params\":[{\"name\":\"user\",\"type\":\"UserProfile\"}]}]"#),
+        "Code: {}",
+        rust_code
+    );
+}
+
+#[test]
+fn test_view_schemas_list_referenced_fields() {
+    let input = r#"
+// name: OrderList
+// param: Customer customer
+// param: bool compact
+
+el {
+    h1 `customer.name`
+    each order `customer.orders` {
+        li class=`compact ? "small" : "large"` {
+            span fragment=total "`order.total` of `size(customer.orders)`"
+        }
+    }
+    p#footer "Thanks, `customer.profile.nickname`"
+}
+    "#;
+
+    let schema = ProtoSchema::from_template(input, None).unwrap_or_default();
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform_with_metadata(&doc, input).expect("Failed to transform");
+    let views = hudlc::proto::view_schemas("OrderList", &root, &schema).expect("Failed to list views");
+
+    assert_eq!(
+        views[0].fields,
+        vec!["compact", "customer.name", "customer.orders", "customer.orders.total", "customer.profile.nickname"]
+    );
+    // Fragments list only what they read
+    let footer = views.iter().find(|v| v.name == "OrderListFooter").expect("footer fragment");
+    assert_eq!(footer.fields, vec!["customer.profile.nickname"]);
+}
+
+#[test]
+fn test_views_manifest() {
+    let input = r#"
+/**
+message ProductPageData {
+    Product product = 1;
+    repeated string tags = 2;
+    optional string coupon = 3;
+}
+*/
+// name: ProductPage
+// param: Product product
+// param: repeated string tags
+// param: optional string coupon
+
+el {
+    h1 `product.name`
+}
+    "#;
+
+    let schema = ProtoSchema::from_template(input, None).expect("Failed to parse proto block");
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform_with_metadata(&doc, input).expect("Failed to transform");
+    let manifest = hudlc::proto::views_manifest(&[("ProductPage".to_string(), root)], &schema).expect("Manifest failed");
+
+    let views: serde_json::Value = serde_json::from_str(&manifest).expect("Manifest is not JSON");
+    assert_eq!(
+        views,
+        serde_json::json!([{
+            "name": "ProductPage",
+            "message": "ProductPageData",
+            "fields": ["product.name"],
+            "params": [
+                {"name": "product", "type": "Product"},
+                {"name": "tags", "type": "string", "repeated": true},
+                {"name": "coupon", "type": "string", "optional": true}
+            ]
+        }])
+    );
+}
+
+#[test]
+fn test_view_message_must_be_unambiguous() {
+    let input = r#"
+/**
+message Greeting {
+    string name = 1;
+}
+message Farewell {
+    string name = 1;
+}
+*/
+// name: Hello
+// param: string name
+
+el {
+    p "Hi `name`"
+}
+    "#;
+
+    let schema = ProtoSchema::from_template(input, None).expect("Failed to parse proto block");
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform_with_metadata(&doc, input).expect("Failed to transform");
+    let err = hudlc::proto::view_schemas("Hello", &root, &schema).unwrap_err();
+    assert!(err.contains("Farewell, Greeting"), "Error: {}", err);
+
+    // `// data:` picks one
+    let input = format!("// data: Greeting\n{}", input);
+    let doc = parser::parse(&input).expect("Failed to parse");
+    let root = transformer::transform_with_metadata(&doc, &input).expect("Failed to transform");
+    let views = hudlc::proto::view_schemas("Hello", &root, &schema).expect("Failed to list views");
+    assert_eq!(views[0].message, "Greeting");
+}
+
+#[test]
+fn test_codegen_if_enum_constant() {
+    let input = r#"
+/**
+enum Status {
+    STATUS_UNKNOWN = 0;
+    STATUS_ACTIVE = 1;
+}
+*/
+// name: StatusBadge
+// param: Status status
+el {
+    if `status == STATUS_ACTIVE` {
+        span "Active"
+    }
+}
+    "#;
+
+    let schema = ProtoSchema::from_template(input, None).expect("Failed to parse proto block");
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform_with_metadata(&doc, input).expect("Failed to transform");
+    let rust_code = codegen_cel::generate_wasm_lib_cel(vec![("StatusBadge".to_string(), root)], &schema)
+        .expect("Codegen failed");
+
+    // Constants are bound by number and the param decodes as an int, so the
+    // comparison is int == int
+    assert!(rust_code.contains("    (\"STATUS_ACTIVE\", 1),\n"), "Code: {}", rust_code);
+    assert!(rust_code.contains("    (\"STATUS_UNKNOWN\", 0),\n"));
+    assert!(rust_code.contains("let _ = ctx.add_variable(*name, CelValue::Int(*number));"));
+    assert!(rust_code.contains("ProtoValue::Varint(n) => CelValue::Int(*n as i64),"));
+    assert!(rust_code.contains("cel_eval(\"status == STATUS_ACTIVE\", &ctx)"));
+}
+
+#[test]
+fn test_views_section_content_type() {
+    let input = r#"
+// name: Logo
+// content-type: image/svg+xml
+el {
+    svg viewBox="0 0 10 10" { circle r="5" }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform_with_metadata(&doc, input).expect("Failed to transform");
+    assert_eq!(root.content_type.as_deref(), Some("image/svg+xml"));
+
+    let rust_code = codegen_cel::generate_wasm_lib_cel(vec![("Logo".to_string(), root)], &ProtoSchema::default())
+        .expect("Codegen failed");
+    assert!(
+        rust_code.contains(r#"[{\"name\":\"Logo\",\"message\":\"\",\"contentType\":\"image/svg+xml\"}]"#),
+        "Code: {}",
+        rust_code
+    );
+}
+
+#[test]
+fn test_component_import_cycle_is_rejected() {
+    let card = r#"
+import {
+    "./badge"
+}
+
+// name: Card
+el {
+    article { Badge }
+}
+    "#;
+    let badge = r#"
+import {
+    "./card"
+}
+
+// name: Badge
+el {
+    span.badge { Card }
+}
+    "#;
+
+    let views: Vec<(String, hudlc::ast::Root)> = [("Card", card), ("Badge", badge)]
+        .iter()
+        .map(|(name, src)| {
+            let doc = parser::parse(src).expect("Failed to parse");
+            (name.to_string(), transformer::transform_with_metadata(&doc, src).expect("Failed to transform"))
+        })
+        .collect();
+
+    let err = codegen_cel::generate_wasm_lib_cel(views, &ProtoSchema::default()).unwrap_err();
+    assert_eq!(err, "component cycle: Badge -> Card -> Badge");
+}
+
+#[test]
+fn test_component_args_checked_against_params() {
+    let card = r#"
+// name: StatCard
+// param: string label
+// param: string value
+// param: optional string trend
+// param: string unit "%"
+el {
+    article.card `label`
+}
+    "#;
+    let page = r#"
+import {
+    "./stat_card"
+}
+
+// name: Page
+el {
+    div.stats {
+        StatCard label="Users" value=`users` trend="up"
+        each s `stats` {
+            StatCard lable=`s.label` value=`s.value`
+        }
+    }
+}
+    "#;
+
+    let views: Vec<(String, hudlc::ast::Root)> = [("StatCard", card), ("Page", page)]
+        .iter()
+        .map(|(name, src)| {
+            let doc = parser::parse(src).expect("Failed to parse");
+            (name.to_string(), transformer::transform_with_metadata(&doc, src).expect("Failed to transform"))
+        })
+        .collect();
+
+    let err = codegen_cel::generate_wasm_lib_cel(views, &ProtoSchema::default()).unwrap_err();
+    assert_eq!(
+        err,
+        "Page:9:9: StatCard: missing required arg 'label'\n\
+         Page:11:13: StatCard: unknown arg 'lable' (did you mean 'label'?)"
+    );
+}
+
+#[test]
+fn test_component_self_reference_is_allowed() {
+    let input = r#"
+// name: TreeNode
+// param: string label
+el {
+    li {
+        span `label`
+        if `false` { TreeNode label="child" }
+    }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform_with_metadata(&doc, input).expect("Failed to transform");
+    codegen_cel::generate_wasm_lib_cel(vec![("TreeNode".to_string(), root)], &ProtoSchema::default())
+        .expect("a component may invoke itself");
+}
+
+fn transform_with_semantic_defaults(input: &str) -> hudlc::ast::Root {
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform_with_metadata(&doc, input).expect("Failed to transform");
+    let mut views = vec![("View".to_string(), root)];
+    transformer::apply_semantic_defaults(&mut views);
+    views.pop().unwrap().1
+}
+
+#[test]
+fn test_semantic_defaults_button_type() {
+    let input = r#"
+el {
+    div {
+        button "Close"
+        button type="submit" "Send"
+        form {
+            button "Submit"
+        }
+    }
+}
+    "#;
+
+    let root = transform_with_semantic_defaults(input);
+    let div = root.nodes[0].as_element().unwrap();
+    let close = div.children[0].as_element().unwrap();
+    let send = div.children[1].as_element().unwrap();
+    let form_button = div.children[2].as_element().unwrap().children[0].as_element().unwrap();
+
+    assert_eq!(close.attributes.get("type"), Some(&"button".to_string()));
+    assert_eq!(send.attributes.get("type"), Some(&"submit".to_string()), "explicit type is kept");
+    assert_eq!(form_button.attributes.get("type"), None, "buttons in a form keep the submit default");
+
+    // Off unless asked for
+    let plain = transformer::transform(&parser::parse(input).unwrap()).unwrap();
+    let close = plain.nodes[0].as_element().unwrap().children[0].as_element().unwrap();
+    assert_eq!(close.attributes.get("type"), None);
+}
+
+#[test]
+fn test_semantic_defaults_form_across_components() {
+    let sources = [
+        ("SaveButton", "// name: SaveButton\nel {\n    button \"Save\"\n}\n"),
+        ("FormCard", "// name: FormCard\nel {\n    form {\n        #content\n    }\n}\n"),
+        ("Page", "// name: Page\nel {\n    form {\n        SaveButton\n    }\n    FormCard {\n        button \"Send\"\n    }\n    button \"Close\"\n}\n"),
+    ];
+    let mut views: Vec<(String, hudlc::ast::Root)> = sources
+        .iter()
+        .map(|(name, src)| {
+            let doc = parser::parse(src).expect("Failed to parse");
+            (name.to_string(), transformer::transform_with_metadata(&doc, src).expect("Failed to transform"))
+        })
+        .collect();
+    transformer::apply_semantic_defaults(&mut views);
+
+    let save = views[0].1.nodes[0].as_element().unwrap();
+    assert_eq!(save.attributes.get("type"), None, "a component used inside a form keeps the submit default");
+
+    let page = &views[2].1.nodes;
+    let send = page[1].as_element().unwrap().children[0].as_element().unwrap();
+    let close = page[2].as_element().unwrap();
+    assert_eq!(send.attributes.get("type"), None, "content placed in a component's form keeps the submit default");
+    assert_eq!(close.attributes.get("type"), Some(&"button".to_string()));
+}
+
+#[test]
+fn test_semantic_defaults_clickable_link_role() {
+    let input = r#"
+el {
+    nav {
+        a ~on:click="$open = true" "Menu"
+        a href="/home" ~on:click="track()" "Home"
+        a "Plain"
+    }
+}
+    "#;
+
+    let root = transform_with_semantic_defaults(input);
+    let nav = root.nodes[0].as_element().unwrap();
+    let role = |i: usize| nav.children[i].as_element().unwrap().attributes.get("role").cloned();
+
+    assert_eq!(role(0), Some("button".to_string()));
+    assert_eq!(role(1), None, "links with href are already links");
+    assert_eq!(role(2), None, "no click handler, no role");
+}
+
+#[test]
+fn test_element_if_attribute_wraps_element() {
+    use hudlc::ast::{ControlFlow, Node};
+
+    let input = r#"
+// name: Link
+// param: string url
+el {
+    nav {
+        a href=`url` if=`url != ""` "Visit"
+        ul {
+            each item `items` {
+                li if=`item.visible` `item.label`
+            }
+        }
+    }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform_with_metadata(&doc, input).expect("Failed to transform");
+
+    let nav = root.nodes[0].as_element().unwrap();
+    match &nav.children[0] {
+        Node::ControlFlow(ControlFlow::If { condition, then_block, else_block }) => {
+            assert_eq!(condition, "url != \"\"");
+            assert!(else_block.is_none());
+            let link = then_block[0].as_element().unwrap();
+            assert_eq!(link.tag, "a");
+            assert!(!link.attributes.contains_key("if"), "if= is not rendered as an attribute");
+        }
+        other => panic!("expected the link wrapped in an if, got {:?}", other),
+    }
+
+    // Inside each, the loop comes first and the condition applies per item
+    let list = nav.children[1].as_element().unwrap();
+    match &list.children[0] {
+        Node::ControlFlow(ControlFlow::Each { body, .. }) => {
+            assert!(matches!(&body[0], Node::ControlFlow(ControlFlow::If { condition, .. }) if condition == "item.visible"));
+        }
+        other => panic!("expected each, got {:?}", other),
+    }
+
+    let rust_code = codegen_cel::generate_wasm_lib_cel(vec![("Link".to_string(), root)], &ProtoSchema::default())
+        .expect("Failed to generate");
+    let cond = rust_code.find("if cel_truthy(&cel_eval(\"url != \\\"\\\"\"").expect("condition is generated");
+    let wrapped = &rust_code[cond..];
+    let link = wrapped.find("<a").expect("link is generated after the condition");
+    let list = wrapped.find("<ul").expect("list is generated");
+    assert!(link < list, "the element is rendered inside the conditional");
+}
+
+#[test]
+fn test_check_params() {
+    use hudlc::ast::check_params;
+
+    let input = r#"
+// param: User user
+// param: string subtitle
+// param: Status status
+el {
+    h1 "Hello `user.name`"
+    each order `user.orders` {
+        li "`order_idx`: `order.total` of `size(user.orders)`"
+    }
+    if `status == STATUS_ACTIVE && show_badge` {
+        span.badge `badge_text`
+    }
+}
+    "#;
+
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform_with_metadata(&doc, input).expect("Failed to transform");
+    let result = check_params(&root.nodes, &root.params, &["STATUS_ACTIVE"]);
+    assert_eq!(result.unused, vec!["subtitle"]);
+    assert_eq!(result.undeclared, vec!["badge_text", "show_badge"]);
+
+    // Without params, a view reads its data's fields directly
+    let input = "el {\n    p `anything.goes`\n}\n";
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform_with_metadata(&doc, input).expect("Failed to transform");
+    assert_eq!(check_params(&root.nodes, &root.params, &[]), Default::default());
+}
@@ -1342,3 +1342,152 @@ el {
     "#);
     assert!(html.contains("data-on-submit__prevent=\"@post('/api/save')\""), "HTML: {}", html);
 }
+
+// =============================================================================
+// SECTION 24: Structured data-* Attributes (JSON-encoded)
+// =============================================================================
+
+#[test]
+fn test_structured_data_signals_transform() {
+    let input = r#"
+el {
+    div {
+        ~ {
+            data-signals {
+                count 0
+                user {
+                    name "Ann \"A\""
+                    admin #false
+                }
+            }
+        }
+    }
+}
+    "#;
+    let root = parse_and_transform(input);
+    let el = get_first_element(&root);
+
+    let json = el.attributes.get("data-signals").expect("Expected data-signals attribute");
+    let value: serde_json::Value = serde_json::from_str(json).expect("data-signals should be valid JSON");
+    assert_eq!(value["count"], 0);
+    assert_eq!(value["user"]["name"], "Ann \"A\"");
+    assert_eq!(value["user"]["admin"], false);
+    assert!(el.datastar.is_empty(), "Structured values should not become datastar attrs");
+}
+
+#[test]
+fn test_structured_data_array() {
+    let input = r#"
+el {
+    div {
+        ~ {
+            data-config {
+                tags "a" "b"
+                items {
+                    - { id 1 }
+                    - { id 2 }
+                }
+            }
+        }
+    }
+}
+    "#;
+    let root = parse_and_transform(input);
+    let el = get_first_element(&root);
+
+    let json = el.attributes.get("data-config").expect("Expected data-config attribute");
+    assert_eq!(json, r#"{"items":[{"id":1},{"id":2}],"tags":["a","b"]}"#);
+}
+
+#[test]
+fn test_render_structured_data_signals_html() {
+    let html = render_html(r#"
+// name: Test
+el {
+    div {
+        ~ {
+            data-signals {
+                count 0
+                label "say \"hi\""
+            }
+        }
+    }
+}
+    "#);
+    assert!(
+        html.contains(r#"data-signals="{&quot;count&quot;:0,&quot;label&quot;:&quot;say \&quot;hi\&quot;&quot;}""#),
+        "HTML: {}", html
+    );
+}
+
+#[test]
+fn test_codegen_structured_data_signals() {
+    let input = r#"
+el {
+    div {
+        ~ {
+            data-signals {
+                count 0
+            }
+        }
+    }
+}
+    "#;
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform(&doc).expect("Failed to transform");
+    let views = vec![("TestView".to_string(), root)];
+    let schema = hudlc::proto::ProtoSchema::default();
+    let rust_code = hudlc::codegen_cel::generate_wasm_lib_cel(views, &schema).expect("Codegen failed");
+
+    assert!(
+        rust_code.contains(r#"data-signals=\"{&quot;count&quot;:0}\""#),
+        "Generated code should contain attribute-escaped JSON"
+    );
+}
+
+#[test]
+fn test_render_hand_quoted_json_attribute() {
+    // Hand-written JSON in a raw string survives pre-parsing and is attribute-escaped
+    let html = render_html(r##"
+// name: Test
+el {
+    div data-signals=#"{"count":0}"#
+}
+    "##);
+    assert!(html.contains(r#"data-signals="{&quot;count&quot;:0}""#), "HTML: {}", html);
+}
+
+// =============================================================================
+// SECTION 25: Client-side Expressions (js)
+// =============================================================================
+
+#[test]
+fn test_client_expression_is_not_evaluated() {
+    // A (js) value is a browser expression: its backticks are a JS template
+    // literal, not CEL, and `name` must not be resolved against the data
+    let input = r#"
+// name: Test
+el {
+    input data-attr-title=(js)"`Hi ${$name}`" {
+        ~ {
+            show (js)"$a && `${$b}` != ''"
+        }
+    }
+}
+    "#;
+    let root = parse_and_transform(input);
+    let el = get_first_element(&root);
+    assert_eq!(el.attributes.get("data-attr-title").map(String::as_str), Some("&#96;Hi ${$name}&#96;"));
+    assert_datastar_attr(el, "show", Some("$a &amp;&amp; &#96;${$b}&#96; != ''"), &[]);
+
+    let html = render_html(input);
+    assert!(html.contains(r#"data-attr-title="&#96;Hi ${$name}&#96;""#), "HTML: {}", html);
+    assert!(html.contains(r#"data-show="$a &amp;&amp; &#96;${$b}&#96; != ''""#), "HTML: {}", html);
+
+    // Compiled views emit the same value as a static string
+    let doc = parser::parse(input).expect("Failed to parse");
+    let root = transformer::transform(&doc).expect("Failed to transform");
+    let rust_code = hudlc::codegen_cel::generate_wasm_lib_cel(vec![("TestView".to_string(), root)], &hudlc::proto::ProtoSchema::default())
+        .expect("Codegen failed");
+    assert!(rust_code.contains(r#"data-attr-title=\"&#96;Hi ${$name}&#96;\""#), "Code: {}", rust_code);
+}
@@ -125,7 +125,7 @@ fn test_compile_datastar_examples() {
                 .unwrap_or_default();
             
             // Render
-            let result = interpreter::render_with_values(&root, &schema, get_mock_data(), &HashMap::new(), None);
+            let result = interpreter::render_with_values(&root, &schema, get_mock_data(), &HashMap::new(), None, "");
             
             match result {
                 Ok(html) => {
@@ -165,7 +165,7 @@ fn test_form_data_output() {
     let root = transformer::transform_with_metadata(&doc, &content).unwrap();
     let schema = ProtoSchema::from_template(&content, None).unwrap_or_default();
     
-    let html = interpreter::render_with_values(&root, &schema, get_mock_data(), &HashMap::new(), None).unwrap();
+    let html = interpreter::render_with_values(&root, &schema, get_mock_data(), &HashMap::new(), None, "").unwrap();
     
     assert!(html.contains(r#"data-signals-firstName="'John'""#));
     assert!(html.contains(r#"data-signals-lastName="'Doe'""#));
@@ -181,7 +181,7 @@ fn test_title_update_output() {
     let root = transformer::transform_with_metadata(&doc, &content).unwrap();
     let schema = ProtoSchema::from_template(&content, None).unwrap_or_default();
     
-    let html = interpreter::render_with_values(&root, &schema, get_mock_data(), &HashMap::new(), None).unwrap();
+    let html = interpreter::render_with_values(&root, &schema, get_mock_data(), &HashMap::new(), None, "").unwrap();
     
     assert!(html.contains(r#"data-title="'Count is ' + $count""#));
 }
@@ -195,7 +195,7 @@ fn test_custom_event_output() {
     let root = transformer::transform_with_metadata(&doc, &content).unwrap();
     let schema = ProtoSchema::from_template(&content, None).unwrap_or_default();
     
-    let html = interpreter::render_with_values(&root, &schema, get_mock_data(), &HashMap::new(), None).unwrap();
+    let html = interpreter::render_with_values(&root, &schema, get_mock_data(), &HashMap::new(), None, "").unwrap();
     
     assert!(html.contains(r#"data-on:my-custom-event="$lastEvent = evt.detail.message""#));
 }